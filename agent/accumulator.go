@@ -13,19 +13,36 @@ type MetricMaker interface {
 	Log() telegraf.Logger
 }
 
+// errorRecorder is implemented by makers keeping the last error of a plugin
+// for its status.
+type errorRecorder interface {
+	RecordError(err error)
+}
+
 type accumulator struct {
 	maker     MetricMaker
-	metrics   chan<- telegraf.Metric
+	add       func(telegraf.Metric)
 	precision time.Duration
 }
 
 func NewAccumulator(
 	maker MetricMaker,
 	metrics chan<- telegraf.Metric,
+) telegraf.Accumulator {
+	return newFuncAccumulator(maker, func(m telegraf.Metric) {
+		metrics <- m
+	})
+}
+
+// newFuncAccumulator returns an accumulator that hands each metric to the add
+// function instead of writing it to a channel.
+func newFuncAccumulator(
+	maker MetricMaker,
+	add func(telegraf.Metric),
 ) telegraf.Accumulator {
 	acc := accumulator{
 		maker:     maker,
-		metrics:   metrics,
+		add:       add,
 		precision: time.Nanosecond,
 	}
 	return &acc
@@ -79,7 +96,7 @@ func (ac *accumulator) AddHistogram(
 func (ac *accumulator) AddMetric(m telegraf.Metric) {
 	m.SetTime(m.Time().Round(ac.precision))
 	if m := ac.maker.MakeMetric(m); m != nil {
-		ac.metrics <- m
+		ac.add(m)
 	}
 }
 
@@ -95,7 +112,7 @@ func (ac *accumulator) addFields(
 		return
 	}
 	if m := ac.maker.MakeMetric(m); m != nil {
-		ac.metrics <- m
+		ac.add(m)
 	}
 }
 
@@ -105,6 +122,9 @@ func (ac *accumulator) AddError(err error) {
 	if err == nil {
 		return
 	}
+	if r, ok := ac.maker.(errorRecorder); ok {
+		r.RecordError(err)
+	}
 	ac.maker.Log().Errorf("Error in plugin: %v", err)
 }
 
@@ -16,9 +16,12 @@ import (
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/schedule"
 	"github.com/influxdata/telegraf/models"
+	"github.com/influxdata/telegraf/plugins/aggregators"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/outputs"
+	"github.com/influxdata/telegraf/plugins/processors"
 	"github.com/influxdata/telegraf/plugins/serializers/influx"
 
 	"github.com/google/uuid"
@@ -28,15 +31,28 @@ import (
 type Agent struct {
 	Config         *config.Config
 	Context        context.Context
-	iu             *inputUnit
-	ou             *outputUnit
+	pipelines      map[string]*pipeline
 	ic             int
 	oc             int
 	runningPlugins map[string]interface{}
 
-	pluginLock *sync.Mutex
-	icLock     *sync.Mutex
-	ocLock     *sync.Mutex
+	// routes are the configured routes besides the fallback route, which
+	// is only set when routes are configured.
+	routes   []*models.Route
+	unrouted *models.Route
+
+	pluginLock    *sync.Mutex
+	pipelinesLock *sync.RWMutex
+	icLock        *sync.Mutex
+	ocLock        *sync.Mutex
+
+	// changeLock serializes the changes to the running plugins made through
+	// the APIs and by config reloads.
+	changeLock *sync.Mutex
+
+	// running is closed once the pipelines of the agent are running.
+	running     chan struct{}
+	runningOnce *sync.Once
 }
 
 // NewAgent returns an Agent for the given Config.
@@ -49,9 +65,14 @@ func NewAgent(config *config.Config) (*Agent, error) {
 		ic:             0,
 		oc:             0,
 		pluginLock:     new(sync.Mutex),
+		pipelinesLock:  new(sync.RWMutex),
+		changeLock:     new(sync.Mutex),
 		icLock:         new(sync.Mutex),
 		ocLock:         new(sync.Mutex),
+		running:        make(chan struct{}),
+		runningOnce:    new(sync.Once),
 	}
+	a.initRoutes()
 	return a, nil
 }
 
@@ -71,13 +92,35 @@ type inputUnit struct {
 	inputs []*models.RunningInput
 }
 
-//  ______     ┌───────────┐     ______
-// ()_____)──▶ │ Processor │──▶ ()_____)
-//             └───────────┘
+// processorUnit is a chain of processors and its source and sink channels.
+// Each processor runs in its own goroutine and reads from its own channel.
+// Metrics pass through the processors in ascending order, processors can be
+// added, removed or reordered while the chain is running by swapping the
+// channels the processors write to.
+//
+//  ______     ┌───────────┐     ┌───────────┐     ______
+// ()_____)──▶ │ Processor │───▶ │ Processor │──▶ ()_____)
+//             └───────────┘     └───────────┘
 type processorUnit struct {
-	src       <-chan telegraf.Metric
-	dst       chan<- telegraf.Metric
+	src <-chan telegraf.Metric
+	dst chan<- telegraf.Metric
+
+	sync.Mutex
+	head    *processorStage
+	stages  []*processorStage
+	stopped bool
+}
+
+// processorStage is a single processor of the chain.  The head of the chain
+// has no processor and passes the metrics from the source channel on.
+type processorStage struct {
 	processor *models.RunningProcessor
+	acc       telegraf.Accumulator
+	src       chan telegraf.Metric
+	done      chan struct{}
+
+	sync.RWMutex
+	dst chan<- telegraf.Metric
 }
 
 // aggregatorUnit is a group of Aggregators and their source and sink channels.
@@ -96,14 +139,26 @@ type processorUnit struct {
 //            │                           ______
 //            └────────────────────────▶ ()_____)
 type aggregatorUnit struct {
-	src         <-chan telegraf.Metric
-	aggC        chan<- telegraf.Metric
-	outputC     chan<- telegraf.Metric
+	src     <-chan telegraf.Metric
+	aggC    chan<- telegraf.Metric
+	outputC chan<- telegraf.Metric
+
+	sync.RWMutex
 	aggregators []*models.RunningAggregator
+	pushers     map[*models.RunningAggregator]*aggregatorPusher
+	stopped     bool
+	wg          sync.WaitGroup
+}
+
+// aggregatorPusher controls the push loop of a single aggregator.
+type aggregatorPusher struct {
+	cancel context.CancelFunc
+	done   chan struct{}
 }
 
 // outputUnit is a group of Outputs and their source channel.  Metrics on the
-// channel are written to all outputs.
+// channel are written to all outputs, or to the outputs of the routes they
+// match when routes are configured.
 //
 //                            ┌────────┐
 //                       ┌──▶ │ Output │
@@ -136,6 +191,19 @@ func (a *Agent) RunSingleInput(input *models.RunningInput, ctx context.Context)
 		return errors.New("you are trying to run an input that is already running")
 	}
 
+	p, err := a.pipeline(input.Config.Pipeline)
+	if err != nil {
+		return err
+	}
+
+	var sched schedule.Schedule
+	if len(input.Config.Schedule) > 0 {
+		sched, err = schedule.Parse(input.Config.Schedule)
+		if err != nil {
+			return err
+		}
+	}
+
 	startTime := time.Now()
 
 	if input.UniqueId == "" {
@@ -154,6 +222,11 @@ func (a *Agent) RunSingleInput(input *models.RunningInput, ctx context.Context)
 	interval := a.Config.Agent.Interval.Duration
 	if input.Config.Interval != 0 {
 		interval = input.Config.Interval
+	} else if sched != nil {
+		// Scheduled inputs are expected to complete before their next run.
+		if d := scheduleInterval(startTime, sched); d > 0 {
+			interval = d
+		}
 	}
 
 	// Overwrite agent precision if this plugin has its own.
@@ -169,13 +242,16 @@ func (a *Agent) RunSingleInput(input *models.RunningInput, ctx context.Context)
 	}
 
 	var ticker Ticker
-	if a.Config.Agent.RoundInterval {
+	switch {
+	case sched != nil:
+		ticker = NewScheduledTicker(startTime, sched, jitter)
+	case a.Config.Agent.RoundInterval:
 		ticker = NewAlignedTicker(startTime, interval, jitter)
-	} else {
+	default:
 		ticker = NewUnalignedTicker(interval, jitter)
 	}
 
-	acc := NewAccumulator(input, a.iu.dst)
+	acc := NewAccumulator(input, p.iu.dst)
 	acc.SetPrecision(getPrecision(precision, interval))
 
 	a.incrementInputCount(1)
@@ -214,6 +290,10 @@ func (a *Agent) RunSingleOutput(output *models.RunningOutput, ctx context.Contex
 		return errors.New("you are trying to run an output that is already running")
 	}
 
+	if _, err := a.pipeline(output.Config.Pipeline); err != nil {
+		return err
+	}
+
 	// Start flush loop
 	interval := a.Config.Agent.FlushInterval.Duration
 	jitter := a.Config.Agent.FlushJitter.Duration
@@ -290,6 +370,22 @@ func GetAllOutputPlugins() []string {
 	return res
 }
 
+func GetAllProcessorPlugins() []string {
+	var res []string
+	for name := range processors.Processors {
+		res = append(res, name)
+	}
+	return res
+}
+
+func GetAllAggregatorPlugins() []string {
+	var res []string
+	for name := range aggregators.Aggregators {
+		res = append(res, name)
+	}
+	return res
+}
+
 func (a *Agent) GetRunningInputPlugins() []map[string]string {
 	var res []map[string]string
 	a.Config.InputsLock.Lock()
@@ -310,6 +406,26 @@ func (a *Agent) GetRunningOutputPlugins() []map[string]string {
 	return res
 }
 
+func (a *Agent) GetRunningProcessorPlugins() []map[string]string {
+	var res []map[string]string
+	a.Config.ProcessorsLock.Lock()
+	for _, runningProcessor := range a.Config.Processors {
+		res = append(res, map[string]string{"name": runningProcessor.Config.Name, "id": runningProcessor.UniqueId})
+	}
+	a.Config.ProcessorsLock.Unlock()
+	return res
+}
+
+func (a *Agent) GetRunningAggregatorPlugins() []map[string]string {
+	var res []map[string]string
+	a.Config.AggregatorsLock.Lock()
+	for _, runningAggregator := range a.Config.Aggregators {
+		res = append(res, map[string]string{"name": runningAggregator.Config.Name, "id": runningAggregator.UniqueId})
+	}
+	a.Config.AggregatorsLock.Unlock()
+	return res
+}
+
 type MapFieldSchema struct {
 	Value interface{}
 	Key   string
@@ -322,6 +438,7 @@ type ArrayFieldSchema struct {
 
 // GetPluginTypes returns a map of a plugin's field names to field value types
 func (a *Agent) GetPluginTypes(p interface{}) (map[string]interface{}, error) {
+	p = unwrapProcessor(p)
 
 	data := reflect.ValueOf(p).Elem() // extract Value of type interface{} from Value pointer to interface
 	schema := getFieldType(data.Type())
@@ -335,6 +452,16 @@ func (a *Agent) GetPluginTypes(p interface{}) (map[string]interface{}, error) {
 	return nil, fmt.Errorf("returned schema is not a map")
 }
 
+// GetPluginSchema returns the JSON-Schema like description of a plugin's
+// settings, with the TOML keys, defaults and docs of each setting.
+func (a *Agent) GetPluginSchema(name string, p interface{}) (*config.Schema, error) {
+	plugin, ok := unwrapProcessor(p).(telegraf.PluginDescriber)
+	if !ok {
+		return nil, fmt.Errorf("plugin %s has no sample config", name)
+	}
+	return config.NewPluginSchema(name, plugin), nil
+}
+
 func getFieldType(data reflect.Type) interface{} {
 	switch data.Kind() {
 	case reflect.Struct:
@@ -362,6 +489,7 @@ func getFieldType(data reflect.Type) interface{} {
 }
 
 func (a *Agent) GetPluginValues(p interface{}) (map[string]interface{}, error) {
+	p = unwrapProcessor(p)
 	data := reflect.ValueOf(p).Elem() // extract Value of type interface{} from Value pointer to interface
 	values := getFieldValue(data)
 	if values == nil {
@@ -423,94 +551,55 @@ func (a *Agent) Run(ctx context.Context) error {
 		return err
 	}
 
-	startTime := time.Now()
-	log.Printf("D! [agent] Connecting outputs")
-	next, ou, err := a.startOutputs(ctx, a.Config.Outputs)
-	if err != nil {
-		return err
+	if a.Config.Trace != nil {
+		log.Printf("I! [agent] Tracing metrics")
+		models.SetTracer(models.NewTracer(a.Config.Trace))
+		defer models.SetTracer(nil)
 	}
-	a.ou = ou
-	var apu []*processorUnit
-	var au *aggregatorUnit
-	if len(a.Config.Aggregators) != 0 {
-		aggC := next
-		if len(a.Config.AggProcessors) != 0 {
-			aggC, apu, err = a.startProcessors(next, a.Config.AggProcessors)
-			if err != nil {
-				return err
-			}
-		}
 
-		next, au, err = a.startAggregators(aggC, next, a.Config.Aggregators)
+	startTime := time.Now()
+	log.Printf("D! [agent] Connecting outputs")
+	pipelines := a.Config.Pipelines()
+	inputC := make(map[string]chan<- telegraf.Metric, len(pipelines))
+	running := make(map[string]*pipeline, len(pipelines))
+	for _, name := range pipelines {
+		next, p, err := a.startPipeline(ctx, name)
 		if err != nil {
 			return err
 		}
+		running[name] = p
+		inputC[name] = next
 	}
 
-	var pu []*processorUnit
-	if len(a.Config.Processors) != 0 {
-		next, pu, err = a.startProcessors(next, a.Config.Processors)
-		if err != nil {
-			return err
+	a.pluginLock.Lock()
+	for _, processor := range a.Config.Processors {
+		if processor.UniqueId != "" {
+			a.runningPlugins[processor.UniqueId] = processor
 		}
 	}
-	iu, err := a.startInputs(next, a.Config.Inputs)
-	a.iu = iu
-	if err != nil {
-		return err
+	for _, aggregator := range a.Config.Aggregators {
+		if aggregator.UniqueId != "" {
+			a.runningPlugins[aggregator.UniqueId] = aggregator
+		}
 	}
+	a.pluginLock.Unlock()
 
-	var wg sync.WaitGroup
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		err := a.runOutputs(ou)
+	for _, name := range pipelines {
+		iu, err := a.startInputs(inputC[name], pipelineInputs(a.Config.Inputs, name))
+		running[name].iu = iu
 		if err != nil {
-			log.Printf("E! [agent] Error running outputs: %v", err)
+			return err
 		}
-	}()
-
-	if au != nil {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			err := a.runProcessors(apu)
-			if err != nil {
-				log.Printf("E! [agent] Error running processors: %v", err)
-			}
-		}()
-
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			err := a.runAggregators(startTime, au)
-			if err != nil {
-				log.Printf("E! [agent] Error running aggregators: %v", err)
-			}
-		}()
 	}
 
-	if pu != nil {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			err := a.runProcessors(pu)
-			if err != nil {
-				log.Printf("E! [agent] Error running processors: %v", err)
-			}
-		}()
+	var wg sync.WaitGroup
+	for _, name := range pipelines {
+		a.runPipeline(ctx, startTime, running[name], &wg)
 	}
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		err := a.runInputs(ctx, startTime, iu)
-		if err != nil {
-			log.Printf("E! [agent] Error running inputs: %v", err)
-		}
-	}()
+	a.setPipelines(running)
 
 	wg.Wait()
+	a.setPipelines(nil)
 
 	log.Printf("D! [agent] Stopped Successfully")
 	return err
@@ -518,6 +607,9 @@ func (a *Agent) Run(ctx context.Context) error {
 
 // StartInput adds an input plugin with default config
 func (a *Agent) StartInput(ctx context.Context, pluginName string) (string, error) {
+	a.changeLock.Lock()
+	defer a.changeLock.Unlock()
+
 	inputConfig := models.InputConfig{
 		Name: pluginName,
 	}
@@ -543,8 +635,12 @@ func (a *Agent) StartInput(ctx context.Context, pluginName string) (string, erro
 		return "", err
 	}
 
-	// add new input to inputunit
-	a.iu.inputs = append(a.iu.inputs, ri)
+	// add new input to the input unit of its pipeline
+	p, err := a.pipeline(ri.Config.Pipeline)
+	if err != nil {
+		return "", err
+	}
+	p.iu.inputs = append(p.iu.inputs, ri)
 
 	err = a.Config.UpdateConfig(
 		map[string]interface{}{
@@ -562,6 +658,9 @@ func (a *Agent) StartInput(ctx context.Context, pluginName string) (string, erro
 
 // StartOutput adds an output plugin with default config
 func (a *Agent) StartOutput(ctx context.Context, pluginName string) (string, error) {
+	a.changeLock.Lock()
+	defer a.changeLock.Unlock()
+
 	outputConfig := models.OutputConfig{
 		Name: pluginName,
 	}
@@ -594,8 +693,12 @@ func (a *Agent) StartOutput(ctx context.Context, pluginName string) (string, err
 		return "", err
 	}
 
-	// add new output to outputunit
-	a.ou.outputs = append(a.ou.outputs, ro)
+	// add new output to the output unit of its pipeline
+	p, err := a.pipeline(ro.Config.Pipeline)
+	if err != nil {
+		return "", err
+	}
+	p.ou.outputs = append(p.ou.outputs, ro)
 
 	err = a.Config.UpdateConfig(map[string]interface{}{"unique_id": uniqueId.String(), "name": pluginName}, uniqueId.String(), "outputs", "START_PLUGIN")
 	if err != nil {
@@ -647,7 +750,35 @@ func (a *Agent) CreateOutput(name string) (telegraf.Output, error) {
 	return nil, fmt.Errorf("could not find output plugin with name: %s", name)
 }
 
-// GetRunningPlugin gets the values of a running plugin's struct.
+// CreateProcessor creates a new processor from the name of a processor.
+func (a *Agent) CreateProcessor(name string) (telegraf.StreamingProcessor, error) {
+	p, exists := processors.Processors[name]
+	if exists {
+		return p(), nil
+	}
+	return nil, fmt.Errorf("could not find processor plugin with name: %s", name)
+}
+
+// CreateAggregator creates a new aggregator from the name of an aggregator.
+func (a *Agent) CreateAggregator(name string) (telegraf.Aggregator, error) {
+	p, exists := aggregators.Aggregators[name]
+	if exists {
+		return p(), nil
+	}
+	return nil, fmt.Errorf("could not find aggregator plugin with name: %s", name)
+}
+
+// unwrapProcessor returns the plugin struct of a processor, processors that
+// are not streaming processors are wrapped when they are created.
+func unwrapProcessor(p interface{}) interface{} {
+	if u, ok := p.(interface{ Unwrap() telegraf.Processor }); ok {
+		return u.Unwrap()
+	}
+	return p
+}
+
+// GetRunningPlugin gets the values of a running plugin's struct.  Secrets the
+// plugin was configured with are replaced by their references.
 func (a *Agent) GetRunningPlugin(uid string) (map[string]interface{}, error) {
 	a.pluginLock.Lock()
 	obj, exists := a.runningPlugins[uid]
@@ -655,49 +786,53 @@ func (a *Agent) GetRunningPlugin(uid string) (map[string]interface{}, error) {
 	if !exists {
 		return nil, fmt.Errorf("specified plugin is not running")
 	}
-	ri, isRi := obj.(*models.RunningInput)
-	if isRi {
-		return a.GetPluginValues(ri.Input)
+
+	var plugin interface{}
+	switch p := obj.(type) {
+	case *models.RunningInput:
+		plugin = p.Input
+	case *models.RunningOutput:
+		plugin = p.Output
+	case *models.RunningProcessor:
+		plugin = p.Processor
+	case *models.RunningAggregator:
+		plugin = p.Aggregator
+	default:
+		return nil, fmt.Errorf("invalid running plugin")
 	}
-	ro, isRo := obj.(*models.RunningOutput)
-	if isRo {
-		return a.GetPluginValues(ro.Output)
+
+	values, err := a.GetPluginValues(plugin)
+	if err != nil {
+		return nil, err
 	}
-	return nil, fmt.Errorf("invalid running plugin")
+	return a.Config.RedactSecrets(uid, values), nil
 }
 
-// If the config is compatible with the struct, return the JSON vesion of the config.
-func validateStructConfig(structPtr reflect.Value, config map[string]interface{}) ([]byte, error) {
-	strct := structPtr.Elem() // extract Value of type interface{} from Value pointer to interface
-
-	if strct.Kind() == reflect.Struct {
-		// Check for if trying to modify unsettable fields and fields that don't exist.
-		for configKey := range config {
-			pluginField := strct.FieldByName(configKey) // cast new value as Value
-			if !(pluginField.IsValid()) {
-				return nil, fmt.Errorf("invalid field name %s", configKey)
-			} else if !(pluginField.CanSet()) {
-				return nil, fmt.Errorf("unsettable field %s", configKey)
-			}
-		}
-
-		// Creates a copy of the struct and see if JSON Unmarshal works without errors
-		empty := reflect.New(strct.Type())
-		cJSON, err := json.Marshal(config)
-		err = json.Unmarshal(cJSON, empty.Interface())
-		if err != nil {
-			return nil, err
-		}
-		return cJSON, nil
+// validatePluginConfig resolves the references to secrets in the updated
+// settings of a plugin and validates the result against the plugin, returning
+// its JSON.
+func (a *Agent) validatePluginConfig(uid string, plugin interface{}, config map[string]interface{}) ([]byte, error) {
+	resolved, err := a.Config.ResolveSecrets(uid, config)
+	if err != nil {
+		return nil, newValidationError(uid, StageConfig, err)
 	}
-
-	return nil, fmt.Errorf("pointer does not point to a struct")
+	configJSON, errs := validateStructConfig(reflect.ValueOf(plugin), resolved)
+	if len(errs) != 0 {
+		return nil, &ValidationError{UniqueId: uid, Stage: StageConfig, Errors: errs}
+	}
+	return configJSON, nil
 }
 
-// UpdateInputPlugin gets the config for an input plugin given its name
-func (a *Agent) UpdateInputPlugin(uid string, config map[string]interface{}) (telegraf.Input, error) {
+// UpdateInputPlugin replaces a running input plugin with a new instance using
+// the updated config.  The new instance is initialized, and if dryRun is set
+// gathered once, before it takes over; if any step fails a ValidationError is
+// returned and the running instance is kept.
+func (a *Agent) UpdateInputPlugin(uid string, config map[string]interface{}, dryRun bool) (telegraf.Input, error) {
+	a.changeLock.Lock()
+	defer a.changeLock.Unlock()
+
 	a.pluginLock.Lock()
-	plugin, ok := a.runningPlugins[uid]
+	input, ok := a.runningPlugins[uid].(*models.RunningInput)
 	a.pluginLock.Unlock()
 
 	if !ok {
@@ -705,36 +840,70 @@ func (a *Agent) UpdateInputPlugin(uid string, config map[string]interface{}) (te
 		return nil, errors.New("you are trying to update an input that does not exist")
 	}
 
-	input := plugin.(*models.RunningInput)
-
-	// This code creates a copy of the struct and see if JSON Unmarshal works without errors
-	configJSON, err := validateStructConfig(reflect.ValueOf(input.Input), config)
+	p, err := a.pipeline(input.Config.Pipeline)
 	if err != nil {
-		return nil, fmt.Errorf("could not update input plugin %s with error: %s", uid, err)
+		return nil, err
 	}
 
-	tomlMap, err := generateTomlKeysMap(reflect.ValueOf(input.Input), config)
+	configJSON, err := a.validatePluginConfig(uid, input.Input, config)
 	if err != nil {
-		return nil, fmt.Errorf("could not update input plugin %s with error: %s", uid, err)
+		return nil, err
 	}
 
-	if len(a.Config.Inputs) == 1 {
-		a.incrementInputCount(1)
+	tomlMap, err := generateTomlKeysMap(reflect.ValueOf(input.Input), config)
+	if err != nil {
+		return nil, newValidationError(uid, StageConfig, err)
 	}
 
-	err = a.StopInputPlugin(uid, false)
+	newInput, err := a.CreateInput(input.Config.Name)
 	if err != nil {
 		return nil, err
 	}
+	copyPluginConfig(newInput, input.Input)
+	if input.Prepare != nil {
+		if err := input.Prepare(newInput); err != nil {
+			return nil, newValidationError(uid, StageConfig, err)
+		}
+	}
+	if err := applyPluginConfig(newInput, config, configJSON); err != nil {
+		return nil, newValidationError(uid, StageConfig, err)
+	}
 
-	err = json.Unmarshal(configJSON, &input.Input)
-	if err != nil {
-		return nil, err
+	ri := models.NewRunningInput(newInput, input.Config, input.UniqueId)
+	ri.SetDefaultTags(a.Config.Tags)
+	ri.Prepare = input.Prepare
+	if err := ri.Init(); err != nil {
+		return nil, newValidationError(uid, StageInit, err)
 	}
 
-	ri := models.NewRunningInput(input.Input, input.Config, input.UniqueId)
-	err = a.RunSingleInput(ri, a.Context)
-	if err != nil {
+	_, isService := newInput.(telegraf.ServiceInput)
+	if dryRun && !isService {
+		timeout := a.Config.Agent.Interval.Duration
+		if input.Config.Interval != 0 {
+			timeout = input.Config.Interval
+		}
+		if errs := dryRunGather(ri, timeout); len(errs) != 0 {
+			return nil, newValidationError(uid, StageGather, errs...)
+		}
+	}
+
+	iu := p.iu
+
+	// Service inputs often hold resources such as a listening socket, so the
+	// running instance has to be stopped before the new one can start.  A
+	// stopped plugin can not be restarted, so if the new instance fails to
+	// start a fresh copy of the running instance takes over instead.
+	if isService {
+		input.Input.(telegraf.ServiceInput).Stop()
+		if err := a.startServiceInput(ri, iu.dst); err != nil {
+			if rerr := a.restoreServiceInput(input, iu); rerr != nil {
+				log.Printf("E! [agent] Could not restore input %s: %v", uid, rerr)
+			}
+			return nil, newValidationError(uid, StageStart, err)
+		}
+	}
+
+	if err := a.replaceInput(input, ri, iu); err != nil {
 		return nil, err
 	}
 
@@ -743,17 +912,64 @@ func (a *Agent) UpdateInputPlugin(uid string, config map[string]interface{}) (te
 		return nil, fmt.Errorf("could not update input plugin %s with error: %s", uid, err)
 	}
 
-	if len(a.Config.Inputs) == 1 {
-		a.incrementInputCount(-1)
+	return ri.Input, nil
+}
+
+// replaceInput stops the running input and runs its replacement in the same
+// input unit.
+func (a *Agent) replaceInput(old, ri *models.RunningInput, iu *inputUnit) error {
+	// Keep the input count from dropping to zero in between, which would
+	// stop the pipeline.
+	a.incrementInputCount(1)
+	defer a.incrementInputCount(-1)
+
+	if err := a.stopInputPlugin(old.UniqueId, false); err != nil {
+		return err
+	}
+
+	if err := a.RunSingleInput(ri, a.Context); err != nil {
+		return err
+	}
+	iu.inputs = append(iu.inputs, ri)
+	return nil
+}
+
+// restoreServiceInput replaces a stopped service input with a fresh instance
+// using the same config and starts it.
+func (a *Agent) restoreServiceInput(input *models.RunningInput, iu *inputUnit) error {
+	plugin, err := a.CreateInput(input.Config.Name)
+	if err != nil {
+		return err
+	}
+	copyPluginConfig(plugin, input.Input)
+	if input.Prepare != nil {
+		if err := input.Prepare(plugin); err != nil {
+			return err
+		}
 	}
 
-	return input.Input, nil
+	ri := models.NewRunningInput(plugin, input.Config, input.UniqueId)
+	ri.SetDefaultTags(a.Config.Tags)
+	ri.Prepare = input.Prepare
+	if err := ri.Init(); err != nil {
+		return err
+	}
+	if err := a.startServiceInput(ri, iu.dst); err != nil {
+		return err
+	}
+	return a.replaceInput(input, ri, iu)
 }
 
-// UpdateOutputPlugin gets the config for an output plugin given its name
+// UpdateOutputPlugin replaces a running output plugin with a new instance
+// using the updated config.  The new instance is initialized and connected
+// before it takes over; if any step fails a ValidationError is returned and the
+// running instance is kept.
 func (a *Agent) UpdateOutputPlugin(uid string, config map[string]interface{}) (telegraf.Output, error) {
+	a.changeLock.Lock()
+	defer a.changeLock.Unlock()
+
 	a.pluginLock.Lock()
-	plugin, ok := a.runningPlugins[uid]
+	output, ok := a.runningPlugins[uid].(*models.RunningOutput)
 	a.pluginLock.Unlock()
 
 	if !ok {
@@ -761,132 +977,641 @@ func (a *Agent) UpdateOutputPlugin(uid string, config map[string]interface{}) (t
 		return nil, errors.New("you are trying to update an output that does not exist")
 	}
 
-	output := plugin.(*models.RunningOutput)
-
-	// This code creates a copy of the struct and see if JSON Unmarshal works without errors
-	configJSON, err := validateStructConfig(reflect.ValueOf(output.Output), config)
+	configJSON, err := a.validatePluginConfig(uid, output.Output, config)
 	if err != nil {
-		return nil, fmt.Errorf("could not update output plugin %s with error: %s", uid, err)
+		return nil, err
 	}
 
 	tomlMap, err := generateTomlKeysMap(reflect.ValueOf(output.Output), config)
 	if err != nil {
-		return nil, fmt.Errorf("could not update output plugin %s with error: %s", uid, err)
+		return nil, newValidationError(uid, StageConfig, err)
 	}
 
-	if len(a.Config.Outputs) == 1 {
-		a.incrementOutputCount(1)
+	newOutput, err := a.CreateOutput(output.Config.Name)
+	if err != nil {
+		return nil, err
+	}
+	copyPluginConfig(newOutput, output.Output)
+	if output.Prepare != nil {
+		if err := output.Prepare(newOutput); err != nil {
+			return nil, newValidationError(uid, StageConfig, err)
+		}
+	}
+	if err := applyPluginConfig(newOutput, config, configJSON); err != nil {
+		return nil, newValidationError(uid, StageConfig, err)
+	}
+
+	ro := models.NewRunningOutput(output.Config.Name, newOutput, output.Config,
+		a.Config.Agent.MetricBatchSize, a.Config.Agent.MetricBufferLimit, output.UniqueId)
+	ro.Prepare = output.Prepare
+	if err := ro.Init(); err != nil {
+		ro.Discard()
+		return nil, newValidationError(uid, StageInit, err)
 	}
 
-	err = a.StopOutputPlugin(uid, false)
+	if err := newOutput.Connect(); err != nil {
+		newOutput.Close()
+		ro.Discard()
+		return nil, newValidationError(uid, StageConnect, err)
+	}
+	ro.SetConnectionState(models.ConnectionStateConnected)
+
+	p, err := a.pipeline(ro.Config.Pipeline)
 	if err != nil {
+		newOutput.Close()
+		ro.Discard()
 		return nil, err
 	}
 
-	err = json.Unmarshal(configJSON, &output.Output)
+	// Keep the output count from dropping to zero in between, which would
+	// stop the pipeline.
+	a.incrementOutputCount(1)
+	defer a.incrementOutputCount(-1)
+
+	// The unsent metrics of the running output are taken over by the new
+	// one once it is stopped, a disk buffer can only be opened after.
+	ro.TakeBuffer(output)
+	err = a.stopOutputPlugin(uid, false)
 	if err != nil {
+		newOutput.Close()
+		ro.Discard()
 		return nil, err
 	}
-
-	ro := models.NewRunningOutput(output.Config.Name, output.Output, output.Config,
-		a.Config.Agent.MetricBatchSize, a.Config.Agent.MetricBufferLimit, output.UniqueId)
+	if err := ro.OpenBuffer(); err != nil {
+		log.Printf("E! [agent] Output %s keeps its metrics in memory: %v", uid, err)
+	}
 
 	err = a.RunSingleOutput(ro, a.Context)
 	if err != nil {
 		return nil, err
 	}
+	p.ou.outputs = append(p.ou.outputs, ro)
 
 	err = a.Config.UpdateConfig(tomlMap, output.UniqueId, "outputs", "UPDATE_PLUGIN")
 	if err != nil {
 		return nil, fmt.Errorf("could not update output plugin %s with error: %s", uid, err)
 	}
 
-	if len(a.Config.Outputs) == 1 {
-		a.incrementOutputCount(-1)
-	}
-
-	return output.Output, nil
+	return ro.Output, nil
 }
 
-// initPlugins runs the Init function on plugins.
-func (a *Agent) initPlugins() error {
-	for _, input := range a.Config.Inputs {
-		err := input.Init()
-		if err != nil {
-			return fmt.Errorf("could not initialize input %s: %v",
-				input.LogName(), err)
-		}
+// StartProcessor adds a processor plugin with default config to both the
+// processor chain and the chain following the aggregators.
+func (a *Agent) StartProcessor(pluginName string) (string, error) {
+	a.changeLock.Lock()
+	defer a.changeLock.Unlock()
+
+	uniqueId, err := uuid.NewUUID()
+	if err != nil {
+		return "", errors.New("errored while generating UUID for new PROCESSOR")
 	}
-	for _, processor := range a.Config.Processors {
-		err := processor.Init()
-		if err != nil {
-			return fmt.Errorf("could not initialize processor %s: %v",
-				processor.Config.Name, err)
-		}
+
+	p, err := a.pipeline(defaultPipeline)
+	if err != nil {
+		return "", err
 	}
-	for _, aggregator := range a.Config.Aggregators {
-		err := aggregator.Init()
+	var started []*models.RunningProcessor
+	for _, unit := range []*processorUnit{p.pu, p.apu} {
+		processor, err := a.CreateProcessor(pluginName)
 		if err != nil {
-			return fmt.Errorf("could not initialize aggregator %s: %v",
-				aggregator.Config.Name, err)
+			return "", err
 		}
-	}
-	for _, processor := range a.Config.AggProcessors {
-		err := processor.Init()
-		if err != nil {
-			return fmt.Errorf("could not initialize processor %s: %v",
-				processor.Config.Name, err)
+
+		processorConfig := models.ProcessorConfig{
+			Name: pluginName,
+		}
+		rp := models.NewRunningProcessor(processor, &processorConfig, uniqueId.String())
+
+		err = rp.Init()
+		if err == nil {
+			err = unit.add(rp)
 		}
-	}
-	for _, output := range a.Config.Outputs {
-		err := output.Init()
 		if err != nil {
-			return fmt.Errorf("could not initialize output %s: %v",
-				output.Config.Name, err)
+			if len(started) != 0 {
+				p.pu.remove(started[0])
+			}
+			return "", err
 		}
+		started = append(started, rp)
 	}
-	return nil
-}
 
-func (a *Agent) startInputs(
-	dst chan<- telegraf.Metric,
-	inputs []*models.RunningInput,
-) (*inputUnit, error) {
-	log.Printf("D! [agent] Starting service inputs")
+	a.Config.ProcessorsLock.Lock()
+	a.Config.Processors = append(a.Config.Processors, started[0])
+	a.Config.AggProcessors = append(a.Config.AggProcessors, started[1])
+	a.Config.ProcessorsLock.Unlock()
 
-	unit := &inputUnit{
-		dst: dst,
+	a.pluginLock.Lock()
+	a.runningPlugins[uniqueId.String()] = started[0]
+	a.pluginLock.Unlock()
+
+	err = a.Config.UpdateConfig(
+		map[string]interface{}{
+			"unique_id": uniqueId.String(),
+			"name":      pluginName,
+		},
+		uniqueId.String(), "processors", "START_PLUGIN")
+	if err != nil {
+		log.Printf("W! [agent] Unable to save configuration for processor %s", uniqueId.String())
 	}
 
-	for _, input := range inputs {
-		if si, ok := input.Input.(telegraf.ServiceInput); ok {
-			// Service input plugins are not normally subject to timestamp
-			// rounding except for when precision is set on the input plugin.
-			//
-			// This only applies to the accumulator passed to Start(), the
-			// Gather() accumulator does apply rounding according to the
-			// precision and interval agent/plugin settings.
-			var interval time.Duration
-			var precision time.Duration
-			if input.Config.Precision != 0 {
-				precision = input.Config.Precision
-			}
+	return uniqueId.String(), nil
+}
 
-			acc := NewAccumulator(input, dst)
-			acc.SetPrecision(getPrecision(precision, interval))
+// StartAggregator adds an aggregator plugin with default config
+func (a *Agent) StartAggregator(pluginName string) (string, error) {
+	a.changeLock.Lock()
+	defer a.changeLock.Unlock()
 
-			err := si.Start(acc)
+	aggregator, err := a.CreateAggregator(pluginName)
+	if err != nil {
+		return "", err
+	}
+
+	uniqueId, err := uuid.NewUUID()
+	if err != nil {
+		return "", errors.New("errored while generating UUID for new AGGREGATOR")
+	}
+
+	// Use the same defaults as aggregators loaded from the config file.
+	aggregatorConfig := models.AggregatorConfig{
+		Name:   pluginName,
+		Delay:  time.Millisecond * 100,
+		Period: time.Second * 30,
+		Tags:   make(map[string]string),
+	}
+	ra := models.NewRunningAggregator(aggregator, &aggregatorConfig, uniqueId.String())
+
+	err = ra.Init()
+	if err != nil {
+		return "", err
+	}
+
+	p, err := a.pipeline(defaultPipeline)
+	if err != nil {
+		return "", err
+	}
+	err = a.addAggregator(p.au, ra)
+	if err != nil {
+		return "", err
+	}
+
+	a.Config.AggregatorsLock.Lock()
+	a.Config.Aggregators = append(a.Config.Aggregators, ra)
+	a.Config.AggregatorsLock.Unlock()
+
+	a.pluginLock.Lock()
+	a.runningPlugins[uniqueId.String()] = ra
+	a.pluginLock.Unlock()
+
+	err = a.Config.UpdateConfig(
+		map[string]interface{}{
+			"unique_id": uniqueId.String(),
+			"name":      pluginName,
+		},
+		uniqueId.String(), "aggregators", "START_PLUGIN")
+	if err != nil {
+		log.Printf("W! [agent] Unable to save configuration for aggregator %s", uniqueId.String())
+	}
+
+	return uniqueId.String(), nil
+}
+
+// UpdateProcessorPlugin updates the config of a running processor plugin.
+// The "order" key moves the processor to a new position in the chain.
+func (a *Agent) UpdateProcessorPlugin(uid string, config map[string]interface{}) (interface{}, error) {
+	a.changeLock.Lock()
+	defer a.changeLock.Unlock()
+
+	a.pluginLock.Lock()
+	plugin, ok := a.runningPlugins[uid].(*models.RunningProcessor)
+	a.pluginLock.Unlock()
+
+	if !ok {
+		log.Printf("E! [agent] You are trying to update a processor that does not exist: %s \n", uid)
+		return nil, errors.New("you are trying to update a processor that does not exist")
+	}
+
+	pluginConfig := make(map[string]interface{}, len(config))
+	for k, v := range config {
+		pluginConfig[k] = v
+	}
+
+	var order int64
+	orderValue, hasOrder := pluginConfig["order"]
+	if hasOrder {
+		delete(pluginConfig, "order")
+		o, err := toInt64(orderValue)
+		if err != nil {
+			return nil, newValidationError(uid, StageConfig, fmt.Errorf("invalid order %v", orderValue))
+		}
+		order = o
+	}
+
+	processor := unwrapProcessor(plugin.Processor)
+
+	configJSON, err := a.validatePluginConfig(uid, processor, pluginConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	tomlMap, err := generateTomlKeysMap(reflect.ValueOf(processor), pluginConfig)
+	if err != nil {
+		return nil, newValidationError(uid, StageConfig, err)
+	}
+	if hasOrder {
+		tomlMap["order"] = order
+	}
+
+	// The processor runs in both the main and the aggregator chain, new
+	// instances for both chains are validated before either is replaced.
+	p, err := a.pipeline(plugin.Config.Pipeline)
+	if err != nil {
+		return nil, err
+	}
+	units := []*processorUnit{p.pu, p.apu}
+	replacements := make([]telegraf.StreamingProcessor, len(units))
+	if len(pluginConfig) != 0 {
+		for i, unit := range units {
+			rp := unit.find(uid)
+			if rp == nil {
+				continue
+			}
+
+			newProcessor, err := a.CreateProcessor(rp.Config.Name)
 			if err != nil {
-				stopServiceInputs(unit.inputs)
-				return nil, fmt.Errorf("starting input %s: %w", input.LogName(), err)
+				return nil, err
+			}
+			copyPluginConfig(unwrapProcessor(newProcessor), unwrapProcessor(rp.Processor))
+			if err := applyPluginConfig(unwrapProcessor(newProcessor), pluginConfig, configJSON); err != nil {
+				return nil, newValidationError(uid, StageConfig, err)
+			}
+
+			models.SetLoggerOnPlugin(newProcessor, rp.Log())
+			if p, ok := newProcessor.(telegraf.Initializer); ok {
+				if err := p.Init(); err != nil {
+					return nil, newValidationError(uid, StageInit, err)
+				}
+			}
+			replacements[i] = newProcessor
+		}
+	}
+
+	for i, unit := range units {
+		rp := unit.find(uid)
+		if rp == nil {
+			continue
+		}
+
+		if replacements[i] != nil {
+			if err := unit.replace(rp, replacements[i]); err != nil {
+				return nil, newValidationError(uid, StageStart, err)
 			}
 		}
+
+		if hasOrder {
+			unit.reorder(rp, order)
+		}
+	}
+
+	if hasOrder {
+		a.Config.ProcessorsLock.Lock()
+		sort.Stable(a.Config.Processors)
+		sort.Stable(a.Config.AggProcessors)
+		a.Config.ProcessorsLock.Unlock()
+	}
+
+	err = a.Config.UpdateConfig(tomlMap, uid, "processors", "UPDATE_PLUGIN")
+	if err != nil {
+		return nil, fmt.Errorf("could not update processor plugin %s with error: %s", uid, err)
+	}
+
+	if replacements[0] != nil {
+		return unwrapProcessor(replacements[0]), nil
+	}
+	return processor, nil
+}
+
+// UpdateAggregatorPlugin replaces a running aggregator with a new instance
+// using the updated config.  The "period", "delay" and "grace" keys change the
+// aggregation window of the new instance.  The current aggregation is pushed
+// before the running instance stops.  If the new instance cannot be
+// initialized a ValidationError is returned and the running instance is kept.
+func (a *Agent) UpdateAggregatorPlugin(uid string, config map[string]interface{}) (telegraf.Aggregator, error) {
+	a.changeLock.Lock()
+	defer a.changeLock.Unlock()
+
+	a.pluginLock.Lock()
+	plugin, ok := a.runningPlugins[uid].(*models.RunningAggregator)
+	a.pluginLock.Unlock()
+
+	if !ok {
+		log.Printf("E! [agent] You are trying to update an aggregator that does not exist: %s \n", uid)
+		return nil, errors.New("you are trying to update an aggregator that does not exist")
+	}
+
+	pluginConfig := make(map[string]interface{}, len(config))
+	for k, v := range config {
+		pluginConfig[k] = v
+	}
+
+	aggregatorConfig := *plugin.Config
+	durations := map[string]*time.Duration{
+		"period": &aggregatorConfig.Period,
+		"delay":  &aggregatorConfig.Delay,
+		"grace":  &aggregatorConfig.Grace,
+	}
+	window := make(map[string]interface{})
+	for key, duration := range durations {
+		value, ok := pluginConfig[key]
+		if !ok {
+			continue
+		}
+		delete(pluginConfig, key)
+		d, err := toDuration(value)
+		if err != nil {
+			return nil, newValidationError(uid, StageConfig, fmt.Errorf("invalid %s %v", key, value))
+		}
+		*duration = d
+		window[key] = d.String()
+	}
+	if aggregatorConfig.Period <= 0 {
+		return nil, newValidationError(uid, StageConfig, errors.New("period must be positive"))
+	}
+
+	configJSON, err := a.validatePluginConfig(uid, plugin.Aggregator, pluginConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	tomlMap, err := generateTomlKeysMap(reflect.ValueOf(plugin.Aggregator), pluginConfig)
+	if err != nil {
+		return nil, newValidationError(uid, StageConfig, err)
+	}
+	for key, value := range window {
+		tomlMap[key] = value
+	}
+
+	aggregator, err := a.CreateAggregator(plugin.Config.Name)
+	if err != nil {
+		return nil, err
+	}
+	copyPluginConfig(aggregator, plugin.Aggregator)
+	if err := applyPluginConfig(aggregator, pluginConfig, configJSON); err != nil {
+		return nil, newValidationError(uid, StageConfig, err)
+	}
+
+	ra := models.NewRunningAggregator(aggregator, &aggregatorConfig, uid)
+	if err := ra.Init(); err != nil {
+		return nil, newValidationError(uid, StageInit, err)
+	}
+
+	p, err := a.pipeline(plugin.Config.Pipeline)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.replaceAggregator(p.au, plugin, ra); err != nil {
+		return nil, err
+	}
+
+	a.Config.AggregatorsLock.Lock()
+	for i, other := range a.Config.Aggregators {
+		if other == plugin {
+			a.Config.Aggregators[i] = ra
+		}
+	}
+	a.Config.AggregatorsLock.Unlock()
+
+	a.pluginLock.Lock()
+	a.runningPlugins[uid] = ra
+	a.pluginLock.Unlock()
+
+	err = a.Config.UpdateConfig(tomlMap, uid, "aggregators", "UPDATE_PLUGIN")
+	if err != nil {
+		return nil, fmt.Errorf("could not update aggregator plugin %s with error: %s", uid, err)
+	}
+
+	return ra.Aggregator, nil
+}
+
+// StopProcessorPlugin removes a processor plugin from the running chains
+func (a *Agent) StopProcessorPlugin(uuid string, shouldUpdateConfig bool) error {
+	a.changeLock.Lock()
+	defer a.changeLock.Unlock()
+
+	return a.stopProcessorPlugin(uuid, shouldUpdateConfig)
+}
+
+func (a *Agent) stopProcessorPlugin(uuid string, shouldUpdateConfig bool) error {
+	a.pluginLock.Lock()
+	plugin, ok := a.runningPlugins[uuid].(*models.RunningProcessor)
+	if ok {
+		delete(a.runningPlugins, uuid)
+	}
+	a.pluginLock.Unlock()
+
+	if !ok {
+		log.Printf("E! [agent] Processor %s is not runnning.\n", uuid)
+		return fmt.Errorf("processor %s is not runnning", uuid)
+	}
+
+	p, err := a.pipeline(plugin.Config.Pipeline)
+	if err != nil {
+		return err
+	}
+	p.pu.remove(plugin)
+	if rp := p.apu.find(uuid); rp != nil {
+		p.apu.remove(rp)
+	}
+
+	if shouldUpdateConfig {
+		err := a.Config.UpdateConfig(map[string]interface{}{}, uuid, "processors", "STOP_PLUGIN")
+		if err != nil {
+			log.Printf("W! [agent] Unable to update configuration for processor plugin %s\n", uuid)
+		}
+	}
+
+	a.Config.ProcessorsLock.Lock()
+	a.Config.Processors = removeProcessor(a.Config.Processors, uuid)
+	a.Config.AggProcessors = removeProcessor(a.Config.AggProcessors, uuid)
+	a.Config.ProcessorsLock.Unlock()
+
+	return nil
+}
+
+// StopAggregatorPlugin removes an aggregator plugin, its current aggregation
+// is pushed before it stops.
+func (a *Agent) StopAggregatorPlugin(uuid string, shouldUpdateConfig bool) error {
+	a.changeLock.Lock()
+	defer a.changeLock.Unlock()
+
+	return a.stopAggregatorPlugin(uuid, shouldUpdateConfig)
+}
+
+func (a *Agent) stopAggregatorPlugin(uuid string, shouldUpdateConfig bool) error {
+	a.pluginLock.Lock()
+	plugin, ok := a.runningPlugins[uuid].(*models.RunningAggregator)
+	if ok {
+		delete(a.runningPlugins, uuid)
+	}
+	a.pluginLock.Unlock()
+
+	if !ok {
+		log.Printf("E! [agent] Aggregator %s is not runnning.\n", uuid)
+		return fmt.Errorf("aggregator %s is not runnning", uuid)
+	}
+
+	p, err := a.pipeline(plugin.Config.Pipeline)
+	if err != nil {
+		return err
+	}
+	p.au.remove(plugin)
+
+	if shouldUpdateConfig {
+		err := a.Config.UpdateConfig(map[string]interface{}{}, uuid, "aggregators", "STOP_PLUGIN")
+		if err != nil {
+			log.Printf("W! [agent] Unable to update configuration for aggregator plugin %s\n", uuid)
+		}
+	}
+
+	a.Config.AggregatorsLock.Lock()
+	for i, other := range a.Config.Aggregators {
+		if other.UniqueId == uuid {
+			a.Config.Aggregators = append(a.Config.Aggregators[:i], a.Config.Aggregators[i+1:]...)
+			break
+		}
+	}
+	a.Config.AggregatorsLock.Unlock()
+
+	return nil
+}
+
+// toInt64 converts a number decoded from a request to an int64.
+func toInt64(v interface{}) (int64, error) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), nil
+	case int64:
+		return n, nil
+	case float64:
+		if n != float64(int64(n)) {
+			return 0, fmt.Errorf("%v is not an integer", n)
+		}
+		return int64(n), nil
+	case json.Number:
+		return n.Int64()
+	}
+	return 0, fmt.Errorf("%v is not a number", v)
+}
+
+// toDuration converts a duration decoded from a request, numbers are taken
+// as seconds like in the config file.
+func toDuration(v interface{}) (time.Duration, error) {
+	if s, ok := v.(string); ok {
+		return time.ParseDuration(s)
+	}
+	n, err := toInt64(v)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(n) * time.Second, nil
+}
+
+func removeProcessor(processors models.RunningProcessors, uuid string) models.RunningProcessors {
+	for i, other := range processors {
+		if other.UniqueId == uuid {
+			return append(processors[:i], processors[i+1:]...)
+		}
+	}
+	return processors
+}
+
+// initPlugins runs the Init function on plugins.
+func (a *Agent) initPlugins() error {
+	for _, input := range a.Config.Inputs {
+		err := input.Init()
+		if err != nil {
+			return fmt.Errorf("could not initialize input %s: %v",
+				input.LogName(), err)
+		}
+	}
+	for _, processor := range a.Config.Processors {
+		err := processor.Init()
+		if err != nil {
+			return fmt.Errorf("could not initialize processor %s: %v",
+				processor.Config.Name, err)
+		}
+	}
+	for _, aggregator := range a.Config.Aggregators {
+		err := aggregator.Init()
+		if err != nil {
+			return fmt.Errorf("could not initialize aggregator %s: %v",
+				aggregator.Config.Name, err)
+		}
+	}
+	for _, processor := range a.Config.AggProcessors {
+		err := processor.Init()
+		if err != nil {
+			return fmt.Errorf("could not initialize processor %s: %v",
+				processor.Config.Name, err)
+		}
+	}
+	for _, output := range a.Config.Outputs {
+		err := output.Init()
+		if err != nil {
+			return fmt.Errorf("could not initialize output %s: %v",
+				output.Config.Name, err)
+		}
+	}
+	return nil
+}
+
+func (a *Agent) startInputs(
+	dst chan<- telegraf.Metric,
+	inputs []*models.RunningInput,
+) (*inputUnit, error) {
+	log.Printf("D! [agent] Starting service inputs")
+
+	unit := &inputUnit{
+		dst: dst,
+	}
+
+	for _, input := range inputs {
+		err := a.startServiceInput(input, dst)
+		if err != nil {
+			stopServiceInputs(unit.inputs)
+			return nil, fmt.Errorf("starting input %s: %w", input.LogName(), err)
+		}
 		unit.inputs = append(unit.inputs, input)
 	}
 
 	return unit, nil
 }
 
+// startServiceInput starts the input if it is a service input.
+func (a *Agent) startServiceInput(input *models.RunningInput, dst chan<- telegraf.Metric) error {
+	si, ok := input.Input.(telegraf.ServiceInput)
+	if !ok {
+		return nil
+	}
+
+	// Service input plugins are not normally subject to timestamp
+	// rounding except for when precision is set on the input plugin.
+	//
+	// This only applies to the accumulator passed to Start(), the
+	// Gather() accumulator does apply rounding according to the
+	// precision and interval agent/plugin settings.
+	var interval time.Duration
+	var precision time.Duration
+	if input.Config.Precision != 0 {
+		precision = input.Config.Precision
+	}
+
+	acc := NewAccumulator(input, dst)
+	acc.SetPrecision(getPrecision(precision, interval))
+
+	return si.Start(acc)
+}
+
 // runInputs starts and triggers the periodic gather for Inputs.
 //
 // When the context is done the timers are stopped and this function returns
@@ -908,7 +1633,7 @@ func (a *Agent) runInputs(
 		select {
 		case <-ctx.Done():
 			log.Printf("D! [agent] Stopping service inputs")
-			stopServiceInputs(a.Config.Inputs)
+			stopServiceInputs(unit.inputs)
 
 			close(unit.dst)
 			log.Printf("D! [agent] Input channel closed")
@@ -1027,6 +1752,13 @@ func stopServiceInputs(inputs []*models.RunningInput) {
 
 // StopInputPlugin stops an input plugin
 func (a *Agent) StopInputPlugin(uuid string, shouldUpdateConfig bool) error {
+	a.changeLock.Lock()
+	defer a.changeLock.Unlock()
+
+	return a.stopInputPlugin(uuid, shouldUpdateConfig)
+}
+
+func (a *Agent) stopInputPlugin(uuid string, shouldUpdateConfig bool) error {
 	a.pluginLock.Lock()
 	plugin, ok := a.runningPlugins[uuid].(*models.RunningInput)
 	a.pluginLock.Unlock()
@@ -1060,6 +1792,13 @@ func (a *Agent) StopInputPlugin(uuid string, shouldUpdateConfig bool) error {
 
 // StopOutputPlugin stops an output plugin
 func (a *Agent) StopOutputPlugin(uuid string, shouldUpdateConfig bool) error {
+	a.changeLock.Lock()
+	defer a.changeLock.Unlock()
+
+	return a.stopOutputPlugin(uuid, shouldUpdateConfig)
+}
+
+func (a *Agent) stopOutputPlugin(uuid string, shouldUpdateConfig bool) error {
 	a.pluginLock.Lock()
 	plugin, ok := a.runningPlugins[uuid].(*models.RunningOutput)
 	a.pluginLock.Unlock()
@@ -1109,130 +1848,338 @@ func (a *Agent) gatherLoop(
 			delete(a.runningPlugins, input.UniqueId)
 			a.pluginLock.Unlock()
 			// delete input from input unit slice
-			for i, io := range a.iu.inputs {
-				if input == io {
-					// swap with last input and truncate slice
-					if len(a.iu.inputs) > 1 {
-						a.iu.inputs[i] = a.iu.inputs[len(a.iu.inputs)-1]
+			if p, err := a.pipeline(input.Config.Pipeline); err == nil {
+				iu := p.iu
+				for i, io := range iu.inputs {
+					if input == io {
+						// swap with last input and truncate slice
+						if len(iu.inputs) > 1 {
+							iu.inputs[i] = iu.inputs[len(iu.inputs)-1]
+						}
+						iu.inputs = iu.inputs[:len(iu.inputs)-1]
+						break
 					}
-					a.iu.inputs = a.iu.inputs[:len(a.iu.inputs)-1]
-					break
 				}
 			}
 			input.Wg.Done()
 			return
-		case <-ticker.Elapsed():
-			err := a.gatherOnce(acc, input, ticker, interval)
+		case tick := <-ticker.Elapsed():
+			if input.BackingOff(tick) {
+				input.SkipGather()
+				continue
+			}
+			overrun, err := a.gatherOnce(acc, input, ticker, interval)
 			if err != nil {
 				acc.AddError(err)
 			}
 			if err != nil {
 				acc.AddError(err)
 			}
+			input.UpdateBackoff(tick, interval, overrun)
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
-// gatherOnce runs the input's Gather function once, logging a warning each
-// interval it fails to complete before.
-func (a *Agent) gatherOnce(
-	acc telegraf.Accumulator,
-	input *models.RunningInput,
-	ticker Ticker,
-	interval time.Duration,
-) error {
-	done := make(chan error)
-	go func() {
-		done <- input.Gather(acc)
-	}()
+// gatherOnce runs the input's Gather function once, logging a warning each
+// interval it fails to complete before.  The ticks elapsing meanwhile are
+// skipped, the returned bool is true if the gather overran its interval.
+func (a *Agent) gatherOnce(
+	acc telegraf.Accumulator,
+	input *models.RunningInput,
+	ticker Ticker,
+	interval time.Duration,
+) (bool, error) {
+	done := make(chan error)
+	go func() {
+		done <- input.Gather(acc)
+	}()
+
+	// Only warn after interval seconds, even if the interval is started late.
+	// Intervals can start late if the previous interval went over or due to
+	// clock changes.
+	slowWarning := time.NewTicker(interval)
+	defer slowWarning.Stop()
+
+	overrun := false
+	for {
+		select {
+		case err := <-done:
+			return overrun, err
+		case <-slowWarning.C:
+			overrun = true
+			log.Printf("W! [%s] Collection took longer than expected; not complete after interval of %s",
+				input.LogName(), interval)
+		case <-ticker.Elapsed():
+			input.SkipGather()
+			log.Printf("D! [%s] Previous collection has not completed; scheduled collection skipped",
+				input.LogName())
+		}
+	}
+}
+
+// startProcessors sets up the processor chain and calls Start on all
+// processors.  If an error occurs any started processors are Stopped.
+func (a *Agent) startProcessors(
+	dst chan<- telegraf.Metric,
+	processors models.RunningProcessors,
+) (chan<- telegraf.Metric, *processorUnit, error) {
+	src := make(chan telegraf.Metric, 100)
+	unit := &processorUnit{
+		src:  src,
+		dst:  dst,
+		head: &processorStage{dst: dst},
+	}
+
+	for _, processor := range processors {
+		err := unit.add(processor)
+		if err != nil {
+			unit.stop()
+			return nil, nil, fmt.Errorf("starting processor %s: %w", processor.LogName(), err)
+		}
+	}
+
+	return src, unit, nil
+}
+
+// runProcessors begins processing metrics and runs until the source channel is
+// closed and all metrics have been written.
+func (a *Agent) runProcessors(
+	unit *processorUnit,
+) error {
+	for m := range unit.src {
+		unit.head.send(m)
+	}
+
+	unit.Lock()
+	unit.stopped = true
+	unit.stop()
+	unit.Unlock()
+
+	close(unit.dst)
+	log.Printf("D! [agent] Processor channel closed")
+
+	return nil
+}
+
+// stop drains and stops the processors from first to last, so metrics emitted
+// while stopping are still handled by the remaining processors.
+func (u *processorUnit) stop() {
+	for _, s := range u.stages {
+		s.drain()
+		s.processor.Stop()
+	}
+}
+
+// add starts the processor and inserts it into the chain according to its
+// order.
+func (u *processorUnit) add(processor *models.RunningProcessor) error {
+	u.Lock()
+	defer u.Unlock()
+
+	if u.stopped {
+		return errors.New("processor chain is stopped")
+	}
+
+	s := newProcessorStage(processor)
+	stages := u.insert(s)
+	s.dst = u.next(stages, s)
+	if err := processor.Start(s.acc); err != nil {
+		return err
+	}
+	s.run()
+
+	u.stages = stages
+	u.relink()
+	return nil
+}
+
+// remove takes the processor out of the chain and stops it once it has
+// handled the metrics already sent to it.
+func (u *processorUnit) remove(processor *models.RunningProcessor) bool {
+	u.Lock()
+	defer u.Unlock()
+
+	s := u.detach(processor)
+	if s == nil {
+		return false
+	}
+	s.processor.Stop()
+	return true
+}
+
+// reorder changes the order of the processor and moves it to its new
+// position in the chain.  The processor keeps running while it is moved.
+func (u *processorUnit) reorder(processor *models.RunningProcessor, order int64) {
+	u.Lock()
+	defer u.Unlock()
+
+	s := u.detach(processor)
+	processor.Config.Order = order
+	if s == nil {
+		return
+	}
+
+	stages := u.insert(s)
+	s.link(u.next(stages, s))
+	s.run()
+
+	u.stages = stages
+	u.relink()
+}
+
+// replace starts the new plugin and swaps it in for the plugin of the running
+// processor, the old plugin is stopped afterwards.  If the new plugin cannot
+// be started the old one keeps running.
+func (u *processorUnit) replace(processor *models.RunningProcessor, plugin telegraf.StreamingProcessor) error {
+	u.Lock()
+	defer u.Unlock()
+
+	s := u.stage(processor)
+	if s == nil {
+		return fmt.Errorf("processor %s is not part of the chain", processor.LogName())
+	}
+
+	if err := plugin.Start(s.acc); err != nil {
+		return err
+	}
+
+	processor.Lock()
+	old := processor.Processor
+	processor.Processor = plugin
+	processor.Unlock()
+
+	return old.Stop()
+}
+
+// find returns the processor with the given unique id.
+func (u *processorUnit) find(uniqueId string) *models.RunningProcessor {
+	u.Lock()
+	defer u.Unlock()
+	for _, s := range u.stages {
+		if s.processor.UniqueId == uniqueId {
+			return s.processor
+		}
+	}
+	return nil
+}
+
+// stage returns the stage running the processor.  The unit must be locked by
+// the caller.
+func (u *processorUnit) stage(processor *models.RunningProcessor) *processorStage {
+	for _, s := range u.stages {
+		if s.processor == processor {
+			return s
+		}
+	}
+	return nil
+}
 
-	// Only warn after interval seconds, even if the interval is started late.
-	// Intervals can start late if the previous interval went over or due to
-	// clock changes.
-	slowWarning := time.NewTicker(interval)
-	defer slowWarning.Stop()
+// detach takes the stage of the processor out of the chain and waits until
+// it has handled the metrics already sent to it.  The processor is not
+// stopped.  The unit must be locked by the caller.
+func (u *processorUnit) detach(processor *models.RunningProcessor) *processorStage {
+	if u.stopped {
+		return nil
+	}
 
-	for {
-		select {
-		case err := <-done:
-			return err
-		case <-slowWarning.C:
-			log.Printf("W! [%s] Collection took longer than expected; not complete after interval of %s",
-				input.LogName(), interval)
-		case <-ticker.Elapsed():
-			log.Printf("D! [%s] Previous collection has not completed; scheduled collection skipped",
-				input.LogName())
+	for i, s := range u.stages {
+		if s.processor == processor {
+			u.stages = append(u.stages[:i:i], u.stages[i+1:]...)
+			u.relink()
+			s.drain()
+			return s
 		}
 	}
+	return nil
 }
 
-// startProcessors sets up the processor chain and calls Start on all
-// processors.  If an error occurs any started processors are Stopped.
-func (a *Agent) startProcessors(
-	dst chan<- telegraf.Metric,
-	processors models.RunningProcessors,
-) (chan<- telegraf.Metric, []*processorUnit, error) {
-	var units []*processorUnit
-
-	// Sort from last to first
-	sort.SliceStable(processors, func(i, j int) bool {
-		return processors[i].Config.Order > processors[j].Config.Order
+// insert returns a copy of the stages with s added according to the order of
+// its processor.
+func (u *processorUnit) insert(s *processorStage) []*processorStage {
+	stages := append(append([]*processorStage(nil), u.stages...), s)
+	sort.SliceStable(stages, func(i, j int) bool {
+		return stages[i].processor.Config.Order < stages[j].processor.Config.Order
 	})
+	return stages
+}
 
-	var src chan telegraf.Metric
-	for _, processor := range processors {
-		src = make(chan telegraf.Metric, 100)
-		acc := NewAccumulator(processor, dst)
-
-		err := processor.Start(acc)
-		if err != nil {
-			for _, u := range units {
-				u.processor.Stop()
-				close(u.dst)
-			}
-			return nil, nil, fmt.Errorf("starting processor %s: %w", processor.LogName(), err)
+// next returns the channel the stage s writes to in the given chain.
+func (u *processorUnit) next(stages []*processorStage, s *processorStage) chan<- telegraf.Metric {
+	for i, other := range stages {
+		if other == s && i+1 < len(stages) {
+			return stages[i+1].src
 		}
+	}
+	return u.dst
+}
 
-		units = append(units, &processorUnit{
-			src:       src,
-			dst:       dst,
-			processor: processor,
-		})
-
-		dst = src
+// relink connects the stages of the chain.  The stages are linked from the
+// sink backwards, so a stage already knows where to write before it receives
+// the first metric.  The unit must be locked by the caller.
+func (u *processorUnit) relink() {
+	dst := u.dst
+	for i := len(u.stages) - 1; i >= 0; i-- {
+		u.stages[i].link(dst)
+		dst = u.stages[i].src
 	}
+	u.head.link(dst)
+}
 
-	return src, units, nil
+func newProcessorStage(processor *models.RunningProcessor) *processorStage {
+	s := &processorStage{processor: processor}
+	s.acc = newFuncAccumulator(processor, s.send)
+	return s
 }
 
-// runProcessors begins processing metrics and runs until the source channel is
-// closed and all metrics have been written.
-func (a *Agent) runProcessors(
-	units []*processorUnit,
-) error {
-	var wg sync.WaitGroup
-	for _, unit := range units {
-		wg.Add(1)
-		go func(unit *processorUnit) {
-			defer wg.Done()
+// send writes the metric to the next stage of the chain.
+func (s *processorStage) send(m telegraf.Metric) {
+	s.RLock()
+	s.dst <- m
+	s.RUnlock()
+}
 
-			acc := NewAccumulator(unit.processor, unit.dst)
-			for m := range unit.src {
-				if err := unit.processor.Add(m, acc); err != nil {
-					acc.AddError(err)
-					m.Drop()
-				}
-			}
-			unit.processor.Stop()
-			close(unit.dst)
-			log.Printf("D! [agent] Processor channel closed")
-		}(unit)
+// link changes the channel the stage writes to.  It waits for a send in
+// progress to complete, afterwards nothing is written to the previous
+// channel.  The destination is only changed while the unit is locked.
+func (s *processorStage) link(dst chan<- telegraf.Metric) {
+	if s.dst == dst {
+		return
 	}
-	wg.Wait()
+	s.Lock()
+	s.dst = dst
+	s.Unlock()
+}
 
-	return nil
+// run creates a new source channel and starts passing the metrics written to
+// it to the processor.
+func (s *processorStage) run() {
+	src := make(chan telegraf.Metric, 100)
+	done := make(chan struct{})
+	s.src = src
+	s.done = done
+
+	go func() {
+		defer close(done)
+		for m := range src {
+			// The lock keeps replace from swapping the plugin during Add.
+			s.processor.Lock()
+			err := s.processor.Add(m, s.acc)
+			s.processor.Unlock()
+			if err != nil {
+				s.acc.AddError(err)
+				m.Drop()
+			}
+		}
+	}()
+}
+
+// drain closes the source channel and waits until the processor has handled
+// the remaining metrics.
+func (s *processorStage) drain() {
+	close(s.src)
+	<-s.done
 }
 
 // startAggregators sets up the aggregator unit and returns the source channel.
@@ -1246,7 +2193,8 @@ func (a *Agent) startAggregators(
 		src:         src,
 		aggC:        aggC,
 		outputC:     outputC,
-		aggregators: aggregators,
+		aggregators: append([]*models.RunningAggregator(nil), aggregators...),
+		pushers:     make(map[*models.RunningAggregator]*aggregatorPusher),
 	}
 	return src, unit, nil
 }
@@ -1257,51 +2205,37 @@ func (a *Agent) runAggregators(
 	startTime time.Time,
 	unit *aggregatorUnit,
 ) error {
-	ctx, cancel := context.WithCancel(context.Background())
-
-	// Before calling Add, initialize the aggregation window.  This ensures
-	// that any metric created after start time will be aggregated.
-	for _, agg := range a.Config.Aggregators {
-		since, until := updateWindow(startTime, a.Config.Agent.RoundInterval, agg.Period())
-		agg.UpdateWindow(since, until)
+	unit.Lock()
+	for _, agg := range unit.aggregators {
+		a.startAggregatorPush(startTime, unit, agg)
 	}
+	unit.Unlock()
 
-	var wg sync.WaitGroup
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		for metric := range unit.src {
-			var dropOriginal bool
-			for _, agg := range a.Config.Aggregators {
-				if ok := agg.Add(metric); ok {
-					dropOriginal = true
-				}
-			}
-
-			if !dropOriginal {
-				unit.outputC <- metric // keep original.
-			} else {
-				metric.Drop()
+	for metric := range unit.src {
+		var dropOriginal bool
+		unit.RLock()
+		for _, agg := range unit.aggregators {
+			if ok := agg.Add(metric); ok {
+				dropOriginal = true
 			}
 		}
-		cancel()
-	}()
+		unit.RUnlock()
 
-	for _, agg := range a.Config.Aggregators {
-		wg.Add(1)
-		go func(agg *models.RunningAggregator) {
-			defer wg.Done()
-
-			interval := a.Config.Agent.Interval.Duration
-			precision := a.Config.Agent.Precision.Duration
+		if !dropOriginal {
+			unit.outputC <- metric // keep original.
+		} else {
+			metric.Drop()
+		}
+	}
 
-			acc := NewAccumulator(agg, unit.aggC)
-			acc.SetPrecision(getPrecision(precision, interval))
-			a.push(ctx, agg, acc)
-		}(agg)
+	unit.Lock()
+	unit.stopped = true
+	for _, pusher := range unit.pushers {
+		pusher.cancel()
 	}
+	unit.Unlock()
 
-	wg.Wait()
+	unit.wg.Wait()
 
 	// In the case that there are no processors, both aggC and outputC are the
 	// same channel.  If there are processors, we close the aggC and the
@@ -1312,6 +2246,111 @@ func (a *Agent) runAggregators(
 	return nil
 }
 
+// startAggregatorPush initializes the aggregation window and starts the push
+// loop of the aggregator.  The unit must be locked by the caller.
+func (a *Agent) startAggregatorPush(
+	startTime time.Time,
+	unit *aggregatorUnit,
+	agg *models.RunningAggregator,
+) {
+	// Before calling Add, initialize the aggregation window.  This ensures
+	// that any metric created after start time will be aggregated.
+	since, until := updateWindow(startTime, a.Config.Agent.RoundInterval, agg.Period())
+	agg.UpdateWindow(since, until)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pusher := &aggregatorPusher{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	unit.pushers[agg] = pusher
+
+	unit.wg.Add(1)
+	go func() {
+		defer unit.wg.Done()
+		defer close(pusher.done)
+
+		interval := a.Config.Agent.Interval.Duration
+		precision := a.Config.Agent.Precision.Duration
+
+		acc := NewAccumulator(agg, unit.aggC)
+		acc.SetPrecision(getPrecision(precision, interval))
+		a.push(ctx, agg, acc)
+	}()
+}
+
+// addAggregator inserts the aggregator into the running unit.
+func (a *Agent) addAggregator(unit *aggregatorUnit, agg *models.RunningAggregator) error {
+	unit.Lock()
+	defer unit.Unlock()
+
+	if unit.stopped {
+		return errors.New("aggregators are stopped")
+	}
+
+	a.startAggregatorPush(time.Now(), unit, agg)
+	unit.aggregators = append(unit.aggregators, agg)
+	return nil
+}
+
+// replaceAggregator swaps the aggregator of the running unit for agg, which
+// starts a new aggregation window.  The current aggregation of the old
+// aggregator is pushed before it stops.
+func (a *Agent) replaceAggregator(unit *aggregatorUnit, old, agg *models.RunningAggregator) error {
+	unit.Lock()
+	if unit.stopped {
+		unit.Unlock()
+		return errors.New("aggregators are stopped")
+	}
+
+	found := false
+	for i, other := range unit.aggregators {
+		if other == old {
+			unit.aggregators[i] = agg
+			found = true
+			break
+		}
+	}
+	if !found {
+		unit.Unlock()
+		return fmt.Errorf("aggregator %s is not running", old.LogName())
+	}
+
+	a.startAggregatorPush(time.Now(), unit, agg)
+	pusher, ok := unit.pushers[old]
+	delete(unit.pushers, old)
+	unit.Unlock()
+
+	if ok {
+		pusher.cancel()
+		<-pusher.done
+	}
+	return nil
+}
+
+// remove takes the aggregator out of the unit, its current aggregation is
+// pushed before it is stopped.
+func (u *aggregatorUnit) remove(agg *models.RunningAggregator) bool {
+	u.Lock()
+	found := false
+	for i, other := range u.aggregators {
+		if other == agg {
+			u.aggregators = append(u.aggregators[:i], u.aggregators[i+1:]...)
+			found = true
+			break
+		}
+	}
+	pusher, ok := u.pushers[agg]
+	delete(u.pushers, agg)
+	u.Unlock()
+
+	if ok {
+		pusher.cancel()
+		<-pusher.done
+	}
+	return found
+}
+
 func updateWindow(start time.Time, roundInterval bool, period time.Duration) (time.Time, time.Time) {
 	var until time.Time
 	if roundInterval {
@@ -1376,6 +2415,10 @@ func (a *Agent) startOutputs(
 
 // connectOutputs connects to all outputs.
 func (a *Agent) connectOutput(ctx context.Context, output *models.RunningOutput) error {
+	if err := output.OpenBuffer(); err != nil {
+		return fmt.Errorf("opening buffer of output %q: %w", output.LogName(), err)
+	}
+
 	log.Printf("D! [agent] Attempting connection to [%s]", output.LogName())
 	err := output.Output.Connect()
 	if err != nil {
@@ -1389,9 +2432,11 @@ func (a *Agent) connectOutput(ctx context.Context, output *models.RunningOutput)
 
 		err = output.Output.Connect()
 		if err != nil {
+			output.SetConnectionState(models.ConnectionStateFailed)
 			return fmt.Errorf("Error connecting to output %q: %w", output.LogName(), err)
 		}
 	}
+	output.SetConnectionState(models.ConnectionStateConnected)
 	log.Printf("D! [agent] Successfully connected to %s", output.LogName())
 	return nil
 }
@@ -1413,15 +2458,18 @@ func (a *Agent) runOutputs(
 	}
 
 	for metric := range unit.src {
-		for i, output := range unit.outputs {
-			a.Config.OutputsLock.Lock()
-			if i == len(a.Config.Outputs)-1 {
+		a.Config.OutputsLock.Lock()
+		outputs := a.routeMetric(metric, unit.outputs)
+		for i, output := range outputs {
+			if i == len(outputs)-1 {
 				output.AddMetric(metric)
 			} else {
-				output.AddMetric(metric.Copy())
+				m := metric.Copy()
+				models.TraceCopy(metric, m)
+				output.AddMetric(m)
 			}
-			a.Config.OutputsLock.Unlock()
 		}
+		a.Config.OutputsLock.Unlock()
 	}
 
 	log.Println("I! [agent] Hang on, flushing any cached metrics before shutdown")
@@ -1471,14 +2519,17 @@ func (a *Agent) flushLoop(
 			delete(a.runningPlugins, output.UniqueId)
 			a.pluginLock.Unlock()
 			// delete output from output unit slice
-			for i, ro := range a.ou.outputs {
-				if output == ro {
-					// swap with last output and truncate slice
-					if len(a.ou.outputs) > 1 {
-						a.ou.outputs[i] = a.ou.outputs[len(a.ou.outputs)-1]
+			if p, err := a.pipeline(output.Config.Pipeline); err == nil {
+				ou := p.ou
+				for i, ro := range ou.outputs {
+					if output == ro {
+						// swap with last output and truncate slice
+						if len(ou.outputs) > 1 {
+							ou.outputs[i] = ou.outputs[len(ou.outputs)-1]
+						}
+						ou.outputs = ou.outputs[:len(ou.outputs)-1]
+						break
 					}
-					a.ou.outputs = a.ou.outputs[:len(a.ou.outputs)-1]
-					break
 				}
 			}
 			output.Close()
@@ -1525,8 +2576,21 @@ func (a *Agent) flushOnce(
 }
 
 // Test runs the inputs, processors and aggregators for a single gather and
-// writes the metrics to stdout.
+// writes the metrics to stdout.  If outputs are configured the metrics each
+// output would receive are written instead, see testOutputs.
 func (a *Agent) Test(ctx context.Context, wait time.Duration) error {
+	if len(a.Config.Outputs) != 0 {
+		err := a.testOutputs(ctx, wait, os.Stdout)
+		if err != nil {
+			return err
+		}
+
+		if models.GlobalGatherErrors.Get() != 0 {
+			return fmt.Errorf("input plugins recorded %d errors", models.GlobalGatherErrors.Get())
+		}
+		return nil
+	}
+
 	src := make(chan telegraf.Metric, 100)
 
 	var wg sync.WaitGroup
@@ -1562,89 +2626,55 @@ func (a *Agent) Test(ctx context.Context, wait time.Duration) error {
 // outputF.  After gathering pauses for the wait duration to allow service
 // inputs to run.
 func (a *Agent) test(ctx context.Context, wait time.Duration, outputC chan<- telegraf.Metric) error {
-	log.Printf("D! [agent] Initializing plugins")
-	err := a.initPlugins()
+	err := a.testPipelines(ctx, wait, func(_ string, metric telegraf.Metric) {
+		outputC <- metric
+	})
 	if err != nil {
 		return err
 	}
 
-	startTime := time.Now()
-
-	next := outputC
-
-	var apu []*processorUnit
-	var au *aggregatorUnit
-	if len(a.Config.Aggregators) != 0 {
-		procC := next
-		if len(a.Config.AggProcessors) != 0 {
-			procC, apu, err = a.startProcessors(next, a.Config.AggProcessors)
-			if err != nil {
-				return err
-			}
-		}
-
-		next, au, err = a.startAggregators(procC, next, a.Config.Aggregators)
-		if err != nil {
-			return err
-		}
-	}
-
-	var pu []*processorUnit
-	if len(a.Config.Processors) != 0 {
-		next, pu, err = a.startProcessors(next, a.Config.Processors)
-		if err != nil {
-			return err
-		}
-	}
+	close(outputC)
+	return nil
+}
 
-	iu, err := a.testStartInputs(next, a.Config.Inputs)
+// testPipelines runs the pipelines for a single gather and calls handle for
+// each metric leaving a pipeline, handle is called concurrently for different
+// pipelines.
+func (a *Agent) testPipelines(
+	ctx context.Context,
+	wait time.Duration,
+	handle func(pipeline string, metric telegraf.Metric),
+) error {
+	log.Printf("D! [agent] Initializing plugins")
+	err := a.initPlugins()
 	if err != nil {
 		return err
 	}
 
-	var wg sync.WaitGroup
-
-	if au != nil {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			err := a.runProcessors(apu)
-			if err != nil {
-				log.Printf("E! [agent] Error running processors: %v", err)
-			}
-		}()
-
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			err := a.runAggregators(startTime, au)
-			if err != nil {
-				log.Printf("E! [agent] Error running aggregators: %v", err)
-			}
-		}()
-	}
+	startTime := time.Now()
 
-	if pu != nil {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			err := a.runProcessors(pu)
-			if err != nil {
-				log.Printf("E! [agent] Error running processors: %v", err)
+	// Each pipeline writes to its own channel, as the last unit of a chain
+	// closes its sink channel once it is done.
+	var wg sync.WaitGroup
+	var forwarders sync.WaitGroup
+	for _, name := range a.Config.Pipelines() {
+		src := make(chan telegraf.Metric, 100)
+		forwarders.Add(1)
+		go func(name string) {
+			defer forwarders.Done()
+			for metric := range src {
+				handle(name, metric)
 			}
-		}()
-	}
+		}(name)
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		err := a.testRunInputs(ctx, wait, iu)
+		err := a.runTestPipeline(ctx, wait, startTime, name, src, &wg)
 		if err != nil {
-			log.Printf("E! [agent] Error running inputs: %v", err)
+			return err
 		}
-	}()
+	}
 
 	wg.Wait()
+	forwarders.Wait()
 
 	log.Printf("D! [agent] Stopped Successfully")
 
@@ -1684,91 +2714,37 @@ func (a *Agent) once(ctx context.Context, wait time.Duration) error {
 
 	startTime := time.Now()
 
-	log.Printf("D! [agent] Connecting outputs")
-	next, ou, err := a.startOutputs(ctx, a.Config.Outputs)
-	if err != nil {
-		return err
-	}
-
-	var apu []*processorUnit
-	var au *aggregatorUnit
-	if len(a.Config.Aggregators) != 0 {
-		procC := next
-		if len(a.Config.AggProcessors) != 0 {
-			procC, apu, err = a.startProcessors(next, a.Config.AggProcessors)
-			if err != nil {
-				return err
-			}
-		}
-
-		next, au, err = a.startAggregators(procC, next, a.Config.Aggregators)
-		if err != nil {
-			return err
-		}
-	}
-
-	var pu []*processorUnit
-	if len(a.Config.Processors) != 0 {
-		next, pu, err = a.startProcessors(next, a.Config.Processors)
+	// Only the outputs of the pipelines are known to the agent, so that the
+	// flush loops can remove stopped outputs.  The agent is not marked as
+	// running.
+	var wg sync.WaitGroup
+	for _, name := range a.Config.Pipelines() {
+		log.Printf("D! [agent] Connecting outputs")
+		next, ou, err := a.startOutputs(ctx, pipelineOutputs(a.Config.Outputs, name))
 		if err != nil {
 			return err
 		}
-	}
-
-	iu, err := a.testStartInputs(next, a.Config.Inputs)
-	if err != nil {
-		return err
-	}
-
-	var wg sync.WaitGroup
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		err := a.runOutputs(ou)
-		if err != nil {
-			log.Printf("E! [agent] Error running outputs: %v", err)
+		a.pipelinesLock.Lock()
+		if a.pipelines == nil {
+			a.pipelines = make(map[string]*pipeline)
 		}
-	}()
-
-	if au != nil {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			err := a.runProcessors(apu)
-			if err != nil {
-				log.Printf("E! [agent] Error running processors: %v", err)
-			}
-		}()
-
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			err := a.runAggregators(startTime, au)
-			if err != nil {
-				log.Printf("E! [agent] Error running aggregators: %v", err)
-			}
-		}()
-	}
+		a.pipelines[name] = &pipeline{name: name, ou: ou}
+		a.pipelinesLock.Unlock()
 
-	if pu != nil {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			err := a.runProcessors(pu)
+			err := a.runOutputs(ou)
 			if err != nil {
-				log.Printf("E! [agent] Error running processors: %v", err)
+				log.Printf("E! [agent] Error running outputs: %v", err)
 			}
 		}()
-	}
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		err := a.testRunInputs(ctx, wait, iu)
+		err = a.runTestPipeline(ctx, wait, startTime, name, next, &wg)
 		if err != nil {
-			log.Printf("E! [agent] Error running inputs: %v", err)
+			return err
 		}
-	}()
+	}
 
 	wg.Wait()
 
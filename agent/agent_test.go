@@ -1,12 +1,24 @@
 package agent
 
 import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/models"
+	"github.com/influxdata/telegraf/plugins/aggregators/minmax"
 	_ "github.com/influxdata/telegraf/plugins/inputs/all"
+	"github.com/influxdata/telegraf/plugins/inputs/socket_listener"
 	_ "github.com/influxdata/telegraf/plugins/outputs/all"
+	"github.com/influxdata/telegraf/plugins/processors"
+	"github.com/influxdata/telegraf/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -166,3 +178,301 @@ func TestWindow(t *testing.T) {
 		})
 	}
 }
+
+// pathProcessor appends its name to the path tag of each metric.
+type pathProcessor struct {
+	name string
+}
+
+func (p *pathProcessor) SampleConfig() string { return "" }
+func (p *pathProcessor) Description() string  { return "" }
+func (p *pathProcessor) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	for _, m := range in {
+		path, _ := m.GetTag("path")
+		m.AddTag("path", path+p.name)
+	}
+	return in
+}
+
+func newPathProcessor(name string, order int64) *models.RunningProcessor {
+	return models.NewRunningProcessor(
+		processors.NewStreamingProcessorFromProcessor(&pathProcessor{name: name}),
+		&models.ProcessorConfig{Name: name, Order: order},
+		name)
+}
+
+func TestProcessorUnit_Runtime(t *testing.T) {
+	a, _ := NewAgent(config.NewConfig())
+
+	dst := make(chan telegraf.Metric, 10)
+	src, unit, err := a.startProcessors(dst, models.RunningProcessors{
+		newPathProcessor("b", 2),
+		newPathProcessor("a", 1),
+	})
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.runProcessors(unit)
+	}()
+
+	path := func() string {
+		src <- testutil.TestMetric(1)
+		m := <-dst
+		path, _ := m.GetTag("path")
+		return path
+	}
+
+	require.Equal(t, "ab", path())
+
+	unit.reorder(unit.find("a"), 3)
+	require.Equal(t, "ba", path())
+
+	require.True(t, unit.remove(unit.find("b")))
+	require.Equal(t, "a", path())
+
+	require.NoError(t, unit.add(newPathProcessor("c", 0)))
+	require.Equal(t, "ca", path())
+
+	require.NoError(t, unit.replace(unit.find("a"), newPathProcessor("x", 0).Processor))
+	require.Equal(t, "cx", path())
+
+	close(src)
+	<-done
+	_, ok := <-dst
+	require.False(t, ok)
+	require.Error(t, unit.add(newPathProcessor("d", 0)))
+}
+
+func TestAggregatorUnit_Replace(t *testing.T) {
+	a, _ := NewAgent(config.NewConfig())
+
+	newAggregator := func(period time.Duration) *models.RunningAggregator {
+		return models.NewRunningAggregator(minmax.NewMinMax(),
+			&models.AggregatorConfig{Name: "minmax", Period: period}, "a")
+	}
+	old := newAggregator(time.Hour)
+
+	aggC := make(chan telegraf.Metric, 10)
+	outC := make(chan telegraf.Metric, 10)
+	src, unit, err := a.startAggregators(aggC, outC, []*models.RunningAggregator{old})
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.runAggregators(time.Now(), unit)
+	}()
+
+	m, _ := metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 42}, time.Now())
+	src <- m
+	<-outC
+
+	// The aggregation of the replaced aggregator is pushed.
+	agg := newAggregator(2 * time.Hour)
+	require.NoError(t, a.replaceAggregator(unit, old, agg))
+	pushed := <-aggC
+	require.Equal(t, float64(42), pushed.Fields()["value_max"])
+
+	require.Equal(t, 2*time.Hour, agg.Period())
+	require.True(t, agg.EndPeriod().After(time.Now()))
+	require.Error(t, a.replaceAggregator(unit, old, newAggregator(time.Hour)))
+
+	close(src)
+	<-done
+}
+
+func TestValidateStructConfig_ReportsAllErrors(t *testing.T) {
+	type plugin struct {
+		Servers []string
+		Timeout int
+		private string
+	}
+
+	_, errs := validateStructConfig(reflect.ValueOf(&plugin{}), map[string]interface{}{
+		"Servers": "localhost",
+		"Timeout": 5,
+		"Missing": true,
+		"private": "x",
+	})
+	require.Len(t, errs, 3)
+	require.Contains(t, errs[0], "invalid field name Missing")
+	require.Contains(t, errs[1], "invalid value for field Servers")
+	require.Contains(t, errs[2], "unsettable field private")
+
+	configJSON, errs := validateStructConfig(reflect.ValueOf(&plugin{}), map[string]interface{}{
+		"Timeout": 5,
+	})
+	require.Empty(t, errs)
+	require.JSONEq(t, `{"Timeout": 5}`, string(configJSON))
+}
+
+func TestApplyPluginConfig_DoesNotModifyRunningInstance(t *testing.T) {
+	type plugin struct {
+		Servers []string
+		Tags    map[string]string
+		Timeout int
+		sync.Mutex
+	}
+
+	running := &plugin{
+		Servers: []string{"a", "b"},
+		Tags:    map[string]string{"a": "1"},
+		Timeout: 5,
+	}
+	running.Lock()
+	defer running.Unlock()
+
+	config := map[string]interface{}{
+		"Servers": []string{"c"},
+		"Tags":    map[string]string{"c": "3"},
+	}
+	configJSON, errs := validateStructConfig(reflect.ValueOf(running), config)
+	require.Empty(t, errs)
+
+	updated := &plugin{}
+	copyPluginConfig(updated, running)
+	require.NoError(t, applyPluginConfig(updated, config, configJSON))
+
+	require.Equal(t, []string{"c"}, updated.Servers)
+	require.Equal(t, map[string]string{"c": "3"}, updated.Tags)
+	require.Equal(t, 5, updated.Timeout)
+
+	require.Equal(t, []string{"a", "b"}, running.Servers)
+	require.Equal(t, map[string]string{"a": "1"}, running.Tags)
+
+	// The lock of the running instance is not copied.
+	updated.Lock()
+	updated.Unlock()
+}
+
+func TestAgent_UpdatePluginOfOtherType(t *testing.T) {
+	a, _ := NewAgent(config.NewConfig())
+	processor := newPathProcessor("a", 0)
+	a.runningPlugins[processor.UniqueId] = processor
+
+	_, err := a.UpdateInputPlugin(processor.UniqueId, map[string]interface{}{}, false)
+	require.Error(t, err)
+	_, err = a.UpdateOutputPlugin(processor.UniqueId, map[string]interface{}{})
+	require.Error(t, err)
+}
+
+// slowInput reports an error after the timeout of a dry run.
+type slowInput struct {
+	delay time.Duration
+}
+
+func (i *slowInput) SampleConfig() string { return "" }
+func (i *slowInput) Description() string  { return "" }
+func (i *slowInput) Gather(acc telegraf.Accumulator) error {
+	time.Sleep(i.delay)
+	acc.AddError(errors.New("late error"))
+	return nil
+}
+
+func TestDryRunGather_WaitsForGather(t *testing.T) {
+	input := models.NewRunningInput(&slowInput{delay: 50 * time.Millisecond},
+		&models.InputConfig{Name: "slow"}, "slow")
+
+	start := time.Now()
+	errs := dryRunGather(input, 10*time.Millisecond)
+	require.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	// The gather is finished and does not report to the accumulator anymore.
+	require.Len(t, errs, 2)
+	require.EqualError(t, errs[0], "gather did not complete within 10ms")
+	require.EqualError(t, errs[1], "late error")
+}
+
+func TestAgent_UpdateServiceInputRestoresOnFailedStart(t *testing.T) {
+	defer os.Remove("./updated_config.conf")
+
+	c := loadReloadConfig(t, `
+[[inputs.socket_listener]]
+  service_address = "tcp://127.0.0.1:0"
+
+[[outputs.discard]]
+`, nil)
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = a.Run(ctx)
+	}()
+
+	uid := c.Inputs[0].UniqueId
+	require.Eventually(t, func() bool {
+		return a.isRunning(uid)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	a.pluginLock.Lock()
+	running := a.runningPlugins[uid].(*models.RunningInput)
+	a.pluginLock.Unlock()
+
+	_, err = a.UpdateInputPlugin(uid, map[string]interface{}{"ServiceAddress": "bogus"}, false)
+	var verr *ValidationError
+	require.True(t, errors.As(err, &verr))
+	require.Equal(t, StageStart, verr.Stage)
+
+	// The stopped instance is replaced by a fresh one with the same config.
+	a.pluginLock.Lock()
+	restored, ok := a.runningPlugins[uid].(*models.RunningInput)
+	a.pluginLock.Unlock()
+	require.True(t, ok)
+	require.NotSame(t, running, restored)
+
+	listener := restored.Input.(*socket_listener.SocketListener)
+	require.Equal(t, "tcp://127.0.0.1:0", listener.ServiceAddress)
+	require.NotNil(t, listener.Closer)
+}
+
+func TestAgent_UpdateOutputKeepsBuffer(t *testing.T) {
+	defer os.Remove("./updated_config.conf")
+
+	c := loadReloadConfig(t, `
+[[inputs.mem]]
+
+[[outputs.http]]
+  url = "http://localhost:1"
+`, nil)
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = a.Run(ctx)
+	}()
+
+	uid := c.Outputs[0].UniqueId
+	require.Eventually(t, func() bool {
+		return a.isRunning(uid)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	a.pluginLock.Lock()
+	running := a.runningPlugins[uid].(*models.RunningOutput)
+	a.pluginLock.Unlock()
+	running.AddMetric(testutil.TestMetric(1))
+	running.AddMetric(testutil.TestMetric(2))
+
+	// A failed update keeps the running instance.
+	_, err = a.UpdateOutputPlugin(uid, map[string]interface{}{"Method": "DELETE"})
+	var verr *ValidationError
+	require.True(t, errors.As(err, &verr))
+	require.Equal(t, StageConnect, verr.Stage)
+	a.pluginLock.Lock()
+	require.Same(t, running, a.runningPlugins[uid])
+	a.pluginLock.Unlock()
+
+	// The new instance takes over the unsent metrics.
+	_, err = a.UpdateOutputPlugin(uid, map[string]interface{}{"Method": "PUT"})
+	require.NoError(t, err)
+	a.pluginLock.Lock()
+	updated := a.runningPlugins[uid].(*models.RunningOutput)
+	a.pluginLock.Unlock()
+	require.NotSame(t, running, updated)
+	require.GreaterOrEqual(t, updated.BufferLength(), 2)
+}
@@ -0,0 +1,153 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/influxdata/telegraf/plugins/serializers/prometheus"
+	"github.com/influxdata/telegraf/selfstat"
+	"github.com/prometheus/common/expfmt"
+)
+
+// DefaultFailureTimeout is how long an output may keep failing before the
+// liveness check fails.
+const DefaultFailureTimeout = 5 * time.Minute
+
+/*
+DiagnosticsServer serves the internal state of the agent over HTTP.  It uses
+its own handler, the pprof endpoints are only served by --pprof-addr.
+
+	GET /metrics  the selfstat metrics in the OpenMetrics format
+	GET /plugins  the running plugins per pipeline as JSON
+	GET /readyz   200 if all outputs are connected and writing, 503 otherwise
+	GET /livez    200 unless an output keeps failing for FailureTimeout
+*/
+type DiagnosticsServer struct {
+	// FailureTimeout is how long an output may keep failing before the
+	// liveness check fails.
+	FailureTimeout time.Duration
+
+	address  string
+	agent    *Agent
+	server   *http.Server
+	listener net.Listener
+}
+
+// NewDiagnosticsServer returns a DiagnosticsServer listening on the host:port
+// address.
+func NewDiagnosticsServer(address string, agent *Agent) *DiagnosticsServer {
+	return &DiagnosticsServer{
+		FailureTimeout: DefaultFailureTimeout,
+		address:        address,
+		agent:          agent,
+	}
+}
+
+// Start begins listening for requests, the server runs until the context is
+// done.
+func (s *DiagnosticsServer) Start(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.address)
+	if err != nil {
+		return err
+	}
+
+	s.listener = listener
+	s.server = &http.Server{
+		Handler: s.routes(),
+	}
+
+	go func() {
+		err := s.server.Serve(listener)
+		if err != nil && err != http.ErrServerClosed {
+			log.Printf("E! [agent] Error serving diagnostics: %s", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		s.server.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("I! [agent] Diagnostics listening on http://%s", listener.Addr())
+	return nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *DiagnosticsServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+func (s *DiagnosticsServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", s.getOnly(s.serveMetrics))
+	mux.HandleFunc("/plugins", s.getOnly(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.agent.GetPluginGraph())
+	}))
+	mux.HandleFunc("/readyz", s.getOnly(func(w http.ResponseWriter, r *http.Request) {
+		writeHealthCheck(w, s.agent.Readiness())
+	}))
+	mux.HandleFunc("/livez", s.getOnly(func(w http.ResponseWriter, r *http.Request) {
+		writeHealthCheck(w, s.agent.Liveness(s.FailureTimeout))
+	}))
+	return mux
+}
+
+// getOnly rejects requests with a method other than GET or HEAD.
+func (s *DiagnosticsServer) getOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *DiagnosticsServer) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	coll := prometheus.NewCollection(prometheus.FormatConfig{
+		MetricSortOrder: prometheus.SortMetrics,
+	})
+	now := time.Now()
+	for _, m := range selfstat.Metrics() {
+		if m != nil {
+			coll.Add(m, now)
+		}
+	}
+
+	w.Header().Set("Content-Type", string(expfmt.FmtOpenMetrics))
+	enc := expfmt.NewEncoder(w, expfmt.FmtOpenMetrics)
+	for _, mf := range coll.GetProto() {
+		if err := enc.Encode(mf); err != nil {
+			log.Printf("E! [agent] Error while writing diagnostics metrics: %s", err)
+			return
+		}
+	}
+	if closer, ok := enc.(expfmt.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Printf("E! [agent] Error while writing diagnostics metrics: %s", err)
+		}
+	}
+}
+
+func writeHealthCheck(w http.ResponseWriter, check *HealthCheck) {
+	status := http.StatusOK
+	if !check.Healthy {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, check)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("E! [agent] Error while writing diagnostics response: %s", err)
+	}
+}
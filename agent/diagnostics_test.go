@@ -0,0 +1,145 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/models"
+	"github.com/influxdata/telegraf/selfstat"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// failingOutput fails all writes while fail is set.
+type failingOutput struct {
+	fail bool
+}
+
+func (o *failingOutput) SampleConfig() string { return "" }
+func (o *failingOutput) Description() string  { return "" }
+func (o *failingOutput) Connect() error       { return nil }
+func (o *failingOutput) Close() error         { return nil }
+func (o *failingOutput) Write(metrics []telegraf.Metric) error {
+	if o.fail {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func serveDiagnostics(t *testing.T, s *DiagnosticsServer, path string, v interface{}) (int, string) {
+	w := httptest.NewRecorder()
+	s.routes().ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	if v != nil {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), v))
+	}
+	return w.Code, w.Body.String()
+}
+
+func TestDiagnosticsServer(t *testing.T) {
+	out := &failingOutput{}
+	ro := models.NewRunningOutput("file", out, &models.OutputConfig{Name: "file"}, 10, 100, "out")
+
+	c := config.NewConfig()
+	c.Inputs = []*models.RunningInput{
+		newPipelineInput("default", ""),
+		newPipelineInput("team_a", "a"),
+	}
+	c.Processors = models.RunningProcessors{
+		newPathProcessor("b", 2),
+		newPathProcessor("a", 1),
+	}
+	c.Outputs = []*models.RunningOutput{ro}
+	c.Routes = []*models.Route{newRoute(t, "all", []string{"file"}, models.Filter{})}
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	s := NewDiagnosticsServer("localhost:0", a)
+
+	// The agent is not ready before the outputs are running.
+	var check HealthCheck
+	status, _ := serveDiagnostics(t, s, "/readyz", &check)
+	require.Equal(t, http.StatusServiceUnavailable, status)
+	require.Equal(t, []string{"no outputs are running"}, check.Errors)
+
+	for _, input := range c.Inputs {
+		a.runningPlugins[input.UniqueId] = input
+	}
+	for _, processor := range c.Processors {
+		a.runningPlugins[processor.UniqueId] = processor
+	}
+	a.runningPlugins[ro.UniqueId] = ro
+	ro.SetConnectionState(models.ConnectionStateConnected)
+
+	status, _ = serveDiagnostics(t, s, "/readyz", nil)
+	require.Equal(t, http.StatusOK, status)
+
+	var graph PluginGraph
+	status, _ = serveDiagnostics(t, s, "/plugins", &graph)
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, graph.Pipelines, 2)
+	require.Equal(t, "", graph.Pipelines[0].Name)
+	require.Equal(t, "default", graph.Pipelines[0].Inputs[0].UniqueId)
+	require.Equal(t, "a", graph.Pipelines[0].Processors[0].UniqueId)
+	require.Equal(t, "b", graph.Pipelines[0].Processors[1].UniqueId)
+	require.Equal(t, 100, graph.Pipelines[0].Outputs[0].Output.BufferLimit)
+	require.Equal(t, "a", graph.Pipelines[1].Name)
+	require.Equal(t, "team_a", graph.Pipelines[1].Inputs[0].UniqueId)
+	require.Empty(t, graph.Pipelines[1].Outputs)
+	require.Len(t, graph.Routes, 2)
+	require.Equal(t, []string{"out"}, graph.Routes[0].Outputs)
+	require.Equal(t, models.UnroutedRoute, graph.Routes[1].Name)
+
+	// A failed write makes the agent unready, but it is only reported dead
+	// once the output keeps failing.
+	out.fail = true
+	ro.AddMetric(testutil.TestMetric(42))
+	require.Error(t, ro.Write())
+
+	status, _ = serveDiagnostics(t, s, "/readyz", &check)
+	require.Equal(t, http.StatusServiceUnavailable, status)
+	require.Contains(t, check.Errors[0], "connection refused")
+
+	status, _ = serveDiagnostics(t, s, "/livez", nil)
+	require.Equal(t, http.StatusOK, status)
+
+	s.FailureTimeout = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	check = HealthCheck{}
+	status, _ = serveDiagnostics(t, s, "/livez", &check)
+	require.Equal(t, http.StatusServiceUnavailable, status)
+	require.False(t, check.Healthy)
+
+	out.fail = false
+	require.NoError(t, ro.Write())
+	status, _ = serveDiagnostics(t, s, "/livez", nil)
+	require.Equal(t, http.StatusOK, status)
+}
+
+func TestDiagnosticsServer_Metrics(t *testing.T) {
+	stat := selfstat.Register("diagnostics_test", "requests", map[string]string{"plugin": "test"})
+	stat.Set(42)
+
+	a, err := NewAgent(config.NewConfig())
+	require.NoError(t, err)
+	s := NewDiagnosticsServer("localhost:0", a)
+
+	w := httptest.NewRecorder()
+	s.routes().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "application/openmetrics-text"))
+
+	body := w.Body.String()
+	require.Contains(t, body, `internal_diagnostics_test_requests{plugin="test"} 42`)
+	require.True(t, strings.HasSuffix(body, "# EOF\n"))
+
+	w = httptest.NewRecorder()
+	s.routes().ServeHTTP(w, httptest.NewRequest("POST", "/metrics", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
@@ -0,0 +1,301 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/models"
+)
+
+// ErrNotRunning is returned when plugins are started, updated or stopped
+// while the pipelines of the agent are not running.
+var ErrNotRunning = errors.New("agent is not running")
+
+// defaultPipeline is the pipeline of the plugins without a pipeline setting
+// and of the plugins started while the agent is running.
+const defaultPipeline = ""
+
+// pipeline is an independent chain of units.  Metrics of the inputs feeding
+// the pipeline only pass through its processors and aggregators and are
+// written to its outputs.  Plugins without a pipeline setting belong to the
+// default pipeline named "".
+//
+//  ┌────────┐    ┌────────────┐    ┌─────────────┐    ┌────────────┐    ┌─────────┐
+//  │ Inputs │──▶ │ Processors │──▶ │ Aggregators │──▶ │ Processors │──▶ │ Outputs │
+//  └────────┘    └────────────┘    └─────────────┘    └────────────┘    └─────────┘
+type pipeline struct {
+	name string
+	iu   *inputUnit
+	pu   *processorUnit
+	au   *aggregatorUnit
+	apu  *processorUnit
+	ou   *outputUnit
+}
+
+// pipeline returns the running pipeline with the given name, or ErrNotRunning
+// if the pipelines of the agent are not running.
+func (a *Agent) pipeline(name string) (*pipeline, error) {
+	a.pipelinesLock.RLock()
+	defer a.pipelinesLock.RUnlock()
+
+	if a.pipelines == nil {
+		return nil, ErrNotRunning
+	}
+	p, ok := a.pipelines[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown pipeline %q", name)
+	}
+	return p, nil
+}
+
+// setPipelines replaces the running pipelines of the agent, the first call
+// with pipelines marks the agent as running.
+func (a *Agent) setPipelines(pipelines map[string]*pipeline) {
+	a.pipelinesLock.Lock()
+	a.pipelines = pipelines
+	a.pipelinesLock.Unlock()
+
+	if pipelines != nil {
+		a.runningOnce.Do(func() { close(a.running) })
+	}
+}
+
+// Running returns a channel that is closed once the pipelines of the agent
+// are running and plugins can be started, updated or stopped.
+func (a *Agent) Running() <-chan struct{} {
+	return a.running
+}
+
+// startPipeline connects the outputs of the pipeline and sets up its
+// processor and aggregator units, the returned channel is the source of the
+// pipeline the inputs write to.
+func (a *Agent) startPipeline(ctx context.Context, name string) (chan<- telegraf.Metric, *pipeline, error) {
+	if name != defaultPipeline {
+		if len(pipelineInputs(a.Config.Inputs, name)) == 0 {
+			log.Printf("W! [agent] Pipeline %q has no inputs", name)
+		}
+		if len(pipelineOutputs(a.Config.Outputs, name)) == 0 {
+			log.Printf("W! [agent] Pipeline %q has no outputs", name)
+		}
+	}
+
+	p := &pipeline{name: name}
+
+	next, ou, err := a.startOutputs(ctx, pipelineOutputs(a.Config.Outputs, name))
+	if err != nil {
+		return nil, nil, err
+	}
+	p.ou = ou
+
+	// The processor and aggregator units are always created so plugins can
+	// be added to them while the agent is running.
+	aggC, apu, err := a.startProcessors(next, pipelineProcessors(a.Config.AggProcessors, name))
+	if err != nil {
+		return nil, nil, err
+	}
+	p.apu = apu
+
+	next, au, err := a.startAggregators(aggC, next, pipelineAggregators(a.Config.Aggregators, name))
+	if err != nil {
+		return nil, nil, err
+	}
+	p.au = au
+
+	next, pu, err := a.startProcessors(next, pipelineProcessors(a.Config.Processors, name))
+	if err != nil {
+		return nil, nil, err
+	}
+	p.pu = pu
+
+	return next, p, nil
+}
+
+// runPipeline runs the units of the pipeline until the context is done.
+func (a *Agent) runPipeline(
+	ctx context.Context,
+	startTime time.Time,
+	p *pipeline,
+	wg *sync.WaitGroup,
+) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := a.runOutputs(p.ou)
+		if err != nil {
+			log.Printf("E! [agent] Error running outputs: %v", err)
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := a.runProcessors(p.apu)
+		if err != nil {
+			log.Printf("E! [agent] Error running processors: %v", err)
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := a.runAggregators(startTime, p.au)
+		if err != nil {
+			log.Printf("E! [agent] Error running aggregators: %v", err)
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := a.runProcessors(p.pu)
+		if err != nil {
+			log.Printf("E! [agent] Error running processors: %v", err)
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := a.runInputs(ctx, startTime, p.iu)
+		if err != nil {
+			log.Printf("E! [agent] Error running inputs: %v", err)
+		}
+	}()
+}
+
+// runTestPipeline is a variation of the pipeline for use in --test and
+// --once mode, the inputs gather once and the metrics that pass through the
+// processors and aggregators of the pipeline are written to dst.
+func (a *Agent) runTestPipeline(
+	ctx context.Context,
+	wait time.Duration,
+	startTime time.Time,
+	name string,
+	dst chan<- telegraf.Metric,
+	wg *sync.WaitGroup,
+) error {
+	next := dst
+	processors := pipelineProcessors(a.Config.Processors, name)
+	aggProcessors := pipelineProcessors(a.Config.AggProcessors, name)
+	aggregators := pipelineAggregators(a.Config.Aggregators, name)
+
+	var err error
+	var apu *processorUnit
+	var au *aggregatorUnit
+	if len(aggregators) != 0 {
+		procC := next
+		if len(aggProcessors) != 0 {
+			procC, apu, err = a.startProcessors(next, aggProcessors)
+			if err != nil {
+				return err
+			}
+		}
+
+		next, au, err = a.startAggregators(procC, next, aggregators)
+		if err != nil {
+			return err
+		}
+	}
+
+	var pu *processorUnit
+	if len(processors) != 0 {
+		next, pu, err = a.startProcessors(next, processors)
+		if err != nil {
+			return err
+		}
+	}
+
+	iu, err := a.testStartInputs(next, pipelineInputs(a.Config.Inputs, name))
+	if err != nil {
+		return err
+	}
+
+	if apu != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := a.runProcessors(apu)
+			if err != nil {
+				log.Printf("E! [agent] Error running processors: %v", err)
+			}
+		}()
+	}
+
+	if au != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := a.runAggregators(startTime, au)
+			if err != nil {
+				log.Printf("E! [agent] Error running aggregators: %v", err)
+			}
+		}()
+	}
+
+	if pu != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := a.runProcessors(pu)
+			if err != nil {
+				log.Printf("E! [agent] Error running processors: %v", err)
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := a.testRunInputs(ctx, wait, iu)
+		if err != nil {
+			log.Printf("E! [agent] Error running inputs: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+func pipelineInputs(inputs []*models.RunningInput, name string) []*models.RunningInput {
+	var selected []*models.RunningInput
+	for _, input := range inputs {
+		if input.Config.Pipeline == name {
+			selected = append(selected, input)
+		}
+	}
+	return selected
+}
+
+func pipelineProcessors(processors models.RunningProcessors, name string) models.RunningProcessors {
+	var selected models.RunningProcessors
+	for _, processor := range processors {
+		if processor.Config.Pipeline == name {
+			selected = append(selected, processor)
+		}
+	}
+	return selected
+}
+
+func pipelineAggregators(aggregators []*models.RunningAggregator, name string) []*models.RunningAggregator {
+	var selected []*models.RunningAggregator
+	for _, aggregator := range aggregators {
+		if aggregator.Config.Pipeline == name {
+			selected = append(selected, aggregator)
+		}
+	}
+	return selected
+}
+
+func pipelineOutputs(outputs []*models.RunningOutput, name string) []*models.RunningOutput {
+	var selected []*models.RunningOutput
+	for _, output := range outputs {
+		if output.Config.Pipeline == name {
+			selected = append(selected, output)
+		}
+	}
+	return selected
+}
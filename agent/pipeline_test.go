@@ -0,0 +1,107 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/models"
+	"github.com/stretchr/testify/require"
+)
+
+// nameInput gathers a single metric with its name.
+type nameInput struct {
+	name string
+}
+
+func (i *nameInput) SampleConfig() string { return "" }
+func (i *nameInput) Description() string  { return "" }
+func (i *nameInput) Gather(acc telegraf.Accumulator) error {
+	acc.AddFields(i.name, map[string]interface{}{"value": 1}, nil)
+	return nil
+}
+
+func newPipelineInput(name, pipeline string) *models.RunningInput {
+	return models.NewRunningInput(&nameInput{name: name},
+		&models.InputConfig{Name: name, Pipeline: pipeline}, name)
+}
+
+func newPipelineProcessor(name, pipeline string) *models.RunningProcessor {
+	processor := newPathProcessor(name, 0)
+	processor.Config.Pipeline = pipeline
+	return processor
+}
+
+func TestAgent_Pipelines(t *testing.T) {
+	c := config.NewConfig()
+	c.Inputs = []*models.RunningInput{
+		newPipelineInput("default", ""),
+		newPipelineInput("team_a", "a"),
+		newPipelineInput("team_b", "b"),
+	}
+	c.Processors = models.RunningProcessors{
+		newPipelineProcessor("d", ""),
+		newPipelineProcessor("a", "a"),
+		newPipelineProcessor("b", "b"),
+		newPipelineProcessor("c", "b"),
+	}
+	require.Equal(t, []string{"", "a", "b"}, c.Pipelines())
+
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	outputC := make(chan telegraf.Metric, 10)
+	require.NoError(t, a.test(context.Background(), 0, outputC))
+
+	paths := make(map[string]string)
+	for m := range outputC {
+		path, _ := m.GetTag("path")
+		paths[m.Name()] = path
+	}
+
+	// The metrics only pass through the processors of their pipeline.
+	require.Equal(t, map[string]string{
+		"default": "d",
+		"team_a":  "a",
+		"team_b":  "bc",
+	}, paths)
+}
+
+func TestAgent_NotRunning(t *testing.T) {
+	defer os.Remove("./updated_config.conf")
+	c := loadReloadConfig(t, `
+[[inputs.mem]]
+
+[[outputs.discard]]
+`, nil)
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	// Plugins cannot be started before the pipelines exist.
+	_, err = a.StartProcessor("printer")
+	require.Equal(t, ErrNotRunning, err)
+	_, err = a.StartInput(context.Background(), "mem")
+	require.Equal(t, ErrNotRunning, err)
+	select {
+	case <-a.Running():
+		require.Fail(t, "agent is running before Run")
+	default:
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = a.Run(ctx)
+	}()
+
+	select {
+	case <-a.Running():
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "agent is not running")
+	}
+	_, err = a.pipeline(defaultPipeline)
+	require.NoError(t, err)
+}
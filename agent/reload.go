@@ -0,0 +1,311 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/models"
+)
+
+// ErrRestartRequired is returned by ReloadConfig when settings other than the
+// plugins changed, these are only applied by restarting the agent.
+var ErrRestartRequired = errors.New("agent settings, global tags, routes, trace or pipelines changed, restart required")
+
+// ReloadResult lists the unique ids of the plugins changed by a reload.
+type ReloadResult struct {
+	Started   []string
+	Stopped   []string
+	Unchanged int
+}
+
+// ReloadConfig applies a newly loaded config to the running agent.  Plugins
+// are matched to the plugins of the current config by their unique id and
+// settings, only plugins that were added, removed or changed are stopped or
+// started.  Plugins started while the agent is running are not touched.
+//
+// The new config should be loaded after calling ReuseUniqueIds with the
+// current config, so that unchanged plugins keep their ids.  The outputs of
+// the new config that are not started are discarded.
+func (a *Agent) ReloadConfig(c *config.Config) (*ReloadResult, error) {
+	a.changeLock.Lock()
+	defer a.changeLock.Unlock()
+
+	if !reflect.DeepEqual(a.Config.Agent, c.Agent) || !reflect.DeepEqual(a.Config.Tags, c.Tags) ||
+		a.Config.RoutesFingerprint() != c.RoutesFingerprint() ||
+		a.Config.TraceFingerprint() != c.TraceFingerprint() ||
+		!reflect.DeepEqual(a.Config.Pipelines(), c.Pipelines()) {
+		c.Discard()
+		return nil, ErrRestartRequired
+	}
+
+	previous := a.Config.Fingerprints()
+	current := c.Fingerprints()
+	changed := func(uid string) bool {
+		return previous[uid] != current[uid]
+	}
+
+	result := &ReloadResult{}
+	var errs []string
+	addErr := func(uid string, err error) {
+		log.Printf("E! [agent] Could not reload plugin %s: %v", uid, err)
+		errs = append(errs, fmt.Sprintf("%s: %v", uid, err))
+	}
+
+	// Changed outputs take over the unsent metrics of the outputs they
+	// replace.
+	a.takeOverBuffers(c.Outputs, previous, changed)
+
+	// Plugins that were removed or changed are stopped in the same order as
+	// on shutdown, inputs first and outputs last.
+	for _, uid := range a.reloadStopOrder(previous) {
+		if !changed(uid) {
+			result.Unchanged++
+			continue
+		}
+		if err := a.stopReloadedPlugin(uid); err != nil {
+			addErr(uid, err)
+			continue
+		}
+		result.Stopped = append(result.Stopped, uid)
+	}
+
+	// New and changed plugins are started in the same order as on startup.
+	for _, output := range c.Outputs {
+		if !changed(output.UniqueId) {
+			// The running instance is kept.
+			output.Discard()
+			continue
+		}
+		if err := a.startReloadedOutput(output); err != nil {
+			addErr(output.UniqueId, err)
+			delete(current, output.UniqueId)
+			output.Discard()
+			continue
+		}
+		result.Started = append(result.Started, output.UniqueId)
+	}
+
+	for _, processor := range c.Processors {
+		if !changed(processor.UniqueId) {
+			continue
+		}
+		var aggProcessor *models.RunningProcessor
+		for _, rp := range c.AggProcessors {
+			if rp.UniqueId == processor.UniqueId {
+				aggProcessor = rp
+			}
+		}
+		if err := a.startReloadedProcessor(processor, aggProcessor); err != nil {
+			addErr(processor.UniqueId, err)
+			delete(current, processor.UniqueId)
+			continue
+		}
+		result.Started = append(result.Started, processor.UniqueId)
+	}
+
+	for _, aggregator := range c.Aggregators {
+		if !changed(aggregator.UniqueId) {
+			continue
+		}
+		if err := a.startReloadedAggregator(aggregator); err != nil {
+			addErr(aggregator.UniqueId, err)
+			delete(current, aggregator.UniqueId)
+			continue
+		}
+		result.Started = append(result.Started, aggregator.UniqueId)
+	}
+
+	for _, input := range c.Inputs {
+		if !changed(input.UniqueId) {
+			continue
+		}
+		if err := a.startReloadedInput(input); err != nil {
+			addErr(input.UniqueId, err)
+			delete(current, input.UniqueId)
+			continue
+		}
+		result.Started = append(result.Started, input.UniqueId)
+	}
+
+	// Plugins that failed to start are retried by the next reload.
+	a.Config.SetFingerprints(current)
+	// The started plugins were configured with the secrets of the new config,
+	// which are needed to redact them.
+	a.Config.AdoptSecrets(c, result.Started)
+
+	if len(errs) != 0 {
+		return result, fmt.Errorf("could not reload all plugins: %s", strings.Join(errs, "; "))
+	}
+	return result, nil
+}
+
+// takeOverBuffers hands the buffers of the running outputs that are stopped
+// by the reload over to the changed outputs with the same buffer path, that
+// is the same name and alias or buffer id.
+func (a *Agent) takeOverBuffers(outputs []*models.RunningOutput, previous map[string]string, changed func(string) bool) {
+	a.pluginLock.Lock()
+	defer a.pluginLock.Unlock()
+
+	taken := make(map[string]bool)
+	for _, output := range outputs {
+		if !changed(output.UniqueId) {
+			continue
+		}
+		for uid := range previous {
+			ro, ok := a.runningPlugins[uid].(*models.RunningOutput)
+			if !ok || taken[uid] || !changed(uid) || ro.Config.BufferPath() != output.Config.BufferPath() {
+				continue
+			}
+			output.TakeBuffer(ro)
+			taken[uid] = true
+			break
+		}
+	}
+}
+
+// reloadStopOrder returns the unique ids in the order their plugins are
+// stopped: inputs, processors, aggregators and then outputs.  Plugins that are
+// no longer running are listed last.
+func (a *Agent) reloadStopOrder(fingerprints map[string]string) []string {
+	var inputs, processors, aggregators, outputs, stopped []string
+
+	a.pluginLock.Lock()
+	for uid := range fingerprints {
+		switch a.runningPlugins[uid].(type) {
+		case *models.RunningInput:
+			inputs = append(inputs, uid)
+		case *models.RunningProcessor:
+			processors = append(processors, uid)
+		case *models.RunningAggregator:
+			aggregators = append(aggregators, uid)
+		case *models.RunningOutput:
+			outputs = append(outputs, uid)
+		default:
+			stopped = append(stopped, uid)
+		}
+	}
+	a.pluginLock.Unlock()
+
+	order := append(inputs, processors...)
+	order = append(order, aggregators...)
+	order = append(order, outputs...)
+	return append(order, stopped...)
+}
+
+// stopReloadedPlugin stops the plugin with the unique id, plugins that were
+// already stopped are skipped.
+func (a *Agent) stopReloadedPlugin(uid string) error {
+	a.pluginLock.Lock()
+	plugin := a.runningPlugins[uid]
+	a.pluginLock.Unlock()
+
+	switch p := plugin.(type) {
+	case *models.RunningInput:
+		if si, ok := p.Input.(telegraf.ServiceInput); ok {
+			si.Stop()
+		}
+		return a.stopInputPlugin(uid, false)
+	case *models.RunningProcessor:
+		return a.stopProcessorPlugin(uid, false)
+	case *models.RunningAggregator:
+		return a.stopAggregatorPlugin(uid, false)
+	case *models.RunningOutput:
+		return a.stopOutputPlugin(uid, false)
+	}
+	return nil
+}
+
+func (a *Agent) startReloadedInput(input *models.RunningInput) error {
+	if err := input.Init(); err != nil {
+		return fmt.Errorf("could not initialize input %s: %v", input.LogName(), err)
+	}
+	p, err := a.pipeline(input.Config.Pipeline)
+	if err != nil {
+		return err
+	}
+	if err := a.startServiceInput(input, p.iu.dst); err != nil {
+		return fmt.Errorf("starting input %s: %w", input.LogName(), err)
+	}
+	if err := a.RunSingleInput(input, a.Context); err != nil {
+		return err
+	}
+	p.iu.inputs = append(p.iu.inputs, input)
+	return nil
+}
+
+func (a *Agent) startReloadedOutput(output *models.RunningOutput) error {
+	p, err := a.pipeline(output.Config.Pipeline)
+	if err != nil {
+		return err
+	}
+	if err := output.Init(); err != nil {
+		return fmt.Errorf("could not initialize output %s: %v", output.LogName(), err)
+	}
+	if err := a.connectOutput(a.Context, output); err != nil {
+		return err
+	}
+	if err := a.RunSingleOutput(output, a.Context); err != nil {
+		return err
+	}
+	p.ou.outputs = append(p.ou.outputs, output)
+	return nil
+}
+
+// startReloadedProcessor adds the processor to the processor chain and its
+// copy to the chain following the aggregators.
+func (a *Agent) startReloadedProcessor(processor, aggProcessor *models.RunningProcessor) error {
+	for _, rp := range []*models.RunningProcessor{processor, aggProcessor} {
+		if err := rp.Init(); err != nil {
+			return fmt.Errorf("could not initialize processor %s: %v", rp.LogName(), err)
+		}
+	}
+
+	p, err := a.pipeline(processor.Config.Pipeline)
+	if err != nil {
+		return err
+	}
+	if err := p.pu.add(processor); err != nil {
+		return err
+	}
+	if err := p.apu.add(aggProcessor); err != nil {
+		p.pu.remove(processor)
+		return err
+	}
+
+	a.Config.ProcessorsLock.Lock()
+	a.Config.Processors = append(a.Config.Processors, processor)
+	a.Config.AggProcessors = append(a.Config.AggProcessors, aggProcessor)
+	a.Config.ProcessorsLock.Unlock()
+
+	a.pluginLock.Lock()
+	a.runningPlugins[processor.UniqueId] = processor
+	a.pluginLock.Unlock()
+	return nil
+}
+
+func (a *Agent) startReloadedAggregator(aggregator *models.RunningAggregator) error {
+	if err := aggregator.Init(); err != nil {
+		return fmt.Errorf("could not initialize aggregator %s: %v", aggregator.LogName(), err)
+	}
+	p, err := a.pipeline(aggregator.Config.Pipeline)
+	if err != nil {
+		return err
+	}
+	if err := a.addAggregator(p.au, aggregator); err != nil {
+		return err
+	}
+
+	a.Config.AggregatorsLock.Lock()
+	a.Config.Aggregators = append(a.Config.Aggregators, aggregator)
+	a.Config.AggregatorsLock.Unlock()
+
+	a.pluginLock.Lock()
+	a.runningPlugins[aggregator.UniqueId] = aggregator
+	a.pluginLock.Unlock()
+	return nil
+}
@@ -0,0 +1,224 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/models"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func loadReloadConfig(t *testing.T, data string, previous *config.Config) *config.Config {
+	dir, err := ioutil.TempDir("", "telegraf-reload")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "telegraf.conf")
+	require.NoError(t, ioutil.WriteFile(path, []byte(data), 0644))
+
+	c := config.NewConfig()
+	if previous != nil {
+		c.ReuseUniqueIds(previous)
+	}
+	require.NoError(t, c.LoadConfig(path))
+	return c
+}
+
+// inputId returns the unique id of the input, the order of the inputs of a
+// loaded config is not stable.
+func inputId(c *config.Config, name string) string {
+	for _, input := range c.Inputs {
+		if input.Config.Name == name {
+			return input.UniqueId
+		}
+	}
+	return ""
+}
+
+func (a *Agent) isRunning(uid string) bool {
+	a.pluginLock.Lock()
+	defer a.pluginLock.Unlock()
+	_, ok := a.runningPlugins[uid]
+	return ok
+}
+
+func TestAgent_ReloadConfig(t *testing.T) {
+	defer os.Remove("./updated_config.conf")
+	c := loadReloadConfig(t, `
+[[inputs.cpu]]
+  percpu = true
+
+[[inputs.mem]]
+
+[[outputs.discard]]
+`, nil)
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = a.Run(ctx)
+	}()
+
+	cpuId := inputId(c, "cpu")
+	memId := inputId(c, "mem")
+	outputId := c.Outputs[0].UniqueId
+	require.Eventually(t, func() bool {
+		return a.isRunning(cpuId) && a.isRunning(memId) && a.isRunning(outputId)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Only the changed input is restarted.
+	c2 := loadReloadConfig(t, `
+[[inputs.cpu]]
+  percpu = false
+
+[[inputs.mem]]
+
+[[outputs.discard]]
+`, a.Config)
+	require.Equal(t, memId, inputId(c2, "mem"))
+	require.Equal(t, outputId, c2.Outputs[0].UniqueId)
+
+	newCpuId := inputId(c2, "cpu")
+	require.NotEqual(t, cpuId, newCpuId)
+
+	result, err := a.ReloadConfig(c2)
+	require.NoError(t, err)
+	require.Equal(t, []string{newCpuId}, result.Started)
+	require.Equal(t, []string{cpuId}, result.Stopped)
+	require.Equal(t, 2, result.Unchanged)
+
+	require.Eventually(t, func() bool {
+		return !a.isRunning(cpuId) && a.isRunning(newCpuId)
+	}, 5*time.Second, 10*time.Millisecond)
+	require.True(t, a.isRunning(memId))
+	require.True(t, a.isRunning(outputId))
+
+	// Reloading the same config again changes nothing.
+	c3 := loadReloadConfig(t, `
+[[inputs.cpu]]
+  percpu = false
+
+[[inputs.mem]]
+
+[[outputs.discard]]
+`, a.Config)
+	result, err = a.ReloadConfig(c3)
+	require.NoError(t, err)
+	require.Empty(t, result.Started)
+	require.Empty(t, result.Stopped)
+	require.Equal(t, 3, result.Unchanged)
+}
+
+func TestAgent_ReloadConfig_AgentSettingsChanged(t *testing.T) {
+	defer os.Remove("./updated_config.conf")
+	c := loadReloadConfig(t, `
+[agent]
+  interval = "10s"
+
+[[inputs.mem]]
+`, nil)
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	c2 := loadReloadConfig(t, `
+[agent]
+  interval = "20s"
+
+[[inputs.mem]]
+`, a.Config)
+	_, err = a.ReloadConfig(c2)
+	require.Equal(t, ErrRestartRequired, err)
+}
+
+func TestAgent_ReloadConfig_RoutesChanged(t *testing.T) {
+	defer os.Remove("./updated_config.conf")
+	c := loadReloadConfig(t, `
+[[outputs.file]]
+
+[[routes]]
+  name = "cpu"
+  outputs = ["file"]
+  namepass = ["cpu"]
+`, nil)
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	c2 := loadReloadConfig(t, `
+[[outputs.file]]
+
+[[routes]]
+  name = "cpu"
+  outputs = ["file"]
+  namepass = ["cpu", "mem"]
+`, a.Config)
+	_, err = a.ReloadConfig(c2)
+	require.Equal(t, ErrRestartRequired, err)
+}
+
+func TestAgent_ReloadConfig_OutputKeepsBuffer(t *testing.T) {
+	defer os.Remove("./updated_config.conf")
+	dir := t.TempDir()
+	data := `
+[agent]
+  buffer_strategy = "disk"
+  buffer_directory = "` + dir + `"
+
+[[inputs.mem]]
+
+[[outputs.http]]
+  url = "http://localhost:1"
+  timeout = "%s"
+`
+	c := loadReloadConfig(t, fmt.Sprintf(data, "5s"), nil)
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = a.Run(ctx)
+	}()
+
+	outputId := c.Outputs[0].UniqueId
+	require.Eventually(t, func() bool {
+		return a.isRunning(outputId)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	a.pluginLock.Lock()
+	running := a.runningPlugins[outputId].(*models.RunningOutput)
+	a.pluginLock.Unlock()
+	running.AddMetric(testutil.TestMetric(1))
+	running.AddMetric(testutil.TestMetric(2))
+
+	// A config that is not applied does not touch the buffer.
+	c2 := loadReloadConfig(t, strings.Replace(fmt.Sprintf(data, "5s"), "[agent]", "[agent]\n  interval = \"20s\"", 1), a.Config)
+	_, err = a.ReloadConfig(c2)
+	require.Equal(t, ErrRestartRequired, err)
+	require.GreaterOrEqual(t, running.BufferLength(), 2)
+
+	// The changed output takes over the unsent metrics.
+	c3 := loadReloadConfig(t, fmt.Sprintf(data, "10s"), a.Config)
+	newOutputId := c3.Outputs[0].UniqueId
+	require.NotEqual(t, outputId, newOutputId)
+	_, err = a.ReloadConfig(c3)
+	require.NoError(t, err)
+
+	a.pluginLock.Lock()
+	updated := a.runningPlugins[newOutputId].(*models.RunningOutput)
+	a.pluginLock.Unlock()
+	require.GreaterOrEqual(t, updated.BufferLength(), 2)
+
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
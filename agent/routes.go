@@ -0,0 +1,112 @@
+package agent
+
+import (
+	"log"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/models"
+)
+
+// initRoutes splits the configured routes from the fallback route, metrics
+// that match no route are dropped when no fallback route is configured.
+func (a *Agent) initRoutes() {
+	a.routes = nil
+	a.unrouted = nil
+	if len(a.Config.Routes) == 0 {
+		return
+	}
+
+	for _, route := range a.Config.Routes {
+		if route.IsUnrouted() {
+			a.unrouted = route
+			continue
+		}
+		a.routes = append(a.routes, route)
+	}
+	if a.unrouted == nil {
+		a.unrouted = models.NewRoute(&models.RouteConfig{Name: models.UnroutedRoute})
+	}
+
+	for _, route := range a.Config.Routes {
+		for _, ref := range route.Config.Outputs {
+			if !hasOutputRef(a.Config.Outputs, ref) {
+				log.Printf("W! [agent] Route %q references unknown output %q", route.Config.Name, ref)
+			}
+		}
+	}
+}
+
+func hasOutputRef(outputs []*models.RunningOutput, ref string) bool {
+	for _, output := range outputs {
+		if models.IsOutputRef(output, ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// routeMetric returns the outputs the metric is written to.  Without routes
+// all outputs receive the metric, otherwise the metric is sent to the outputs
+// of every route it matches, or to the outputs of the fallback route if it
+// matches none.  A metric routed to no output is dropped.
+func (a *Agent) routeMetric(metric telegraf.Metric, outputs []*models.RunningOutput) []*models.RunningOutput {
+	if a.unrouted == nil {
+		return outputs
+	}
+
+	var matched []*models.Route
+	for _, route := range a.routes {
+		if route.Match(metric) {
+			matched = append(matched, route)
+		}
+	}
+	if len(matched) == 0 {
+		matched = append(matched, a.unrouted)
+	}
+
+	var routed []*models.RunningOutput
+	for _, route := range matched {
+		n := 0
+		for _, output := range outputs {
+			if !route.HasOutput(output) {
+				continue
+			}
+			n++
+			if !containsOutput(routed, output) {
+				routed = append(routed, output)
+			}
+		}
+		if n > 0 {
+			route.MetricsRouted.Incr(1)
+		} else {
+			route.MetricsDropped.Incr(1)
+		}
+	}
+
+	if trace := models.TraceMetric(metric, "routes", ""); trace != nil {
+		names := make([]string, 0, len(matched))
+		for _, route := range matched {
+			names = append(names, route.Config.Name)
+		}
+		if len(routed) == 0 {
+			trace.Done(models.TraceDropped, "no outputs in routes "+strings.Join(names, ", "), nil)
+		} else {
+			trace.Done(models.TraceRouted, "routes "+strings.Join(names, ", "), metric)
+		}
+	}
+
+	if len(routed) == 0 {
+		metric.Drop()
+	}
+	return routed
+}
+
+func containsOutput(outputs []*models.RunningOutput, output *models.RunningOutput) bool {
+	for _, ro := range outputs {
+		if ro == output {
+			return true
+		}
+	}
+	return false
+}
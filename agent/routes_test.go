@@ -0,0 +1,82 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/models"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func newRoute(t *testing.T, name string, outputs []string, filter models.Filter) *models.Route {
+	require.NoError(t, filter.Compile())
+	return models.NewRoute(&models.RouteConfig{Name: name, Outputs: outputs, Filter: filter})
+}
+
+func TestAgent_RouteMetric(t *testing.T) {
+	local := &models.RunningOutput{Config: &models.OutputConfig{Name: "influxdb", Alias: "local"}}
+	eu := &models.RunningOutput{Config: &models.OutputConfig{Name: "influxdb", Alias: "eu"}}
+	file := &models.RunningOutput{Config: &models.OutputConfig{Name: "file"}}
+	outputs := []*models.RunningOutput{local, eu, file}
+
+	c := config.NewConfig()
+	c.Outputs = outputs
+	c.Routes = []*models.Route{
+		newRoute(t, "system", []string{"local"}, models.Filter{NamePass: []string{"cpu"}}),
+		newRoute(t, "eu", []string{"eu", "local"}, models.Filter{
+			TagPass: []models.TagFilter{{Name: "region", Filter: []string{"eu-*"}}},
+		}),
+		newRoute(t, "stopped", []string{"stopped"}, models.Filter{NamePass: []string{"mem"}}),
+	}
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	cpu := testutil.MustMetric("cpu",
+		map[string]string{"region": "eu-west"},
+		map[string]interface{}{"usage_idle": 42.0},
+		time.Unix(0, 0))
+	require.Equal(t, []*models.RunningOutput{local, eu}, a.routeMetric(cpu, outputs))
+
+	disk := testutil.MustMetric("disk",
+		map[string]string{"region": "us-east"},
+		map[string]interface{}{"used": 42},
+		time.Unix(0, 0))
+	require.Empty(t, a.routeMetric(disk, outputs))
+
+	mem := testutil.MustMetric("mem",
+		map[string]string{},
+		map[string]interface{}{"used": 42},
+		time.Unix(0, 0))
+	require.Empty(t, a.routeMetric(mem, outputs))
+
+	require.Equal(t, int64(1), c.Routes[0].MetricsRouted.Get())
+	require.Equal(t, int64(1), c.Routes[1].MetricsRouted.Get())
+	require.Equal(t, int64(1), c.Routes[2].MetricsDropped.Get())
+	require.Equal(t, int64(1), a.unrouted.MetricsDropped.Get())
+
+	// Metrics matching no route are sent to the fallback route.
+	c.Routes = append(c.Routes, newRoute(t, models.UnroutedRoute, []string{"file"}, models.Filter{}))
+	a, err = NewAgent(c)
+	require.NoError(t, err)
+	require.Equal(t, []*models.RunningOutput{file}, a.routeMetric(disk, outputs))
+	require.Equal(t, int64(1), a.unrouted.MetricsRouted.Get())
+}
+
+func TestAgent_RouteMetricWithoutRoutes(t *testing.T) {
+	outputs := []*models.RunningOutput{
+		{Config: &models.OutputConfig{Name: "influxdb"}},
+		{Config: &models.OutputConfig{Name: "file"}},
+	}
+	c := config.NewConfig()
+	c.Outputs = outputs
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	m := testutil.MustMetric("cpu",
+		map[string]string{},
+		map[string]interface{}{"usage_idle": 42.0},
+		time.Unix(0, 0))
+	require.Equal(t, outputs, a.routeMetric(m, outputs))
+}
@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	httpOut "github.com/influxdata/telegraf/plugins/outputs/http"
+	"github.com/influxdata/telegraf/plugins/secretstores/keyring"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgent_PluginSecrets(t *testing.T) {
+	defer os.Remove("./updated_config.conf")
+
+	dir, err := ioutil.TempDir("", "telegraf-keyring")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store := &keyring.Keyring{Service: "telegraf", Directory: dir}
+	require.NoError(t, store.Init())
+	require.NoError(t, store.Set("password", "s3cr3t"))
+	require.NoError(t, store.Set("rotated", "n3w"))
+
+	c := loadReloadConfig(t, fmt.Sprintf(`
+[[secretstores.keyring]]
+  id = "kr"
+  directory = %q
+
+[[inputs.mem]]
+
+[[outputs.http]]
+  url = "http://localhost:1"
+  password = "@{kr:password}"
+`, dir), nil)
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = a.Run(ctx)
+	}()
+
+	uid := c.Outputs[0].UniqueId
+	require.Eventually(t, func() bool {
+		return a.isRunning(uid)
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, "s3cr3t", c.Outputs[0].Output.(*httpOut.HTTP).Password)
+
+	// The secret is not returned, only its reference.
+	values, err := a.GetRunningPlugin(uid)
+	require.NoError(t, err)
+	require.Equal(t, "@{kr:password}", values["Password"])
+
+	// Updates may reference secrets.
+	output, err := a.UpdateOutputPlugin(uid, map[string]interface{}{"Password": "@{kr:rotated}"})
+	require.NoError(t, err)
+	require.Equal(t, "n3w", output.(*httpOut.HTTP).Password)
+
+	values, err = a.GetRunningPlugin(uid)
+	require.NoError(t, err)
+	require.Equal(t, "@{kr:rotated}", values["Password"])
+
+	_, err = a.UpdateOutputPlugin(uid, map[string]interface{}{"Password": "@{kr:missing}"})
+	require.Error(t, err)
+}
@@ -0,0 +1,288 @@
+package agent
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/influxdata/telegraf/models"
+)
+
+// PluginStatus is a snapshot of the health of a running plugin, only the
+// status matching the type of the plugin is set.
+type PluginStatus struct {
+	UniqueId string    `json:"unique_id"`
+	Type     string    `json:"type"`
+	Name     string    `json:"name"`
+	Alias    string    `json:"alias,omitempty"`
+	Time     time.Time `json:"time"`
+
+	Input      *models.InputStatus      `json:"input,omitempty"`
+	Output     *models.OutputStatus     `json:"output,omitempty"`
+	Processor  *models.ProcessorStatus  `json:"processor,omitempty"`
+	Aggregator *models.AggregatorStatus `json:"aggregator,omitempty"`
+}
+
+// GetPluginStatus returns the status of the running plugin with the unique id.
+func (a *Agent) GetPluginStatus(uid string) (*PluginStatus, error) {
+	a.pluginLock.Lock()
+	obj, exists := a.runningPlugins[uid]
+	a.pluginLock.Unlock()
+	if !exists {
+		return nil, fmt.Errorf("specified plugin is not running")
+	}
+	return pluginStatus(uid, obj, time.Now())
+}
+
+// GetPluginStatuses returns the status of all running plugins, ordered by
+// their unique id.
+func (a *Agent) GetPluginStatuses() []*PluginStatus {
+	now := time.Now()
+
+	a.pluginLock.Lock()
+	statuses := make([]*PluginStatus, 0, len(a.runningPlugins))
+	for uid, obj := range a.runningPlugins {
+		status, err := pluginStatus(uid, obj, now)
+		if err != nil {
+			continue
+		}
+		statuses = append(statuses, status)
+	}
+	a.pluginLock.Unlock()
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].UniqueId < statuses[j].UniqueId
+	})
+	return statuses
+}
+
+func pluginStatus(uid string, obj interface{}, now time.Time) (*PluginStatus, error) {
+	status := &PluginStatus{UniqueId: uid, Time: now}
+
+	switch p := obj.(type) {
+	case *models.RunningInput:
+		s := p.Status()
+		status.Type = "INPUT"
+		status.Name = p.Config.Name
+		status.Alias = p.Config.Alias
+		status.Input = &s
+	case *models.RunningOutput:
+		s := p.Status()
+		status.Type = "OUTPUT"
+		status.Name = p.Config.Name
+		status.Alias = p.Config.Alias
+		status.Output = &s
+	case *models.RunningProcessor:
+		s := p.Status()
+		status.Type = "PROCESSOR"
+		status.Name = p.Config.Name
+		status.Alias = p.Config.Alias
+		status.Processor = &s
+	case *models.RunningAggregator:
+		s := p.Status()
+		status.Type = "AGGREGATOR"
+		status.Name = p.Config.Name
+		status.Alias = p.Config.Alias
+		status.Aggregator = &s
+	default:
+		return nil, fmt.Errorf("invalid running plugin")
+	}
+	return status, nil
+}
+
+// PluginGraph is the layout of the running plugins.  Metrics flow from the
+// inputs of a pipeline through its processors and aggregators to its
+// outputs, or to the outputs of the routes they match when routes are
+// configured.
+type PluginGraph struct {
+	Pipelines []*PipelineGraph `json:"pipelines"`
+	Routes    []*RouteGraph    `json:"routes,omitempty"`
+}
+
+// PipelineGraph lists the running plugins of a pipeline.  Processors are
+// listed in the order they are applied, they run both before and after the
+// aggregators.
+type PipelineGraph struct {
+	Name        string          `json:"name"`
+	Inputs      []*PluginStatus `json:"inputs"`
+	Processors  []*PluginStatus `json:"processors"`
+	Aggregators []*PluginStatus `json:"aggregators"`
+	Outputs     []*PluginStatus `json:"outputs"`
+}
+
+// RouteGraph describes a route and the unique ids of the outputs it sends
+// metrics to.
+type RouteGraph struct {
+	Name           string   `json:"name"`
+	Outputs        []string `json:"outputs"`
+	MetricsRouted  int64    `json:"metrics_routed"`
+	MetricsDropped int64    `json:"metrics_dropped"`
+}
+
+// GetPluginGraph returns the running plugins grouped by pipeline, ordered by
+// pipeline name.
+func (a *Agent) GetPluginGraph() *PluginGraph {
+	statuses := a.GetPluginStatuses()
+
+	order := make(map[string]int64)
+	var outputs []*models.RunningOutput
+	pipelines := make(map[string]*PipelineGraph)
+	a.pluginLock.Lock()
+	for _, status := range statuses {
+		var name string
+		switch p := a.runningPlugins[status.UniqueId].(type) {
+		case *models.RunningInput:
+			name = p.Config.Pipeline
+		case *models.RunningOutput:
+			name = p.Config.Pipeline
+			outputs = append(outputs, p)
+		case *models.RunningProcessor:
+			name = p.Config.Pipeline
+			order[status.UniqueId] = p.Config.Order
+		case *models.RunningAggregator:
+			name = p.Config.Pipeline
+		default:
+			continue
+		}
+
+		pg, ok := pipelines[name]
+		if !ok {
+			pg = &PipelineGraph{
+				Name:        name,
+				Inputs:      []*PluginStatus{},
+				Processors:  []*PluginStatus{},
+				Aggregators: []*PluginStatus{},
+				Outputs:     []*PluginStatus{},
+			}
+			pipelines[name] = pg
+		}
+
+		switch status.Type {
+		case "INPUT":
+			pg.Inputs = append(pg.Inputs, status)
+		case "OUTPUT":
+			pg.Outputs = append(pg.Outputs, status)
+		case "PROCESSOR":
+			pg.Processors = append(pg.Processors, status)
+		case "AGGREGATOR":
+			pg.Aggregators = append(pg.Aggregators, status)
+		}
+	}
+	a.pluginLock.Unlock()
+
+	graph := &PluginGraph{Pipelines: make([]*PipelineGraph, 0, len(pipelines))}
+	for _, pg := range pipelines {
+		sort.SliceStable(pg.Processors, func(i, j int) bool {
+			return order[pg.Processors[i].UniqueId] < order[pg.Processors[j].UniqueId]
+		})
+		graph.Pipelines = append(graph.Pipelines, pg)
+	}
+	sort.Slice(graph.Pipelines, func(i, j int) bool {
+		return graph.Pipelines[i].Name < graph.Pipelines[j].Name
+	})
+
+	if a.unrouted == nil {
+		return graph
+	}
+	for _, route := range append(a.routes, a.unrouted) {
+		rg := &RouteGraph{
+			Name:           route.Config.Name,
+			Outputs:        []string{},
+			MetricsRouted:  route.MetricsRouted.Get(),
+			MetricsDropped: route.MetricsDropped.Get(),
+		}
+		for _, output := range outputs {
+			for _, ref := range route.Config.Outputs {
+				if models.IsOutputRef(output, ref) {
+					rg.Outputs = append(rg.Outputs, output.UniqueId)
+					break
+				}
+			}
+		}
+		graph.Routes = append(graph.Routes, rg)
+	}
+	return graph
+}
+
+// HealthCheck is the result of a readiness or liveness check, Errors
+// describes why the check failed.
+type HealthCheck struct {
+	Healthy bool     `json:"healthy"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// Readiness checks that the agent is running outputs, and that all of them
+// are connected and their last write succeeded.
+func (a *Agent) Readiness() *HealthCheck {
+	check := &HealthCheck{}
+	outputs := 0
+	for _, status := range a.GetPluginStatuses() {
+		if status.Output == nil {
+			continue
+		}
+		outputs++
+
+		switch status.Output.ConnectionState {
+		case models.ConnectionStateConnected:
+		case models.ConnectionStateFailed:
+			check.Errors = append(check.Errors, fmt.Sprintf("output %s (%s) is failing: %s",
+				status.Name, status.UniqueId, status.Output.LastError))
+		default:
+			check.Errors = append(check.Errors, fmt.Sprintf("output %s (%s) is %s",
+				status.Name, status.UniqueId, status.Output.ConnectionState))
+		}
+	}
+	if outputs == 0 {
+		check.Errors = append(check.Errors, "no outputs are running")
+	}
+
+	check.Healthy = len(check.Errors) == 0
+	return check
+}
+
+// Liveness checks that no output has been failing to connect or to write for
+// longer than timeout.
+func (a *Agent) Liveness(timeout time.Duration) *HealthCheck {
+	check := &HealthCheck{}
+	now := time.Now()
+	for _, status := range a.GetPluginStatuses() {
+		if status.Output == nil || status.Output.FailingSince.IsZero() {
+			continue
+		}
+
+		failing := now.Sub(status.Output.FailingSince)
+		if failing > timeout {
+			check.Errors = append(check.Errors, fmt.Sprintf("output %s (%s) is failing for %s: %s",
+				status.Name, status.UniqueId, failing.Truncate(time.Second), status.Output.LastError))
+		}
+	}
+
+	check.Healthy = len(check.Errors) == 0
+	return check
+}
+
+// GetTraces returns the kept traces of the metrics, oldest first.  If uid is
+// set, only the traces passing through the plugin with the unique id are
+// returned.
+func (a *Agent) GetTraces(uid string) ([]*models.Trace, error) {
+	tracer := models.CurrentTracer()
+	if tracer == nil {
+		return nil, fmt.Errorf("tracing is not enabled, configure the [trace] table")
+	}
+
+	traces := tracer.Traces()
+	if uid == "" {
+		return traces, nil
+	}
+
+	selected := make([]*models.Trace, 0, len(traces))
+	for _, trace := range traces {
+		for _, hop := range trace.Hops {
+			if hop.UniqueId == uid {
+				selected = append(selected, trace)
+				break
+			}
+		}
+	}
+	return selected, nil
+}
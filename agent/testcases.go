@@ -0,0 +1,243 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/models"
+	"github.com/influxdata/telegraf/plugins/parsers"
+	"github.com/influxdata/telegraf/plugins/parsers/influx"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/influxdata/toml"
+)
+
+/*
+TestCase is a golden-file test of the parsers and processors of a config.
+The input is parsed, passed through the processors and compared with the
+expected metrics.  Test cases are written in TOML:
+
+	name = "converts the status to an integer"
+	parser = "status_file"
+	input = '''
+	status,code=ok value=1 1600000000000000000
+	'''
+	expected = '''
+	status code=200i,value=1 1600000000000000000
+	'''
+*/
+type TestCase struct {
+	// Name of the test case, defaults to the name of the file.
+	Name string `toml:"name"`
+	// Parser references the input whose parser reads the input, by its
+	// alias, unique_id or plugin name.  Without parser the input is line
+	// protocol.
+	Parser string `toml:"parser"`
+	// Input is the raw data parsed into the metrics of the test case.
+	Input string `toml:"input"`
+	// Pipeline selects the processors of the pipeline, by default the
+	// processors of the default pipeline are run.
+	Pipeline string `toml:"pipeline"`
+	// Processors restricts the processors run to the referenced processors,
+	// by their alias, unique_id or plugin name.
+	Processors []string `toml:"processors"`
+	// Expected are the metrics leaving the processors, in line protocol.
+	Expected string `toml:"expected"`
+	// IgnoreTime ignores the timestamps of the metrics.
+	IgnoreTime bool `toml:"ignore_time"`
+	// SortMetrics ignores the order of the metrics.
+	SortMetrics bool `toml:"sort_metrics"`
+}
+
+// LoadTestCase reads a test case from the file.
+func LoadTestCase(path string) (*TestCase, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tc := &TestCase{}
+	if err := toml.Unmarshal(data, tc); err != nil {
+		return nil, fmt.Errorf("parsing test case %s: %w", path, err)
+	}
+	if tc.Name == "" {
+		tc.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return tc, nil
+}
+
+// RunTestCase runs the test case against the parsers and processors of the
+// config.  The inputs and outputs are not started, and the modifiers and
+// filters of the input of the parser are not applied.  It returns the
+// differences between the expected and the actual metrics, or an empty
+// string if the test case passed.  The processors are started and stopped,
+// the config should not be used afterwards.
+func RunTestCase(c *config.Config, tc *TestCase) (string, error) {
+	parser, err := testCaseParser(c, tc.Parser)
+	if err != nil {
+		return "", err
+	}
+
+	metrics, err := parser.Parse([]byte(tc.Input))
+	if err != nil {
+		return "", fmt.Errorf("parsing input: %w", err)
+	}
+
+	processors, err := testCaseProcessors(c, tc)
+	if err != nil {
+		return "", err
+	}
+	for _, processor := range processors {
+		metrics, err = runTestCaseProcessor(processor, metrics)
+		if err != nil {
+			return "", fmt.Errorf("running %s: %w", processor.LogName(), err)
+		}
+	}
+
+	expected, err := newLineProtocolParser().Parse([]byte(tc.Expected))
+	if err != nil {
+		return "", fmt.Errorf("parsing expected metrics: %w", err)
+	}
+
+	var opts []cmp.Option
+	if tc.IgnoreTime {
+		opts = append(opts, testutil.IgnoreTime())
+	}
+	if tc.SortMetrics {
+		opts = append(opts, testutil.SortMetrics())
+	}
+	return testutil.MetricsDiff(expected, metrics, opts...), nil
+}
+
+func newLineProtocolParser() parsers.Parser {
+	return influx.NewParser(influx.NewMetricHandler())
+}
+
+// parserInput receives the parser of an input.
+type parserInput struct {
+	parser parsers.Parser
+}
+
+func (p *parserInput) SampleConfig() string                  { return "" }
+func (p *parserInput) Description() string                   { return "" }
+func (p *parserInput) Gather(acc telegraf.Accumulator) error { return nil }
+
+func (p *parserInput) SetParser(parser parsers.Parser) {
+	p.parser = parser
+}
+
+// testCaseParser returns the parser of the referenced input, or a line
+// protocol parser if ref is empty.
+func testCaseParser(c *config.Config, ref string) (parsers.Parser, error) {
+	if ref == "" {
+		return newLineProtocolParser(), nil
+	}
+
+	for _, input := range c.Inputs {
+		if ref != input.UniqueId && ref != input.Config.Name &&
+			(input.Config.Alias == "" || ref != input.Config.Alias) {
+			continue
+		}
+
+		switch input.Input.(type) {
+		case parsers.ParserInput, parsers.ParserFuncInput:
+		default:
+			return nil, fmt.Errorf("input %s has no parser", input.LogName())
+		}
+		if input.Prepare == nil {
+			return nil, fmt.Errorf("input %s has no parser config", input.LogName())
+		}
+
+		p := &parserInput{}
+		if err := input.Prepare(p); err != nil {
+			return nil, fmt.Errorf("creating parser of %s: %w", input.LogName(), err)
+		}
+		return p.parser, nil
+	}
+	return nil, fmt.Errorf("unknown input %q", ref)
+}
+
+// testCaseProcessors returns the processors run by the test case, in order.
+func testCaseProcessors(c *config.Config, tc *TestCase) (models.RunningProcessors, error) {
+	processors := pipelineProcessors(c.Processors, tc.Pipeline)
+	if len(tc.Processors) == 0 {
+		return processors, nil
+	}
+
+	var selected models.RunningProcessors
+	for _, ref := range tc.Processors {
+		found := false
+		for _, processor := range processors {
+			if ref == processor.UniqueId || ref == processor.Config.Name ||
+				(processor.Config.Alias != "" && ref == processor.Config.Alias) {
+				found = true
+				if !containsProcessor(selected, processor) {
+					selected = append(selected, processor)
+				}
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown processor %q", ref)
+		}
+	}
+
+	// Keep the order of the config.
+	var ordered models.RunningProcessors
+	for _, processor := range processors {
+		if containsProcessor(selected, processor) {
+			ordered = append(ordered, processor)
+		}
+	}
+	return ordered, nil
+}
+
+func containsProcessor(processors models.RunningProcessors, processor *models.RunningProcessor) bool {
+	for _, p := range processors {
+		if p == processor {
+			return true
+		}
+	}
+	return false
+}
+
+// runTestCaseProcessor passes the metrics through the processor and returns
+// the metrics it emitted, including those emitted when stopping.
+func runTestCaseProcessor(processor *models.RunningProcessor, metrics []telegraf.Metric) ([]telegraf.Metric, error) {
+	if err := processor.Init(); err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	var out []telegraf.Metric
+	acc := newFuncAccumulator(processor, func(m telegraf.Metric) {
+		mu.Lock()
+		out = append(out, m)
+		mu.Unlock()
+	})
+
+	if err := processor.Start(acc); err != nil {
+		return nil, err
+	}
+
+	var errs []string
+	for _, m := range metrics {
+		if err := processor.Add(m, acc); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	processor.Stop()
+
+	if len(errs) != 0 {
+		return nil, errors.New(strings.Join(errs, "; "))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	return out, nil
+}
@@ -0,0 +1,105 @@
+package agent
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/influxdata/telegraf/config"
+	_ "github.com/influxdata/telegraf/plugins/processors/converter"
+	_ "github.com/influxdata/telegraf/plugins/processors/rename"
+	"github.com/stretchr/testify/require"
+)
+
+const testCasesConfig = `
+[[inputs.file]]
+  alias = "status"
+  files = ["/dev/null"]
+  data_format = "json"
+  json_name_key = "name"
+  tag_keys = ["code"]
+
+[[processors.converter]]
+  order = 1
+  [processors.converter.fields]
+    integer = ["value"]
+
+[[processors.rename]]
+  order = 2
+  [[processors.rename.replace]]
+    measurement = "req"
+    dest = "request"
+`
+
+func runTestCase(t *testing.T, tc *TestCase) (string, error) {
+	c := config.NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(testCasesConfig)))
+	return RunTestCase(c, tc)
+}
+
+func TestRunTestCase(t *testing.T) {
+	diff, err := runTestCase(t, &TestCase{
+		Parser:     "status",
+		Input:      `{"name": "req", "code": "ok", "value": 1.5}`,
+		Expected:   "request,code=ok value=2i 0\n",
+		IgnoreTime: true,
+	})
+	require.NoError(t, err)
+	require.Empty(t, diff)
+
+	// Only the selected processors run.
+	diff, err = runTestCase(t, &TestCase{
+		Input:      "req value=2.5 1600000000000000000\n",
+		Processors: []string{"rename"},
+		Expected:   "request value=2.5 1600000000000000000\n",
+	})
+	require.NoError(t, err)
+	require.Empty(t, diff)
+
+	diff, err = runTestCase(t, &TestCase{
+		Input:    "req value=2.5 1600000000000000000\n",
+		Expected: "request value=2.5 1600000000000000000\n",
+	})
+	require.NoError(t, err)
+	require.Contains(t, diff, "int64(3)")
+}
+
+func TestRunTestCaseErrors(t *testing.T) {
+	_, err := runTestCase(t, &TestCase{Parser: "unknown"})
+	require.EqualError(t, err, `unknown input "unknown"`)
+
+	_, err = runTestCase(t, &TestCase{Processors: []string{"unknown"}})
+	require.EqualError(t, err, `unknown processor "unknown"`)
+
+	_, err = runTestCase(t, &TestCase{Input: "not line protocol"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "parsing input")
+}
+
+func TestLoadTestCase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telegraf-testcase")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "status.toml")
+	data := `
+parser = "status"
+input = '{"name": "req", "value": 1}'
+expected = '''
+request value=1i 0
+'''
+ignore_time = true
+`
+	require.NoError(t, ioutil.WriteFile(path, []byte(data), 0644))
+
+	tc, err := LoadTestCase(path)
+	require.NoError(t, err)
+	require.Equal(t, &TestCase{
+		Name:       "status",
+		Parser:     "status",
+		Input:      `{"name": "req", "value": 1}`,
+		Expected:   "request value=1i 0\n",
+		IgnoreTime: true,
+	}, tc)
+}
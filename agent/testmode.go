@@ -0,0 +1,118 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/models"
+	"github.com/influxdata/telegraf/plugins/serializers"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+)
+
+// testOutput stands in for an output in test mode.  The metrics written are
+// serialized into a buffer with the serializer of the output instead of
+// being sent.
+type testOutput struct {
+	serializer serializers.Serializer
+	buf        bytes.Buffer
+	written    int
+}
+
+func (o *testOutput) SampleConfig() string { return "" }
+func (o *testOutput) Description() string  { return "" }
+func (o *testOutput) Connect() error       { return nil }
+func (o *testOutput) Close() error         { return nil }
+
+func (o *testOutput) SetSerializer(serializer serializers.Serializer) {
+	o.serializer = serializer
+}
+
+func (o *testOutput) Write(metrics []telegraf.Metric) error {
+	octets, err := o.serializer.SerializeBatch(metrics)
+	if err != nil {
+		return err
+	}
+	o.buf.Write(octets)
+	o.written += len(metrics)
+	return nil
+}
+
+// newTestOutput returns a copy of the output writing to a testOutput.  The
+// copy applies the filters and modifiers of the output, but always buffers
+// the metrics in memory.  Outputs without a serializer are shown in line
+// protocol.
+func newTestOutput(output *models.RunningOutput) (*models.RunningOutput, *testOutput, error) {
+	to := &testOutput{}
+	if _, ok := output.Output.(serializers.SerializerOutput); ok && output.Prepare != nil {
+		if err := output.Prepare(to); err != nil {
+			return nil, nil, fmt.Errorf("creating serializer of %s: %w", output.LogName(), err)
+		}
+	}
+	if to.serializer == nil {
+		s := influx.NewSerializer()
+		s.SetFieldSortOrder(influx.SortFields)
+		to.serializer = s
+	}
+
+	config := *output.Config
+	config.BufferStrategy = models.BufferStrategyMemory
+	ro := models.NewRunningOutput(config.Name, to, &config,
+		output.MetricBatchSize, output.MetricBufferLimit, output.UniqueId)
+	return ro, to, nil
+}
+
+// testOutputs runs the pipelines for a single gather, including the
+// processors and aggregators, and writes what each output would receive to
+// w.  The metrics are routed, filtered and serialized like in a normal run,
+// but nothing is sent by the outputs.  Aggregators push once at the end of
+// the gather instead of waiting for the end of their period.
+func (a *Agent) testOutputs(ctx context.Context, wait time.Duration, w io.Writer) error {
+	outputs := make(map[*models.RunningOutput]*models.RunningOutput, len(a.Config.Outputs))
+	written := make(map[*models.RunningOutput]*testOutput, len(a.Config.Outputs))
+	for _, output := range a.Config.Outputs {
+		ro, to, err := newTestOutput(output)
+		if err != nil {
+			return err
+		}
+		outputs[output] = ro
+		written[output] = to
+	}
+
+	err := a.testPipelines(ctx, wait, func(pipeline string, metric telegraf.Metric) {
+		a.Config.OutputsLock.Lock()
+		defer a.Config.OutputsLock.Unlock()
+
+		routed := a.routeMetric(metric, pipelineOutputs(a.Config.Outputs, pipeline))
+		for i, output := range routed {
+			if i == len(routed)-1 {
+				outputs[output].AddMetric(metric)
+			} else {
+				outputs[output].AddMetric(metric.Copy())
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, output := range a.Config.Outputs {
+		to := written[output]
+		werr := outputs[output].Write()
+
+		fmt.Fprintf(w, "# %s: %d metrics\n", output.LogName(), to.written)
+		if to.buf.Len() > 0 && !bytes.HasSuffix(to.buf.Bytes(), []byte("\n")) {
+			to.buf.WriteByte('\n')
+		}
+		if _, err := to.buf.WriteTo(w); err != nil {
+			return err
+		}
+		if werr != nil {
+			fmt.Fprintf(w, "# %s: serializing failed: %v\n", output.LogName(), werr)
+		}
+	}
+	return nil
+}
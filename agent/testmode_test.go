@@ -0,0 +1,44 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/models"
+	_ "github.com/influxdata/telegraf/plugins/aggregators/minmax"
+	"github.com/stretchr/testify/require"
+)
+
+const testOutputsConfig = `
+[[outputs.file]]
+  alias = "json"
+  data_format = "json"
+
+[[outputs.discard]]
+  fieldpass = ["value_min"]
+
+[[aggregators.minmax]]
+  period = "1h"
+`
+
+func TestAgent_TestOutputs(t *testing.T) {
+	c := config.NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(testOutputsConfig)))
+	c.Inputs = []*models.RunningInput{newPipelineInput("cpu", "")}
+
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, a.testOutputs(context.Background(), 0, &buf))
+	out := buf.String()
+
+	// Both outputs receive the aggregate although the period is not over,
+	// each in the format of the output.
+	require.Contains(t, out, "# outputs.file::json: 2 metrics\n")
+	require.Contains(t, out, `"name":"cpu"`)
+	require.Contains(t, out, `"fields":{"value_max":1,"value_min":1}`)
+	require.Contains(t, out, "# outputs.discard: 1 metrics\ncpu value_min=1 ")
+}
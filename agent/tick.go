@@ -7,6 +7,7 @@ import (
 
 	"github.com/benbjohnson/clock"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/schedule"
 )
 
 type empty struct{}
@@ -273,3 +274,119 @@ func (t *RollingTicker) Stop() {
 	t.cancel()
 	t.wg.Wait()
 }
+
+// ScheduledTicker delivers ticks at the times of a cron or calendar schedule
+// plus an optional jitter.  Each tick is scheduled from the time of the
+// previous tick, so changes to the system clock are handled at the next tick.
+//
+// The first tick is emitted at the next time of the schedule.
+//
+// Ticks are dropped for slow consumers.
+type ScheduledTicker struct {
+	schedule schedule.Schedule
+	jitter   time.Duration
+	ch       chan time.Time
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+func NewScheduledTicker(now time.Time, schedule schedule.Schedule, jitter time.Duration) *ScheduledTicker {
+	return newScheduledTicker(now, schedule, jitter, clock.New())
+}
+
+func newScheduledTicker(now time.Time, schedule schedule.Schedule, jitter time.Duration, clock clock.Clock) *ScheduledTicker {
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &ScheduledTicker{
+		schedule: schedule,
+		jitter:   jitter,
+		ch:       make(chan time.Time, 1),
+		cancel:   cancel,
+	}
+
+	d, ok := t.next(now)
+	if !ok {
+		return t
+	}
+	timer := clock.Timer(d)
+
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		t.run(ctx, timer)
+	}()
+
+	return t
+}
+
+// next returns the duration until the next tick, or false if the schedule
+// has ended.
+func (t *ScheduledTicker) next(now time.Time) (time.Duration, bool) {
+	next := t.schedule.Next(now)
+	if next.IsZero() {
+		return 0, false
+	}
+	return next.Sub(now) + internal.RandomDuration(t.jitter), true
+}
+
+func (t *ScheduledTicker) run(ctx context.Context, timer *clock.Timer) {
+	for {
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case now := <-timer.C:
+			select {
+			case t.ch <- now:
+			default:
+			}
+
+			d, ok := t.next(now)
+			if !ok {
+				return
+			}
+			timer.Reset(d)
+		}
+	}
+}
+
+func (t *ScheduledTicker) Elapsed() <-chan time.Time {
+	return t.ch
+}
+
+func (t *ScheduledTicker) Stop() {
+	t.cancel()
+	t.wg.Wait()
+}
+
+// scheduleWindow is the time after the next tick scheduleInterval looks at,
+// calendar schedules and cron expressions without days of month or months
+// repeat every week.
+const scheduleWindow = 7 * 24 * time.Hour
+
+// scheduleInterval returns the smallest time between two ticks of the
+// schedule in the week after the next tick, it takes the place of the
+// interval of scheduled inputs.  Schedules firing less often than weekly use
+// the time between their next two ticks.
+func scheduleInterval(now time.Time, schedule schedule.Schedule) time.Duration {
+	prev := schedule.Next(now)
+	if prev.IsZero() {
+		return 0
+	}
+
+	end := prev.Add(scheduleWindow)
+	var interval time.Duration
+	for {
+		next := schedule.Next(prev)
+		if next.IsZero() {
+			break
+		}
+		if gap := next.Sub(prev); interval == 0 || gap < interval {
+			interval = gap
+		}
+		if next.After(end) {
+			break
+		}
+		prev = next
+	}
+	return interval
+}
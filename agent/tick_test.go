@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/benbjohnson/clock"
+	"github.com/influxdata/telegraf/internal/schedule"
 	"github.com/stretchr/testify/require"
 )
 
@@ -160,6 +161,84 @@ func TestRollingTicker(t *testing.T) {
 	require.Equal(t, expected, actual)
 }
 
+func TestScheduledTicker(t *testing.T) {
+	s, err := schedule.Parse([]string{"TZ=UTC */15 * * * *"})
+	require.NoError(t, err)
+
+	clock := clock.NewMock()
+	since := clock.Now()
+
+	ticker := newScheduledTicker(since, s, 0, clock)
+	defer ticker.Stop()
+
+	expected := []time.Time{
+		time.Unix(15*60, 0).UTC(),
+		time.Unix(30*60, 0).UTC(),
+		time.Unix(45*60, 0).UTC(),
+		time.Unix(60*60, 0).UTC(),
+	}
+
+	actual := []time.Time{}
+	for range expected {
+		clock.Add(15 * time.Minute)
+		tm := <-ticker.Elapsed()
+		actual = append(actual, tm.UTC())
+	}
+
+	require.Equal(t, expected, actual)
+}
+
+func TestScheduledTickerCalendar(t *testing.T) {
+	// The mock clock starts on Thursday, January 1st 1970.
+	s, err := schedule.Parse([]string{"TZ=UTC weekdays at 02:00"})
+	require.NoError(t, err)
+
+	clock := clock.NewMock()
+	since := clock.Now()
+	until := since.Add(5 * 24 * time.Hour)
+
+	ticker := newScheduledTicker(since, s, 0, clock)
+	defer ticker.Stop()
+
+	expected := []time.Time{
+		time.Date(1970, 1, 1, 2, 0, 0, 0, time.UTC),
+		time.Date(1970, 1, 2, 2, 0, 0, 0, time.UTC),
+		time.Date(1970, 1, 5, 2, 0, 0, 0, time.UTC),
+	}
+
+	actual := []time.Time{}
+	for !clock.Now().After(until) {
+		clock.Add(1 * time.Hour)
+		select {
+		case tm := <-ticker.Elapsed():
+			actual = append(actual, tm.UTC())
+		default:
+		}
+	}
+
+	require.Equal(t, expected, actual)
+}
+
+func TestScheduleInterval(t *testing.T) {
+	// 2021-03-05 is a Friday.
+	now := time.Date(2021, 3, 5, 10, 0, 0, 0, time.UTC)
+
+	s, err := schedule.Parse([]string{"TZ=UTC weekdays every 5m between 08:00-18:00"})
+	require.NoError(t, err)
+	require.Equal(t, 5*time.Minute, scheduleInterval(now, s))
+
+	// The last tick of the week is not followed by the weekend gap.
+	require.Equal(t, 5*time.Minute, scheduleInterval(now.Add(7*time.Hour+58*time.Minute), s))
+
+	s, err = schedule.Parse([]string{"TZ=UTC sat,sun at 06:00, 18:00"})
+	require.NoError(t, err)
+	require.Equal(t, 12*time.Hour, scheduleInterval(now, s))
+
+	s, err = schedule.Parse([]string{"TZ=UTC 0 2 * * *"})
+	require.NoError(t, err)
+	require.Equal(t, 24*time.Hour, scheduleInterval(now, s))
+}
+
 // Simulates running the Ticker for an hour and displays stats about the
 // operation.
 func TestAlignedTickerDistribution(t *testing.T) {
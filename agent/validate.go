@@ -0,0 +1,195 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/models"
+)
+
+// Stages of a plugin update at which validation can fail.
+const (
+	StageConfig  = "config"
+	StageInit    = "init"
+	StageConnect = "connect"
+	StageStart   = "start"
+	StageGather  = "gather"
+)
+
+// ValidationError is returned when a plugin update is rejected.  The running
+// instance of the plugin is left unchanged and the configuration is not saved.
+type ValidationError struct {
+	UniqueId string   `json:"unique_id"`
+	Stage    string   `json:"stage"`
+	Errors   []string `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("could not update plugin %s, %s failed: %s",
+		e.UniqueId, e.Stage, strings.Join(e.Errors, "; "))
+}
+
+func newValidationError(uniqueId, stage string, errs ...error) *ValidationError {
+	verr := &ValidationError{UniqueId: uniqueId, Stage: stage}
+	for _, err := range errs {
+		verr.Errors = append(verr.Errors, err.Error())
+	}
+	return verr
+}
+
+// If the config is compatible with the struct, return the JSON version of the
+// config, otherwise return a description of every field that was rejected.
+func validateStructConfig(structPtr reflect.Value, config map[string]interface{}) ([]byte, []string) {
+	strct := structPtr.Elem() // extract Value of type interface{} from Value pointer to interface
+
+	if strct.Kind() != reflect.Struct {
+		return nil, []string{"pointer does not point to a struct"}
+	}
+
+	keys := make([]string, 0, len(config))
+	for configKey := range config {
+		keys = append(keys, configKey)
+	}
+	sort.Strings(keys)
+
+	var errs []string
+	for _, configKey := range keys {
+		// Check for if trying to modify unsettable fields and fields that don't exist.
+		pluginField := strct.FieldByName(configKey)
+		if !pluginField.IsValid() {
+			errs = append(errs, fmt.Sprintf("invalid field name %s", configKey))
+			continue
+		} else if !pluginField.CanSet() {
+			errs = append(errs, fmt.Sprintf("unsettable field %s", configKey))
+			continue
+		}
+
+		// Check each value on its own so all invalid values are reported.
+		fieldJSON, err := json.Marshal(map[string]interface{}{configKey: config[configKey]})
+		if err == nil {
+			err = json.Unmarshal(fieldJSON, reflect.New(strct.Type()).Interface())
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("invalid value for field %s: %s", configKey, err))
+		}
+	}
+	if len(errs) != 0 {
+		return nil, errs
+	}
+
+	cJSON, err := json.Marshal(config)
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+	return cJSON, nil
+}
+
+// copyPluginConfig copies the exported fields, which hold the settings of a
+// plugin, from the running instance to a new instance of the same plugin.
+func copyPluginConfig(dst, src interface{}) {
+	d := reflect.ValueOf(dst).Elem()
+	s := reflect.ValueOf(src).Elem()
+	if d.Kind() != reflect.Struct || d.Type() != s.Type() {
+		return
+	}
+
+	for i := 0; i < d.NumField(); i++ {
+		field := d.Field(i)
+		// Locks and wait groups are state, not settings.
+		if !field.CanSet() || field.Type().PkgPath() == "sync" {
+			continue
+		}
+		field.Set(s.Field(i))
+	}
+}
+
+// applyPluginConfig sets the fields in config on the plugin.  Maps, slices and
+// pointers are reset first, as they may still be shared with the running
+// instance the settings were copied from.
+func applyPluginConfig(plugin interface{}, config map[string]interface{}, configJSON []byte) error {
+	strct := reflect.ValueOf(plugin).Elem()
+	for configKey := range config {
+		field := strct.FieldByName(configKey)
+		switch field.Kind() {
+		case reflect.Map, reflect.Slice, reflect.Ptr:
+			field.Set(reflect.Zero(field.Type()))
+		}
+	}
+	return json.Unmarshal(configJSON, plugin)
+}
+
+// dryRunMaker passes on metrics without applying the input's settings or
+// counting them in the input's statistics.
+type dryRunMaker struct {
+	*models.RunningInput
+}
+
+func (m dryRunMaker) MakeMetric(metric telegraf.Metric) telegraf.Metric {
+	return metric
+}
+
+// dryRunAccumulator discards all metrics and collects the errors reported
+// during a gather.  Errors reported once the accumulator is closed are
+// discarded as well.
+type dryRunAccumulator struct {
+	telegraf.Accumulator
+
+	sync.Mutex
+	errs   []error
+	closed bool
+}
+
+func (acc *dryRunAccumulator) AddError(err error) {
+	if err == nil {
+		return
+	}
+	acc.Lock()
+	if !acc.closed {
+		acc.errs = append(acc.errs, err)
+	}
+	acc.Unlock()
+}
+
+// close returns the collected errors and discards later errors.
+func (acc *dryRunAccumulator) close() []error {
+	acc.Lock()
+	defer acc.Unlock()
+	acc.closed = true
+	return acc.errs
+}
+
+// dryRunGather runs a single gather of the input, the gathered metrics are
+// discarded.  All errors are returned, including a gather that does not
+// finish within the timeout.  A gather cannot be cancelled, so it is waited
+// for in any case; the input is not used by anything else once it returns.
+func dryRunGather(input *models.RunningInput, timeout time.Duration) []error {
+	acc := &dryRunAccumulator{
+		Accumulator: newFuncAccumulator(dryRunMaker{input}, func(m telegraf.Metric) {
+			m.Drop()
+		}),
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- input.Input.Gather(acc)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		acc.AddError(err)
+	case <-timer.C:
+		acc.AddError(fmt.Errorf("gather did not complete within %s", timeout))
+		input.Log().Warnf("Waiting for the gather of the dry run to complete")
+		acc.AddError(<-done)
+	}
+	return acc.close()
+}
@@ -8,10 +8,12 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/influxdata/telegraf/agent"
+	"github.com/influxdata/telegraf/config"
 	"github.com/influxdata/telegraf/internal"
 )
 
@@ -23,6 +25,11 @@ type Assistant struct {
 	conn    *websocket.Conn  // Active websocket conn
 	running bool
 	agent   *agent.Agent // Pointer to agent to issue commands
+
+	writeLock sync.Mutex // Serializes writes of responses and status events
+
+	subscriptionLock   sync.Mutex
+	cancelSubscription context.CancelFunc
 }
 
 /*
@@ -68,15 +75,37 @@ const (
 	STOP_PLUGIN         = requestType("STOP_PLUGIN")
 	GET_RUNNING_PLUGINS = requestType("GET_RUNNING_PLUGINS")
 	GET_ALL_PLUGINS     = requestType("GET_ALL_PLUGINS")
+	GET_PLUGIN_STATUS   = requestType("GET_PLUGIN_STATUS")
+	GET_TRACES          = requestType("GET_TRACES")
+	SUBSCRIBE           = requestType("SUBSCRIBE")
+	UNSUBSCRIBE         = requestType("UNSUBSCRIBE")
 
 	SUCCESS = "SUCCESS"
 	FAILURE = "FAILURE"
+
+	// PLUGIN_STATUS is the type of the events sent to subscribers.
+	PLUGIN_STATUS = "PLUGIN_STATUS"
+
+	defaultSubscriptionInterval = 10 * time.Second
 )
 
 type request struct {
 	Operation requestType
 	UUID      string
 	Plugin    pluginInfo
+	// DryRun gathers an updated input once before it replaces the running
+	// instance, the update is rejected if the gather fails.
+	DryRun bool
+	// Subscription selects the plugins and interval of a SUBSCRIBE request.
+	Subscription subscription
+}
+
+type subscription struct {
+	// UniqueIds of the plugins to send the status of, all running plugins if
+	// empty.
+	UniqueIds []string
+	// Interval between status events, such as "10s".
+	Interval string
 }
 
 type response struct {
@@ -85,6 +114,14 @@ type response struct {
 	Data   interface{}
 }
 
+// event is sent to the server without a request, UUID is the id of the
+// request that subscribed to the events.
+type event struct {
+	Event string
+	UUID  string
+	Data  interface{}
+}
+
 func (a *Assistant) init(ctx context.Context) error {
 	token, exists := os.LookupEnv("INFLUX_TOKEN")
 	if !exists {
@@ -109,7 +146,9 @@ func (a *Assistant) init(ctx context.Context) error {
 
 		ws, _, err = websocket.DefaultDialer.Dial(u.String(), header)
 	}
+	a.writeLock.Lock()
 	a.conn = ws
+	a.writeLock.Unlock()
 
 	log.Printf("D! [assistant] Successfully connected to %s", a.config.Host)
 	return nil
@@ -158,13 +197,21 @@ func (a *Assistant) listen(ctx context.Context) {
 		}
 		res := a.handleRequest(ctx, &req)
 
-		if err := a.conn.WriteJSON(res); err != nil {
+		if err := a.write(res); err != nil {
 			log.Printf("E! [assistant] Error while writing to server: %s", err)
-			a.conn.WriteJSON(response{FAILURE, req.UUID, "error marshalling config"})
+			a.write(response{FAILURE, req.UUID, "error marshalling config"})
 		}
 	}
 }
 
+// write sends a message to the server, it is safe to call from the listener
+// and the subscription at the same time.
+func (a *Assistant) write(v interface{}) error {
+	a.writeLock.Lock()
+	defer a.writeLock.Unlock()
+	return a.conn.WriteJSON(v)
+}
+
 func (a *Assistant) shutdownOnContext(ctx context.Context) {
 	<-ctx.Done()
 	a.running = false
@@ -190,11 +237,24 @@ func (a *Assistant) handleRequest(ctx context.Context, req *request) response {
 		resp, err = a.getRunningPlugins(req)
 	case GET_ALL_PLUGINS:
 		resp, err = a.getAllPlugins(req)
+	case GET_PLUGIN_STATUS:
+		resp, err = a.getPluginStatus(req)
+	case GET_TRACES:
+		resp, err = a.agent.GetTraces(req.Plugin.UniqueId)
+	case SUBSCRIBE:
+		resp, err = a.subscribe(ctx, req)
+	case UNSUBSCRIBE:
+		resp, err = a.unsubscribe(req)
 	default:
 		err = errors.New("invalid operation")
 	}
 
 	if err != nil {
+		// Rejected updates report every problem that was found.
+		var verr *agent.ValidationError
+		if errors.As(err, &verr) {
+			return response{FAILURE, req.UUID, verr}
+		}
 		return response{FAILURE, req.UUID, err.Error()}
 	}
 
@@ -216,6 +276,9 @@ func (a *Assistant) getPlugin(req *request) (interface{}, error) {
 type pluginSchema struct {
 	Schema   map[string]interface{}
 	Defaults map[string]interface{}
+	// JSONSchema describes the settings by their TOML keys, including docs,
+	// formats such as durations, allowed values and nested tables.
+	JSONSchema *config.Schema
 }
 
 // getSchema returns the struct response containing config schema for a single plugin
@@ -230,6 +293,10 @@ func (a *Assistant) getSchema(req *request) (interface{}, error) {
 		plugin, err = a.agent.CreateInput(req.Plugin.Name)
 	case "OUTPUT":
 		plugin, err = a.agent.CreateOutput(req.Plugin.Name)
+	case "PROCESSOR":
+		plugin, err = a.agent.CreateProcessor(req.Plugin.Name)
+	case "AGGREGATOR":
+		plugin, err = a.agent.CreateAggregator(req.Plugin.Name)
 	default:
 		err = fmt.Errorf("did not provide a valid plugin type")
 	}
@@ -248,9 +315,15 @@ func (a *Assistant) getSchema(req *request) (interface{}, error) {
 		return nil, err
 	}
 
+	jsonSchema, err := a.agent.GetPluginSchema(req.Plugin.Name, plugin)
+	if err != nil {
+		return nil, err
+	}
+
 	resp := pluginSchema{
-		Schema:   schema,
-		Defaults: defaults,
+		Schema:     schema,
+		Defaults:   defaults,
+		JSONSchema: jsonSchema,
 	}
 
 	return resp, nil
@@ -267,6 +340,10 @@ func (a *Assistant) startPlugin(ctx context.Context, req *request) (interface{},
 		uid, err = a.agent.StartInput(ctx, req.Plugin.Name)
 	case "OUTPUT":
 		uid, err = a.agent.StartOutput(ctx, req.Plugin.Name)
+	case "PROCESSOR":
+		uid, err = a.agent.StartProcessor(req.Plugin.Name)
+	case "AGGREGATOR":
+		uid, err = a.agent.StartAggregator(req.Plugin.Name)
 	default:
 		err = fmt.Errorf("invalid plugin type")
 	}
@@ -294,9 +371,13 @@ func (a *Assistant) updatePlugin(req *request) (interface{}, error) {
 
 	switch req.Plugin.Type {
 	case "INPUT":
-		data, err = a.agent.UpdateInputPlugin(req.Plugin.UniqueId, req.Plugin.Config)
+		data, err = a.agent.UpdateInputPlugin(req.Plugin.UniqueId, req.Plugin.Config, req.DryRun)
 	case "OUTPUT":
 		data, err = a.agent.UpdateOutputPlugin(req.Plugin.UniqueId, req.Plugin.Config)
+	case "PROCESSOR":
+		data, err = a.agent.UpdateProcessorPlugin(req.Plugin.UniqueId, req.Plugin.Config)
+	case "AGGREGATOR":
+		data, err = a.agent.UpdateAggregatorPlugin(req.Plugin.UniqueId, req.Plugin.Config)
 	default:
 		err = fmt.Errorf("did not provide a valid plugin type")
 	}
@@ -318,6 +399,10 @@ func (a *Assistant) stopPlugin(req *request) (interface{}, error) {
 		err = a.agent.StopInputPlugin(req.Plugin.UniqueId, true)
 	case "OUTPUT":
 		err = a.agent.StopOutputPlugin(req.Plugin.UniqueId, true)
+	case "PROCESSOR":
+		err = a.agent.StopProcessorPlugin(req.Plugin.UniqueId, true)
+	case "AGGREGATOR":
+		err = a.agent.StopAggregatorPlugin(req.Plugin.UniqueId, true)
 	default:
 		err = fmt.Errorf("did not provide a valid plugin type")
 	}
@@ -330,29 +415,141 @@ func (a *Assistant) stopPlugin(req *request) (interface{}, error) {
 }
 
 type runningPlugins struct {
-	Inputs  []map[string]string
-	Outputs []map[string]string
+	Inputs      []map[string]string
+	Outputs     []map[string]string
+	Processors  []map[string]string
+	Aggregators []map[string]string
 }
 
 // getRunningPlugins returns an object with all running plugins
 func (assistant *Assistant) getRunningPlugins(req *request) (interface{}, error) {
 	runningPlugins := runningPlugins{
-		Inputs:  assistant.agent.GetRunningInputPlugins(),
-		Outputs: assistant.agent.GetRunningOutputPlugins(),
+		Inputs:      assistant.agent.GetRunningInputPlugins(),
+		Outputs:     assistant.agent.GetRunningOutputPlugins(),
+		Processors:  assistant.agent.GetRunningProcessorPlugins(),
+		Aggregators: assistant.agent.GetRunningAggregatorPlugins(),
 	}
 	return runningPlugins, nil
 }
 
 type availablePlugins struct {
-	Inputs  []string
-	Outputs []string
+	Inputs      []string
+	Outputs     []string
+	Processors  []string
+	Aggregators []string
 }
 
 // getAllPlugins returns an object with the names of all available plugins
 func (assistant *Assistant) getAllPlugins(req *request) (interface{}, error) {
 	availablePlugins := availablePlugins{
-		Inputs:  agent.GetAllInputPlugins(),
-		Outputs: agent.GetAllOutputPlugins(),
+		Inputs:      agent.GetAllInputPlugins(),
+		Outputs:     agent.GetAllOutputPlugins(),
+		Processors:  agent.GetAllProcessorPlugins(),
+		Aggregators: agent.GetAllAggregatorPlugins(),
 	}
 	return availablePlugins, nil
 }
+
+// getPluginStatus returns the status of a single plugin, or of all running
+// plugins if no id is given.
+func (a *Assistant) getPluginStatus(req *request) (interface{}, error) {
+	if req.Plugin.UniqueId == "" {
+		return a.agent.GetPluginStatuses(), nil
+	}
+	return a.agent.GetPluginStatus(req.Plugin.UniqueId)
+}
+
+// subscribe starts sending the status of the selected plugins to the server
+// every interval, replacing any previous subscription.
+func (a *Assistant) subscribe(ctx context.Context, req *request) (interface{}, error) {
+	log.Printf("D! [assistant] Received request: %s", req.Operation)
+
+	if a.conn == nil {
+		return nil, errors.New("subscriptions require a connection to the server")
+	}
+
+	interval := defaultSubscriptionInterval
+	if req.Subscription.Interval != "" {
+		var err error
+		interval, err = time.ParseDuration(req.Subscription.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval: %s", err)
+		}
+		if interval <= 0 {
+			return nil, fmt.Errorf("invalid interval: must be positive")
+		}
+	}
+
+	for _, uid := range req.Subscription.UniqueIds {
+		if _, err := a.agent.GetPluginStatus(uid); err != nil {
+			return nil, fmt.Errorf("could not subscribe to %s: %s", uid, err)
+		}
+	}
+
+	subCtx, cancel := context.WithCancel(ctx)
+	a.subscriptionLock.Lock()
+	if a.cancelSubscription != nil {
+		a.cancelSubscription()
+	}
+	a.cancelSubscription = cancel
+	a.subscriptionLock.Unlock()
+
+	go a.sendStatus(subCtx, req.UUID, req.Subscription.UniqueIds, interval)
+
+	return map[string]interface{}{
+		"interval":   interval.String(),
+		"unique_ids": req.Subscription.UniqueIds,
+	}, nil
+}
+
+// unsubscribe stops sending status events.
+func (a *Assistant) unsubscribe(req *request) (interface{}, error) {
+	log.Printf("D! [assistant] Received request: %s", req.Operation)
+
+	a.subscriptionLock.Lock()
+	defer a.subscriptionLock.Unlock()
+	if a.cancelSubscription == nil {
+		return nil, errors.New("no active subscription")
+	}
+	a.cancelSubscription()
+	a.cancelSubscription = nil
+	return "unsubscribed", nil
+}
+
+// sendStatus writes a status event for each selected plugin every interval
+// until the context is done.  Plugins that are no longer running are skipped.
+func (a *Assistant) sendStatus(ctx context.Context, uuid string, uids []string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var statuses []*agent.PluginStatus
+		if len(uids) == 0 {
+			statuses = a.agent.GetPluginStatuses()
+		} else {
+			for _, uid := range uids {
+				status, err := a.agent.GetPluginStatus(uid)
+				if err != nil {
+					continue
+				}
+				statuses = append(statuses, status)
+			}
+		}
+
+		for _, status := range statuses {
+			if ctx.Err() != nil {
+				return
+			}
+			if err := a.write(event{PLUGIN_STATUS, uuid, status}); err != nil {
+				log.Printf("E! [assistant] Error while sending status to server: %s", err)
+				break
+			}
+		}
+	}
+}
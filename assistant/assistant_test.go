@@ -3,17 +3,26 @@ package assistant
 import (
 	"context"
 	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/influxdata/telegraf/agent"
 	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/models"
+	_ "github.com/influxdata/telegraf/plugins/aggregators/all"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	_ "github.com/influxdata/telegraf/plugins/inputs/all"
 	"github.com/influxdata/telegraf/plugins/outputs"
 	_ "github.com/influxdata/telegraf/plugins/outputs/all"
+	_ "github.com/influxdata/telegraf/plugins/processors/all"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func initAgentAndAssistant(ctx context.Context, configName string, t *testing.T) (*agent.Agent, *Assistant) {
@@ -41,7 +50,7 @@ func buildRequest(rt requestType, params pluginInfo) (request, error) {
 	if err == nil {
 		var pi pluginInfo
 		err = json.Unmarshal(paramsJSON, &pi)
-		return request{rt, "123", pi}, err // 123 is dummy request uuid
+		return request{rt, "123", pi, false, subscription{}}, err // 123 is dummy request uuid
 	}
 	return req, err
 }
@@ -65,6 +74,18 @@ func (ast *Assistant) getPluginID(name string) string {
 		}
 	}
 
+	for _, proc := range pluginsWithID.Processors {
+		if proc["name"] == name {
+			return proc["id"]
+		}
+	}
+
+	for _, agg := range pluginsWithID.Aggregators {
+		if agg["name"] == name {
+			return agg["id"]
+		}
+	}
+
 	return ""
 }
 
@@ -123,6 +144,12 @@ func TestAssistant_GetInputPluginSchema(t *testing.T) {
 	assert.Equal(t, map[string]interface{}{
 		"Duration": dur,
 	}, s.Defaults["ResponseTimeout"])
+	// test s.JSONSchema
+	responseTimeout := s.JSONSchema.Properties["response_timeout"]
+	assert.Equal(t, "duration", responseTimeout.Format)
+	assert.Equal(t, "5s", responseTimeout.Default)
+	assert.Equal(t, "ResponseTimeout", responseTimeout.Field)
+	assert.Contains(t, s.JSONSchema.Properties["method"].Description, "HTTP method")
 
 	// test aerospike (empty default values)
 	req, err = buildRequest(GET_PLUGIN_SCHEMA, pluginInfo{"aerospike", "INPUT", nil, ""})
@@ -221,6 +248,32 @@ func TestAssistant_GetOutputPluginSchema(t *testing.T) {
 
 	cancel()
 }
+func TestAssistant_GetProcessorPluginSchema(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	_, ast := initAgentAndAssistant(ctx, "single_plugin", t)
+
+	req, err := buildRequest(GET_PLUGIN_SCHEMA, pluginInfo{"override", "PROCESSOR", nil, ""})
+	assert.NoError(t, err)
+	res := ast.handleRequest(ctx, &req)
+	assert.Equal(t, SUCCESS, res.Status)
+
+	s, isSchema := res.Data.(pluginSchema)
+	assert.True(t, isSchema)
+	assert.Equal(t, "string", s.Schema["NameOverride"])
+	assert.Equal(t, agent.MapFieldSchema{Value: "string", Key: "string"}, s.Schema["Tags"])
+
+	req, err = buildRequest(GET_PLUGIN_SCHEMA, pluginInfo{"basicstats", "AGGREGATOR", nil, ""})
+	assert.NoError(t, err)
+	res = ast.handleRequest(ctx, &req)
+	assert.Equal(t, SUCCESS, res.Status)
+
+	s, isSchema = res.Data.(pluginSchema)
+	assert.True(t, isSchema)
+	assert.Equal(t, agent.ArrayFieldSchema{Value: "string", Length: 0}, s.Schema["Stats"])
+
+	cancel()
+}
+
 func TestAssistant_GetInputPlugin(t *testing.T) {
 	// Test getting an input plugin
 	ctx, cancel := context.WithCancel(context.Background())
@@ -413,11 +466,118 @@ func TestAssistant_UpdatePlugins_WithInvalidFieldType(t *testing.T) {
 	cancel()
 }
 
+func TestAssistant_UpdatePlugin_ReportsAllInvalidFields(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	_, ast := initAgentAndAssistant(ctx, "single_plugin", t)
+
+	memcachedID := ast.getPluginID("memcached")
+	req, err := buildRequest(UPDATE_PLUGIN, pluginInfo{"", "INPUT", map[string]interface{}{
+		"Servers":      "localhost",
+		"InvalidField": true,
+	}, memcachedID})
+	assert.NoError(t, err)
+
+	response := ast.handleRequest(ctx, &req)
+	assert.Equal(t, FAILURE, response.Status)
+
+	verr := response.Data.(*agent.ValidationError)
+	assert.Equal(t, memcachedID, verr.UniqueId)
+	assert.Equal(t, agent.StageConfig, verr.Stage)
+	assert.Len(t, verr.Errors, 2)
+
+	cancel()
+}
+
+func TestAssistant_UpdatePlugin_InitFailureKeepsRunningInstance(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ag, ast := initAgentAndAssistant(ctx, "single_plugin", t)
+
+	req, err := buildRequest(START_PLUGIN, pluginInfo{"http", "INPUT", nil, ""})
+	assert.NoError(t, err)
+	res := ast.handleRequest(ctx, &req)
+	assert.Equal(t, SUCCESS, res.Status)
+
+	httpID := ast.getPluginID("http")
+	running, err := ag.GetRunningPlugin(httpID)
+	assert.NoError(t, err)
+
+	req, err = buildRequest(UPDATE_PLUGIN, pluginInfo{"", "INPUT", map[string]interface{}{
+		"URLs":  []string{"http://localhost:1"},
+		"TLSCA": "/nonexistent/ca.pem",
+	}, httpID})
+	assert.NoError(t, err)
+
+	res = ast.handleRequest(ctx, &req)
+	assert.Equal(t, FAILURE, res.Status)
+	verr := res.Data.(*agent.ValidationError)
+	assert.Equal(t, agent.StageInit, verr.Stage)
+	assert.Len(t, verr.Errors, 1)
+
+	after, err := ag.GetRunningPlugin(httpID)
+	assert.NoError(t, err)
+	assert.Equal(t, running["URLs"], after["URLs"])
+
+	data, err := ioutil.ReadFile("./updated_config.conf")
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "/nonexistent/ca.pem")
+
+	cancel()
+}
+
+func TestAssistant_UpdatePlugin_DryRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	_, ast := initAgentAndAssistant(ctx, "single_plugin", t)
+
+	memcachedID := ast.getPluginID("memcached")
+	req, err := buildRequest(UPDATE_PLUGIN, pluginInfo{"", "INPUT", map[string]interface{}{
+		"Servers": []string{"127.0.0.1:1"},
+	}, memcachedID})
+	assert.NoError(t, err)
+	req.DryRun = true
+
+	res := ast.handleRequest(ctx, &req)
+	assert.Equal(t, FAILURE, res.Status)
+	assert.Equal(t, agent.StageGather, res.Data.(*agent.ValidationError).Stage)
+
+	req.DryRun = false
+	res = ast.handleRequest(ctx, &req)
+	assert.Equal(t, SUCCESS, res.Status)
+
+	cancel()
+}
+
+func TestAssistant_UpdateOutputPlugin_ConnectFailureKeepsRunningInstance(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ag, ast := initAgentAndAssistant(ctx, "single_plugin", t)
+
+	req, err := buildRequest(START_PLUGIN, pluginInfo{"file", "OUTPUT", nil, ""})
+	assert.NoError(t, err)
+	res := ast.handleRequest(ctx, &req)
+	assert.Equal(t, SUCCESS, res.Status)
+
+	fileID := ast.getPluginID("file")
+
+	req, err = buildRequest(UPDATE_PLUGIN, pluginInfo{"", "OUTPUT", map[string]interface{}{
+		"Files": []string{"/nonexistent/dir/metrics.out"},
+	}, fileID})
+	assert.NoError(t, err)
+
+	res = ast.handleRequest(ctx, &req)
+	assert.Equal(t, FAILURE, res.Status)
+	assert.Equal(t, agent.StageConnect, res.Data.(*agent.ValidationError).Stage)
+
+	after, err := ag.GetRunningPlugin(fileID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"stdout"}, after["Files"])
+
+	cancel()
+}
+
 func TestAssistant_GetAllPlugins(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	_, ast := initAgentAndAssistant(ctx, "single_plugin", t)
 
-	getReq := request{GET_ALL_PLUGINS, "000", pluginInfo{"memcached", "INPUT", nil, ""}}
+	getReq := request{GET_ALL_PLUGINS, "000", pluginInfo{"memcached", "INPUT", nil, ""}, false, subscription{}}
 	res := ast.handleRequest(ctx, &getReq)
 	assert.Equal(t, SUCCESS, res.Status)
 
@@ -432,7 +592,7 @@ func TestAssistant_GetAllRunningPlugins(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	_, ast := initAgentAndAssistant(ctx, "single_plugin", t)
 
-	getReq := request{GET_RUNNING_PLUGINS, "000", pluginInfo{"", "", nil, ""}}
+	getReq := request{GET_RUNNING_PLUGINS, "000", pluginInfo{"", "", nil, ""}, false, subscription{}}
 	res := ast.handleRequest(ctx, &getReq)
 	pList, ok := res.Data.(runningPlugins)
 
@@ -450,11 +610,11 @@ func TestAssistant_StopSinglePlugin(t *testing.T) {
 	_, ast := initAgentAndAssistant(ctx, "single_plugin", t)
 
 	memcachedID := ast.getPluginID("memcached")
-	req := request{STOP_PLUGIN, "123", pluginInfo{"", "INPUT", nil, memcachedID}}
+	req := request{STOP_PLUGIN, "123", pluginInfo{"", "INPUT", nil, memcachedID}, false, subscription{}}
 	res := ast.handleRequest(ctx, &req)
 	assert.Equal(t, SUCCESS, res.Status)
 
-	getReq := request{GET_RUNNING_PLUGINS, "000", pluginInfo{"", "INPUT", nil, memcachedID}}
+	getReq := request{GET_RUNNING_PLUGINS, "000", pluginInfo{"", "INPUT", nil, memcachedID}, false, subscription{}}
 	res2 := ast.handleRequest(ctx, &getReq)
 
 	t.Log(res2)
@@ -641,3 +801,193 @@ func TestAssistant_MultiIDIntegrationTest(t *testing.T) {
 
 	cancel()
 }
+
+func TestAssistant_ProcessorIntegrationTest(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ag, ast := initAgentAndAssistant(ctx, "single_plugin", t)
+
+	startReq, _ := buildRequest(START_PLUGIN, pluginInfo{"override", "PROCESSOR", nil, ""})
+	startRes := ast.handleRequest(ctx, &startReq)
+	assert.Equal(t, SUCCESS, startRes.Status)
+	overrideID, ok := startRes.Data.(map[string]string)
+	assert.True(t, ok)
+	assert.Equal(t, ast.getPluginID("override"), overrideID["id"])
+
+	updateReq, _ := buildRequest(UPDATE_PLUGIN, pluginInfo{"", "PROCESSOR",
+		map[string]interface{}{"NameSuffix": "_suffix", "order": 2}, overrideID["id"]})
+	updateRes := ast.handleRequest(ctx, &updateReq)
+	assert.Equal(t, SUCCESS, updateRes.Status)
+
+	getReq, _ := buildRequest(GET_PLUGIN, pluginInfo{"", "PROCESSOR", nil, overrideID["id"]})
+	getRes := ast.handleRequest(ctx, &getReq)
+	assert.Equal(t, SUCCESS, getRes.Status)
+	dataMap, ok := getRes.Data.(map[string]interface{})
+	assert.True(t, ok)
+	assert.Equal(t, "_suffix", dataMap["NameSuffix"])
+
+	assert.Len(t, ag.Config.Processors, 1)
+	assert.Len(t, ag.Config.AggProcessors, 1)
+	assert.Equal(t, int64(2), ag.Config.Processors[0].Config.Order)
+
+	data, err := ioutil.ReadFile("./updated_config.conf")
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "[[processors.override]]")
+	assert.Contains(t, string(data), "order=2")
+
+	updateReq, _ = buildRequest(UPDATE_PLUGIN, pluginInfo{"", "PROCESSOR",
+		map[string]interface{}{"NotAField": true}, overrideID["id"]})
+	updateRes = ast.handleRequest(ctx, &updateReq)
+	assert.Equal(t, FAILURE, updateRes.Status)
+
+	stopReq, _ := buildRequest(STOP_PLUGIN, pluginInfo{"", "PROCESSOR", nil, overrideID["id"]})
+	stopRes := ast.handleRequest(ctx, &stopReq)
+	assert.Equal(t, SUCCESS, stopRes.Status)
+	assert.Equal(t, "", ast.getPluginID("override"))
+	assert.Len(t, ag.Config.Processors, 0)
+	assert.Len(t, ag.Config.AggProcessors, 0)
+
+	data, err = ioutil.ReadFile("./updated_config.conf")
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "[[processors.override]]")
+
+	cancel()
+}
+
+func TestAssistant_AggregatorIntegrationTest(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ag, ast := initAgentAndAssistant(ctx, "single_plugin", t)
+
+	startReq, _ := buildRequest(START_PLUGIN, pluginInfo{"basicstats", "AGGREGATOR", nil, ""})
+	startRes := ast.handleRequest(ctx, &startReq)
+	assert.Equal(t, SUCCESS, startRes.Status)
+	statsID, ok := startRes.Data.(map[string]string)
+	assert.True(t, ok)
+	assert.Equal(t, ast.getPluginID("basicstats"), statsID["id"])
+	assert.Len(t, ag.Config.Aggregators, 1)
+
+	updateReq, _ := buildRequest(UPDATE_PLUGIN, pluginInfo{"", "AGGREGATOR",
+		map[string]interface{}{"Stats": []string{"count"}}, statsID["id"]})
+	updateRes := ast.handleRequest(ctx, &updateReq)
+	assert.Equal(t, SUCCESS, updateRes.Status)
+
+	getReq, _ := buildRequest(GET_PLUGIN, pluginInfo{"", "AGGREGATOR", nil, statsID["id"]})
+	getRes := ast.handleRequest(ctx, &getReq)
+	assert.Equal(t, SUCCESS, getRes.Status)
+	dataMap, ok := getRes.Data.(map[string]interface{})
+	assert.True(t, ok)
+	assert.Equal(t, []string{"count"}, dataMap["Stats"])
+
+	data, err := ioutil.ReadFile("./updated_config.conf")
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "[[aggregators.basicstats]]")
+
+	stopReq, _ := buildRequest(STOP_PLUGIN, pluginInfo{"", "AGGREGATOR", nil, statsID["id"]})
+	stopRes := ast.handleRequest(ctx, &stopReq)
+	assert.Equal(t, SUCCESS, stopRes.Status)
+	assert.Equal(t, "", ast.getPluginID("basicstats"))
+	assert.Len(t, ag.Config.Aggregators, 0)
+
+	cancel()
+}
+
+// connectToServer connects the assistant to a test websocket server and
+// returns the server side of the connection.
+func connectToServer(t *testing.T, ast *Assistant) *websocket.Conn {
+	serverConns := make(chan *websocket.Conn, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		serverConns <- conn
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	ast.conn = conn
+
+	return <-serverConns
+}
+
+func TestAssistant_SubscribeToPluginStatus(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, ast := initAgentAndAssistant(ctx, "single_plugin", t)
+	server := connectToServer(t, ast)
+
+	memcachedID := ast.getPluginID("memcached")
+	req := request{SUBSCRIBE, "sub", pluginInfo{}, false, subscription{[]string{memcachedID}, "100ms"}}
+	res := ast.handleRequest(ctx, &req)
+	require.Equal(t, SUCCESS, res.Status, res.Data)
+
+	for i := 0; i < 2; i++ {
+		var ev struct {
+			Event string
+			UUID  string
+			Data  agent.PluginStatus
+		}
+		require.NoError(t, server.SetReadDeadline(time.Now().Add(5*time.Second)))
+		require.NoError(t, server.ReadJSON(&ev))
+		require.Equal(t, PLUGIN_STATUS, ev.Event)
+		require.Equal(t, "sub", ev.UUID)
+		require.Equal(t, memcachedID, ev.Data.UniqueId)
+		require.Equal(t, "INPUT", ev.Data.Type)
+		require.Equal(t, "memcached", ev.Data.Name)
+		require.NotNil(t, ev.Data.Input)
+	}
+
+	req = request{UNSUBSCRIBE, "unsub", pluginInfo{}, false, subscription{}}
+	res = ast.handleRequest(ctx, &req)
+	require.Equal(t, SUCCESS, res.Status)
+
+	// Drain an event that may have been sent before unsubscribing.
+	server.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+	for server.ReadJSON(&map[string]interface{}{}) == nil {
+	}
+
+	res = ast.handleRequest(ctx, &req)
+	require.Equal(t, FAILURE, res.Status)
+}
+
+func TestAssistant_SubscribeToUnknownPlugin(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, ast := initAgentAndAssistant(ctx, "single_plugin", t)
+	connectToServer(t, ast)
+
+	req := request{SUBSCRIBE, "sub", pluginInfo{}, false, subscription{[]string{"unknown"}, ""}}
+	res := ast.handleRequest(ctx, &req)
+	require.Equal(t, FAILURE, res.Status)
+
+	req = request{SUBSCRIBE, "sub", pluginInfo{}, false, subscription{nil, "soon"}}
+	res = ast.handleRequest(ctx, &req)
+	require.Equal(t, FAILURE, res.Status)
+}
+
+func TestAssistant_GetPluginStatus(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, ast := initAgentAndAssistant(ctx, "single_plugin", t)
+
+	req, err := buildRequest(START_PLUGIN, pluginInfo{"file", "OUTPUT", nil, ""})
+	require.NoError(t, err)
+	res := ast.handleRequest(ctx, &req)
+	require.Equal(t, SUCCESS, res.Status)
+
+	fileID := ast.getPluginID("file")
+	req = request{GET_PLUGIN_STATUS, "123", pluginInfo{"", "OUTPUT", nil, fileID}, false, subscription{}}
+	res = ast.handleRequest(ctx, &req)
+	require.Equal(t, SUCCESS, res.Status, res.Data)
+
+	status, ok := res.Data.(*agent.PluginStatus)
+	require.True(t, ok)
+	require.Equal(t, "OUTPUT", status.Type)
+	require.NotNil(t, status.Output)
+	require.Equal(t, models.ConnectionStateConnected, status.Output.ConnectionState)
+
+	req = request{GET_PLUGIN_STATUS, "123", pluginInfo{}, false, subscription{}}
+	res = ast.handleRequest(ctx, &req)
+	require.Equal(t, SUCCESS, res.Status)
+	require.NotEmpty(t, res.Data)
+}
@@ -0,0 +1,269 @@
+package assistant
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/influxdata/telegraf/agent"
+	tlsint "github.com/influxdata/telegraf/plugins/common/tls"
+)
+
+/*
+HTTPConfig configures the local control API, which offers the operations of
+the assistant as JSON endpoints without a connection to a remote server.
+*/
+type HTTPConfig struct {
+	// Address to listen on, either "unix:///path/to/socket" or
+	// "tcp://host:port".
+	Address string
+	// Token required in the Authorization header of each request.
+	Token string
+
+	tlsint.ServerConfig
+}
+
+// HTTPServer serves the local control API.
+type HTTPServer struct {
+	config    *HTTPConfig
+	assistant *Assistant
+	server    *http.Server
+	listener  net.Listener
+	ctx       context.Context
+}
+
+// NewHTTPServer returns an HTTPServer for the given config.
+func NewHTTPServer(config *HTTPConfig, agent *agent.Agent) *HTTPServer {
+	return &HTTPServer{
+		config:    config,
+		assistant: &Assistant{agent: agent},
+	}
+}
+
+// pluginTypes maps the plugin types used in the URL paths to the types used
+// in assistant requests.
+var pluginTypes = map[string]string{
+	"inputs":      "INPUT",
+	"outputs":     "OUTPUT",
+	"processors":  "PROCESSOR",
+	"aggregators": "AGGREGATOR",
+}
+
+// maxBodySize limits the size of request bodies, plugin configs are small.
+const maxBodySize = 1 << 20
+
+// Start begins listening for requests, the server runs until the context is
+// done.
+func (s *HTTPServer) Start(ctx context.Context) error {
+	network, address, err := parseAddress(s.config.Address)
+	if err != nil {
+		return err
+	}
+
+	tlsConfig, err := s.config.TLSConfig()
+	if err != nil {
+		return err
+	}
+
+	if network == "tcp" && (tlsConfig == nil || s.config.Token == "") {
+		return errors.New("listening on tcp requires a TLS certificate, key and a token")
+	}
+
+	if network == "unix" {
+		// Remove a socket left behind by a previous run.
+		if err := os.Remove(address); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+
+	if network == "unix" {
+		if err := os.Chmod(address, 0600); err != nil {
+			listener.Close()
+			return err
+		}
+	}
+
+	s.ctx = ctx
+	s.listener = listener
+	s.server = &http.Server{
+		Handler: s.routes(),
+	}
+
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+
+	go func() {
+		err := s.server.Serve(listener)
+		if err != nil && err != http.ErrServerClosed {
+			log.Printf("E! [assistant] Error serving control API: %s", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		s.server.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("I! [assistant] Control API listening on %s://%s", network, listener.Addr())
+	return nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *HTTPServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+func (s *HTTPServer) routes() http.Handler {
+	router := mux.NewRouter()
+	router.HandleFunc("/plugins", s.handle(GET_ALL_PLUGINS)).Methods("GET")
+	router.HandleFunc("/plugins/{type}/{name}/schema", s.handle(GET_PLUGIN_SCHEMA)).Methods("GET")
+	router.HandleFunc("/running", s.handle(GET_RUNNING_PLUGINS)).Methods("GET")
+	router.HandleFunc("/status", s.handle(GET_PLUGIN_STATUS)).Methods("GET")
+	router.HandleFunc("/traces", s.handle(GET_TRACES)).Methods("GET")
+	router.HandleFunc("/running/{type}", s.handle(START_PLUGIN)).Methods("POST")
+	router.HandleFunc("/running/{type}/{id}", s.handle(GET_PLUGIN)).Methods("GET")
+	router.HandleFunc("/running/{type}/{id}", s.handle(UPDATE_PLUGIN)).Methods("PUT", "PATCH")
+	router.HandleFunc("/running/{type}/{id}", s.handle(STOP_PLUGIN)).Methods("DELETE")
+	router.HandleFunc("/running/{type}/{id}/status", s.handle(GET_PLUGIN_STATUS)).Methods("GET")
+	router.HandleFunc("/running/{type}/{id}/traces", s.handle(GET_TRACES)).Methods("GET")
+	return s.authenticate(router)
+}
+
+// authenticate rejects requests without the configured token.
+func (s *HTTPServer) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.config.Token != "" {
+			token := strings.TrimPrefix(r.Header.Get("Authorization"), "Token ")
+			if subtle.ConstantTimeCompare([]byte(token), []byte(s.config.Token)) != 1 {
+				writeResponse(w, http.StatusUnauthorized, response{FAILURE, "", "unauthorized"})
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handle builds an assistant request from the URL and body of the HTTP
+// request and writes the response as JSON.
+func (s *HTTPServer) handle(operation requestType) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		req := request{
+			Operation: operation,
+			UUID:      r.Header.Get("X-Request-Id"),
+			Plugin: pluginInfo{
+				Name:     vars["name"],
+				UniqueId: vars["id"],
+			},
+		}
+
+		if t, ok := vars["type"]; ok {
+			pluginType, ok := pluginTypes[t]
+			if !ok {
+				writeResponse(w, http.StatusNotFound, response{FAILURE, req.UUID, fmt.Sprintf("unknown plugin type %q", t)})
+				return
+			}
+			req.Plugin.Type = pluginType
+		}
+
+		switch operation {
+		case GET_PLUGIN, STOP_PLUGIN, GET_PLUGIN_STATUS:
+			if req.Plugin.UniqueId != "" && !s.isRunningAs(req.Plugin.UniqueId, req.Plugin.Type) {
+				writeResponse(w, http.StatusNotFound, response{FAILURE, req.UUID, fmt.Sprintf("no running %s plugin %q", vars["type"], req.Plugin.UniqueId)})
+				return
+			}
+		}
+
+		switch operation {
+		case START_PLUGIN:
+			var body struct {
+				Name string `json:"name"`
+			}
+			if err := decodeBody(w, r, &body); err != nil {
+				writeResponse(w, http.StatusBadRequest, response{FAILURE, req.UUID, err.Error()})
+				return
+			}
+			req.Plugin.Name = body.Name
+		case UPDATE_PLUGIN:
+			if err := decodeBody(w, r, &req.Plugin.Config); err != nil {
+				writeResponse(w, http.StatusBadRequest, response{FAILURE, req.UUID, err.Error()})
+				return
+			}
+			req.DryRun = r.URL.Query().Get("dry_run") == "true"
+		}
+
+		// Plugins started through the API live as long as the server, not
+		// as long as the HTTP request.
+		res := s.assistant.handleRequest(s.ctx, &req)
+
+		status := http.StatusOK
+		if res.Status != SUCCESS {
+			status = http.StatusBadRequest
+		}
+		writeResponse(w, status, res)
+	}
+}
+
+// isRunningAs reports whether the plugin with the unique id is running and
+// of the given type.
+func (s *HTTPServer) isRunningAs(uid string, pluginType string) bool {
+	status, err := s.assistant.agent.GetPluginStatus(uid)
+	return err == nil && status.Type == pluginType
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("invalid request body: %s", err)
+	}
+	return nil
+}
+
+func writeResponse(w http.ResponseWriter, status int, res response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		log.Printf("E! [assistant] Error while writing response: %s", err)
+	}
+}
+
+// parseAddress splits an address like "unix:///path" or "tcp://host:port"
+// into its network and address.
+func parseAddress(address string) (string, string, error) {
+	parts := strings.SplitN(address, "://", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid address %q, expected unix:// or tcp:// scheme", address)
+	}
+
+	if parts[0] != "unix" && parts[0] != "tcp" {
+		return "", "", fmt.Errorf("unsupported network %q in address %q", parts[0], address)
+	}
+
+	if parts[1] == "" {
+		return "", "", fmt.Errorf("invalid address %q", address)
+	}
+
+	return parts[0], parts[1], nil
+}
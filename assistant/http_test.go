@@ -0,0 +1,205 @@
+package assistant
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pki = testutil.NewPKI("../testutil/pki")
+
+func unixClient(socket string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+}
+
+func doRequest(t *testing.T, client *http.Client, method, url, token string, body interface{}) (int, map[string]interface{}) {
+	var buf bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&buf).Encode(body))
+	}
+
+	req, err := http.NewRequest(method, url, &buf)
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Token "+token)
+	}
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var res map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+	return resp.StatusCode, res
+}
+
+func TestHTTPServer_UnixSocket(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ag, _ := initAgentAndAssistant(ctx, "single_plugin", t)
+
+	socket := filepath.Join(t.TempDir(), "control.sock")
+	srv := NewHTTPServer(&HTTPConfig{Address: "unix://" + socket, Token: "secret"}, ag)
+	require.NoError(t, srv.Start(ctx))
+
+	client := unixClient(socket)
+
+	status, _ := doRequest(t, client, "GET", "http://telegraf/running", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, res := doRequest(t, client, "GET", "http://telegraf/running", "secret", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, SUCCESS, res["Status"])
+	running := res["Data"].(map[string]interface{})
+	assert.Len(t, running["Inputs"], 1)
+
+	status, res = doRequest(t, client, "GET", "http://telegraf/plugins/inputs/memcached/schema", "secret", nil)
+	assert.Equal(t, http.StatusOK, status)
+	schema := res["Data"].(map[string]interface{})["Schema"].(map[string]interface{})
+	assert.Contains(t, schema, "Servers")
+
+	status, res = doRequest(t, client, "POST", "http://telegraf/running/inputs", "secret",
+		map[string]string{"name": "mem"})
+	assert.Equal(t, http.StatusOK, status)
+	id := res["Data"].(map[string]interface{})["id"].(string)
+	assert.NotEmpty(t, id)
+
+	status, res = doRequest(t, client, "GET", "http://telegraf/running/inputs/"+id, "secret", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, SUCCESS, res["Status"])
+
+	status, res = doRequest(t, client, "GET", "http://telegraf/running/inputs/"+id+"/status", "secret", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "mem", res["Data"].(map[string]interface{})["name"])
+	assert.Contains(t, res["Data"], "input")
+
+	status, res = doRequest(t, client, "GET", "http://telegraf/status", "secret", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, res["Data"], 2)
+
+	// The plugin is only found under its own type.
+	status, res = doRequest(t, client, "GET", "http://telegraf/running/outputs/"+id, "secret", nil)
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, FAILURE, res["Status"])
+
+	status, _ = doRequest(t, client, "DELETE", "http://telegraf/running/processors/"+id, "secret", nil)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, res = doRequest(t, client, "DELETE", "http://telegraf/running/inputs/"+id, "secret", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, SUCCESS, res["Status"])
+
+	status, res = doRequest(t, client, "GET", "http://telegraf/running/inputs/"+id, "secret", nil)
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, FAILURE, res["Status"])
+
+	status, _ = doRequest(t, client, "GET", "http://telegraf/running/widgets/"+id, "secret", nil)
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestHTTPServer_UpdatePlugin(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ag, ast := initAgentAndAssistant(ctx, "single_plugin", t)
+
+	socket := filepath.Join(t.TempDir(), "control.sock")
+	srv := NewHTTPServer(&HTTPConfig{Address: "unix://" + socket}, ag)
+	require.NoError(t, srv.Start(ctx))
+
+	client := unixClient(socket)
+	id := ast.getPluginID("memcached")
+
+	status, res := doRequest(t, client, "PATCH", "http://telegraf/running/inputs/"+id, "",
+		map[string]interface{}{"Servers": []string{"127.0.0.1"}})
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, SUCCESS, res["Status"])
+
+	status, res = doRequest(t, client, "GET", "http://telegraf/running/inputs/"+id, "", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []interface{}{"127.0.0.1"}, res["Data"].(map[string]interface{})["Servers"])
+
+	status, res = doRequest(t, client, "PATCH", "http://telegraf/running/inputs/"+id, "",
+		map[string]interface{}{"NotAField": true})
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, FAILURE, res["Status"])
+	assert.Equal(t, "config", res["Data"].(map[string]interface{})["stage"])
+
+	status, res = doRequest(t, client, "PATCH", "http://telegraf/running/inputs/"+id, "",
+		map[string]interface{}{"Servers": []string{strings.Repeat("a", maxBodySize)}})
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, res["Data"], "too large")
+
+	status, res = doRequest(t, client, "PATCH", "http://telegraf/running/inputs/"+id+"?dry_run=true", "",
+		map[string]interface{}{"Servers": []string{"127.0.0.1:1"}})
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "gather", res["Data"].(map[string]interface{})["stage"])
+}
+
+func TestHTTPServer_TCPWithTLS(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ag, _ := initAgentAndAssistant(ctx, "single_plugin", t)
+
+	cfg := &HTTPConfig{Address: "tcp://127.0.0.1:0", Token: "secret"}
+	cfg.TLSCert = pki.ServerCertPath()
+	cfg.TLSKey = pki.ServerKeyPath()
+	cfg.TLSAllowedCACerts = []string{pki.CACertPath()}
+	srv := NewHTTPServer(cfg, ag)
+	require.NoError(t, srv.Start(ctx))
+
+	tlsConfig, err := pki.TLSClientConfig().TLSConfig()
+	require.NoError(t, err)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+
+	status, res := doRequest(t, client, "GET", "https://localhost:"+portOf(srv)+"/plugins", "secret", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, SUCCESS, res["Status"])
+}
+
+func TestHTTPServer_TCPRequiresTLSAndToken(t *testing.T) {
+	srv := NewHTTPServer(&HTTPConfig{Address: "tcp://127.0.0.1:0", Token: "secret"}, nil)
+	require.Error(t, srv.Start(context.Background()))
+
+	cfg := &HTTPConfig{Address: "tcp://127.0.0.1:0"}
+	cfg.ServerConfig = *pki.TLSServerConfig()
+	srv = NewHTTPServer(cfg, nil)
+	require.Error(t, srv.Start(context.Background()))
+}
+
+func TestParseAddress(t *testing.T) {
+	network, address, err := parseAddress("unix:///var/run/telegraf.sock")
+	require.NoError(t, err)
+	assert.Equal(t, "unix", network)
+	assert.Equal(t, "/var/run/telegraf.sock", address)
+
+	network, address, err = parseAddress("tcp://localhost:8125")
+	require.NoError(t, err)
+	assert.Equal(t, "tcp", network)
+	assert.Equal(t, "localhost:8125", address)
+
+	for _, invalid := range []string{"localhost:8125", "udp://localhost:8125", "tcp://"} {
+		_, _, err = parseAddress(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func portOf(srv *HTTPServer) string {
+	_, port, _ := net.SplitHostPort(srv.Addr().String())
+	return port
+}
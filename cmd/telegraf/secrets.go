@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+)
+
+const secretsUsage = `usage: telegraf [--config <file>] [--config-directory <directory>] secrets <command>
+
+The commands are:
+
+  list <store id>        list the keys of the secrets in the store
+  set <store id> <key>   store the secret read from stdin under the key
+`
+
+// runSecrets manages the secrets of the secret stores defined in the config.
+func runSecrets(args []string) error {
+	if len(args) == 0 {
+		return errors.New(secretsUsage)
+	}
+
+	c := config.NewConfig()
+	if err := c.LoadSecretStores(*fConfig); err != nil {
+		return err
+	}
+	if *fConfigDirectory != "" {
+		if err := c.LoadSecretStoresDirectory(*fConfigDirectory); err != nil {
+			return err
+		}
+	}
+
+	store := func(id string) (telegraf.SecretStore, error) {
+		s, ok := c.SecretStores[id]
+		if !ok {
+			return nil, fmt.Errorf("unknown secret store %q", id)
+		}
+		return s, nil
+	}
+
+	switch {
+	case args[0] == "list" && len(args) == 2:
+		s, err := store(args[1])
+		if err != nil {
+			return err
+		}
+		keys, err := s.List()
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			fmt.Println(key)
+		}
+		return nil
+	case args[0] == "set" && len(args) == 3:
+		s, err := store(args[1])
+		if err != nil {
+			return err
+		}
+
+		// The secret is read from stdin to keep it out of the process list
+		// and the shell history.
+		value, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		value = strings.TrimRight(value, "\r\n")
+		return s.Set(args[2], value)
+	}
+	return errors.New(secretsUsage)
+}
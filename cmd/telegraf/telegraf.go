@@ -27,6 +27,7 @@ import (
 	"github.com/influxdata/telegraf/plugins/outputs"
 	_ "github.com/influxdata/telegraf/plugins/outputs/all"
 	_ "github.com/influxdata/telegraf/plugins/processors/all"
+	_ "github.com/influxdata/telegraf/plugins/secretstores/all"
 )
 
 // If you update these, update usage.go and usage_windows.go
@@ -36,7 +37,7 @@ var pprofAddr = flag.String("pprof-addr", "",
 	"pprof address to listen on, not activate pprof if empty")
 var fQuiet = flag.Bool("quiet", false,
 	"run in quiet mode")
-var fTest = flag.Bool("test", false, "enable test mode: gather metrics, print them out, and exit. Note: Test mode only runs inputs, not processors, aggregators, or outputs")
+var fTest = flag.Bool("test", false, "enable test mode: gather metrics, run them through the processors and aggregators, print what each output would receive, and exit. Nothing is written by the outputs")
 var fTestWait = flag.Int("test-wait", 0, "wait up to this many seconds for service inputs to complete in test mode")
 var fConfig = flag.String("config", "", "configuration file to load")
 var fConfigDirectory = flag.String("config-directory", "",
@@ -69,6 +70,12 @@ var fRunAsConsole = flag.Bool("console", false, "run as console application (win
 var fPlugins = flag.String("plugin-directory", "",
 	"path to directory containing external plugins")
 var fRunOnce = flag.Bool("once", false, "run one gather and exit")
+var fWatchConfig = flag.Bool("watch-config", false,
+	"watch the config file and directory, restarting only the changed plugins")
+
+// configWatchInterval is how often the config files are checked for changes
+// when --watch-config is set.
+const configWatchInterval = 2 * time.Second
 
 var (
 	version string
@@ -91,6 +98,10 @@ func reloadLoop(
 
 		ctx, cancel := context.WithCancel(context.Background())
 
+		// restart is requested by the config watcher for changes that cannot
+		// be applied to the running agent.
+		restart := make(chan struct{}, 1)
+
 		signals := make(chan os.Signal, 1)
 		signal.Notify(signals, os.Interrupt, syscall.SIGHUP,
 			syscall.SIGTERM, syscall.SIGINT)
@@ -103,39 +114,66 @@ func reloadLoop(
 					reload <- true
 				}
 				cancel()
+			case <-restart:
+				log.Printf("I! Restarting Telegraf to apply the changed config")
+				<-reload
+				reload <- true
+				cancel()
 			case <-stop:
 				cancel()
 			}
 		}()
 
-		err := runAgent(ctx, inputFilters, outputFilters)
+		err := runAgent(ctx, inputFilters, outputFilters, restart)
 		if err != nil && err != context.Canceled {
 			log.Fatalf("E! [telegraf] Error running agent: %v", err)
 		}
 	}
 }
 
-func runAgent(ctx context.Context,
+// loadConfig loads the config file and directory.  If previous is set,
+// plugins that did not change keep the unique ids they have in previous.
+func loadConfig(
 	inputFilters []string,
 	outputFilters []string,
-) error {
-	log.Printf("I! Starting Telegraf %s", version)
-
-	// If no other options are specified, load the config file and run.
+	previous *config.Config,
+) (*config.Config, error) {
 	c := config.NewConfig()
 	c.OutputFilters = outputFilters
 	c.InputFilters = inputFilters
+	if previous != nil {
+		c.ReuseUniqueIds(previous)
+	}
+
 	err := c.LoadConfig(*fConfig)
 	if err != nil {
-		return err
+		c.Discard()
+		return nil, err
 	}
 
 	if *fConfigDirectory != "" {
 		err = c.LoadDirectory(*fConfigDirectory)
 		if err != nil {
-			return err
+			c.Discard()
+			return nil, err
 		}
 	}
+	return c, nil
+}
+
+func runAgent(ctx context.Context,
+	inputFilters []string,
+	outputFilters []string,
+	restart chan<- struct{},
+) error {
+	log.Printf("I! Starting Telegraf %s", version)
+
+	// If no other options are specified, load the config file and run.
+	c, err := loadConfig(inputFilters, outputFilters, nil)
+	if err != nil {
+		return err
+	}
+
 	if !*fTest && len(c.Outputs) == 0 {
 		return errors.New("Error: no outputs found, did you provide a valid config file?")
 	}
@@ -206,9 +244,85 @@ func runAgent(ctx context.Context,
 		}
 	}
 
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- ag.Run(ctx)
+	}()
+
+	// The assistant, the servers and the config watcher change the plugins
+	// of the pipelines, so they start once the pipelines are running.
+	select {
+	case <-ag.Running():
+	case err := <-errC:
+		return err
+	}
+
 	go startAssistant(ag, ctx)
 
-	return ag.Run(ctx)
+	if *fWatchConfig {
+		go watchConfig(ctx, ag, inputFilters, outputFilters, restart)
+	}
+
+	if c.Agent.ControlAddress != "" {
+		if err := startControlAPI(ag, ctx); err != nil {
+			cancel()
+			<-errC
+			return fmt.Errorf("could not start control API: %v", err)
+		}
+	}
+
+	if c.Agent.DiagnosticsAddress != "" {
+		if err := startDiagnostics(ag, ctx); err != nil {
+			cancel()
+			<-errC
+			return fmt.Errorf("could not start diagnostics server: %v", err)
+		}
+	}
+
+	return <-errC
+}
+
+// watchConfig applies changes of the config files to the running agent until
+// the context is done.  Only plugins that were added, removed or changed are
+// restarted; a restart of the agent is requested if other settings changed.
+func watchConfig(
+	ctx context.Context,
+	ag *agent.Agent,
+	inputFilters []string,
+	outputFilters []string,
+	restart chan<- struct{},
+) {
+	watcher := config.NewWatcher(*fConfig, *fConfigDirectory, configWatchInterval)
+	log.Printf("I! Watching config for changes")
+
+	watcher.Watch(ctx, func() {
+		log.Printf("I! Config changed, reloading changed plugins")
+		c, err := loadConfig(inputFilters, outputFilters, ag.Config)
+		if err != nil {
+			log.Printf("E! Could not load changed config, keeping the running plugins: %v", err)
+			return
+		}
+
+		result, err := ag.ReloadConfig(c)
+		if errors.Is(err, agent.ErrRestartRequired) {
+			log.Printf("I! %v", err)
+			select {
+			case restart <- struct{}{}:
+			default:
+			}
+			return
+		}
+		if err != nil {
+			log.Printf("E! %v", err)
+		}
+		if result != nil {
+			log.Printf("I! Reloaded config: %d plugins started, %d stopped, %d unchanged",
+				len(result.Started), len(result.Stopped), result.Unchanged)
+		}
+	})
 }
 
 func startAssistant(ag *agent.Agent, ctx context.Context) {
@@ -218,6 +332,26 @@ func startAssistant(ag *agent.Agent, ctx context.Context) {
 	}
 }
 
+func startControlAPI(ag *agent.Agent, ctx context.Context) error {
+	cfg := &assistant.HTTPConfig{
+		Address: ag.Config.Agent.ControlAddress,
+		Token:   ag.Config.Agent.ControlToken,
+	}
+	cfg.TLSCert = ag.Config.Agent.ControlTLSCert
+	cfg.TLSKey = ag.Config.Agent.ControlTLSKey
+	cfg.TLSAllowedCACerts = ag.Config.Agent.ControlTLSAllowedCACerts
+
+	return assistant.NewHTTPServer(cfg, ag).Start(ctx)
+}
+
+func startDiagnostics(ag *agent.Agent, ctx context.Context) error {
+	srv := agent.NewDiagnosticsServer(ag.Config.Agent.DiagnosticsAddress, ag)
+	if ag.Config.Agent.DiagnosticsFailureTimeout.Duration > 0 {
+		srv.FailureTimeout = ag.Config.Agent.DiagnosticsFailureTimeout.Duration
+	}
+	return srv.Start(ctx)
+}
+
 func usageExit(rc int) {
 	fmt.Println(internal.Usage)
 	os.Exit(rc)
@@ -311,6 +445,16 @@ func main() {
 				processorFilters,
 			)
 			return
+		case "secrets":
+			if err := runSecrets(args[1:]); err != nil {
+				log.Fatal("E! " + err.Error())
+			}
+			return
+		case "test-config":
+			if err := runTestConfig(args[1:]); err != nil {
+				log.Fatal("E! " + err.Error())
+			}
+			return
 		}
 	}
 
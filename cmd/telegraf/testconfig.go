@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/influxdata/telegraf/agent"
+)
+
+const testConfigUsage = `usage: telegraf [--config <file>] [--config-directory <directory>] test-config <test case>...
+
+Runs the test cases against the parsers and processors of the config.  A test
+case is a TOML file, or a directory of .toml files, with the input, the input
+whose parser reads it and the expected metrics in line protocol.
+`
+
+// runTestConfig runs the test cases given as files or directories and
+// reports the differences between the expected and actual metrics.
+func runTestConfig(args []string) error {
+	if len(args) == 0 {
+		return errors.New(testConfigUsage)
+	}
+
+	var paths []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+
+		matches, err := filepath.Glob(filepath.Join(arg, "*.toml"))
+		if err != nil {
+			return err
+		}
+		paths = append(paths, matches...)
+	}
+
+	failed := 0
+	for _, path := range paths {
+		tc, err := agent.LoadTestCase(path)
+		if err != nil {
+			return err
+		}
+
+		// Each test case gets its own plugins, as processors keep state.
+		c, err := loadConfig(nil, nil, nil)
+		if err != nil {
+			return err
+		}
+
+		diff, err := agent.RunTestCase(c, tc)
+		switch {
+		case err != nil:
+			failed++
+			fmt.Printf("--- FAIL: %s (%s)\n    %v\n", tc.Name, path, err)
+		case diff != "":
+			failed++
+			fmt.Printf("--- FAIL: %s (%s)\n--- expected\n+++ actual\n%s", tc.Name, path, diff)
+		default:
+			fmt.Printf("--- PASS: %s (%s)\n", tc.Name, path)
+		}
+	}
+
+	if failed != 0 {
+		return fmt.Errorf("%d of %d test cases failed", failed, len(paths))
+	}
+	fmt.Printf("%d test cases passed\n", len(paths))
+	return nil
+}
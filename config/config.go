@@ -19,13 +19,17 @@ import (
 	"sync"
 	"time"
 
+	"github.com/alecthomas/units"
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/schedule"
 	"github.com/influxdata/telegraf/models"
 	"github.com/influxdata/telegraf/plugins/aggregators"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/outputs"
 	"github.com/influxdata/telegraf/plugins/parsers"
+	"github.com/influxdata/telegraf/plugins/parsers/json_v2"
+	"github.com/influxdata/telegraf/plugins/parsers/xml"
 	"github.com/influxdata/telegraf/plugins/processors"
 	"github.com/influxdata/telegraf/plugins/serializers"
 	"github.com/influxdata/toml"
@@ -77,8 +81,39 @@ type Config struct {
 	Processors    models.RunningProcessors
 	AggProcessors models.RunningProcessors
 
-	InputsLock  *sync.Mutex
-	OutputsLock *sync.Mutex
+	InputsLock      *sync.Mutex
+	OutputsLock     *sync.Mutex
+	ProcessorsLock  *sync.Mutex
+	AggregatorsLock *sync.Mutex
+
+	fingerprintLock sync.Mutex
+	// fingerprints holds a hash of the settings of each plugin loaded from
+	// the config files by unique id, which is used to find the plugins that
+	// changed when the config is reloaded.
+	fingerprints map[string]string
+	// previousIds are the unique ids of the plugins of the previous config by
+	// fingerprint.
+	previousIds map[string][]string
+
+	// SecretStores holds the secret stores by id, plugin settings reference
+	// their secrets with "@{<id>:<key>}".
+	SecretStores map[string]telegraf.SecretStore
+	secretLock   sync.Mutex
+	// secrets holds the settings each plugin was configured with secrets
+	// for by unique id, for redaction.
+	secrets map[string]pluginSecrets
+
+	// Routes send the metrics they select to a set of outputs, when no
+	// routes are configured all outputs receive all metrics.
+	Routes []*models.Route
+	// routeSettings holds a hash of the settings of each route.
+	routeSettings []string
+
+	// Trace selects the metrics traced through the plugins, tracing is
+	// disabled if nil.
+	Trace *models.TraceConfig
+	// traceSettings holds a hash of the settings of the trace.
+	traceSettings string
 }
 
 // NewConfig creates a new struct to hold the Telegraf config.
@@ -97,15 +132,19 @@ func NewConfig() *Config {
 			LogfileRotationMaxArchives: 5,
 		},
 
-		Tags:          make(map[string]string),
-		Inputs:        make([]*models.RunningInput, 0),
-		Outputs:       make([]*models.RunningOutput, 0),
-		Processors:    make([]*models.RunningProcessor, 0),
-		AggProcessors: make([]*models.RunningProcessor, 0),
-		InputFilters:  make([]string, 0),
-		OutputFilters: make([]string, 0),
-		InputsLock:    new(sync.Mutex),
-		OutputsLock:   new(sync.Mutex),
+		Tags:            make(map[string]string),
+		Inputs:          make([]*models.RunningInput, 0),
+		Outputs:         make([]*models.RunningOutput, 0),
+		Processors:      make([]*models.RunningProcessor, 0),
+		AggProcessors:   make([]*models.RunningProcessor, 0),
+		InputFilters:    make([]string, 0),
+		OutputFilters:   make([]string, 0),
+		InputsLock:      new(sync.Mutex),
+		OutputsLock:     new(sync.Mutex),
+		ProcessorsLock:  new(sync.Mutex),
+		AggregatorsLock: new(sync.Mutex),
+		SecretStores:    make(map[string]telegraf.SecretStore),
+		secrets:         make(map[string]pluginSecrets),
 	}
 
 	tomlCfg := &toml.Config{
@@ -141,6 +180,12 @@ type AgentConfig struct {
 	// same time, which can have a measurable effect on the system.
 	CollectionJitter internal.Duration
 
+	// MaxGatherBackoff is the default limit of the backoff of inputs whose
+	// gathers keep failing or overrunning their interval.  Each consecutive
+	// failure doubles the time until the next gather up to this limit, a
+	// successful gather resets it.  Disabled when "0s".
+	MaxGatherBackoff internal.Duration `toml:"max_gather_backoff"`
+
 	// FlushInterval is the Interval at which to flush data
 	FlushInterval internal.Duration
 
@@ -161,6 +206,14 @@ type AgentConfig struct {
 	// not be less than 2 times MetricBatchSize.
 	MetricBufferLimit int
 
+	// BufferStrategy is the default buffer strategy of the outputs, either
+	// "memory" or "disk".
+	BufferStrategy string `toml:"buffer_strategy"`
+
+	// BufferDirectory is the default directory for outputs using the disk
+	// buffer strategy.  Each output keeps its segments in a subdirectory.
+	BufferDirectory string `toml:"buffer_directory"`
+
 	// FlushBufferWhenFull tells Telegraf to flush the metric buffer whenever
 	// it fills up, regardless of FlushInterval. Setting this option to true
 	// does _not_ deactivate FlushInterval.
@@ -200,6 +253,27 @@ type AgentConfig struct {
 
 	Hostname     string
 	OmitHostname bool
+
+	// ControlAddress enables the local control API on a unix socket
+	// ("unix:///path") or tcp address ("tcp://host:port").
+	ControlAddress string `toml:"control_address"`
+
+	// ControlToken is required in the Authorization header of control API
+	// requests.  Mandatory when listening on tcp.
+	ControlToken string `toml:"control_token"`
+
+	// TLS settings of the control API, mandatory when listening on tcp.
+	ControlTLSCert           string   `toml:"control_tls_cert"`
+	ControlTLSKey            string   `toml:"control_tls_key"`
+	ControlTLSAllowedCACerts []string `toml:"control_tls_allowed_cacerts"`
+
+	// DiagnosticsAddress enables the diagnostics HTTP server on a host:port
+	// address.
+	DiagnosticsAddress string `toml:"diagnostics_address"`
+
+	// DiagnosticsFailureTimeout is how long an output may keep failing
+	// before the liveness check of the diagnostics server fails.
+	DiagnosticsFailureTimeout internal.Duration `toml:"diagnostics_failure_timeout"`
 }
 
 // InputNames returns a list of strings of the configured inputs.
@@ -216,18 +290,22 @@ func (c *Config) InputNames() []string {
 // AggregatorNames returns a list of strings of the configured aggregators.
 func (c *Config) AggregatorNames() []string {
 	var name []string
+	c.AggregatorsLock.Lock()
 	for _, aggregator := range c.Aggregators {
 		name = append(name, aggregator.Config.Name)
 	}
+	c.AggregatorsLock.Unlock()
 	return PluginNameCounts(name)
 }
 
 // ProcessorNames returns a list of strings of the configured processors.
 func (c *Config) ProcessorNames() []string {
 	var name []string
+	c.ProcessorsLock.Lock()
 	for _, processor := range c.Processors {
 		name = append(name, processor.Config.Name)
 	}
+	c.ProcessorsLock.Unlock()
 	return PluginNameCounts(name)
 }
 
@@ -320,12 +398,25 @@ var agentConfig = `
   ## cost of higher maximum memory usage.
   metric_buffer_limit = 10000
 
+  ## Buffer strategy used by the outputs to store unwritten metrics, either
+  ## "memory" or "disk".  With "disk" the metrics are kept in segment files
+  ## below buffer_directory and survive a restart of Telegraf.  Each output
+  ## uses a subdirectory named after its buffer_id, or its name and alias.
+  ## The metric_buffer_limit applies to both strategies.
+  # buffer_strategy = "memory"
+  # buffer_directory = "/var/lib/telegraf/buffer"
+
   ## Collection jitter is used to jitter the collection by a random amount.
   ## Each plugin will sleep for a random time within jitter before collecting.
   ## This can be used to avoid many plugins querying things like sysfs at the
   ## same time, which can have a measurable effect on the system.
   collection_jitter = "0s"
 
+  ## Maximum backoff of inputs whose gathers keep failing or take longer than
+  ## their interval.  Each consecutive failure doubles the time until the next
+  ## gather up to this limit, a successful gather resets it.  Disabled when 0s.
+  # max_gather_backoff = "0s"
+
   ## Default flushing interval for all outputs. Maximum flush_interval will be
   ## flush_interval + flush_jitter
   flush_interval = "10s"
@@ -375,6 +466,22 @@ var agentConfig = `
   ## If set to true, do no set the "host" tag in the telegraf agent.
   omit_hostname = false
 
+  ## Local control API to start, stop, update and inspect plugins while
+  ## Telegraf is running.  Listens on a unix socket or on tcp, tcp requires
+  ## a token and TLS.  Requests must send "Authorization: Token <token>".
+  # control_address = "unix:///var/run/telegraf/control.sock"
+  # control_token = ""
+  # control_tls_cert = "/etc/telegraf/cert.pem"
+  # control_tls_key = "/etc/telegraf/key.pem"
+  # control_tls_allowed_cacerts = ["/etc/telegraf/clientca.pem"]
+
+  ## Diagnostics HTTP server serving the internal metrics in the OpenMetrics
+  ## format on /metrics, the running plugins on /plugins and readiness and
+  ## liveness checks on /readyz and /livez.  The liveness check fails once an
+  ## output keeps failing for diagnostics_failure_timeout.
+  # diagnostics_address = "localhost:9274"
+  # diagnostics_failure_timeout = "5m"
+
 `
 
 var outputHeader = `
@@ -672,6 +779,12 @@ func PrintOutputConfig(name string) error {
 
 // LoadDirectory loads all toml config files found in the specified path, recursively.
 func (c *Config) LoadDirectory(path string) error {
+	return walkConfigDirectory(path, c.LoadConfig)
+}
+
+// walkConfigDirectory calls fn for all *.conf files found in the specified
+// path, recursively.
+func walkConfigDirectory(path string, fn func(path string) error) error {
 	walkfn := func(thispath string, info os.FileInfo, _ error) error {
 		if info == nil {
 			log.Printf("W! Telegraf is not permitted to read %s", thispath)
@@ -690,11 +803,7 @@ func (c *Config) LoadDirectory(path string) error {
 		if len(name) < 6 || name[len(name)-5:] != ".conf" {
 			return nil
 		}
-		err := c.LoadConfig(thispath)
-		if err != nil {
-			return err
-		}
-		return nil
+		return fn(thispath)
 	}
 	return filepath.Walk(path, walkfn)
 }
@@ -804,15 +913,45 @@ func (c *Config) LoadConfigData(data []byte) error {
 		return fmt.Errorf("line %d: configuration specified the fields %q, but they weren't used", tbl.Line, keys(c.UnusedFields))
 	}
 
+	// Parse secret stores before the plugins referencing them:
+	if val, ok := tbl.Fields["secretstores"]; ok {
+		subTable, ok := val.(*ast.Table)
+		if !ok {
+			return fmt.Errorf("invalid configuration, error parsing secretstores table")
+		}
+		if err = c.addSecretStores(subTable); err != nil {
+			return err
+		}
+	}
+
+	if val, ok := tbl.Fields["routes"]; ok {
+		if err = c.addRoutes(val); err != nil {
+			return err
+		}
+	}
+
+	if val, ok := tbl.Fields["trace"]; ok {
+		subTable, ok := val.(*ast.Table)
+		if !ok {
+			return fmt.Errorf("invalid configuration, error parsing trace table")
+		}
+		if err = c.addTrace(subTable); err != nil {
+			return err
+		}
+	}
+
 	// Parse all the rest of the plugins:
 	for name, val := range tbl.Fields {
+		if name == "routes" {
+			continue
+		}
 		subTable, ok := val.(*ast.Table)
 		if !ok {
 			return fmt.Errorf("invalid configuration, error parsing field %q as table", name)
 		}
 
 		switch name {
-		case "agent", "global_tags", "tags":
+		case "agent", "global_tags", "tags", "secretstores", "trace":
 		case "outputs":
 			for pluginName, pluginVal := range subTable.Fields {
 				switch pluginSubTable := pluginVal.(type) {
@@ -955,16 +1094,22 @@ func (c *Config) serializeTable(t *ast.Table, config map[string]interface{}, f *
 			}
 		}
 	}
-	if numTabs == 0 && (parentTableName == "inputs." || parentTableName == "outputs.") {
+	if numTabs == 0 && isPluginTable(parentTableName) {
 		var uniqueId string
 		c.getFieldString(t, "unique_id", &uniqueId)
 		if uniqueId == "" {
-			uniqueId, err := uuid.NewUUID()
+			// Unchanged plugins keep their id when the config is reloaded.
+			uniqueId = c.previousUniqueId(pluginTableType(parentTableName), t.Name, t)
+		}
+		if uniqueId == "" {
+			id, err := uuid.NewUUID()
 			if err != nil {
 				return fmt.Errorf("errored while generating UUID for config serialization")
 			}
-
-			_, err = f.WriteString(tabChars + "  unique_id=\"" + uniqueId.String() + "\"\n")
+			uniqueId = id.String()
+		}
+		if _, ok := t.Fields["unique_id"]; !ok {
+			_, err = f.WriteString(tabChars + "  unique_id=\"" + uniqueId + "\"\n")
 			if err != nil {
 				return fmt.Errorf("Couldn't serialize config")
 			}
@@ -990,16 +1135,40 @@ func (c *Config) serializeTable(t *ast.Table, config map[string]interface{}, f *
 		case *ast.Table:
 			// Recurse to handle indefinitely nested AST
 			tbl := val.(*ast.Table)
-			err := c.serializeTable(tbl, config, f, parentTableName+t.Name+".", numTabs+1, false)
+			err := c.serializeTable(tbl, map[string]interface{}{}, f, parentTableName+t.Name+".", numTabs+1, false)
 			if err != nil {
 				return fmt.Errorf("Couldn't serialize config")
 			}
+		case []*ast.Table:
+			// Arrays of tables such as [[processors.rename.replace]]
+			for _, tbl := range val.([]*ast.Table) {
+				err := c.serializeTable(tbl, map[string]interface{}{}, f, parentTableName+t.Name+".", numTabs+1, true)
+				if err != nil {
+					return fmt.Errorf("Couldn't serialize config")
+				}
+			}
 		}
 	}
 
 	return nil
 }
 
+// isPluginTable returns true if tables with the given parent name are plugin
+// instances that are identified by a unique_id.
+func isPluginTable(parentTableName string) bool {
+	switch parentTableName {
+	case "inputs.", "outputs.", "processors.", "aggregators.":
+		return true
+	}
+	return false
+}
+
+// pluginTableType returns the plugin type of tables with the given parent
+// name.
+func pluginTableType(parentTableName string) string {
+	return strings.TrimSuffix(parentTableName, ".")
+}
+
 func (c *Config) serializePlugin(pluginType string, f *os.File, newConfig map[string]interface{}) error {
 	// Serialize table header
 	pluginName := fmt.Sprintf("%v", newConfig["name"])
@@ -1013,6 +1182,14 @@ func (c *Config) serializePlugin(pluginType string, f *os.File, newConfig map[st
 		creator := outputs.Outputs[pluginName]
 		output := creator()
 		config = output.SampleConfig()
+	} else if pluginType == "processors" {
+		creator := processors.Processors[pluginName]
+		processor := creator()
+		config = processor.SampleConfig()
+	} else if pluginType == "aggregators" {
+		creator := aggregators.Aggregators[pluginName]
+		aggregator := creator()
+		config = aggregator.SampleConfig()
 	} else {
 		return fmt.Errorf("Plugin type was not valid")
 	}
@@ -1092,11 +1269,87 @@ func (c *Config) serializeConfig(data []byte, newConfigPath string, config map[s
 		}
 	}
 
+	// Serialize the trace table:
+	if val, ok := tbl.Fields["trace"]; ok {
+		subTable, ok := val.(*ast.Table)
+		if !ok {
+			return fmt.Errorf("invalid configuration, error parsing trace table")
+		}
+
+		err := c.serializeTable(subTable, map[string]interface{}{}, f, "", 0, false)
+		if err != nil {
+			return fmt.Errorf("Couldn't serialize config")
+		}
+
+		_, err = f.WriteString("\n")
+		if err != nil {
+			return fmt.Errorf("Couldn't serialize config")
+		}
+	}
+
+	// Serialize secret stores:
+	if val, ok := tbl.Fields["secretstores"]; ok {
+		subTable, ok := val.(*ast.Table)
+		if !ok {
+			return fmt.Errorf("invalid configuration, error parsing secretstores table")
+		}
+
+		for name, storeVal := range subTable.Fields {
+			var storeTables []*ast.Table
+			switch v := storeVal.(type) {
+			case *ast.Table:
+				storeTables = []*ast.Table{v}
+			case []*ast.Table:
+				storeTables = v
+			default:
+				return fmt.Errorf("Unsupported config format: %s", name)
+			}
+
+			for _, t := range storeTables {
+				err := c.serializeTable(t, map[string]interface{}{}, f, "secretstores.", 0, true)
+				if err != nil {
+					return fmt.Errorf("Couldn't serialize config")
+				}
+
+				_, err = f.WriteString("\n")
+				if err != nil {
+					return fmt.Errorf("Couldn't serialize config")
+				}
+			}
+		}
+	}
+
+	// Serialize routes:
+	if val, ok := tbl.Fields["routes"]; ok {
+		routeTables, ok := val.([]*ast.Table)
+		if !ok {
+			return fmt.Errorf("invalid configuration, routes must be configured as [[routes]]")
+		}
+
+		for _, t := range routeTables {
+			err := c.serializeTable(t, map[string]interface{}{}, f, "", 0, true)
+			if err != nil {
+				return fmt.Errorf("Couldn't serialize config")
+			}
+
+			_, err = f.WriteString("\n")
+			if err != nil {
+				return fmt.Errorf("Couldn't serialize config")
+			}
+		}
+	}
+
+	// Secrets are never written, only their references.
+	config = c.RedactSecrets(uniqueId, config)
+
 	// check if we have addedPlugin, default to true if not "START_PLUGIN"
 	var addedPlugin = (operationType != "START_PLUGIN")
 
 	// Parse all the rest of the plugins:
 	for pType, val := range tbl.Fields {
+		if pType == "routes" {
+			continue
+		}
 		subTable, ok := val.(*ast.Table)
 		if !ok {
 			return fmt.Errorf("invalid configuration, error parsing field %q as table", pType)
@@ -1263,6 +1516,13 @@ func (c *Config) addAggregator(name string, table *ast.Table) error {
 	}
 	aggregator := creator()
 
+	var uniqueId string
+	c.getFieldString(table, "unique_id", &uniqueId)
+	c.setFingerprint(uniqueId, "aggregators", name, table)
+	if err := c.resolveSecrets(uniqueId, table); err != nil {
+		return err
+	}
+
 	conf, err := c.buildAggregator(name, table)
 	if err != nil {
 		return err
@@ -1272,7 +1532,7 @@ func (c *Config) addAggregator(name string, table *ast.Table) error {
 		return err
 	}
 
-	c.Aggregators = append(c.Aggregators, models.NewRunningAggregator(aggregator, conf))
+	c.Aggregators = append(c.Aggregators, models.NewRunningAggregator(aggregator, conf, uniqueId))
 	return nil
 }
 
@@ -1282,6 +1542,13 @@ func (c *Config) addProcessor(name string, table *ast.Table) error {
 		return fmt.Errorf("Undefined but requested processor: %s", name)
 	}
 
+	var uniqueId string
+	c.getFieldString(table, "unique_id", &uniqueId)
+	c.setFingerprint(uniqueId, "processors", name, table)
+	if err := c.resolveSecrets(uniqueId, table); err != nil {
+		return err
+	}
+
 	processorConfig, err := c.buildProcessor(name, table)
 	if err != nil {
 		return err
@@ -1293,7 +1560,13 @@ func (c *Config) addProcessor(name string, table *ast.Table) error {
 	}
 	c.Processors = append(c.Processors, rf)
 
-	// save a copy for the aggregator
+	// save a copy for the aggregator, each chain gets its own config as the
+	// order may be changed while the chains are running
+	processorConfig, err = c.buildProcessor(name, table)
+	if err != nil {
+		return err
+	}
+
 	rf, err = c.newRunningProcessor(creator, processorConfig, name, table)
 	if err != nil {
 		return err
@@ -1321,7 +1594,9 @@ func (c *Config) newRunningProcessor(
 		}
 	}
 
-	rf := models.NewRunningProcessor(processor, processorConfig)
+	var uniqueId string
+	c.getFieldString(table, "unique_id", &uniqueId)
+	rf := models.NewRunningProcessor(processor, processorConfig, uniqueId)
 	return rf, nil
 }
 
@@ -1335,40 +1610,65 @@ func (c *Config) addOutput(name string, table *ast.Table) error {
 	}
 	output := creator()
 
-	// If the output has a SetSerializer function, then this means it can write
-	// arbitrary types of output, so build the serializer and set it.
-	switch t := output.(type) {
-	case serializers.SerializerOutput:
-		serializer, err := c.buildSerializer(name, table)
-		if err != nil {
-			return err
-		}
-		t.SetSerializer(serializer)
+	var uniqueId string
+	c.getFieldString(table, "unique_id", &uniqueId)
+	c.setFingerprint(uniqueId, "outputs", name, table)
+	if err := c.resolveSecrets(uniqueId, table); err != nil {
+		return err
 	}
 
 	outputConfig, err := c.buildOutput(name, table)
 	if err != nil {
 		return err
 	}
+	if err := c.checkBufferPath(outputConfig); err != nil {
+		return err
+	}
+
+	prepare, err := c.buildOutputPrepare(name, output, table)
+	if err != nil {
+		return err
+	}
+
+	if err := prepare(output); err != nil {
+		return err
+	}
 
 	if err := c.toml.UnmarshalTable(table, output); err != nil {
 		return err
 	}
 
-	var uniqueId string
-	c.getFieldString(table, "unique_id", &uniqueId)
 	ro := models.NewRunningOutput(name, output, outputConfig,
 		c.Agent.MetricBatchSize, c.Agent.MetricBufferLimit, uniqueId)
+	ro.Prepare = prepare
 	c.OutputsLock.Lock()
 	c.Outputs = append(c.Outputs, ro)
 	c.OutputsLock.Unlock()
 	return nil
 }
 
+// checkBufferPath verifies that no other output uses the directory of the
+// disk buffer of the output.
+func (c *Config) checkBufferPath(oc *models.OutputConfig) error {
+	if oc.BufferStrategy != models.BufferStrategyDisk {
+		return nil
+	}
+
+	c.OutputsLock.Lock()
+	defer c.OutputsLock.Unlock()
+	for _, ro := range c.Outputs {
+		if ro.Config.BufferStrategy == models.BufferStrategyDisk && ro.Config.BufferPath() == oc.BufferPath() {
+			return fmt.Errorf("disk buffer directory %s is already used by another output, set alias or buffer_id", oc.BufferPath())
+		}
+	}
+	return nil
+}
+
 func (c *Config) addInput(name string, table *ast.Table) error {
 	if len(c.InputFilters) > 0 && !sliceContains(name, c.InputFilters) {
 		return nil
 	}
+	tableName := name
 	// Legacy support renaming io input to diskio
 	if name == "io" {
 		name = "diskio"
@@ -1380,39 +1680,34 @@ func (c *Config) addInput(name string, table *ast.Table) error {
 	}
 	input := creator()
 
-	// If the input has a SetParser function, then this means it can accept
-	// arbitrary types of input, so build the parser and set it.
-	if t, ok := input.(parsers.ParserInput); ok {
-		parser, err := c.buildParser(name, table)
-		if err != nil {
-			return err
-		}
-		t.SetParser(parser)
+	var uniqueId string
+	c.getFieldString(table, "unique_id", &uniqueId)
+	c.setFingerprint(uniqueId, "inputs", tableName, table)
+	if err := c.resolveSecrets(uniqueId, table); err != nil {
+		return err
 	}
 
-	if t, ok := input.(parsers.ParserFuncInput); ok {
-		config, err := c.getParserConfig(name, table)
-		if err != nil {
-			return err
-		}
-		t.SetParserFunc(func() (parsers.Parser, error) {
-			return parsers.NewParser(config)
-		})
+	pluginConfig, err := c.buildInput(name, table)
+	if err != nil {
+		return err
 	}
 
-	pluginConfig, err := c.buildInput(name, table)
+	prepare, err := c.buildInputPrepare(name, input, table)
 	if err != nil {
 		return err
 	}
 
+	if err := prepare(input); err != nil {
+		return err
+	}
+
 	if err := c.toml.UnmarshalTable(table, input); err != nil {
 		return err
 	}
 
-	var uniqueId string
-	c.getFieldString(table, "unique_id", &uniqueId)
 	rp := models.NewRunningInput(input, pluginConfig, uniqueId)
 	rp.SetDefaultTags(c.Tags)
+	rp.Prepare = prepare
 	c.InputsLock.Lock()
 	c.Inputs = append(c.Inputs, rp)
 	c.InputsLock.Unlock()
@@ -1438,6 +1733,7 @@ func (c *Config) buildAggregator(name string, tbl *ast.Table) (*models.Aggregato
 	c.getFieldString(tbl, "name_suffix", &conf.MeasurementSuffix)
 	c.getFieldString(tbl, "name_override", &conf.NameOverride)
 	c.getFieldString(tbl, "alias", &conf.Alias)
+	c.getFieldString(tbl, "pipeline", &conf.Pipeline)
 
 	conf.Tags = make(map[string]string)
 	if node, ok := tbl.Fields["tags"]; ok {
@@ -1468,6 +1764,7 @@ func (c *Config) buildProcessor(name string, tbl *ast.Table) (*models.ProcessorC
 
 	c.getFieldInt64(tbl, "order", &conf.Order)
 	c.getFieldString(tbl, "alias", &conf.Alias)
+	c.getFieldString(tbl, "pipeline", &conf.Pipeline)
 
 	if c.hasErrs() {
 		return nil, c.firstErr()
@@ -1482,7 +1779,7 @@ func (c *Config) buildProcessor(name string, tbl *ast.Table) (*models.ProcessorC
 }
 
 // buildFilter builds a Filter
-// (tagpass/tagdrop/namepass/namedrop/fieldpass/fielddrop) to
+// (tagpass/tagdrop/namepass/namedrop/fieldpass/fielddrop/metricpass) to
 // be inserted into the models.OutputConfig/models.InputConfig
 // to be used for glob filtering on tags and measurements
 func (c *Config) buildFilter(tbl *ast.Table) (models.Filter, error) {
@@ -1503,6 +1800,8 @@ func (c *Config) buildFilter(tbl *ast.Table) (models.Filter, error) {
 	c.getFieldStringSlice(tbl, "tagexclude", &f.TagExclude)
 	c.getFieldStringSlice(tbl, "taginclude", &f.TagInclude)
 
+	c.getFieldString(tbl, "metricpass", &f.MetricPass)
+
 	if c.hasErrs() {
 		return f, c.firstErr()
 	}
@@ -1522,10 +1821,26 @@ func (c *Config) buildInput(name string, tbl *ast.Table) (*models.InputConfig, e
 	c.getFieldDuration(tbl, "interval", &cp.Interval)
 	c.getFieldDuration(tbl, "precision", &cp.Precision)
 	c.getFieldDuration(tbl, "collection_jitter", &cp.CollectionJitter)
+	cp.MaxGatherBackoff = c.Agent.MaxGatherBackoff.Duration
+	c.getFieldDuration(tbl, "max_gather_backoff", &cp.MaxGatherBackoff)
 	c.getFieldString(tbl, "name_prefix", &cp.MeasurementPrefix)
 	c.getFieldString(tbl, "name_suffix", &cp.MeasurementSuffix)
 	c.getFieldString(tbl, "name_override", &cp.NameOverride)
 	c.getFieldString(tbl, "alias", &cp.Alias)
+	c.getFieldString(tbl, "pipeline", &cp.Pipeline)
+
+	// A single schedule can be given as a string.
+	var spec string
+	c.getFieldString(tbl, "schedule", &spec)
+	if spec != "" {
+		cp.Schedule = append(cp.Schedule, spec)
+	}
+	c.getFieldStringSlice(tbl, "schedule", &cp.Schedule)
+	if len(cp.Schedule) > 0 {
+		if _, err := schedule.Parse(cp.Schedule); err != nil {
+			c.addError(tbl, err)
+		}
+	}
 
 	cp.Tags = make(map[string]string)
 	if node, ok := tbl.Fields["tags"]; ok {
@@ -1548,15 +1863,39 @@ func (c *Config) buildInput(name string, tbl *ast.Table) (*models.InputConfig, e
 	return cp, nil
 }
 
-// buildParser grabs the necessary entries from the ast.Table for creating
-// a parsers.Parser object, and creates it, which can then be added onto
-// an Input object.
-func (c *Config) buildParser(name string, tbl *ast.Table) (parsers.Parser, error) {
-	config, err := c.getParserConfig(name, tbl)
-	if err != nil {
-		return nil, err
+// buildInputPrepare grabs the necessary entries from the ast.Table for
+// creating a parsers.Parser object, and returns the function that sets it on an
+// Input object.  The function is kept with the running input so new instances
+// of the input can be prepared at runtime.
+func (c *Config) buildInputPrepare(name string, input telegraf.Input, tbl *ast.Table) (func(telegraf.Input) error, error) {
+	var config *parsers.Config
+	switch input.(type) {
+	case parsers.ParserInput, parsers.ParserFuncInput:
+		pc, err := c.getParserConfig(name, tbl)
+		if err != nil {
+			return nil, err
+		}
+		config = pc
 	}
-	return parsers.NewParser(config)
+
+	return func(input telegraf.Input) error {
+		// If the input has a SetParser function, then this means it can accept
+		// arbitrary types of input, so build the parser and set it.
+		if t, ok := input.(parsers.ParserInput); ok {
+			parser, err := parsers.NewParser(config)
+			if err != nil {
+				return err
+			}
+			t.SetParser(parser)
+		}
+
+		if t, ok := input.(parsers.ParserFuncInput); ok {
+			t.SetParserFunc(func() (parsers.Parser, error) {
+				return parsers.NewParser(config)
+			})
+		}
+		return nil
+	}, nil
 }
 
 func (c *Config) getParserConfig(name string, tbl *ast.Table) (*parsers.Config, error) {
@@ -1623,6 +1962,61 @@ func (c *Config) getParserConfig(name string, tbl *ast.Table) (*parsers.Config,
 
 	c.getFieldStringSlice(tbl, "form_urlencoded_tag_keys", &pc.FormUrlencodedTagKeys)
 
+	//for avro parser
+	c.getFieldString(tbl, "avro_schema", &pc.AvroSchema)
+	c.getFieldString(tbl, "avro_schema_file", &pc.AvroSchemaFile)
+	c.getFieldString(tbl, "avro_schema_registry", &pc.AvroSchemaRegistry)
+	c.getFieldString(tbl, "avro_measurement", &pc.AvroMeasurement)
+	c.getFieldString(tbl, "avro_measurement_field", &pc.AvroMeasurementField)
+	c.getFieldStringSlice(tbl, "avro_tags", &pc.AvroTags)
+	c.getFieldStringSlice(tbl, "avro_fields", &pc.AvroFields)
+	c.getFieldString(tbl, "avro_timestamp", &pc.AvroTimestamp)
+	c.getFieldString(tbl, "avro_timestamp_format", &pc.AvroTimestampFormat)
+	c.getFieldString(tbl, "avro_field_separator", &pc.AvroFieldSeparator)
+
+	//for prometheus parser
+	c.getFieldBool(tbl, "prometheus_distribution", &pc.PrometheusDistribution)
+
+	//for prometheusremotewrite parser
+	c.getFieldInt(tbl, "prometheus_metric_version", &pc.PrometheusMetricVersion)
+
+	//for protobuf parser
+	c.getFieldString(tbl, "protobuf_file", &pc.ProtobufFile)
+	c.getFieldStringSlice(tbl, "protobuf_import_paths", &pc.ProtobufImportPaths)
+	c.getFieldString(tbl, "protobuf_message_type", &pc.ProtobufMessageType)
+	c.getFieldString(tbl, "protobuf_schema_registry", &pc.ProtobufSchemaRegistry)
+	c.getFieldString(tbl, "protobuf_measurement", &pc.ProtobufMeasurement)
+	c.getFieldString(tbl, "protobuf_measurement_field", &pc.ProtobufMeasurementField)
+	c.getFieldStringSlice(tbl, "protobuf_tags", &pc.ProtobufTags)
+	c.getFieldStringSlice(tbl, "protobuf_fields", &pc.ProtobufFields)
+	c.getFieldString(tbl, "protobuf_timestamp", &pc.ProtobufTimestamp)
+	c.getFieldString(tbl, "protobuf_timestamp_format", &pc.ProtobufTimestampFormat)
+	c.getFieldString(tbl, "protobuf_field_separator", &pc.ProtobufFieldSeparator)
+
+	//for json_v2 parser
+	if node, ok := tbl.Fields["json_v2"]; ok {
+		if subtbls, ok := node.([]*ast.Table); ok {
+			pc.JSONV2Config = make([]json_v2.Config, len(subtbls))
+			for i, subtbl := range subtbls {
+				if err := c.toml.UnmarshalTable(subtbl, &pc.JSONV2Config[i]); err != nil {
+					c.addError(subtbl, err)
+				}
+			}
+		}
+	}
+
+	//for xml parser
+	if node, ok := tbl.Fields["xml"]; ok {
+		if subtbls, ok := node.([]*ast.Table); ok {
+			pc.XMLConfig = make([]xml.Config, len(subtbls))
+			for i, subtbl := range subtbls {
+				if err := c.toml.UnmarshalTable(subtbl, &pc.XMLConfig[i]); err != nil {
+					c.addError(subtbl, err)
+				}
+			}
+		}
+	}
+
 	pc.MetricName = name
 
 	if c.hasErrs() {
@@ -1632,10 +2026,36 @@ func (c *Config) getParserConfig(name string, tbl *ast.Table) (*parsers.Config,
 	return pc, nil
 }
 
-// buildSerializer grabs the necessary entries from the ast.Table for creating
-// a serializers.Serializer object, and creates it, which can then be added onto
-// an Output object.
-func (c *Config) buildSerializer(name string, tbl *ast.Table) (serializers.Serializer, error) {
+// buildOutputPrepare returns the function that creates the serializer and sets
+// it on an Output object.  The function is kept with the running output so new
+// instances of the output can be prepared at runtime.
+func (c *Config) buildOutputPrepare(name string, output telegraf.Output, tbl *ast.Table) (func(telegraf.Output) error, error) {
+	var config *serializers.Config
+	if _, ok := output.(serializers.SerializerOutput); ok {
+		sc, err := c.getSerializerConfig(name, tbl)
+		if err != nil {
+			return nil, err
+		}
+		config = sc
+	}
+
+	return func(output telegraf.Output) error {
+		// If the output has a SetSerializer function, then this means it can
+		// write arbitrary types of output, so build the serializer and set it.
+		if t, ok := output.(serializers.SerializerOutput); ok {
+			serializer, err := serializers.NewSerializer(config)
+			if err != nil {
+				return err
+			}
+			t.SetSerializer(serializer)
+		}
+		return nil
+	}, nil
+}
+
+// getSerializerConfig grabs the necessary entries from the ast.Table for
+// creating a serializers.Serializer object.
+func (c *Config) getSerializerConfig(name string, tbl *ast.Table) (*serializers.Config, error) {
 	sc := &serializers.Config{TimestampUnits: time.Duration(1 * time.Second)}
 
 	c.getFieldString(tbl, "data_format", &sc.DataFormat)
@@ -1671,7 +2091,7 @@ func (c *Config) buildSerializer(name string, tbl *ast.Table) (serializers.Seria
 		return nil, c.firstErr()
 	}
 
-	return serializers.NewSerializer(sc)
+	return sc, nil
 }
 
 // buildOutput parses output specific items from the ast.Table,
@@ -1696,10 +2116,40 @@ func (c *Config) buildOutput(name string, tbl *ast.Table) (*models.OutputConfig,
 	c.getFieldInt(tbl, "metric_buffer_limit", &oc.MetricBufferLimit)
 	c.getFieldInt(tbl, "metric_batch_size", &oc.MetricBatchSize)
 	c.getFieldString(tbl, "alias", &oc.Alias)
+	c.getFieldString(tbl, "pipeline", &oc.Pipeline)
 	c.getFieldString(tbl, "name_override", &oc.NameOverride)
 	c.getFieldString(tbl, "name_suffix", &oc.NameSuffix)
 	c.getFieldString(tbl, "name_prefix", &oc.NamePrefix)
 
+	oc.BufferStrategy = c.Agent.BufferStrategy
+	oc.BufferDirectory = c.Agent.BufferDirectory
+	c.getFieldString(tbl, "buffer_strategy", &oc.BufferStrategy)
+	c.getFieldString(tbl, "buffer_directory", &oc.BufferDirectory)
+	c.getFieldString(tbl, "buffer_id", &oc.BufferID)
+	c.getFieldSize(tbl, "buffer_max_size", &oc.BufferMaxSize)
+	c.getFieldSize(tbl, "buffer_segment_size", &oc.BufferSegmentSize)
+	c.getFieldString(tbl, "buffer_fsync", &oc.BufferFsync)
+	c.getFieldDuration(tbl, "buffer_fsync_interval", &oc.BufferFsyncInterval)
+
+	switch oc.BufferStrategy {
+	case "", models.BufferStrategyMemory:
+	case models.BufferStrategyDisk:
+		if oc.BufferDirectory == "" {
+			c.addError(tbl, fmt.Errorf("buffer_directory is required for the disk buffer strategy"))
+		}
+	default:
+		c.addError(tbl, fmt.Errorf("unknown buffer strategy %q", oc.BufferStrategy))
+	}
+
+	c.getFieldInt(tbl, "max_series", &oc.SeriesLimit.MaxSeries)
+	c.getFieldInt(tbl, "max_series_per_measurement", &oc.SeriesLimit.MaxSeriesPerMeasurement)
+	c.getFieldString(tbl, "series_limit_action", &oc.SeriesLimit.Action)
+	c.getFieldStringSlice(tbl, "series_limit_strip_tags", &oc.SeriesLimit.StripTags)
+	c.getFieldDuration(tbl, "series_limit_reset", &oc.SeriesLimit.Reset)
+	if err := oc.SeriesLimit.Validate(); err != nil {
+		c.addError(tbl, err)
+	}
+
 	if c.hasErrs() {
 		return nil, c.firstErr()
 	}
@@ -1709,7 +2159,10 @@ func (c *Config) buildOutput(name string, tbl *ast.Table) (*models.OutputConfig,
 
 func (c *Config) missingTomlField(typ reflect.Type, key string) error {
 	switch key {
-	case "alias", "carbon2_format", "collectd_auth_file", "collectd_parse_multivalue",
+	case "alias", "avro_field_separator", "avro_fields", "avro_measurement", "avro_measurement_field",
+		"avro_schema", "avro_schema_file", "avro_schema_registry", "avro_tags", "avro_timestamp",
+		"avro_timestamp_format", "buffer_directory", "buffer_fsync", "buffer_fsync_interval", "buffer_id", "buffer_max_size",
+		"buffer_segment_size", "buffer_strategy", "carbon2_format", "collectd_auth_file", "collectd_parse_multivalue",
 		"collectd_security_level", "collectd_typesdb", "collection_jitter", "csv_column_names",
 		"csv_column_types", "csv_comment", "csv_delimiter", "csv_header_row_count",
 		"csv_measurement_column", "csv_skip_columns", "csv_skip_rows", "csv_tag_columns",
@@ -1721,13 +2174,19 @@ func (c *Config) missingTomlField(typ reflect.Type, key string) error {
 		"grok_custom_patterns", "grok_named_patterns", "grok_patterns", "grok_timezone",
 		"grok_unique_timestamp", "influx_max_line_bytes", "influx_sort_fields", "influx_uint_support",
 		"interval", "json_name_key", "json_query", "json_strict", "json_string_fields",
-		"json_time_format", "json_time_key", "json_timestamp_units", "json_timezone",
-		"metric_batch_size", "metric_buffer_limit", "name_override", "name_prefix",
-		"name_suffix", "namedrop", "namepass", "order", "pass", "period", "precision",
-		"prefix", "prometheus_export_timestamp", "prometheus_sort_metrics", "prometheus_string_as_label",
-		"separator", "splunkmetric_hec_routing", "splunkmetric_multimetric", "tag_keys",
+		"json_time_format", "json_time_key", "json_timestamp_units", "json_timezone", "json_v2",
+		"max_gather_backoff", "max_series", "max_series_per_measurement", "metric_batch_size",
+		"metric_buffer_limit", "metricpass",
+		"name_override", "name_prefix", "name_suffix", "namedrop", "namepass", "order", "pass", "period", "pipeline", "precision",
+		"prefix", "prometheus_distribution", "prometheus_export_timestamp", "prometheus_metric_version", "prometheus_sort_metrics",
+		"prometheus_string_as_label",
+		"protobuf_field_separator", "protobuf_fields", "protobuf_file", "protobuf_import_paths",
+		"protobuf_measurement", "protobuf_measurement_field", "protobuf_message_type",
+		"protobuf_schema_registry", "protobuf_tags", "protobuf_timestamp", "protobuf_timestamp_format",
+		"schedule", "separator", "series_limit_action", "series_limit_reset", "series_limit_strip_tags",
+		"splunkmetric_hec_routing", "splunkmetric_multimetric", "tag_keys",
 		"tagdrop", "tagexclude", "taginclude", "tagpass", "tags", "template", "templates",
-		"wavefront_source_override", "wavefront_use_strict", "unique_id":
+		"wavefront_source_override", "wavefront_use_strict", "unique_id", "xml":
 
 		// ignore fields that are common to all plugins.
 	default:
@@ -1762,6 +2221,31 @@ func (c *Config) getFieldDuration(tbl *ast.Table, fieldName string, target inter
 	}
 }
 
+func (c *Config) getFieldSize(tbl *ast.Table, fieldName string, target *int64) {
+	if node, ok := tbl.Fields[fieldName]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			switch v := kv.Value.(type) {
+			case *ast.String:
+				size, err := units.ParseStrictBytes(v.Value)
+				if err != nil {
+					c.addError(tbl, fmt.Errorf("error parsing size: %w", err))
+					return
+				}
+				*target = size
+			case *ast.Integer:
+				i, err := v.Int()
+				if err != nil {
+					c.addError(tbl, fmt.Errorf("unexpected int type %q, expecting int", v.Value))
+					return
+				}
+				*target = i
+			default:
+				c.addError(tbl, fmt.Errorf("unknown size value type %q, expecting size", kv.Value.Source()))
+			}
+		}
+	}
+}
+
 func (c *Config) getFieldBool(tbl *ast.Table, fieldName string, target *bool) {
 	var err error
 	if node, ok := tbl.Fields[fieldName]; ok {
@@ -1802,6 +2286,31 @@ func (c *Config) getFieldInt(tbl *ast.Table, fieldName string, target *int) {
 	}
 }
 
+func (c *Config) getFieldFloat(tbl *ast.Table, fieldName string, target *float64) {
+	if node, ok := tbl.Fields[fieldName]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			switch v := kv.Value.(type) {
+			case *ast.Float:
+				f, err := v.Float()
+				if err != nil {
+					c.addError(tbl, fmt.Errorf("unexpected float type %q, expecting float", v.Value))
+					return
+				}
+				*target = f
+			case *ast.Integer:
+				i, err := v.Int()
+				if err != nil {
+					c.addError(tbl, fmt.Errorf("unexpected int type %q, expecting float", v.Value))
+					return
+				}
+				*target = float64(i)
+			default:
+				c.addError(tbl, fmt.Errorf("unknown float value type %q, expecting float", kv.Value.Source()))
+			}
+		}
+	}
+}
+
 func (c *Config) getFieldInt64(tbl *ast.Table, fieldName string, target *int64) {
 	if node, ok := tbl.Fields[fieldName]; ok {
 		if kv, ok := node.(*ast.KeyValue); ok {
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	// some imports are needed to ensure that configs can be properly serialized
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/models"
 	"github.com/influxdata/telegraf/plugins/inputs"
@@ -24,6 +25,7 @@ import (
 	_ "github.com/influxdata/telegraf/plugins/outputs/influxdb_v2"
 	_ "github.com/influxdata/telegraf/plugins/outputs/kafka"
 	"github.com/influxdata/telegraf/plugins/parsers"
+	"github.com/influxdata/telegraf/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -232,6 +234,78 @@ func TestConfig_WrongFieldType(t *testing.T) {
 	assert.Equal(t, "Error loading config file ./testdata/wrong_field_type2.toml: error parsing http_listener_v2, line 2: (http_listener_v2.HTTPListenerV2.Methods) cannot unmarshal TOML string into []string", err.Error())
 }
 
+func TestConfig_MetricPass(t *testing.T) {
+	c := loadReloadConfig(t, `
+[[inputs.mem]]
+  metricpass = 'fields.used_percent > 90 and tags.host =~ "^web-"'
+`, nil)
+	require.Len(t, c.Inputs, 1)
+	require.Equal(t, `fields.used_percent > 90 and tags.host =~ "^web-"`, c.Inputs[0].Config.Filter.MetricPass)
+	require.True(t, c.Inputs[0].Config.Filter.Select(testutil.MustMetric("mem",
+		map[string]string{"host": "web-01"},
+		map[string]interface{}{"used_percent": 95.0},
+		time.Unix(0, 0))))
+	require.False(t, c.Inputs[0].Config.Filter.Select(testutil.MustMetric("mem",
+		map[string]string{"host": "db-01"},
+		map[string]interface{}{"used_percent": 95.0},
+		time.Unix(0, 0))))
+
+	c = NewConfig()
+	err := c.LoadConfigData([]byte(`
+[[inputs.mem]]
+  metricpass = 'host == "web-01"'
+`))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `Error compiling 'metricpass', unknown identifier "host" at position 1`)
+}
+
+func TestConfig_Schedule(t *testing.T) {
+	c := loadReloadConfig(t, `
+[[inputs.mem]]
+  schedule = "every day at 02:00"
+
+[[inputs.cpu]]
+  schedule = ["weekdays every 5m between 08:00-18:00", "TZ=UTC 30 1 * * *"]
+`, nil)
+	require.Len(t, c.Inputs, 2)
+	schedules := map[string][]string{}
+	for _, input := range c.Inputs {
+		schedules[input.Config.Name] = input.Config.Schedule
+	}
+	require.Equal(t, []string{"every day at 02:00"}, schedules["mem"])
+	require.Equal(t, []string{"weekdays every 5m between 08:00-18:00", "TZ=UTC 30 1 * * *"}, schedules["cpu"])
+
+	c = NewConfig()
+	err := c.LoadConfigData([]byte(`
+[[inputs.mem]]
+  schedule = "every day at 25:00"
+`))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `invalid schedule "every day at 25:00": invalid time "25:00", expected HH:MM`)
+}
+
+func TestConfig_MaxGatherBackoff(t *testing.T) {
+	c := loadReloadConfig(t, `
+[agent]
+  max_gather_backoff = "5m"
+
+[[inputs.mem]]
+
+[[inputs.cpu]]
+  max_gather_backoff = "1h"
+`, nil)
+	require.Equal(t, 5*time.Minute, c.Agent.MaxGatherBackoff.Duration)
+	require.Len(t, c.Inputs, 2)
+	for _, input := range c.Inputs {
+		switch input.Config.Name {
+		case "mem":
+			require.Equal(t, 5*time.Minute, input.Config.MaxGatherBackoff)
+		case "cpu":
+			require.Equal(t, time.Hour, input.Config.MaxGatherBackoff)
+		}
+	}
+}
+
 func TestConfig_InlineTables(t *testing.T) {
 	// #4098
 	c := NewConfig()
@@ -289,6 +363,296 @@ func TestConfig_AzureMonitorNamespacePrefix(t *testing.T) {
 	assert.Equal(t, true, ok)
 }
 
+func TestConfig_OutputBufferSettings(t *testing.T) {
+	dir := t.TempDir()
+	c := NewConfig()
+	err := c.LoadConfigData([]byte(`
+[agent]
+  buffer_strategy = "disk"
+  buffer_directory = "` + dir + `"
+
+[[outputs.influxdb]]
+  buffer_max_size = "64MiB"
+  buffer_segment_size = 1048576
+  buffer_fsync = "interval"
+  buffer_fsync_interval = "5s"
+
+[[outputs.influxdb_v2]]
+  buffer_strategy = "memory"
+`))
+	require.NoError(t, err)
+	require.Len(t, c.Outputs, 2)
+
+	for _, output := range c.Outputs {
+		switch output.Config.Name {
+		case "influxdb":
+			require.Equal(t, models.BufferStrategyDisk, output.Config.BufferStrategy)
+			require.Equal(t, dir, output.Config.BufferDirectory)
+			require.Equal(t, int64(64*1024*1024), output.Config.BufferMaxSize)
+			require.Equal(t, int64(1048576), output.Config.BufferSegmentSize)
+			require.Equal(t, models.FsyncInterval, output.Config.BufferFsync)
+			require.Equal(t, 5*time.Second, output.Config.BufferFsyncInterval)
+		case "influxdb_v2":
+			require.Equal(t, models.BufferStrategyMemory, output.Config.BufferStrategy)
+		}
+		require.NoError(t, output.Init())
+	}
+
+	c = NewConfig()
+	err = c.LoadConfigData([]byte(`
+[[outputs.influxdb]]
+  buffer_strategy = "disk"
+`))
+	require.Error(t, err)
+}
+
+func TestConfig_OutputDiskBufferRestart(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/telegraf.conf"
+	require.NoError(t, ioutil.WriteFile(path, []byte(`
+[agent]
+  buffer_strategy = "disk"
+  buffer_directory = "`+dir+`/buffer"
+
+[[outputs.influxdb]]
+`), 0644))
+	defer os.Remove(updatedConfigPath)
+
+	c := NewConfig()
+	require.NoError(t, c.LoadConfig(path))
+	require.Len(t, c.Outputs, 1)
+	output := c.Outputs[0]
+	uid := output.UniqueId
+	require.NoError(t, output.OpenBuffer())
+	for _, m := range testutil.MockMetrics() {
+		output.AddMetric(m)
+	}
+	output.AddMetric(testutil.TestMetric(42))
+	output.Discard()
+
+	// A restart loads the config again, the unique id of the output changes
+	// but its buffer is found again.
+	c = NewConfig()
+	require.NoError(t, c.LoadConfig(path))
+	require.Len(t, c.Outputs, 1)
+	output = c.Outputs[0]
+	defer output.Discard()
+	require.NotEqual(t, uid, output.UniqueId)
+	require.NoError(t, output.OpenBuffer())
+	require.Equal(t, 2, output.BufferLength())
+
+	entries, err := ioutil.ReadDir(dir + "/buffer")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "influxdb", entries[0].Name())
+}
+
+func TestConfig_OutputBufferPathConflict(t *testing.T) {
+	dir := t.TempDir()
+	c := NewConfig()
+	err := c.LoadConfigData([]byte(`
+[agent]
+  buffer_strategy = "disk"
+  buffer_directory = "` + dir + `"
+
+[[outputs.influxdb]]
+
+[[outputs.influxdb]]
+`))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "set alias or buffer_id")
+	for _, output := range c.Outputs {
+		output.Discard()
+	}
+
+	c = NewConfig()
+	err = c.LoadConfigData([]byte(`
+[agent]
+  buffer_strategy = "disk"
+  buffer_directory = "` + dir + `"
+
+[[outputs.influxdb]]
+
+[[outputs.influxdb]]
+  alias = "eu"
+
+[[outputs.influxdb]]
+  buffer_id = "influxdb-us"
+`))
+	require.NoError(t, err)
+	require.Len(t, c.Outputs, 3)
+	for _, output := range c.Outputs {
+		require.NoError(t, output.OpenBuffer())
+		output.Discard()
+	}
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+}
+
+func TestConfig_OutputSeriesLimit(t *testing.T) {
+	c := NewConfig()
+	err := c.LoadConfigData([]byte(`
+[[outputs.influxdb]]
+  max_series = 100000
+  max_series_per_measurement = 1000
+  series_limit_action = "strip_tags"
+  series_limit_strip_tags = ["container_id"]
+  series_limit_reset = "1h"
+`))
+	require.NoError(t, err)
+	require.Len(t, c.Outputs, 1)
+	require.Equal(t, models.SeriesLimitConfig{
+		MaxSeries:               100000,
+		MaxSeriesPerMeasurement: 1000,
+		Action:                  models.SeriesLimitStripTags,
+		StripTags:               []string{"container_id"},
+		Reset:                   time.Hour,
+	}, c.Outputs[0].Config.SeriesLimit)
+
+	c = NewConfig()
+	err = c.LoadConfigData([]byte(`
+[[outputs.influxdb]]
+  max_series = 100
+  series_limit_action = "strip_tags"
+`))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "series_limit_strip_tags is required")
+}
+
+// parserInput captures the parser set by the config.
+type parserInput struct {
+	parser parsers.Parser
+}
+
+func (p *parserInput) SampleConfig() string                  { return "" }
+func (p *parserInput) Description() string                   { return "" }
+func (p *parserInput) Gather(acc telegraf.Accumulator) error { return nil }
+func (p *parserInput) SetParser(parser parsers.Parser)       { p.parser = parser }
+
+func TestConfig_XMLParser(t *testing.T) {
+	c := NewConfig()
+	err := c.LoadConfigData([]byte(`
+[[inputs.exec]]
+  commands = ["cat status.xml"]
+  data_format = "xml"
+
+  [[inputs.exec.xml]]
+    metric_selection = "//Sensor"
+    [inputs.exec.xml.tags]
+      id = "@id"
+    [inputs.exec.xml.fields]
+      value = "number(Value)"
+
+  [[inputs.exec.xml]]
+    metric_name = "'device'"
+    [inputs.exec.xml.fields_int]
+      uptime = "/Device/Uptime"
+`))
+	require.NoError(t, err)
+	require.Len(t, c.Inputs, 1)
+
+	input := &parserInput{}
+	require.NoError(t, c.Inputs[0].Prepare(input))
+
+	metrics, err := input.parser.Parse([]byte(`
+<Device>
+  <Uptime>42</Uptime>
+  <Sensor id="temp1"><Value>21.5</Value></Sensor>
+</Device>`))
+	require.NoError(t, err)
+	expected := []telegraf.Metric{
+		testutil.MustMetric("exec",
+			map[string]string{"id": "temp1"},
+			map[string]interface{}{"value": 21.5},
+			time.Unix(0, 0)),
+		testutil.MustMetric("device",
+			map[string]string{},
+			map[string]interface{}{"uptime": int64(42)},
+			time.Unix(0, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, metrics, testutil.IgnoreTime())
+
+	c = NewConfig()
+	err = c.LoadConfigData([]byte(`
+[[inputs.exec]]
+  commands = ["cat status.xml"]
+  data_format = "xml"
+
+  [[inputs.exec.xml]]
+    metric_selection = "//Sensor["
+`))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid xpath")
+}
+
+func TestConfig_JSONV2Parser(t *testing.T) {
+	c := NewConfig()
+	err := c.LoadConfigData([]byte(`
+[[inputs.exec]]
+  commands = ["cat status.json"]
+  data_format = "json_v2"
+
+  [[inputs.exec.json_v2]]
+    measurement_name = "sensor"
+    [[inputs.exec.json_v2.tag]]
+      path = "device"
+    [[inputs.exec.json_v2.object]]
+      path = "sensors"
+      tags = ["id"]
+      [inputs.exec.json_v2.object.fields]
+        value = "int"
+`))
+	require.NoError(t, err)
+	require.Len(t, c.Inputs, 1)
+
+	input := &parserInput{}
+	require.NoError(t, c.Inputs[0].Prepare(input))
+
+	metrics, err := input.parser.Parse([]byte(`
+{"device": "plc1", "sensors": [{"id": "temp1", "value": "21"}, {"id": "temp2", "value": 70}]}`))
+	require.NoError(t, err)
+	expected := []telegraf.Metric{
+		testutil.MustMetric("sensor",
+			map[string]string{"device": "plc1", "id": "temp1"},
+			map[string]interface{}{"value": int64(21)},
+			time.Unix(0, 0)),
+		testutil.MustMetric("sensor",
+			map[string]string{"device": "plc1", "id": "temp2"},
+			map[string]interface{}{"value": int64(70)},
+			time.Unix(0, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, metrics, testutil.IgnoreTime())
+}
+
+func TestConfig_AvroParser(t *testing.T) {
+	c := NewConfig()
+	err := c.LoadConfigData([]byte(`
+[[inputs.exec]]
+  commands = ["cat reading.avro"]
+  data_format = "avro"
+  avro_schema = '{"type": "record", "name": "Reading", "fields": [{"name": "device", "type": "string"}, {"name": "value", "type": "long"}]}'
+  avro_measurement = "reading"
+  avro_tags = ["device"]
+`))
+	require.NoError(t, err)
+	require.Len(t, c.Inputs, 1)
+
+	input := &parserInput{}
+	require.NoError(t, c.Inputs[0].Prepare(input))
+
+	// "plc1" and 42, zigzag encoded.
+	metrics, err := input.parser.Parse([]byte{8, 'p', 'l', 'c', '1', 84})
+	require.NoError(t, err)
+	expected := []telegraf.Metric{
+		testutil.MustMetric("reading",
+			map[string]string{"device": "plc1"},
+			map[string]interface{}{"value": int64(42)},
+			time.Unix(0, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, metrics, testutil.IgnoreTime())
+}
+
 func TestConfig_SerializeSameConfig(t *testing.T) {
 	c := NewConfig()
 	err := c.LoadConfig("./testdata/basic_config.toml")
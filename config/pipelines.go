@@ -0,0 +1,31 @@
+package config
+
+import (
+	"sort"
+)
+
+// Pipelines returns the sorted names of the pipelines the plugins belong to.
+// Plugins without a pipeline setting belong to the default pipeline named
+// "", which is always included.
+func (c *Config) Pipelines() []string {
+	names := map[string]bool{"": true}
+	for _, input := range c.Inputs {
+		names[input.Config.Pipeline] = true
+	}
+	for _, processor := range c.Processors {
+		names[processor.Config.Pipeline] = true
+	}
+	for _, aggregator := range c.Aggregators {
+		names[aggregator.Config.Pipeline] = true
+	}
+	for _, output := range c.Outputs {
+		names[output.Config.Pipeline] = true
+	}
+
+	pipelines := make([]string, 0, len(names))
+	for name := range names {
+		pipelines = append(pipelines, name)
+	}
+	sort.Strings(pipelines)
+	return pipelines
+}
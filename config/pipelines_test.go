@@ -0,0 +1,33 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_Pipelines(t *testing.T) {
+	c := loadReloadConfig(t, `
+[[inputs.mem]]
+  pipeline = "team_a"
+
+[[inputs.cpu]]
+
+[[processors.reverse_dns]]
+  pipeline = "team_b"
+
+[[outputs.file]]
+  pipeline = "team_a"
+`, nil)
+	require.Equal(t, []string{"", "team_a", "team_b"}, c.Pipelines())
+	require.Equal(t, "team_b", c.Processors[0].Config.Pipeline)
+	require.Equal(t, "team_b", c.AggProcessors[0].Config.Pipeline)
+	require.Equal(t, "team_a", c.Outputs[0].Config.Pipeline)
+	for _, input := range c.Inputs {
+		if input.Config.Name == "mem" {
+			require.Equal(t, "team_a", input.Config.Pipeline)
+		} else {
+			require.Equal(t, "", input.Config.Pipeline)
+		}
+	}
+}
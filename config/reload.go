@@ -0,0 +1,122 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"sort"
+
+	"github.com/influxdata/toml/ast"
+)
+
+// pluginFingerprint returns a hash of the settings of a plugin table, which
+// changes when any setting other than the unique_id is changed.
+func pluginFingerprint(pluginType, name string, tbl *ast.Table) string {
+	h := sha256.New()
+	io.WriteString(h, pluginType+"."+name+"\n")
+	writeTableSettings(h, tbl, true)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeTableSettings writes the keys and values of the table in a stable
+// order, the source of the config may list them in any order.
+func writeTableSettings(w io.Writer, tbl *ast.Table, top bool) {
+	keys := make([]string, 0, len(tbl.Fields))
+	for key := range tbl.Fields {
+		if top && key == "unique_id" {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		switch val := tbl.Fields[key].(type) {
+		case *ast.KeyValue:
+			io.WriteString(w, key+"="+val.Value.Source()+"\n")
+		case *ast.Table:
+			io.WriteString(w, "["+key+"]\n")
+			writeTableSettings(w, val, false)
+			io.WriteString(w, "[/"+key+"]\n")
+		case []*ast.Table:
+			for _, t := range val {
+				io.WriteString(w, "[["+key+"]]\n")
+				writeTableSettings(w, t, false)
+				io.WriteString(w, "[[/"+key+"]]\n")
+			}
+		}
+	}
+}
+
+// setFingerprint records the settings the plugin with the unique id was
+// loaded with.
+func (c *Config) setFingerprint(uniqueId, pluginType, name string, tbl *ast.Table) {
+	if uniqueId == "" {
+		return
+	}
+	c.fingerprintLock.Lock()
+	defer c.fingerprintLock.Unlock()
+	if c.fingerprints == nil {
+		c.fingerprints = make(map[string]string)
+	}
+	c.fingerprints[uniqueId] = pluginFingerprint(pluginType, name, tbl)
+}
+
+// Fingerprints returns a hash of the settings of each plugin loaded from the
+// config files, keyed by the unique id of the plugin.  Plugins started while
+// the agent is running are not included.
+func (c *Config) Fingerprints() map[string]string {
+	c.fingerprintLock.Lock()
+	defer c.fingerprintLock.Unlock()
+	fingerprints := make(map[string]string, len(c.fingerprints))
+	for uid, fingerprint := range c.fingerprints {
+		fingerprints[uid] = fingerprint
+	}
+	return fingerprints
+}
+
+// SetFingerprints replaces the settings recorded for the loaded plugins, it
+// is used once the plugins of a reloaded config have been applied.
+func (c *Config) SetFingerprints(fingerprints map[string]string) {
+	c.fingerprintLock.Lock()
+	defer c.fingerprintLock.Unlock()
+	c.fingerprints = make(map[string]string, len(fingerprints))
+	for uid, fingerprint := range fingerprints {
+		c.fingerprints[uid] = fingerprint
+	}
+}
+
+// ReuseUniqueIds makes plugins without a unique_id in the config files keep
+// the ids they were given by the previous config, if their settings did not
+// change.  Call it before loading the config files.
+func (c *Config) ReuseUniqueIds(previous *Config) {
+	c.previousIds = make(map[string][]string)
+	for uid, fingerprint := range previous.Fingerprints() {
+		c.previousIds[fingerprint] = append(c.previousIds[fingerprint], uid)
+	}
+	for _, uids := range c.previousIds {
+		sort.Strings(uids)
+	}
+}
+
+// previousUniqueId returns the unique id of an unchanged plugin of the
+// previous config, or "" if there is none.  Each id is only handed out once.
+func (c *Config) previousUniqueId(pluginType, name string, tbl *ast.Table) string {
+	fingerprint := pluginFingerprint(pluginType, name, tbl)
+	uids := c.previousIds[fingerprint]
+	if len(uids) == 0 {
+		return ""
+	}
+	c.previousIds[fingerprint] = uids[1:]
+	return uids[0]
+}
+
+// Discard releases the outputs of a loaded config that is not run, such as a
+// reloaded config that is not applied.
+func (c *Config) Discard() {
+	c.OutputsLock.Lock()
+	defer c.OutputsLock.Unlock()
+	for _, output := range c.Outputs {
+		output.Discard()
+	}
+}
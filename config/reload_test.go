@@ -0,0 +1,127 @@
+package config
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const reloadConfig = `
+[[inputs.memcached]]
+  servers = ["localhost"]
+
+[[inputs.memcached]]
+  servers = ["otherhost"]
+
+[[outputs.http]]
+  url = "http://localhost"
+`
+
+func loadReloadConfig(t *testing.T, data string, previous *Config) *Config {
+	dir, err := ioutil.TempDir("", "telegraf-reload")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer os.Remove("./updated_config.conf")
+
+	path := filepath.Join(dir, "telegraf.conf")
+	require.NoError(t, ioutil.WriteFile(path, []byte(data), 0644))
+
+	c := NewConfig()
+	if previous != nil {
+		c.ReuseUniqueIds(previous)
+	}
+	require.NoError(t, c.LoadConfig(path))
+	return c
+}
+
+func TestConfig_ReuseUniqueIds(t *testing.T) {
+	c := loadReloadConfig(t, reloadConfig, nil)
+	require.Len(t, c.Fingerprints(), 3)
+
+	// Unchanged plugins keep their ids.
+	c2 := loadReloadConfig(t, reloadConfig, c)
+	require.Equal(t, c.Fingerprints(), c2.Fingerprints())
+	require.Equal(t, c.Inputs[0].UniqueId, c2.Inputs[0].UniqueId)
+	require.Equal(t, c.Inputs[1].UniqueId, c2.Inputs[1].UniqueId)
+	require.Equal(t, c.Outputs[0].UniqueId, c2.Outputs[0].UniqueId)
+
+	// A changed plugin gets a new id.
+	changed := `
+[[inputs.memcached]]
+  servers = ["localhost"]
+
+[[inputs.memcached]]
+  servers = ["changedhost"]
+
+[[outputs.http]]
+  url = "http://localhost"
+`
+	c3 := loadReloadConfig(t, changed, c2)
+	require.Equal(t, c2.Inputs[0].UniqueId, c3.Inputs[0].UniqueId)
+	require.NotEqual(t, c2.Inputs[1].UniqueId, c3.Inputs[1].UniqueId)
+	require.Equal(t, c2.Outputs[0].UniqueId, c3.Outputs[0].UniqueId)
+	require.NotContains(t, c3.Fingerprints(), c2.Inputs[1].UniqueId)
+}
+
+func TestConfig_FingerprintIgnoresUniqueId(t *testing.T) {
+	withId := `
+[[inputs.memcached]]
+  unique_id = "memcached-1"
+  servers = ["localhost"]
+`
+	c := loadReloadConfig(t, withId, nil)
+	require.Equal(t, "memcached-1", c.Inputs[0].UniqueId)
+
+	c2 := loadReloadConfig(t, reloadConfig, nil)
+	require.Equal(t, c2.Fingerprints()[c2.Inputs[0].UniqueId], c.Fingerprints()["memcached-1"])
+}
+
+func TestWatcher_DetectsChanges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telegraf-watch")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "telegraf.conf")
+	require.NoError(t, ioutil.WriteFile(file, []byte(reloadConfig), 0644))
+	confDir := filepath.Join(dir, "telegraf.d")
+	require.NoError(t, os.Mkdir(confDir, 0755))
+
+	w := NewWatcher(file, confDir, 10*time.Millisecond)
+	require.Len(t, w.sums, 1)
+
+	changes := make(chan struct{}, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Watch(ctx, func() { changes <- struct{}{} })
+
+	expectChange := func() {
+		select {
+		case <-changes:
+		case <-time.After(5 * time.Second):
+			t.Fatal("change not detected")
+		}
+	}
+
+	// Files that are not *.conf are ignored.
+	require.NoError(t, ioutil.WriteFile(filepath.Join(confDir, "notes.txt"), []byte("x"), 0644))
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(confDir, "mem.conf"), []byte("[[inputs.mem]]\n"), 0644))
+	expectChange()
+
+	require.NoError(t, ioutil.WriteFile(file, []byte(reloadConfig+"\n[[inputs.cpu]]\n"), 0644))
+	expectChange()
+
+	require.NoError(t, os.Remove(filepath.Join(confDir, "mem.conf")))
+	expectChange()
+
+	select {
+	case <-changes:
+		t.Fatal("unexpected change")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/influxdata/telegraf/models"
+	"github.com/influxdata/toml/ast"
+)
+
+// addRoutes parses the [[routes]] tables, routes are kept in the order they
+// are configured in.
+func (c *Config) addRoutes(val interface{}) error {
+	tables, ok := val.([]*ast.Table)
+	if !ok {
+		return fmt.Errorf("invalid configuration, routes must be configured as [[routes]]")
+	}
+
+	for _, tbl := range tables {
+		if err := c.addRoute(tbl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Config) addRoute(tbl *ast.Table) error {
+	rc := &models.RouteConfig{}
+	c.getFieldString(tbl, "name", &rc.Name)
+	c.getFieldStringSlice(tbl, "outputs", &rc.Outputs)
+	if c.hasErrs() {
+		return c.firstErr()
+	}
+	if rc.Name == "" {
+		return fmt.Errorf("line %d: route without a name", tbl.Line)
+	}
+	for _, route := range c.Routes {
+		if route.Config.Name == rc.Name {
+			return fmt.Errorf("line %d: duplicate route %q", tbl.Line, rc.Name)
+		}
+	}
+
+	for key := range tbl.Fields {
+		switch key {
+		case "name", "outputs", "namepass", "namedrop", "pass", "fieldpass",
+			"drop", "fielddrop", "tagpass", "tagdrop", "metricpass":
+		default:
+			c.UnusedFields[key] = true
+		}
+	}
+	if len(c.UnusedFields) > 0 {
+		return fmt.Errorf("route %s: line %d: configuration specified the fields %q, but they weren't used", rc.Name, tbl.Line, keys(c.UnusedFields))
+	}
+
+	var err error
+	rc.Filter, err = c.buildFilter(tbl)
+	if err != nil {
+		return fmt.Errorf("route %s: %w", rc.Name, err)
+	}
+	if rc.Name == models.UnroutedRoute && rc.Filter.IsActive() {
+		return fmt.Errorf("route %s: the fallback route cannot filter metrics", rc.Name)
+	}
+
+	c.Routes = append(c.Routes, models.NewRoute(rc))
+	c.routeSettings = append(c.routeSettings, pluginFingerprint("routes", rc.Name, tbl))
+	return nil
+}
+
+// RoutesFingerprint returns a hash of the settings of the routes, which
+// changes when any route is added, removed or changed.
+func (c *Config) RoutesFingerprint() string {
+	return strings.Join(c.routeSettings, ",")
+}
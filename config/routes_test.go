@@ -0,0 +1,122 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/models"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+const routesConfig = `
+[[outputs.file]]
+  alias = "eu"
+
+[[outputs.file]]
+  alias = "rest"
+
+[[routes]]
+  name = "eu"
+  outputs = ["eu"]
+  namepass = ["cpu"]
+  [routes.tagpass]
+    region = ["eu-*"]
+
+[[routes]]
+  name = "unrouted"
+  outputs = ["rest"]
+`
+
+func TestConfig_Routes(t *testing.T) {
+	c := loadReloadConfig(t, routesConfig, nil)
+	require.Len(t, c.Routes, 2)
+
+	eu := c.Routes[0]
+	require.Equal(t, "eu", eu.Config.Name)
+	require.Equal(t, []string{"eu"}, eu.Config.Outputs)
+	require.True(t, eu.Match(testutil.MustMetric("cpu",
+		map[string]string{"region": "eu-west"},
+		map[string]interface{}{"usage_idle": 42.0},
+		time.Unix(0, 0))))
+	require.False(t, eu.Match(testutil.MustMetric("cpu",
+		map[string]string{"region": "us-east"},
+		map[string]interface{}{"usage_idle": 42.0},
+		time.Unix(0, 0))))
+
+	require.True(t, c.Routes[1].IsUnrouted())
+	require.Equal(t, []string{"rest"}, c.Routes[1].Config.Outputs)
+
+	// The fingerprint only changes with the settings of the routes.
+	c2 := loadReloadConfig(t, routesConfig, nil)
+	require.Equal(t, c.RoutesFingerprint(), c2.RoutesFingerprint())
+	c2 = loadReloadConfig(t, strings.Replace(routesConfig, `"eu-*"`, `"eu-west"`, 1), nil)
+	require.NotEqual(t, c.RoutesFingerprint(), c2.RoutesFingerprint())
+}
+
+func TestConfig_RoutesErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telegraf-routes")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer os.Remove("./updated_config.conf")
+
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			name:   "missing name",
+			config: strings.Replace(routesConfig, `name = "eu"`, "", 1),
+			err:    "route without a name",
+		},
+		{
+			name:   "duplicate name",
+			config: strings.Replace(routesConfig, `name = "unrouted"`, `name = "eu"`, 1),
+			err:    `duplicate route "eu"`,
+		},
+		{
+			name:   "unknown field",
+			config: strings.Replace(routesConfig, `namepass = ["cpu"]`, `taginclude = ["region"]`, 1),
+			err:    `configuration specified the fields ["taginclude"]`,
+		},
+		{
+			name:   "filtering fallback",
+			config: routesConfig + "  namepass = [\"mem\"]\n",
+			err:    "the fallback route cannot filter metrics",
+		},
+		{
+			name:   "single table",
+			config: "[routes]\n  name = \"eu\"\n",
+			err:    "routes must be configured as [[routes]]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "telegraf.conf")
+			require.NoError(t, ioutil.WriteFile(path, []byte(tt.config), 0644))
+
+			err := NewConfig().LoadConfig(path)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestConfig_RoutesKeepOrder(t *testing.T) {
+	var routes []string
+	for _, name := range []string{"c", "a", "b", models.UnroutedRoute} {
+		routes = append(routes, "[[routes]]\n  name = \""+name+"\"\n")
+	}
+	c := loadReloadConfig(t, strings.Join(routes, "\n"), nil)
+
+	var names []string
+	for _, route := range c.Routes {
+		names = append(names, route.Config.Name)
+	}
+	require.Equal(t, []string{"c", "a", "b", models.UnroutedRoute}, names)
+}
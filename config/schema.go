@@ -0,0 +1,332 @@
+package config
+
+import (
+	"bufio"
+	"encoding"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/toml"
+)
+
+// Schema describes the settings of a plugin in a JSON-Schema like format,
+// using the keys of the TOML configuration.
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Sensitive            bool               `json:"sensitive,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+
+	// Field is the name of the struct field holding the setting, which is
+	// used to update the setting of a running plugin.
+	Field string `json:"x-field,omitempty"`
+}
+
+// Formats of string and integer settings with special syntax.
+const (
+	FormatDuration = "duration"
+	FormatSize     = "size"
+)
+
+var (
+	durationType         = reflect.TypeOf(time.Duration(0))
+	internalDurationType = reflect.TypeOf(internal.Duration{})
+	configDurationType   = reflect.TypeOf(Duration(0))
+	internalSizeType     = reflect.TypeOf(internal.Size{})
+	configSizeType       = reflect.TypeOf(Size(0))
+	internalNumberType   = reflect.TypeOf(internal.Number{})
+	tomlUnmarshalerType  = reflect.TypeOf((*interface{ UnmarshalTOML([]byte) error })(nil)).Elem()
+	textUnmarshalerType  = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+	// sampleTableRe matches table headers, which may be commented out, such
+	// as "# [[inputs.snmp.field]]".
+	sampleTableRe = regexp.MustCompile(`^#*\s*\[\[?\s*([\w.\-]+)\s*\]\]?`)
+	// sampleKeyRe matches keys, which may be commented out, such as
+	// "# timeout = "5s"".
+	sampleKeyRe = regexp.MustCompile(`^#*\s*([\w\-]+)\s*=`)
+	// enumRe matches the part of a description listing the allowed values of
+	// a setting, such as `Can be either "inotify" or "poll".`.
+	enumRe   = regexp.MustCompile(`(?i)\b(either|one of|must be|valid values|possible values|supported values|available values|options are)\b[^.]*`)
+	quotedRe = regexp.MustCompile(`"([^"]*)"`)
+	// sensitiveRe matches the keys of settings holding credentials.
+	sensitiveRe = regexp.MustCompile(`(?i)(password|passwd|secret|token|api_?key|access_key|credential)`)
+)
+
+// sampleDocs holds the documentation of the keys in a sample config, keyed by
+// the path of the key relative to the plugin table, such as "field.oid".
+type sampleDocs struct {
+	docs map[string]string
+	// keys maps normalized key names to the key as written in the sample.
+	keys map[string]string
+}
+
+// NewPluginSchema returns the schema of the settings of the plugin.  Docs
+// are taken from the comments of the plugin's sample config, and defaults
+// from the values set by the plugin's creator.
+func NewPluginSchema(name string, plugin telegraf.PluginDescriber) *Schema {
+	docs := parseSampleDocs(plugin.SampleConfig())
+
+	schema := newTypeSchema(reflect.TypeOf(plugin), reflect.ValueOf(plugin), docs, "")
+	if schema == nil || schema.Properties == nil {
+		schema = &Schema{Type: "object", Properties: map[string]*Schema{}}
+	}
+	schema.Title = name
+	schema.Description = plugin.Description()
+	return schema
+}
+
+// parseSampleDocs collects the "##" comments preceding each key of a sample
+// config.  Comments apply to all keys up to the next blank line or comment.
+func parseSampleDocs(sample string) *sampleDocs {
+	s := &sampleDocs{
+		docs: make(map[string]string),
+		keys: make(map[string]string),
+	}
+
+	var prefix string
+	var doc []string
+	lastWasKey := false
+
+	scanner := bufio.NewScanner(strings.NewReader(sample))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "":
+			doc = nil
+			lastWasKey = false
+		case strings.HasPrefix(line, "##"):
+			if lastWasKey {
+				doc = nil
+			}
+			doc = append(doc, strings.TrimSpace(strings.TrimLeft(line, "#")))
+			lastWasKey = false
+		case sampleTableRe.MatchString(line):
+			// Plugin tables are named "<type>.<name>.<path>".
+			parts := strings.Split(sampleTableRe.FindStringSubmatch(line)[1], ".")
+			prefix = ""
+			if len(parts) > 2 {
+				prefix = strings.Join(parts[2:], ".") + "."
+			}
+			doc = nil
+			lastWasKey = false
+		case sampleKeyRe.MatchString(line):
+			key := sampleKeyRe.FindStringSubmatch(line)[1]
+			path := prefix + key
+			if _, ok := s.docs[path]; !ok && len(doc) != 0 {
+				s.docs[path] = strings.TrimSpace(strings.Join(doc, "\n"))
+			}
+			s.keys[prefix+normalizeKey(key)] = key
+			lastWasKey = true
+		}
+	}
+	return s
+}
+
+func normalizeKey(key string) string {
+	return toml.DefaultConfig.NormFieldName(nil, key)
+}
+
+// tomlKey returns the key of the struct field in the TOML config, preferring
+// the spelling used in the sample config.
+func (s *sampleDocs) tomlKey(typ reflect.Type, field reflect.StructField, prefix string) (string, bool) {
+	tag := strings.Split(field.Tag.Get("toml"), ",")[0]
+	if tag == "-" {
+		return "", false
+	}
+	if tag != "" {
+		return tag, true
+	}
+	if key, ok := s.keys[prefix+normalizeKey(field.Name)]; ok {
+		return key, true
+	}
+	return toml.DefaultConfig.FieldToKey(typ, field.Name), true
+}
+
+// newTypeSchema returns the schema of a value of the given type, value holds
+// the default and may be invalid if there is none.
+func newTypeSchema(typ reflect.Type, value reflect.Value, docs *sampleDocs, prefix string) *Schema {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+		if value.IsValid() && !value.IsNil() {
+			value = value.Elem()
+		} else {
+			value = reflect.Value{}
+		}
+	}
+
+	switch typ {
+	case durationType, internalDurationType, configDurationType:
+		schema := &Schema{Type: "string", Format: FormatDuration}
+		if d := durationValue(value); d != 0 {
+			schema.Default = d.String()
+		}
+		return schema
+	case internalSizeType, configSizeType:
+		schema := &Schema{Type: "integer", Format: FormatSize}
+		if value.IsValid() && !value.IsZero() {
+			if typ == internalSizeType {
+				schema.Default = value.Field(0).Int()
+			} else {
+				schema.Default = value.Int()
+			}
+		}
+		return schema
+	case internalNumberType:
+		schema := &Schema{Type: "number"}
+		if value.IsValid() {
+			schema.Default = value.Field(0).Float()
+		}
+		return schema
+	}
+
+	schema := &Schema{}
+
+	// Settings with their own TOML syntax, such as enum types that are stored
+	// as integers, are written as strings.
+	if reflect.PtrTo(typ).Implements(tomlUnmarshalerType) || reflect.PtrTo(typ).Implements(textUnmarshalerType) {
+		schema.Type = "string"
+		if value.IsValid() && value.CanInterface() {
+			if v, ok := value.Interface().(fmt.Stringer); ok && v.String() != "" {
+				schema.Default = v.String()
+			}
+		}
+		return schema
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		schema.Type = "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		schema.Type = "integer"
+	case reflect.Float32, reflect.Float64:
+		schema.Type = "number"
+	case reflect.String:
+		schema.Type = "string"
+	case reflect.Slice, reflect.Array:
+		schema.Type = "array"
+		schema.Items = newTypeSchema(typ.Elem(), reflect.Value{}, docs, prefix)
+	case reflect.Map:
+		schema.Type = "object"
+		schema.AdditionalProperties = newTypeSchema(typ.Elem(), reflect.Value{}, docs, prefix)
+	case reflect.Struct:
+		schema.Type = "object"
+		schema.Properties = make(map[string]*Schema)
+		addFieldSchemas(schema, typ, value, docs, prefix)
+		if len(schema.Properties) == 0 {
+			return nil
+		}
+		return schema
+	default:
+		// Interfaces, functions and channels hold state, not settings.
+		return nil
+	}
+
+	if !value.IsValid() || !value.CanInterface() {
+		return schema
+	}
+	// Empty strings, lists and tables mean the setting is not set, while
+	// false and zero are the actual defaults of booleans and numbers.
+	switch schema.Type {
+	case "string", "array", "object":
+		if !value.IsZero() {
+			schema.Default = value.Interface()
+		}
+	default:
+		schema.Default = value.Interface()
+	}
+	return schema
+}
+
+// addFieldSchemas adds the exported fields of the struct as properties of the
+// schema, fields of embedded structs are added as if they were declared in
+// the struct itself.
+func addFieldSchemas(schema *Schema, typ reflect.Type, value reflect.Value, docs *sampleDocs, prefix string) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		var fieldValue reflect.Value
+		if value.IsValid() {
+			fieldValue = value.Field(i)
+		}
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("toml") == "" {
+			addFieldSchemas(schema, field.Type, fieldValue, docs, prefix)
+			continue
+		}
+		if field.PkgPath != "" || field.Type.PkgPath() == "sync" {
+			continue
+		}
+
+		key, ok := docs.tomlKey(typ, field, prefix)
+		if !ok {
+			continue
+		}
+
+		doc, documented := docs.docs[prefix+key]
+		// Pointers to structs are often clients created by the plugin, only
+		// include them if they are part of the sample config.
+		if field.Type.Kind() == reflect.Ptr && field.Type.Elem().Kind() == reflect.Struct && !documented {
+			if _, ok := docs.keys[prefix+normalizeKey(key)]; !ok {
+				continue
+			}
+		}
+		fieldSchema := newTypeSchema(field.Type, fieldValue, docs, prefix+key+".")
+		if fieldSchema == nil {
+			continue
+		}
+		fieldSchema.Field = field.Name
+		fieldSchema.Description = doc
+		fieldSchema.Sensitive = sensitiveRe.MatchString(key)
+		if fieldSchema.Type == "string" && fieldSchema.Format == "" {
+			fieldSchema.Enum = enumValues(doc)
+		} else if fieldSchema.Items != nil && fieldSchema.Items.Type == "string" {
+			fieldSchema.Items.Enum = enumValues(doc)
+		}
+		schema.Properties[key] = fieldSchema
+	}
+}
+
+// enumValues returns the quoted values of a description listing the allowed
+// values of a setting.
+func enumValues(doc string) []string {
+	match := enumRe.FindString(strings.Replace(doc, "\n", " ", -1))
+	if match == "" {
+		return nil
+	}
+
+	var values []string
+	for _, m := range quotedRe.FindAllStringSubmatch(match, -1) {
+		values = append(values, m[1])
+	}
+	if len(values) < 2 {
+		return nil
+	}
+	return values
+}
+
+func durationValue(value reflect.Value) time.Duration {
+	if !value.IsValid() || !value.CanInterface() {
+		return 0
+	}
+	switch v := value.Interface().(type) {
+	case time.Duration:
+		return v
+	case internal.Duration:
+		return v.Duration
+	case Duration:
+		return time.Duration(v)
+	}
+	return 0
+}
@@ -0,0 +1,74 @@
+package config_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/inputs"
+	_ "github.com/influxdata/telegraf/plugins/inputs/all"
+	"github.com/influxdata/telegraf/plugins/outputs"
+	_ "github.com/influxdata/telegraf/plugins/outputs/all"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPluginSchema_AllPlugins(t *testing.T) {
+	for name, creator := range inputs.Inputs {
+		schema := config.NewPluginSchema(name, creator())
+		require.Equal(t, "object", schema.Type, name)
+		_, err := json.Marshal(schema)
+		require.NoError(t, err, name)
+	}
+
+	for name, creator := range outputs.Outputs {
+		schema := config.NewPluginSchema(name, creator())
+		require.Equal(t, "object", schema.Type, name)
+		_, err := json.Marshal(schema)
+		require.NoError(t, err, name)
+	}
+}
+
+func TestNewPluginSchema_Docs(t *testing.T) {
+	schema := config.NewPluginSchema("tail", inputs.Inputs["tail"]())
+	require.Equal(t, "tail", schema.Title)
+
+	fromBeginning := schema.Properties["from_beginning"]
+	require.Equal(t, "boolean", fromBeginning.Type)
+	require.Equal(t, "Read file from beginning.", fromBeginning.Description)
+	require.Equal(t, false, fromBeginning.Default)
+	require.Equal(t, "FromBeginning", fromBeginning.Field)
+
+	watchMethod := schema.Properties["watch_method"]
+	require.Equal(t, "string", watchMethod.Type)
+	require.Equal(t, []string{"inotify", "poll"}, watchMethod.Enum)
+
+	multiline := schema.Properties["multiline"]
+	require.Equal(t, "object", multiline.Type)
+	require.Equal(t, "duration", multiline.Properties["timeout"].Format)
+	require.Equal(t, "string", multiline.Properties["match_which_line"].Type)
+	require.Equal(t, "previous", multiline.Properties["match_which_line"].Default)
+	require.Contains(t, multiline.Properties["pattern"].Description, "regexp")
+}
+
+func TestNewPluginSchema_Types(t *testing.T) {
+	schema := config.NewPluginSchema("http", inputs.Inputs["http"]())
+
+	require.Equal(t, "array", schema.Properties["urls"].Type)
+	require.Equal(t, "string", schema.Properties["urls"].Items.Type)
+	require.Equal(t, "object", schema.Properties["headers"].Type)
+	require.Equal(t, "string", schema.Properties["headers"].AdditionalProperties.Type)
+	require.True(t, schema.Properties["password"].Sensitive)
+	require.False(t, schema.Properties["username"].Sensitive)
+
+	// Settings of embedded structs are part of the plugin table.
+	require.Equal(t, "TLSCA", schema.Properties["tls_ca"].Field)
+
+	schema = config.NewPluginSchema("snmp", inputs.Inputs["snmp"]())
+	field := schema.Properties["field"]
+	require.Equal(t, "array", field.Type)
+	require.Equal(t, "object", field.Items.Type)
+	require.Equal(t, "string", field.Items.Properties["oid"].Type)
+	require.Equal(t, []string{"MD5", "SHA", ""}, schema.Properties["auth_protocol"].Enum)
+	require.Equal(t, "duration", schema.Properties["timeout"].Format)
+	require.Equal(t, "5s", schema.Properties["timeout"].Default)
+}
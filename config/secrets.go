@@ -0,0 +1,398 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/secretstores"
+	"github.com/influxdata/toml/ast"
+)
+
+var (
+	// secretRefRe matches references to secrets, "@{<store id>:<key>}".
+	secretRefRe = regexp.MustCompile(`@\{([A-Za-z0-9_.-]+):([^{}]+)\}`)
+
+	secretStoreIdRe = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+)
+
+// addSecretStores builds the secret stores of the [[secretstores.<name>]]
+// tables.
+func (c *Config) addSecretStores(tbl *ast.Table) error {
+	for name, val := range tbl.Fields {
+		switch storeTables := val.(type) {
+		case *ast.Table:
+			if err := c.addSecretStore(name, storeTables); err != nil {
+				return fmt.Errorf("error parsing %s, %w", name, err)
+			}
+		case []*ast.Table:
+			for _, t := range storeTables {
+				if err := c.addSecretStore(name, t); err != nil {
+					return fmt.Errorf("error parsing %s array, %w", name, err)
+				}
+			}
+		default:
+			return fmt.Errorf("unsupported config format: %s", name)
+		}
+		if len(c.UnusedFields) > 0 {
+			return fmt.Errorf("secret store %s: line %d: configuration specified the fields %q, but they weren't used", name, tbl.Line, keys(c.UnusedFields))
+		}
+	}
+	return nil
+}
+
+func (c *Config) addSecretStore(name string, tbl *ast.Table) error {
+	creator, ok := secretstores.SecretStores[name]
+	if !ok {
+		return fmt.Errorf("Undefined but requested secret store: %s", name)
+	}
+	store := creator()
+
+	var id string
+	c.getFieldString(tbl, "id", &id)
+	if !secretStoreIdRe.MatchString(id) {
+		return fmt.Errorf("invalid id %q of secret store %s", id, name)
+	}
+	delete(tbl.Fields, "id")
+
+	if err := c.toml.UnmarshalTable(tbl, store); err != nil {
+		return err
+	}
+	if s, ok := store.(telegraf.Initializer); ok {
+		if err := s.Init(); err != nil {
+			return fmt.Errorf("could not initialize secret store %s: %v", id, err)
+		}
+	}
+
+	c.secretLock.Lock()
+	defer c.secretLock.Unlock()
+	if _, exists := c.SecretStores[id]; exists {
+		return fmt.Errorf("duplicate secret store id %q", id)
+	}
+	c.SecretStores[id] = store
+	return nil
+}
+
+// LoadSecretStores loads only the secret stores of the config file, so that
+// secrets can be managed before the plugins referencing them can be loaded.
+func (c *Config) LoadSecretStores(path string) error {
+	var err error
+	if path == "" {
+		if path, err = getDefaultConfigPath(); err != nil {
+			return err
+		}
+	}
+	data, err := loadConfig(path)
+	if err != nil {
+		return fmt.Errorf("Error loading config file %s: %w", path, err)
+	}
+	tbl, err := parseConfig(data)
+	if err != nil {
+		return fmt.Errorf("Error parsing data: %s", err)
+	}
+
+	if val, ok := tbl.Fields["secretstores"]; ok {
+		subTable, ok := val.(*ast.Table)
+		if !ok {
+			return fmt.Errorf("invalid configuration, error parsing secretstores table")
+		}
+		if err := c.addSecretStores(subTable); err != nil {
+			return fmt.Errorf("Error loading config file %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// LoadSecretStoresDirectory loads the secret stores of all config files
+// found in the specified path, recursively.
+func (c *Config) LoadSecretStoresDirectory(path string) error {
+	return walkConfigDirectory(path, c.LoadSecretStores)
+}
+
+// getSecret looks up the secret in the store with the id.
+func (c *Config) getSecret(id, key string) (string, error) {
+	c.secretLock.Lock()
+	store, ok := c.SecretStores[id]
+	c.secretLock.Unlock()
+	if !ok {
+		return "", fmt.Errorf("unknown secret store %q", id)
+	}
+
+	value, err := store.Get(key)
+	if err != nil {
+		return "", fmt.Errorf("could not get secret %q from store %q: %v", key, id, err)
+	}
+	return value, nil
+}
+
+// pluginSecrets maps the settings of a plugin that referenced secrets to
+// their resolved values, and those to the values with the references.
+type pluginSecrets map[string]map[string]string
+
+// secretKey returns the key a setting is recorded under, settings are named
+// by their TOML key or by their field name.
+func secretKey(key string) string {
+	return strings.ToLower(strings.Replace(key, "_", "", -1))
+}
+
+// resolveString replaces the references to secrets in s, the resolved value
+// is recorded for the setting of the plugin with the unique id so it can be
+// redacted.
+func (c *Config) resolveString(uniqueId, key, s string) (string, error) {
+	var err error
+	resolved := secretRefRe.ReplaceAllStringFunc(s, func(ref string) string {
+		if err != nil {
+			return ref
+		}
+		m := secretRefRe.FindStringSubmatch(ref)
+		var value string
+		value, err = c.getSecret(m[1], m[2])
+		if err != nil {
+			return ref
+		}
+		return value
+	})
+	if err != nil {
+		return "", err
+	}
+	c.recordSecret(uniqueId, key, resolved, s)
+	return resolved, nil
+}
+
+func (c *Config) recordSecret(uniqueId, key, resolved, s string) {
+	if resolved == "" || resolved == s {
+		return
+	}
+	c.secretLock.Lock()
+	defer c.secretLock.Unlock()
+	if c.secrets[uniqueId] == nil {
+		c.secrets[uniqueId] = make(pluginSecrets)
+	}
+	key = secretKey(key)
+	if c.secrets[uniqueId][key] == nil {
+		c.secrets[uniqueId][key] = make(map[string]string)
+	}
+	c.secrets[uniqueId][key][resolved] = s
+}
+
+// resolveSecrets replaces the references to secrets in the string values of
+// a plugin table.  The settings are decoded from the table afterwards.
+func (c *Config) resolveSecrets(uniqueId string, tbl *ast.Table) error {
+	for key, val := range tbl.Fields {
+		if err := c.resolveField(uniqueId, key, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveField resolves the secrets of the plugin setting with the key, the
+// values of nested tables are recorded for the setting as well.
+func (c *Config) resolveField(uniqueId, key string, val interface{}) error {
+	switch v := val.(type) {
+	case *ast.KeyValue:
+		if err := c.resolveValue(uniqueId, key, v.Value); err != nil {
+			return fmt.Errorf("line %d: %v", v.Line, err)
+		}
+	case *ast.Table:
+		for _, field := range v.Fields {
+			if err := c.resolveField(uniqueId, key, field); err != nil {
+				return err
+			}
+		}
+	case []*ast.Table:
+		for _, t := range v {
+			for _, field := range t.Fields {
+				if err := c.resolveField(uniqueId, key, field); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Config) resolveValue(uniqueId, key string, val ast.Value) error {
+	switch v := val.(type) {
+	case *ast.String:
+		if !secretRefRe.MatchString(v.Value) {
+			return nil
+		}
+		resolved, err := c.resolveString(uniqueId, key, v.Value)
+		if err != nil {
+			return err
+		}
+		// Settings implementing UnmarshalTOML are decoded from the source.
+		v.Value = resolved
+		v.Data = []rune(tomlString(resolved))
+	case *ast.Array:
+		for _, elem := range v.Value {
+			if err := c.resolveValue(uniqueId, key, elem); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// tomlString returns s as a TOML basic string.
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// ResolveSecrets returns a copy of the settings of the plugin with the unique
+// id, with references to secrets in string values replaced by the secrets.
+func (c *Config) ResolveSecrets(uniqueId string, config map[string]interface{}) (map[string]interface{}, error) {
+	resolved := make(map[string]interface{}, len(config))
+	for key, value := range config {
+		var err error
+		resolved[key] = mapValueStrings(value, func(s string) string {
+			if err != nil || !secretRefRe.MatchString(s) {
+				return s
+			}
+			var r string
+			r, err = c.resolveString(uniqueId, key, s)
+			return r
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return resolved, nil
+}
+
+// RedactSecrets returns a copy of the settings of the plugin with the unique
+// id, with the values the plugin was configured with secrets for replaced by
+// the values with the references.  Only the settings that referenced secrets
+// are redacted, and only values matching a resolved value as a whole.
+func (c *Config) RedactSecrets(uniqueId string, config map[string]interface{}) map[string]interface{} {
+	c.secretLock.Lock()
+	secrets := c.secrets[uniqueId]
+	c.secretLock.Unlock()
+
+	if len(secrets) == 0 || config == nil {
+		return config
+	}
+
+	redacted := make(map[string]interface{}, len(config))
+	for key, value := range config {
+		refs, ok := secrets[secretKey(key)]
+		if !ok {
+			redacted[key] = value
+			continue
+		}
+		redacted[key] = mapValueStrings(value, func(s string) string {
+			if ref, ok := refs[s]; ok {
+				return ref
+			}
+			return s
+		})
+	}
+	return redacted
+}
+
+// AdoptSecrets makes c use the secret stores of the newly loaded config, and
+// takes over the secrets recorded for the plugins with the unique ids.
+func (c *Config) AdoptSecrets(from *Config, uniqueIds []string) {
+	from.secretLock.Lock()
+	stores := make(map[string]telegraf.SecretStore, len(from.SecretStores))
+	for id, store := range from.SecretStores {
+		stores[id] = store
+	}
+	secrets := make(map[string]pluginSecrets, len(uniqueIds))
+	for _, uid := range uniqueIds {
+		secrets[uid] = from.secrets[uid]
+	}
+	from.secretLock.Unlock()
+
+	c.secretLock.Lock()
+	defer c.secretLock.Unlock()
+	c.SecretStores = stores
+	for uid, s := range secrets {
+		if s == nil {
+			delete(c.secrets, uid)
+			continue
+		}
+		c.secrets[uid] = s
+	}
+}
+
+// mapValueStrings is mapStrings for a setting decoded into an interface.
+func mapValueStrings(v interface{}, f func(string) string) interface{} {
+	if v == nil {
+		return nil
+	}
+	return mapStrings(reflect.ValueOf(v), f).Interface()
+}
+
+// mapStrings returns a copy of v with f applied to all strings in v,
+// including strings in slices and maps.  Structs and pointers are returned
+// as they are.
+func mapStrings(v reflect.Value, f func(string) string) reflect.Value {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.Set(mapStrings(v.Elem(), f))
+		return out
+	case reflect.String:
+		return reflect.ValueOf(f(v.String())).Convert(v.Type())
+	case reflect.Slice:
+		if v.IsNil() || v.Type().Elem().Kind() == reflect.Uint8 {
+			return v
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(mapStrings(v.Index(i), f))
+		}
+		return out
+	case reflect.Array:
+		out := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(mapStrings(v.Index(i), f))
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(iter.Key(), mapStrings(iter.Value(), f))
+		}
+		return out
+	}
+	return v
+}
@@ -0,0 +1,210 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/influxdata/telegraf"
+	httpOut "github.com/influxdata/telegraf/plugins/outputs/http"
+	"github.com/influxdata/telegraf/plugins/secretstores"
+	"github.com/influxdata/toml"
+	"github.com/stretchr/testify/require"
+)
+
+type mockSecretStore struct {
+	Secrets map[string]string `toml:"secrets"`
+}
+
+func (s *mockSecretStore) SampleConfig() string { return "" }
+func (s *mockSecretStore) Description() string  { return "" }
+
+func (s *mockSecretStore) Get(key string) (string, error) {
+	value, ok := s.Secrets[key]
+	if !ok {
+		return "", fmt.Errorf("secret %q not found", key)
+	}
+	return value, nil
+}
+
+func (s *mockSecretStore) Set(key, value string) error {
+	s.Secrets[key] = value
+	return nil
+}
+
+func (s *mockSecretStore) List() ([]string, error) {
+	keys := make([]string, 0, len(s.Secrets))
+	for key := range s.Secrets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func init() {
+	secretstores.Add("mock", func() telegraf.SecretStore {
+		return &mockSecretStore{}
+	})
+}
+
+const secretsConfig = `
+[[secretstores.mock]]
+  id = "vault"
+  secrets = { http_password = "s3cr3t", user = "admin" }
+
+[[outputs.http]]
+  url = "http://localhost"
+  username = "@{vault:user}"
+  password = "@{vault:http_password}"
+  headers = { Authorization = "Bearer @{vault:http_password}" }
+`
+
+func TestConfig_ResolveSecrets(t *testing.T) {
+	c := loadReloadConfig(t, secretsConfig, nil)
+	require.Contains(t, c.SecretStores, "vault")
+	require.Len(t, c.Outputs, 1)
+
+	output := c.Outputs[0].Output.(*httpOut.HTTP)
+	require.Equal(t, "admin", output.Username)
+	require.Equal(t, "s3cr3t", output.Password)
+	require.Equal(t, "Bearer s3cr3t", output.Headers["Authorization"])
+
+	// The fingerprint is computed from the references, not the secrets.
+	c2 := loadReloadConfig(t, strings.Replace(secretsConfig, "s3cr3t", "changed", 1), c)
+	require.Equal(t, c.Outputs[0].UniqueId, c2.Outputs[0].UniqueId)
+}
+
+func TestConfig_ResolveSecrets_Errors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telegraf-secrets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer os.Remove("./updated_config.conf")
+
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			name:   "unknown store",
+			config: strings.Replace(secretsConfig, "@{vault:user}", "@{other:user}", 1),
+			err:    `unknown secret store "other"`,
+		},
+		{
+			name:   "unknown secret",
+			config: strings.Replace(secretsConfig, "@{vault:user}", "@{vault:missing}", 1),
+			err:    `could not get secret "missing" from store "vault"`,
+		},
+		{
+			name:   "missing id",
+			config: strings.Replace(secretsConfig, `id = "vault"`, "", 1),
+			err:    `invalid id "" of secret store mock`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "telegraf.conf")
+			require.NoError(t, ioutil.WriteFile(path, []byte(tt.config), 0644))
+
+			err := NewConfig().LoadConfig(path)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestConfig_RedactSecrets(t *testing.T) {
+	c := loadReloadConfig(t, secretsConfig, nil)
+	uid := c.Outputs[0].UniqueId
+
+	values := map[string]interface{}{
+		"Username": "admin",
+		"Password": "s3cr3t",
+		"Headers":  map[string]string{"Authorization": "Bearer s3cr3t", "X-User": "admin"},
+		"URLs":     []string{"http://admin.example.com"},
+		"Timeout":  5,
+	}
+	redacted := c.RedactSecrets(uid, values)
+	require.Equal(t, map[string]interface{}{
+		"Username": "@{vault:user}",
+		"Password": "@{vault:http_password}",
+		"Headers":  map[string]string{"Authorization": "Bearer @{vault:http_password}", "X-User": "admin"},
+		"URLs":     []string{"http://admin.example.com"},
+		"Timeout":  5,
+	}, redacted)
+
+	// Only values of settings that referenced secrets are redacted, and only
+	// if they match a resolved value as a whole.
+	require.Equal(t, []string{"http://admin.example.com"}, redacted["URLs"])
+
+	// The values passed in are not modified.
+	require.Equal(t, "s3cr3t", values["Password"])
+
+	// Secrets are only redacted for the plugins configured with them.
+	require.Equal(t, values, c.RedactSecrets("other", values))
+}
+
+func TestConfig_ResolveSecretsOfUpdate(t *testing.T) {
+	c := loadReloadConfig(t, secretsConfig, nil)
+
+	resolved, err := c.ResolveSecrets("updated", map[string]interface{}{
+		"Password": "@{vault:http_password}",
+		"Headers":  map[string]interface{}{"X-User": "@{vault:user}"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"Password": "s3cr3t",
+		"Headers":  map[string]interface{}{"X-User": "admin"},
+	}, resolved)
+
+	redacted := c.RedactSecrets("updated", resolved)
+	require.Equal(t, "@{vault:http_password}", redacted["Password"])
+
+	_, err = c.ResolveSecrets("updated", map[string]interface{}{"Password": "@{vault:missing}"})
+	require.Error(t, err)
+}
+
+func TestConfig_SerializeRedactsSecrets(t *testing.T) {
+	source := strings.Replace(secretsConfig, "[[outputs.http]]", "[[outputs.http]]\n  unique_id = \"http-1\"", 1)
+	c := loadReloadConfig(t, source, nil)
+	uid := c.Outputs[0].UniqueId
+	require.Equal(t, "http-1", uid)
+
+	dir, err := ioutil.TempDir("", "telegraf-secrets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "telegraf.conf")
+	require.NoError(t, ioutil.WriteFile(path, []byte(source), 0644))
+	data, err := loadConfig(path)
+	require.NoError(t, err)
+
+	// An update setting a secret in clear text is written as its reference.
+	updated := filepath.Join(dir, "updated.conf")
+	err = c.serializeConfig(data, updated, map[string]interface{}{"password": "s3cr3t"}, uid, "outputs", "UPDATE_PLUGIN")
+	require.NoError(t, err)
+
+	serialized, err := ioutil.ReadFile(updated)
+	require.NoError(t, err)
+	require.Contains(t, string(serialized), "[[secretstores.mock]]")
+	require.Contains(t, string(serialized), "\n  password=\"@{vault:http_password}\"")
+	require.NotContains(t, string(serialized), "\n  password=\"s3cr3t\"")
+
+	// The serialized config can be loaded again.
+	c2 := NewConfig()
+	defer os.Remove("./updated_config.conf")
+	require.NoError(t, c2.LoadConfig(updated))
+	require.Equal(t, "s3cr3t", c2.Outputs[0].Output.(*httpOut.HTTP).Password)
+}
+
+func TestTomlString(t *testing.T) {
+	for _, value := range []string{"s3cr3t", `quote " and \ backslash`, "tab\tnew line\n", "bell\a del\x7f", "unicode ☃"} {
+		var v struct{ Value string }
+		require.NoError(t, toml.Unmarshal([]byte("value = "+tomlString(value)), &v))
+		require.Equal(t, value, v.Value)
+	}
+}
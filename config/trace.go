@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/influxdata/telegraf/models"
+	"github.com/influxdata/toml/ast"
+)
+
+// addTrace parses the [trace] table selecting the metrics to trace.
+func (c *Config) addTrace(tbl *ast.Table) error {
+	tc := &models.TraceConfig{}
+	c.getFieldFloat(tbl, "sample_rate", &tc.SampleRate)
+	c.getFieldInt(tbl, "max_traces", &tc.MaxTraces)
+	if c.hasErrs() {
+		return c.firstErr()
+	}
+
+	for key := range tbl.Fields {
+		switch key {
+		case "sample_rate", "max_traces", "namepass", "namedrop", "tagpass",
+			"tagdrop", "metricpass":
+		default:
+			c.UnusedFields[key] = true
+		}
+	}
+	if len(c.UnusedFields) > 0 {
+		return fmt.Errorf("trace: line %d: configuration specified the fields %q, but they weren't used", tbl.Line, keys(c.UnusedFields))
+	}
+
+	var err error
+	tc.Filter, err = c.buildFilter(tbl)
+	if err != nil {
+		return fmt.Errorf("trace: %w", err)
+	}
+	if tc.SampleRate < 0 || tc.SampleRate > 1 {
+		return fmt.Errorf("trace: sample_rate must be between 0 and 1, got %v", tc.SampleRate)
+	}
+	if tc.SampleRate == 0 && !tc.Filter.IsActive() {
+		return fmt.Errorf("trace: either sample_rate or a filter is required")
+	}
+	if tc.MaxTraces == 0 {
+		tc.MaxTraces = models.DefaultMaxTraces
+	}
+
+	c.Trace = tc
+	c.traceSettings = pluginFingerprint("trace", "", tbl)
+	return nil
+}
+
+// TraceFingerprint returns a hash of the settings of the trace, which changes
+// when the trace is enabled, disabled or changed.
+func (c *Config) TraceFingerprint() string {
+	return c.traceSettings
+}
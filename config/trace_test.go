@@ -0,0 +1,92 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/models"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+const traceConfig = `
+[[outputs.file]]
+
+[trace]
+  namepass = ["cpu"]
+  max_traces = 20
+  [trace.tagpass]
+    cpu = ["cpu0"]
+`
+
+func TestConfig_Trace(t *testing.T) {
+	c := loadReloadConfig(t, traceConfig, nil)
+	require.NotNil(t, c.Trace)
+	require.Equal(t, 20, c.Trace.MaxTraces)
+	require.Equal(t, 0.0, c.Trace.SampleRate)
+	require.True(t, c.Trace.Filter.Select(testutil.MustMetric("cpu",
+		map[string]string{"cpu": "cpu0"},
+		map[string]interface{}{"usage_idle": 42.0},
+		time.Unix(0, 0))))
+	require.False(t, c.Trace.Filter.Select(testutil.MustMetric("cpu",
+		map[string]string{"cpu": "cpu1"},
+		map[string]interface{}{"usage_idle": 42.0},
+		time.Unix(0, 0))))
+
+	c = loadReloadConfig(t, "[[outputs.file]]\n[trace]\n  sample_rate = 1\n", nil)
+	require.Equal(t, 1.0, c.Trace.SampleRate)
+	require.Equal(t, models.DefaultMaxTraces, c.Trace.MaxTraces)
+
+	// The fingerprint only changes with the settings of the trace.
+	c = loadReloadConfig(t, traceConfig, nil)
+	c2 := loadReloadConfig(t, traceConfig, nil)
+	require.Equal(t, c.TraceFingerprint(), c2.TraceFingerprint())
+	c2 = loadReloadConfig(t, strings.Replace(traceConfig, "max_traces = 20", "max_traces = 10", 1), nil)
+	require.NotEqual(t, c.TraceFingerprint(), c2.TraceFingerprint())
+	c2 = loadReloadConfig(t, "[[outputs.file]]\n", nil)
+	require.Nil(t, c2.Trace)
+	require.NotEqual(t, c.TraceFingerprint(), c2.TraceFingerprint())
+}
+
+func TestConfig_TraceErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telegraf-trace")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer os.Remove("./updated_config.conf")
+
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			name:   "nothing selected",
+			config: "[trace]\n  max_traces = 10\n",
+			err:    "either sample_rate or a filter is required",
+		},
+		{
+			name:   "invalid sample rate",
+			config: "[trace]\n  sample_rate = 1.5\n",
+			err:    "sample_rate must be between 0 and 1",
+		},
+		{
+			name:   "unknown field",
+			config: "[trace]\n  sample_rate = 0.1\n  fieldpass = [\"usage_*\"]\n",
+			err:    `configuration specified the fields ["fieldpass"]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "telegraf.conf")
+			require.NoError(t, ioutil.WriteFile(path, []byte(tt.config), 0644))
+
+			err := NewConfig().LoadConfig(path)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
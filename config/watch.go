@@ -0,0 +1,96 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"log"
+	"time"
+)
+
+// Watcher polls the config file and the *.conf files of the config directory
+// for changes.
+type Watcher struct {
+	File      string
+	Directory string
+	Interval  time.Duration
+
+	sums map[string][sha256.Size]byte
+}
+
+// NewWatcher returns a Watcher for the config file and directory.  Like
+// LoadConfig, the default config file is watched if file is empty.
+func NewWatcher(file, directory string, interval time.Duration) *Watcher {
+	if file == "" {
+		if path, err := getDefaultConfigPath(); err == nil {
+			file = path
+		}
+	}
+
+	w := &Watcher{
+		File:      file,
+		Directory: directory,
+		Interval:  interval,
+	}
+	w.sums = w.checksums()
+	return w
+}
+
+// Watch calls onChange each time the content of the files changed, including
+// files that were added or removed, until the context is done.
+func (w *Watcher) Watch(ctx context.Context, onChange func()) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		sums := w.checksums()
+		if w.changed(sums) {
+			w.sums = sums
+			onChange()
+		}
+	}
+}
+
+func (w *Watcher) changed(sums map[string][sha256.Size]byte) bool {
+	if len(sums) != len(w.sums) {
+		return true
+	}
+	for path, sum := range sums {
+		if prev, ok := w.sums[path]; !ok || prev != sum {
+			return true
+		}
+	}
+	return false
+}
+
+// checksums returns the checksum of each config file, files that cannot be
+// read are left out and reported as removed.
+func (w *Watcher) checksums() map[string][sha256.Size]byte {
+	sums := make(map[string][sha256.Size]byte)
+
+	add := func(path string) {
+		data, err := loadConfig(path)
+		if err != nil {
+			log.Printf("D! [config] Could not read %s while watching for changes: %v", path, err)
+			return
+		}
+		sums[path] = sha256.Sum256(data)
+	}
+
+	if w.File != "" {
+		add(w.File)
+	}
+
+	if w.Directory != "" {
+		walkConfigDirectory(w.Directory, func(path string) error {
+			add(path)
+			return nil
+		})
+	}
+	return sums
+}
@@ -0,0 +1,54 @@
+package telegraf
+
+// Distribution is a field value describing the observations of a histogram
+// or a summary.  Histograms have cumulative buckets, summaries have
+// quantiles.
+//
+// Field values are stored as *Distribution and must not be modified after
+// the field is added, use Copy to make changes.
+type Distribution struct {
+	// Count is the number of observations.
+	Count uint64
+
+	// Sum is the sum of the observations.
+	Sum float64
+
+	// Buckets are the number of observations less than or equal to the
+	// upper bound, ordered by the upper bound.  The +Inf bucket is implied
+	// by Count.
+	Buckets []Bucket
+
+	// Quantiles are the estimated values of the quantiles, ordered by the
+	// quantile.
+	Quantiles []Quantile
+}
+
+// Bucket is a cumulative bucket of a histogram.
+type Bucket struct {
+	UpperBound float64
+	Count      uint64
+}
+
+// Quantile is an estimated quantile of a summary.
+type Quantile struct {
+	Quantile float64
+	Value    float64
+}
+
+// IsSummary returns true if the distribution has quantiles instead of
+// buckets.
+func (d *Distribution) IsSummary() bool {
+	return len(d.Buckets) == 0 && len(d.Quantiles) != 0
+}
+
+// Copy returns a deep copy of the distribution.
+func (d *Distribution) Copy() *Distribution {
+	d2 := &Distribution{Count: d.Count, Sum: d.Sum}
+	if d.Buckets != nil {
+		d2.Buckets = append([]Bucket(nil), d.Buckets...)
+	}
+	if d.Quantiles != nil {
+		d2.Quantiles = append([]Quantile(nil), d.Quantiles...)
+	}
+	return d2
+}
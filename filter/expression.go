@@ -0,0 +1,902 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/influxdata/telegraf"
+)
+
+// Expression is a compiled boolean expression over a metric.
+type Expression struct {
+	source string
+	eval   evalFunc
+}
+
+// CompileExpression compiles an expression over the name, tags, fields and
+// time of a metric, ie:
+//
+//   fields.usage_idle > 95 && tags.cpu != "cpu-total"
+//   tags.env != "prod" and tags.host =~ '^web-\d+$'
+//   has(fields.value) && is_numeric(fields.value)
+//   time > now() - 5m
+//
+// Errors in the syntax, unknown identifiers and functions, invalid regular
+// expressions and operations on literals of the wrong type are reported
+// here, before the expression is evaluated.
+func CompileExpression(source string) (*Expression, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %s at position %d", tok, tok.pos)
+	}
+	if n.kind != kindAny && n.kind != kindBool {
+		return nil, fmt.Errorf("expression must be a condition, not a %s", n.kind)
+	}
+	return &Expression{source: source, eval: n.eval}, nil
+}
+
+// Select returns true if the expression is true for the metric.  Comparisons
+// with missing tags or fields and between values of different types are
+// false, except for "!=" which is true.
+func (e *Expression) Select(metric telegraf.Metric) bool {
+	b, ok := e.eval(metric).(bool)
+	return ok && b
+}
+
+// String returns the source of the expression.
+func (e *Expression) String() string {
+	return e.source
+}
+
+type evalFunc func(telegraf.Metric) interface{}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenDuration
+	tokenString
+	tokenIdent
+	tokenOp
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value interface{}
+	pos   int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+// operators are matched longest first.
+var operators = []string{
+	"==", "!=", "<=", ">=", "=~", "!~", "&&", "||",
+	"<", ">", "!", "+", "-", "*", "/", "%", "(", ")", "[", "]", ".", ",",
+}
+
+var keywordOperators = map[string]string{
+	"and": "&&",
+	"or":  "||",
+	"not": "!",
+}
+
+func lex(source string) ([]token, error) {
+	var tokens []token
+	rs := []rune(source)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		pos := i + 1
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r >= '0' && r <= '9':
+			tok, n, err := lexNumber(rs[i:], pos)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, tok)
+			i += n
+		case r == '"' || r == '\'':
+			tok, n, err := lexString(rs[i:], pos)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, tok)
+			i += n
+		case r == '_' || unicode.IsLetter(r):
+			j := i
+			for j < len(rs) && (rs[j] == '_' || unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j])) {
+				j++
+			}
+			text := string(rs[i:j])
+			if op, ok := keywordOperators[text]; ok {
+				tokens = append(tokens, token{kind: tokenOp, text: op, pos: pos})
+			} else {
+				tokens = append(tokens, token{kind: tokenIdent, text: text, pos: pos})
+			}
+			i = j
+		default:
+			found := false
+			for _, op := range operators {
+				if strings.HasPrefix(string(rs[i:]), op) {
+					tokens = append(tokens, token{kind: tokenOp, text: op, pos: pos})
+					i += len([]rune(op))
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("unexpected character %q at position %d", r, pos)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(rs) + 1}), nil
+}
+
+// lexNumber reads an integer, float or duration such as "1h30m".
+func lexNumber(rs []rune, pos int) (token, int, error) {
+	n := 0
+	isFloat := false
+	for n < len(rs) && (unicode.IsDigit(rs[n]) || rs[n] == '.') {
+		if rs[n] == '.' {
+			isFloat = true
+		}
+		n++
+	}
+	if n < len(rs) && (rs[n] == 'e' || rs[n] == 'E') {
+		j := n + 1
+		if j < len(rs) && (rs[j] == '+' || rs[j] == '-') {
+			j++
+		}
+		if j < len(rs) && unicode.IsDigit(rs[j]) {
+			for j < len(rs) && unicode.IsDigit(rs[j]) {
+				j++
+			}
+			n = j
+			isFloat = true
+		}
+	}
+
+	if n < len(rs) && unicode.IsLetter(rs[n]) {
+		for n < len(rs) && (unicode.IsLetter(rs[n]) || unicode.IsDigit(rs[n]) || rs[n] == '.') {
+			n++
+		}
+		text := string(rs[:n])
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			return token{}, 0, fmt.Errorf("invalid duration %q at position %d", text, pos)
+		}
+		return token{kind: tokenDuration, text: text, value: d, pos: pos}, n, nil
+	}
+
+	text := string(rs[:n])
+	if isFloat {
+		v, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return token{}, 0, fmt.Errorf("invalid number %q at position %d", text, pos)
+		}
+		return token{kind: tokenNumber, text: text, value: v, pos: pos}, n, nil
+	}
+	v, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return token{}, 0, fmt.Errorf("invalid number %q at position %d", text, pos)
+	}
+	return token{kind: tokenNumber, text: text, value: v, pos: pos}, n, nil
+}
+
+// lexString reads a double quoted string with Go escapes, or a raw single
+// quoted string which is handy for regular expressions.
+func lexString(rs []rune, pos int) (token, int, error) {
+	quote := rs[0]
+	n := 1
+	for n < len(rs) && rs[n] != quote {
+		if quote == '"' && rs[n] == '\\' {
+			n++
+		}
+		n++
+	}
+	if n >= len(rs) {
+		return token{}, 0, fmt.Errorf("unterminated string at position %d", pos)
+	}
+	n++
+
+	text := string(rs[:n])
+	if quote == '\'' {
+		return token{kind: tokenString, text: text, value: text[1 : len(text)-1], pos: pos}, n, nil
+	}
+	v, err := strconv.Unquote(text)
+	if err != nil {
+		return token{}, 0, fmt.Errorf("invalid string %s at position %d", text, pos)
+	}
+	return token{kind: tokenString, text: text, value: v, pos: pos}, n, nil
+}
+
+// valueKind is the type of a node as far as it is known before evaluation,
+// tags and fields are of any kind.
+type valueKind int
+
+const (
+	kindAny valueKind = iota
+	kindBool
+	kindNumber
+	kindString
+	kindTime
+	kindDuration
+)
+
+func (k valueKind) String() string {
+	switch k {
+	case kindBool:
+		return "boolean"
+	case kindNumber:
+		return "number"
+	case kindString:
+		return "string"
+	case kindTime:
+		return "time"
+	case kindDuration:
+		return "duration"
+	}
+	return "value"
+}
+
+type node struct {
+	kind valueKind
+	eval evalFunc
+	pos  int
+
+	// access is set for tag and field accesses, literal for literals.
+	access  string
+	literal interface{}
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) isOp(ops ...string) bool {
+	tok := p.peek()
+	if tok.kind != tokenOp {
+		return false
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.isOp(op) {
+		tok := p.peek()
+		return fmt.Errorf("expected %q but found %s at position %d", op, tok, tok.pos)
+	}
+	p.next()
+	return nil
+}
+
+func checkCondition(n *node, op string) error {
+	if n.kind != kindAny && n.kind != kindBool {
+		return fmt.Errorf("operand of %q must be a condition, not a %s at position %d", op, n.kind, n.pos)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (*node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkCondition(left, "||"); err != nil {
+			return nil, err
+		}
+		if err := checkCondition(right, "||"); err != nil {
+			return nil, err
+		}
+		l, r := left.eval, right.eval
+		left = &node{kind: kindBool, pos: left.pos, eval: func(m telegraf.Metric) interface{} {
+			if b, ok := l(m).(bool); ok && b {
+				return true
+			}
+			b, ok := r(m).(bool)
+			return ok && b
+		}}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (*node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkCondition(left, "&&"); err != nil {
+			return nil, err
+		}
+		if err := checkCondition(right, "&&"); err != nil {
+			return nil, err
+		}
+		l, r := left.eval, right.eval
+		left = &node{kind: kindBool, pos: left.pos, eval: func(m telegraf.Metric) interface{} {
+			if b, ok := l(m).(bool); !ok || !b {
+				return false
+			}
+			b, ok := r(m).(bool)
+			return ok && b
+		}}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (*node, error) {
+	if p.isOp("!") {
+		tok := p.next()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkCondition(operand, "!"); err != nil {
+			return nil, err
+		}
+		eval := operand.eval
+		return &node{kind: kindBool, pos: tok.pos, eval: func(m telegraf.Metric) interface{} {
+			if b, ok := eval(m).(bool); ok {
+				return !b
+			}
+			return nil
+		}}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (*node, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isOp("==", "!=", "<", "<=", ">", ">=", "=~", "!~") {
+		return left, nil
+	}
+	op := p.next()
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	if op.text == "=~" || op.text == "!~" {
+		return regexNode(op, left, right)
+	}
+
+	if left.kind != kindAny && right.kind != kindAny && left.kind != right.kind {
+		return nil, fmt.Errorf("cannot compare %s and %s at position %d", left.kind, right.kind, op.pos)
+	}
+	if op.text != "==" && op.text != "!=" && (left.kind == kindBool || right.kind == kindBool) {
+		return nil, fmt.Errorf("cannot order conditions with %q at position %d", op.text, op.pos)
+	}
+
+	l, r := left.eval, right.eval
+	var eval evalFunc
+	switch op.text {
+	case "==":
+		eval = func(m telegraf.Metric) interface{} { return equal(l(m), r(m)) }
+	case "!=":
+		eval = func(m telegraf.Metric) interface{} { return !equal(l(m), r(m)) }
+	default:
+		test := orderTests[op.text]
+		eval = func(m telegraf.Metric) interface{} {
+			c, ok := compare(l(m), r(m))
+			return ok && test(c)
+		}
+	}
+	return &node{kind: kindBool, pos: left.pos, eval: eval}, nil
+}
+
+var orderTests = map[string]func(int) bool{
+	"<":  func(c int) bool { return c < 0 },
+	"<=": func(c int) bool { return c <= 0 },
+	">":  func(c int) bool { return c > 0 },
+	">=": func(c int) bool { return c >= 0 },
+}
+
+func regexNode(op token, left, right *node) (*node, error) {
+	if left.kind != kindAny && left.kind != kindString {
+		return nil, fmt.Errorf("cannot match a %s with %q at position %d", left.kind, op.text, op.pos)
+	}
+	pattern, ok := right.literal.(string)
+	if !ok {
+		return nil, fmt.Errorf("%q must be followed by a string pattern at position %d", op.text, right.pos)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression at position %d: %v", right.pos, err)
+	}
+
+	negate := op.text == "!~"
+	l := left.eval
+	return &node{kind: kindBool, pos: left.pos, eval: func(m telegraf.Metric) interface{} {
+		s, ok := l(m).(string)
+		if !ok {
+			return negate
+		}
+		return re.MatchString(s) != negate
+	}}, nil
+}
+
+func (p *parser) parseAdditive() (*node, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+", "-") {
+		op := p.next()
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left, err = arithmeticNode(op, left, right)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) parseMultiplicative() (*node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("*", "/", "%") {
+		op := p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left, err = arithmeticNode(op, left, right)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return left, nil
+}
+
+// arithmeticKind returns the kind of the result of the operation, or false if
+// the operation is not supported for the kinds.
+func arithmeticKind(op string, left, right valueKind) (valueKind, bool) {
+	if left == kindBool || right == kindBool || left == kindString || right == kindString {
+		return kindAny, false
+	}
+	// Tags and fields are never times or durations, so they can only be
+	// used in arithmetic as numbers.
+	if left == kindAny {
+		left = kindNumber
+	}
+	if right == kindAny {
+		right = kindNumber
+	}
+	switch {
+	case left == kindNumber && right == kindNumber:
+		return kindNumber, true
+	case op == "-" && left == kindTime && right == kindTime:
+		return kindDuration, true
+	case (op == "+" || op == "-") && left == kindTime && right == kindDuration:
+		return kindTime, true
+	case op == "+" && left == kindDuration && right == kindTime:
+		return kindTime, true
+	case (op == "+" || op == "-") && left == kindDuration && right == kindDuration:
+		return kindDuration, true
+	}
+	return kindAny, false
+}
+
+func arithmeticNode(op token, left, right *node) (*node, error) {
+	kind, ok := arithmeticKind(op.text, left.kind, right.kind)
+	if !ok {
+		return nil, fmt.Errorf("invalid operation %s %s %s at position %d", left.kind, op.text, right.kind, op.pos)
+	}
+	l, r := left.eval, right.eval
+	return &node{kind: kind, pos: left.pos, eval: func(m telegraf.Metric) interface{} {
+		return arithmetic(op.text, l(m), r(m))
+	}}, nil
+}
+
+func (p *parser) parseUnary() (*node, error) {
+	if p.isOp("-") {
+		tok := p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return arithmeticNode(tok, &node{kind: kindNumber, pos: tok.pos, literal: int64(0), eval: func(telegraf.Metric) interface{} {
+			return int64(0)
+		}}, operand)
+	}
+	return p.parsePrimary()
+}
+
+func literalNode(tok token, kind valueKind) *node {
+	value := tok.value
+	return &node{kind: kind, pos: tok.pos, literal: value, eval: func(telegraf.Metric) interface{} {
+		return value
+	}}
+}
+
+func (p *parser) parsePrimary() (*node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenNumber:
+		return literalNode(tok, kindNumber), nil
+	case tokenDuration:
+		return literalNode(tok, kindDuration), nil
+	case tokenString:
+		return literalNode(tok, kindString), nil
+	case tokenOp:
+		if tok.text != "(" {
+			break
+		}
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case tokenIdent:
+		if p.isOp("(") {
+			return p.parseCall(tok)
+		}
+		return p.parseIdent(tok)
+	}
+	return nil, fmt.Errorf("unexpected %s at position %d", tok, tok.pos)
+}
+
+func (p *parser) parseIdent(tok token) (*node, error) {
+	switch tok.text {
+	case "true", "false":
+		tok.value = tok.text == "true"
+		return literalNode(tok, kindBool), nil
+	case "name":
+		return &node{kind: kindString, pos: tok.pos, eval: func(m telegraf.Metric) interface{} {
+			return m.Name()
+		}}, nil
+	case "time":
+		return &node{kind: kindTime, pos: tok.pos, eval: func(m telegraf.Metric) interface{} {
+			return m.Time()
+		}}, nil
+	case "tags", "fields":
+		key, err := p.parseKey(tok)
+		if err != nil {
+			return nil, err
+		}
+		n := &node{kind: kindAny, pos: tok.pos, access: tok.text}
+		if tok.text == "tags" {
+			n.eval = func(m telegraf.Metric) interface{} {
+				if v, ok := m.GetTag(key); ok {
+					return v
+				}
+				return nil
+			}
+		} else {
+			n.eval = func(m telegraf.Metric) interface{} {
+				if v, ok := m.GetField(key); ok {
+					return v
+				}
+				return nil
+			}
+		}
+		return n, nil
+	}
+	return nil, fmt.Errorf("unknown identifier %q at position %d", tok.text, tok.pos)
+}
+
+// parseKey reads the key of a tag or field access, either tags.key or
+// tags["key"].
+func (p *parser) parseKey(tok token) (string, error) {
+	switch {
+	case p.isOp("."):
+		p.next()
+		key := p.next()
+		if key.kind != tokenIdent {
+			return "", fmt.Errorf("expected a key after %q but found %s at position %d", tok.text+".", key, key.pos)
+		}
+		return key.text, nil
+	case p.isOp("["):
+		p.next()
+		key := p.next()
+		if key.kind != tokenString {
+			return "", fmt.Errorf("expected a string key but found %s at position %d", key, key.pos)
+		}
+		if err := p.expect("]"); err != nil {
+			return "", err
+		}
+		return key.value.(string), nil
+	}
+	next := p.peek()
+	return "", fmt.Errorf("expected a key after %q but found %s at position %d", tok.text, next, next.pos)
+}
+
+func (p *parser) parseCall(fn token) (*node, error) {
+	p.next()
+	var args []*node
+	for !p.isOp(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.next()
+
+	arity := func(n int) error {
+		if len(args) != n {
+			return fmt.Errorf("%s expects %d arguments but got %d at position %d", fn.text, n, len(args), fn.pos)
+		}
+		return nil
+	}
+
+	switch fn.text {
+	case "now":
+		if err := arity(0); err != nil {
+			return nil, err
+		}
+		return &node{kind: kindTime, pos: fn.pos, eval: func(telegraf.Metric) interface{} {
+			return time.Now()
+		}}, nil
+	case "has":
+		if err := arity(1); err != nil {
+			return nil, err
+		}
+		if args[0].access == "" {
+			return nil, fmt.Errorf("has expects a tag or field at position %d", args[0].pos)
+		}
+		eval := args[0].eval
+		return &node{kind: kindBool, pos: fn.pos, eval: func(m telegraf.Metric) interface{} {
+			return eval(m) != nil
+		}}, nil
+	case "is_numeric", "is_string", "is_bool":
+		if err := arity(1); err != nil {
+			return nil, err
+		}
+		eval := args[0].eval
+		test := typeTests[fn.text]
+		return &node{kind: kindBool, pos: fn.pos, eval: func(m telegraf.Metric) interface{} {
+			return test(eval(m))
+		}}, nil
+	}
+	return nil, fmt.Errorf("unknown function %q at position %d", fn.text, fn.pos)
+}
+
+var typeTests = map[string]func(interface{}) bool{
+	"is_numeric": func(v interface{}) bool {
+		switch v.(type) {
+		case int64, uint64, float64:
+			return true
+		}
+		return false
+	},
+	"is_string": func(v interface{}) bool {
+		_, ok := v.(string)
+		return ok
+	},
+	"is_bool": func(v interface{}) bool {
+		_, ok := v.(bool)
+		return ok
+	},
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// compare returns the order of two values of the same type, numbers of
+// different types are compared as floats.
+func compare(a, b interface{}) (int, bool) {
+	switch a := a.(type) {
+	case int64:
+		if b, ok := b.(int64); ok {
+			return compareInts(a, b), true
+		}
+	case uint64:
+		if b, ok := b.(uint64); ok {
+			switch {
+			case a < b:
+				return -1, true
+			case a > b:
+				return 1, true
+			}
+			return 0, true
+		}
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b), true
+		}
+		return 0, false
+	case time.Time:
+		if b, ok := b.(time.Time); ok {
+			return compareInts(a.UnixNano(), b.UnixNano()), true
+		}
+		return 0, false
+	case time.Duration:
+		if b, ok := b.(time.Duration); ok {
+			return compareInts(int64(a), int64(b)), true
+		}
+		return 0, false
+	}
+
+	x, ok := toFloat(a)
+	if !ok {
+		return 0, false
+	}
+	y, ok := toFloat(b)
+	if !ok {
+		return 0, false
+	}
+	switch {
+	case x < y:
+		return -1, true
+	case x > y:
+		return 1, true
+	}
+	return 0, true
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func equal(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if x, ok := a.(bool); ok {
+		y, ok := b.(bool)
+		return ok && x == y
+	}
+	c, ok := compare(a, b)
+	return ok && c == 0
+}
+
+// arithmetic applies the operator, the result is nil if the operation is not
+// supported for the values.
+func arithmetic(op string, a, b interface{}) interface{} {
+	switch a := a.(type) {
+	case time.Time:
+		switch b := b.(type) {
+		case time.Time:
+			if op == "-" {
+				return a.Sub(b)
+			}
+		case time.Duration:
+			switch op {
+			case "+":
+				return a.Add(b)
+			case "-":
+				return a.Add(-b)
+			}
+		}
+		return nil
+	case time.Duration:
+		switch b := b.(type) {
+		case time.Time:
+			if op == "+" {
+				return b.Add(a)
+			}
+		case time.Duration:
+			switch op {
+			case "+":
+				return a + b
+			case "-":
+				return a - b
+			}
+		}
+		return nil
+	case int64:
+		if b, ok := b.(int64); ok {
+			switch op {
+			case "+":
+				return a + b
+			case "-":
+				return a - b
+			case "*":
+				return a * b
+			case "/":
+				if b != 0 {
+					return a / b
+				}
+			case "%":
+				if b != 0 {
+					return a % b
+				}
+			}
+			return nil
+		}
+	}
+
+	x, ok := toFloat(a)
+	if !ok {
+		return nil
+	}
+	y, ok := toFloat(b)
+	if !ok {
+		return nil
+	}
+	switch op {
+	case "+":
+		return x + y
+	case "-":
+		return x - y
+	case "*":
+		return x * y
+	case "/":
+		if y != 0 {
+			return x / y
+		}
+	}
+	return nil
+}
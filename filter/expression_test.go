@@ -0,0 +1,138 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpression_Select(t *testing.T) {
+	now := time.Now()
+	m := testutil.MustMetric("cpu",
+		map[string]string{
+			"cpu":  "cpu-total",
+			"host": "web-01",
+		},
+		map[string]interface{}{
+			"usage_idle":   float64(97.5),
+			"usage_user":   int64(2),
+			"count":        uint64(10),
+			"state":        "ok",
+			"healthy":      true,
+			"with.a.dot":   int64(1),
+			"nanoseconds":  int64(500),
+			"another_user": int64(-3),
+		},
+		now.Add(-time.Minute),
+	)
+
+	tests := []struct {
+		expression string
+		expected   bool
+	}{
+		{`name == "cpu"`, true},
+		{`name != "cpu"`, false},
+		{`tags.cpu == "cpu-total"`, true},
+		{`tags["cpu"] == "cpu-total"`, true},
+		{`tags.missing == "cpu-total"`, false},
+		{`tags.missing != "cpu-total"`, true},
+		{`fields.usage_idle > 95`, true},
+		{`fields.usage_idle >= 97.5`, true},
+		{`fields.usage_idle < 95`, false},
+		{`fields.usage_user <= 2`, true},
+		{`fields.count == 10`, true},
+		{`fields.count > 9.5`, true},
+		{`fields["with.a.dot"] == 1`, true},
+		{`fields.another_user < -2`, true},
+		{`fields.missing < 5`, false},
+		{`fields.missing > 5`, false},
+		{`fields.state > 5`, false},
+		{`fields.state == "ok"`, true},
+		{`fields.healthy == true`, true},
+		{`fields.healthy`, true},
+		{`!fields.healthy`, false},
+		{`fields.state`, false},
+		{`fields.usage_user * 10 + 5 == 25`, true},
+		{`fields.usage_user / 4 == 0`, true},
+		{`fields.usage_idle / 2 > 48`, true},
+		{`fields.usage_user % 2 == 0`, true},
+		{`fields.usage_user / 0 == 0`, false},
+		{`-fields.usage_user == -2`, true},
+		{`tags.host =~ "^web-"`, true},
+		{`tags.host =~ '^web-\d+$'`, true},
+		{`tags.host !~ '^db-'`, true},
+		{`tags.missing =~ '.*'`, false},
+		{`tags.missing !~ '^db-'`, true},
+		{`tags.cpu == "cpu-total" && fields.usage_idle > 95`, true},
+		{`tags.cpu == "cpu-total" and fields.usage_idle < 95`, false},
+		{`tags.cpu == "cpu0" || fields.usage_idle > 95`, true},
+		{`tags.cpu == "cpu0" or fields.usage_idle < 95`, false},
+		{`not (tags.cpu == "cpu0" or fields.usage_idle < 95)`, true},
+		{`has(tags.cpu) && !has(fields.missing)`, true},
+		{`is_numeric(fields.usage_idle) && is_numeric(fields.count)`, true},
+		{`is_numeric(fields.state)`, false},
+		{`is_string(fields.state) && is_bool(fields.healthy)`, true},
+		{`time > now() - 5m`, true},
+		{`time > now() - 30s`, false},
+		{`now() - time >= 1m`, true},
+		{`now() - time < 1h30m`, true},
+		{`true`, true},
+		{`false || true && false`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			e, err := CompileExpression(tt.expression)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, e.Select(m))
+			require.Equal(t, tt.expression, e.String())
+		})
+	}
+}
+
+func TestCompileExpression_Errors(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   string
+	}{
+		{``, `unexpected end of expression at position 1`},
+		{`name ==`, `unexpected end of expression at position 8`},
+		{`name == "cpu" )`, `unexpected ")" at position 15`},
+		{`(name == "cpu"`, `expected ")" but found end of expression at position 15`},
+		{`name == "cpu`, `unterminated string at position 9`},
+		{`name == 'cpu`, `unterminated string at position 9`},
+		{`name == "\d"`, `invalid string "\d" at position 9`},
+		{`name # 1`, `unexpected character '#' at position 6`},
+		{`host == "a"`, `unknown identifier "host" at position 1`},
+		{`tags == "a"`, `expected a key after "tags" but found "==" at position 6`},
+		{`tags.`, `expected a key after "tags." but found end of expression at position 6`},
+		{`fields[1] == 1`, `expected a string key but found "1" at position 8`},
+		{`count(fields.a) > 1`, `unknown function "count" at position 1`},
+		{`has(name)`, `has expects a tag or field at position 5`},
+		{`has(tags.a, tags.b)`, `has expects 1 arguments but got 2 at position 1`},
+		{`time > now(1)`, `now expects 0 arguments but got 1 at position 8`},
+		{`tags.host =~ "[a-"`, "invalid regular expression at position 14: error parsing regexp: missing closing ]: `[a-`"},
+		{`tags.host =~ tags.pattern`, `"=~" must be followed by a string pattern at position 14`},
+		{`fields.a > 5x`, `invalid duration "5x" at position 12`},
+		{`name == 5`, `cannot compare string and number at position 6`},
+		{`time > 5m`, `cannot compare time and duration at position 6`},
+		{`true < false`, `cannot order conditions with "<" at position 6`},
+		{`time =~ "a"`, `cannot match a time with "=~" at position 6`},
+		{`name + 1 > 2`, `invalid operation string + number at position 6`},
+		{`time + time > now()`, `invalid operation time + time at position 6`},
+		{`time - fields.a > now()`, `invalid operation time - value at position 6`},
+		{`fields.a + 1`, `expression must be a condition, not a number`},
+		{`name`, `expression must be a condition, not a string`},
+		{`fields.a > 1 && name`, `operand of "&&" must be a condition, not a string at position 17`},
+		{`!5`, `operand of "!" must be a condition, not a number at position 2`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, err := CompileExpression(tt.expression)
+			require.EqualError(t, err, tt.expected)
+		})
+	}
+}
@@ -0,0 +1,483 @@
+// Package schedule parses the cron and calendar schedules of inputs.
+//
+// A schedule is either a cron expression with five fields, minute, hour, day
+// of month, month and day of week:
+//
+//   0 2 * * *
+//   */15 8-18 * * mon-fri
+//   @daily
+//
+// or a calendar description:
+//
+//   every day at 02:00
+//   weekdays every 5m between 08:00-18:00
+//   sat,sun at 06:00, 18:00
+//
+// Both can be prefixed with the timezone they are evaluated in, otherwise
+// the local time is used:
+//
+//   TZ=Europe/Berlin 0 2 * * *
+//   CRON_TZ=America/New_York weekdays at 09:30
+package schedule
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a series of times.
+type Schedule interface {
+	// Next returns the first time of the schedule after t, or the zero time
+	// if there is none.
+	Next(t time.Time) time.Time
+}
+
+// Parse parses a list of schedules into a schedule firing at the times of
+// any of them.
+func Parse(specs []string) (Schedule, error) {
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("no schedule given")
+	}
+
+	var u union
+	for _, spec := range specs {
+		s, err := ParseSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		u = append(u, s)
+	}
+	if len(u) == 1 {
+		return u[0], nil
+	}
+	return u, nil
+}
+
+// ParseSpec parses a single cron expression or calendar description.
+func ParseSpec(spec string) (Schedule, error) {
+	s, err := parseSpec(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %v", spec, err)
+	}
+	if s.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("invalid schedule %q: never fires", spec)
+	}
+	return s, nil
+}
+
+func parseSpec(spec string) (Schedule, error) {
+	loc := time.Local
+	fields := strings.Fields(strings.ToLower(spec))
+	if len(fields) > 0 && (strings.HasPrefix(fields[0], "tz=") || strings.HasPrefix(fields[0], "cron_tz=")) {
+		// Timezone names are case sensitive.
+		name := strings.Fields(spec)[0]
+		name = name[strings.Index(name, "=")+1:]
+		var err error
+		loc, err = time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("unknown timezone %q", name)
+		}
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty schedule")
+	}
+
+	if expr, ok := descriptors[fields[0]]; ok {
+		if len(fields) != 1 {
+			return nil, fmt.Errorf("unexpected %q after %s", fields[1], fields[0])
+		}
+		fields = strings.Fields(expr)
+	}
+
+	if isCronField(fields[0]) {
+		return parseCron(fields, loc)
+	}
+	return parseCalendar(fields, loc)
+}
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// isCronField returns true if the first field of the schedule is a cron
+// minute field rather than a word.
+func isCronField(field string) bool {
+	return strings.IndexFunc(field, func(r rune) bool {
+		return !strings.ContainsRune("0123456789*,-/", r)
+	}) < 0
+}
+
+// union fires at the times of all its schedules.
+type union []Schedule
+
+func (u union) Next(t time.Time) time.Time {
+	var next time.Time
+	for _, s := range u {
+		n := s.Next(t)
+		if !n.IsZero() && (next.IsZero() || n.Before(next)) {
+			next = n
+		}
+	}
+	return next
+}
+
+var weekdayNames = map[string]int{
+	"sun": 0, "sunday": 0,
+	"mon": 1, "monday": 1,
+	"tue": 2, "tuesday": 2,
+	"wed": 3, "wednesday": 3,
+	"thu": 4, "thursday": 4,
+	"fri": 5, "friday": 5,
+	"sat": 6, "saturday": 6,
+}
+
+var monthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+// cronSchedule fires at the minutes matching all fields of a cron
+// expression.  The day of month and day of week match if either does when
+// both are restricted, as in cron.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+	location                      *time.Location
+}
+
+func parseCron(fields []string, loc *time.Location) (*cronSchedule, error) {
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 cron fields but got %d", len(fields))
+	}
+
+	s := &cronSchedule{location: loc}
+	var err error
+	if s.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("minute: %v", err)
+	}
+	if s.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("hour: %v", err)
+	}
+	if s.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("day of month: %v", err)
+	}
+	if s.month, err = parseCronField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, fmt.Errorf("month: %v", err)
+	}
+	if s.dow, err = parseCronField(fields[4], 0, 7, weekdayNames); err != nil {
+		return nil, fmt.Errorf("day of week: %v", err)
+	}
+	// Sunday is both 0 and 7.
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = fields[2] == "*"
+	s.dowStar = fields[4] == "*"
+	return s, nil
+}
+
+// parseCronField returns the bits of the values of a field, ie "1-5",
+// "*/15", "mon,wed,fri".
+func parseCronField(field string, min, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part[i+1:])
+			}
+			part = part[:i]
+		}
+
+		lo, hi := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			i := strings.Index(part, "-")
+			var err error
+			if lo, err = cronValue(part[:i], min, max, names); err != nil {
+				return 0, err
+			}
+			if hi, err = cronValue(part[i+1:], min, max, names); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+		default:
+			var err error
+			if lo, err = cronValue(part, min, max, names); err != nil {
+				return 0, err
+			}
+			// "5/15" is short for "5-max/15".
+			if step == 1 {
+				hi = lo
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func cronValue(s string, min, max int, names map[string]int) (int, error) {
+	if v, ok := names[s]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if v < min || v > max {
+		return 0, fmt.Errorf("value %d out of range %d-%d", v, min, max)
+	}
+	return v, nil
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the next matching minute after t.  Nothing is found for
+// impossible dates such as February 30th, the search stops after five years.
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	t = t.In(s.location)
+	limit := t.AddDate(5, 0, 0)
+
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	for next.Before(limit) {
+		switch {
+		case s.month&(1<<uint(next.Month())) == 0:
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, s.location)
+		case !s.dayMatches(next):
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, s.location)
+		case s.hour&(1<<uint(next.Hour())) == 0:
+			next = next.Add(time.Duration(60-next.Minute()) * time.Minute)
+		case s.minute&(1<<uint(next.Minute())) == 0:
+			next = next.Add(time.Minute)
+		default:
+			if next.After(t) {
+				return next
+			}
+			next = next.Add(time.Minute)
+		}
+	}
+	return time.Time{}
+}
+
+// calendarSchedule fires on the selected weekdays at fixed times of the day,
+// or every interval within a window of the day.  Times are seconds since
+// midnight in wall clock time.
+type calendarSchedule struct {
+	weekdays uint8
+	at       []int
+	every    int
+	from, to int
+	location *time.Location
+}
+
+const (
+	allDays  = 0x7f
+	weekdays = 0x3e
+	weekends = 0x41
+	daySecs  = 24 * 60 * 60
+)
+
+func parseCalendar(fields []string, loc *time.Location) (*calendarSchedule, error) {
+	s := &calendarSchedule{weekdays: allDays, to: daySecs, location: loc}
+
+	switch {
+	case len(fields) > 1 && fields[0] == "every" && fields[1] == "day":
+		fields = fields[2:]
+	case fields[0] == "daily":
+		fields = fields[1:]
+	case fields[0] == "weekdays":
+		s.weekdays = weekdays
+		fields = fields[1:]
+	case fields[0] == "weekends":
+		s.weekdays = weekends
+		fields = fields[1:]
+	case fields[0] == "on" && len(fields) > 1:
+		days, err := parseWeekdays(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		s.weekdays = days
+		fields = fields[2:]
+	case fields[0] != "at" && fields[0] != "every":
+		days, err := parseWeekdays(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		s.weekdays = days
+		fields = fields[1:]
+	}
+
+	if len(fields) == 0 {
+		return nil, fmt.Errorf(`expected "at" or "every"`)
+	}
+
+	switch fields[0] {
+	case "at":
+		times := strings.Split(strings.Join(fields[1:], ""), ",")
+		for _, tm := range times {
+			secs, err := parseTimeOfDay(tm)
+			if err != nil {
+				return nil, err
+			}
+			s.at = append(s.at, secs)
+		}
+		sort.Ints(s.at)
+	case "every":
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("expected an interval after every")
+		}
+		d, err := time.ParseDuration(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval %q", fields[1])
+		}
+		if d < time.Second || d > 24*time.Hour || d%time.Second != 0 {
+			return nil, fmt.Errorf("interval %s must be whole seconds up to 24h", d)
+		}
+		s.every = int(d / time.Second)
+
+		rest := fields[2:]
+		if len(rest) > 0 {
+			if rest[0] != "between" {
+				return nil, fmt.Errorf("unexpected %q", rest[0])
+			}
+			window := strings.Join(rest[1:], " ")
+			window = strings.Replace(window, " and ", "-", 1)
+			window = strings.Replace(window, " ", "", -1)
+			parts := strings.Split(window, "-")
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("invalid window %q, expected HH:MM-HH:MM", window)
+			}
+			if s.from, err = parseTimeOfDay(parts[0]); err != nil {
+				return nil, err
+			}
+			if s.to, err = parseTimeOfDay(parts[1]); err != nil {
+				return nil, err
+			}
+			if s.from > s.to {
+				return nil, fmt.Errorf("window %q ends before it starts", window)
+			}
+			// The end of the window is included.
+			s.to++
+		}
+	default:
+		return nil, fmt.Errorf(`expected "at" or "every" but got %q`, fields[0])
+	}
+	return s, nil
+}
+
+// parseWeekdays parses a list of days and ranges, ie "mon,wed" or "mon-fri".
+func parseWeekdays(s string) (uint8, error) {
+	var days uint8
+	for _, part := range strings.Split(s, ",") {
+		if part == "" {
+			continue
+		}
+		lo, hi := part, part
+		if i := strings.Index(part, "-"); i >= 0 {
+			lo, hi = part[:i], part[i+1:]
+		}
+		from, ok := weekdayNames[lo]
+		if !ok {
+			return 0, fmt.Errorf("unknown day %q", lo)
+		}
+		to, ok := weekdayNames[hi]
+		if !ok {
+			return 0, fmt.Errorf("unknown day %q", hi)
+		}
+		for d := from; ; d = (d + 1) % 7 {
+			days |= 1 << uint(d)
+			if d == to {
+				break
+			}
+		}
+	}
+	if days == 0 {
+		return 0, fmt.Errorf("no days given")
+	}
+	return days, nil
+}
+
+// parseTimeOfDay parses HH:MM or HH:MM:SS into seconds since midnight.
+func parseTimeOfDay(s string) (int, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	limits := []int{23, 59, 59}
+	secs := 0
+	for i, part := range parts {
+		v, err := strconv.Atoi(part)
+		if err != nil || v < 0 || v > limits[i] {
+			return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+		}
+		secs = secs*60 + v
+	}
+	if len(parts) == 2 {
+		secs *= 60
+	}
+	return secs, nil
+}
+
+// Next returns the next time of the schedule after t, looking at most a week
+// ahead.
+func (s *calendarSchedule) Next(t time.Time) time.Time {
+	t = t.In(s.location)
+	for i := 0; i < 8; i++ {
+		day := time.Date(t.Year(), t.Month(), t.Day()+i, 0, 0, 0, 0, s.location)
+		if s.weekdays&(1<<uint(day.Weekday())) == 0 {
+			continue
+		}
+		at := func(secs int) time.Time {
+			return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, secs, 0, s.location)
+		}
+
+		if s.every == 0 {
+			for _, secs := range s.at {
+				if next := at(secs); next.After(t) {
+					return next
+				}
+			}
+			continue
+		}
+
+		start := s.from
+		if i == 0 {
+			// Skip the times of the day that have passed already.
+			now := t.Hour()*3600 + t.Minute()*60 + t.Second()
+			if now > start {
+				start += (now - start) / s.every * s.every
+			}
+		}
+		for secs := start; secs < s.to; secs += s.every {
+			if next := at(secs); next.After(t) {
+				return next
+			}
+		}
+	}
+	return time.Time{}
+}
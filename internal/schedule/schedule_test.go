@@ -0,0 +1,191 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedule_Next(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	// 2021-03-05 is a Friday.
+	start := time.Date(2021, 3, 5, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		spec     string
+		expected []time.Time
+	}{
+		{
+			spec: "TZ=UTC */15 * * * *",
+			expected: []time.Time{
+				time.Date(2021, 3, 5, 10, 15, 0, 0, time.UTC),
+				time.Date(2021, 3, 5, 10, 30, 0, 0, time.UTC),
+				time.Date(2021, 3, 5, 10, 45, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "TZ=UTC 0 2 * * *",
+			expected: []time.Time{
+				time.Date(2021, 3, 6, 2, 0, 0, 0, time.UTC),
+				time.Date(2021, 3, 7, 2, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "TZ=UTC 30 9 * * mon-fri",
+			expected: []time.Time{
+				time.Date(2021, 3, 8, 9, 30, 0, 0, time.UTC),
+				time.Date(2021, 3, 9, 9, 30, 0, 0, time.UTC),
+			},
+		},
+		{
+			// The day of month and day of week match either.
+			spec: "TZ=UTC 0 0 1 * sun",
+			expected: []time.Time{
+				time.Date(2021, 3, 7, 0, 0, 0, 0, time.UTC),
+				time.Date(2021, 3, 14, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "TZ=UTC 0 0 29 feb *",
+			expected: []time.Time{
+				time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "TZ=UTC @monthly",
+			expected: []time.Time{
+				time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC),
+				time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "CRON_TZ=Europe/Berlin 0 2 * * *",
+			expected: []time.Time{
+				time.Date(2021, 3, 6, 2, 0, 0, 0, berlin),
+				time.Date(2021, 3, 7, 2, 0, 0, 0, berlin),
+			},
+		},
+		{
+			spec: "TZ=UTC every day at 02:00",
+			expected: []time.Time{
+				time.Date(2021, 3, 6, 2, 0, 0, 0, time.UTC),
+				time.Date(2021, 3, 7, 2, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "TZ=UTC sat,sun at 18:00, 06:00",
+			expected: []time.Time{
+				time.Date(2021, 3, 6, 6, 0, 0, 0, time.UTC),
+				time.Date(2021, 3, 6, 18, 0, 0, 0, time.UTC),
+				time.Date(2021, 3, 7, 6, 0, 0, 0, time.UTC),
+				time.Date(2021, 3, 7, 18, 0, 0, 0, time.UTC),
+				time.Date(2021, 3, 13, 6, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "TZ=UTC weekdays every 5m between 08:00-10:10",
+			expected: []time.Time{
+				time.Date(2021, 3, 5, 10, 10, 0, 0, time.UTC),
+				time.Date(2021, 3, 8, 8, 0, 0, 0, time.UTC),
+				time.Date(2021, 3, 8, 8, 5, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "TZ=UTC on mon-wed every 12h",
+			expected: []time.Time{
+				time.Date(2021, 3, 8, 0, 0, 0, 0, time.UTC),
+				time.Date(2021, 3, 8, 12, 0, 0, 0, time.UTC),
+				time.Date(2021, 3, 9, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "TZ=UTC every 45s between 10:08 and 10:09",
+			expected: []time.Time{
+				time.Date(2021, 3, 5, 10, 8, 0, 0, time.UTC),
+				time.Date(2021, 3, 5, 10, 8, 45, 0, time.UTC),
+				time.Date(2021, 3, 6, 10, 8, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, err := ParseSpec(tt.spec)
+			require.NoError(t, err)
+
+			now := start
+			for _, expected := range tt.expected {
+				now = s.Next(now)
+				require.True(t, expected.Equal(now), "expected %s but got %s", expected, now)
+			}
+		})
+	}
+}
+
+func TestSchedule_NextDaylightSaving(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	// The clocks skip from 02:00 to 03:00 on 2021-03-28.
+	s, err := ParseSpec("TZ=Europe/Berlin every 30m between 01:00-03:30")
+	require.NoError(t, err)
+
+	now := time.Date(2021, 3, 28, 0, 0, 0, 0, berlin)
+	var actual []string
+	for i := 0; i < 4; i++ {
+		now = s.Next(now)
+		actual = append(actual, now.Format("15:04 MST"))
+	}
+	// The skipped times fire once the clocks are turned forward.
+	require.Equal(t, []string{"01:00 CET", "01:30 CET", "03:00 CEST", "03:30 CEST"}, actual)
+}
+
+func TestParse_Union(t *testing.T) {
+	s, err := Parse([]string{"TZ=UTC 0 2 * * *", "TZ=UTC weekdays at 12:00"})
+	require.NoError(t, err)
+
+	now := time.Date(2021, 3, 5, 10, 0, 0, 0, time.UTC)
+	now = s.Next(now)
+	require.Equal(t, time.Date(2021, 3, 5, 12, 0, 0, 0, time.UTC), now)
+	now = s.Next(now)
+	require.Equal(t, time.Date(2021, 3, 6, 2, 0, 0, 0, time.UTC), now)
+	now = s.Next(now)
+	require.Equal(t, time.Date(2021, 3, 7, 2, 0, 0, 0, time.UTC), now)
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected string
+	}{
+		{"", `invalid schedule "": empty schedule`},
+		{"TZ=Nowhere/Town 0 2 * * *", `invalid schedule "TZ=Nowhere/Town 0 2 * * *": unknown timezone "Nowhere/Town"`},
+		{"0 2 * *", `invalid schedule "0 2 * *": expected 5 cron fields but got 4`},
+		{"60 * * * *", `invalid schedule "60 * * * *": minute: value 60 out of range 0-59`},
+		{"* 5-1 * * *", `invalid schedule "* 5-1 * * *": hour: invalid range "5-1"`},
+		{"*/0 * * * *", `invalid schedule "*/0 * * * *": minute: invalid step "0"`},
+		{"0 0 * foo *", `invalid schedule "0 0 * foo *": month: invalid value "foo"`},
+		{"0 0 30 2 *", `invalid schedule "0 0 30 2 *": never fires`},
+		{"@daily at 02:00", `invalid schedule "@daily at 02:00": unexpected "at" after @daily`},
+		{"every day", `invalid schedule "every day": expected "at" or "every"`},
+		{"every day at 25:00", `invalid schedule "every day at 25:00": invalid time "25:00", expected HH:MM`},
+		{"someday at 02:00", `invalid schedule "someday at 02:00": unknown day "someday"`},
+		{"weekdays every 5x", `invalid schedule "weekdays every 5x": invalid interval "5x"`},
+		{"every 1500ms", `invalid schedule "every 1500ms": interval 1.5s must be whole seconds up to 24h`},
+		{"every 5m between 18:00-08:00", `invalid schedule "every 5m between 18:00-08:00": window "18:00-08:00" ends before it starts`},
+		{"every 5m from 08:00", `invalid schedule "every 5m from 08:00": unexpected "from"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			_, err := Parse([]string{tt.spec})
+			require.EqualError(t, err, tt.expected)
+		})
+	}
+
+	_, err := Parse(nil)
+	require.EqualError(t, err, "no schedule given")
+}
@@ -11,6 +11,8 @@ Usage:
 The commands & flags are:
 
   config              print out full sample configuration to stdout
+  secrets             list or set the secrets of a secret store
+  test-config         run test cases against the parsers and processors
   version             print the version to stdout
 
   --aggregator-filter <filter>   filter the aggregators to enable, separator is :
@@ -30,11 +32,15 @@ The commands & flags are:
                                  Valid values are 'agent', 'global_tags', 'outputs',
                                  'processors', 'aggregators' and 'inputs'
   --once                         enable once mode: gather metrics once, write them, and exit
-  --test                         enable test mode: gather metrics once and print them
+  --test                         enable test mode: gather metrics once, run the
+                                 processors and aggregators and print what each
+                                 output would receive, without writing it
   --test-wait                    wait up to this many seconds for service
                                  inputs to complete in test or once mode
   --usage <plugin>               print usage for a plugin, ie, 'telegraf --usage mysql'
   --version                      display the version and exit
+  --watch-config                 watch the config files and restart only the plugins
+                                 that were added, removed or changed
 
   --console                      run as console application (windows only)
   --service <service>            operate on the service (windows only)
@@ -49,9 +55,12 @@ Examples:
   # generate config with only cpu input & influxdb output plugins defined
   telegraf --input-filter cpu --output-filter influxdb config
 
-  # run a single telegraf collection, outputting metrics to stdout
+  # run a single telegraf collection, printing the metrics of each output to stdout
   telegraf --config telegraf.conf --test
 
+  # run the test cases in the testdata directory against the config
+  telegraf --config telegraf.conf test-config testdata
+
   # run telegraf with all plugins defined in config file
   telegraf --config telegraf.conf
 
@@ -0,0 +1,119 @@
+package metric
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/influxdata/telegraf"
+)
+
+// HasDistribution returns true if any field of the metric is a
+// *telegraf.Distribution.
+func HasDistribution(m telegraf.Metric) bool {
+	for _, field := range m.FieldList() {
+		if _, ok := field.Value.(*telegraf.Distribution); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// FlattenDistributions replaces the distribution fields of the metrics with
+// metrics in the layout of the prometheus parser, for serializers that lack
+// native support:
+//
+//   - Each histogram bucket becomes a <field>_bucket field tagged with the
+//     upper bound as le, including the +Inf bucket.
+//   - Each summary quantile becomes a <field> field tagged with the quantile.
+//   - The count and sum become the <field>_count and <field>_sum fields.
+//
+// The flattened metrics have the Histogram or Summary type, the other fields
+// are kept in a metric of their own.  Metrics without distributions are
+// returned as is.
+func FlattenDistributions(metrics []telegraf.Metric) []telegraf.Metric {
+	for i, m := range metrics {
+		if !HasDistribution(m) {
+			continue
+		}
+
+		// Copy the slice on the first distribution to leave the argument
+		// untouched.
+		flattened := make([]telegraf.Metric, 0, len(metrics)+1)
+		flattened = append(flattened, metrics[:i]...)
+		for _, m := range metrics[i:] {
+			if HasDistribution(m) {
+				flattened = append(flattened, flatten(m)...)
+			} else {
+				flattened = append(flattened, m)
+			}
+		}
+		return flattened
+	}
+	return metrics
+}
+
+func flatten(m telegraf.Metric) []telegraf.Metric {
+	var result []telegraf.Metric
+
+	tags := m.Tags()
+	rest := make(map[string]interface{})
+	for _, field := range m.FieldList() {
+		d, ok := field.Value.(*telegraf.Distribution)
+		if !ok {
+			rest[field.Key] = field.Value
+			continue
+		}
+
+		tp := telegraf.Histogram
+		if d.IsSummary() {
+			tp = telegraf.Summary
+		}
+
+		fields := map[string]interface{}{
+			field.Key + "_count": float64(d.Count),
+			field.Key + "_sum":   d.Sum,
+		}
+		if s, err := New(m.Name(), tags, fields, m.Time(), tp); err == nil {
+			result = append(result, s)
+		}
+
+		if tp == telegraf.Summary {
+			for _, q := range d.Quantiles {
+				result = appendFlattened(result, m, tp, "quantile", q.Quantile, field.Key, q.Value)
+			}
+			continue
+		}
+
+		for _, b := range d.Buckets {
+			result = appendFlattened(result, m, tp, "le", b.UpperBound, field.Key+"_bucket", float64(b.Count))
+		}
+		if n := len(d.Buckets); n == 0 || !math.IsInf(d.Buckets[n-1].UpperBound, 1) {
+			result = appendFlattened(result, m, tp, "le", math.Inf(1), field.Key+"_bucket", float64(d.Count))
+		}
+	}
+
+	if len(rest) != 0 {
+		if r, err := New(m.Name(), tags, rest, m.Time(), m.Type()); err == nil {
+			result = append([]telegraf.Metric{r}, result...)
+		}
+	}
+	return result
+}
+
+func appendFlattened(
+	metrics []telegraf.Metric,
+	m telegraf.Metric,
+	tp telegraf.ValueType,
+	tag string,
+	bound float64,
+	key string,
+	value float64,
+) []telegraf.Metric {
+	tags := m.Tags()
+	tags[tag] = strconv.FormatFloat(bound, 'g', -1, 64)
+	s, err := New(m.Name(), tags, map[string]interface{}{key: value}, m.Time(), tp)
+	if err != nil {
+		return metrics
+	}
+	return append(metrics, s)
+}
@@ -0,0 +1,88 @@
+package metric
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMetric_Distribution(t *testing.T) {
+	d := telegraf.Distribution{
+		Count:   3,
+		Sum:     1.5,
+		Buckets: []telegraf.Bucket{{UpperBound: 1, Count: 2}},
+	}
+	m, err := New("http", nil, map[string]interface{}{"duration": d}, time.Unix(0, 0))
+	require.NoError(t, err)
+
+	v, ok := m.GetField("duration")
+	require.True(t, ok)
+	require.Equal(t, &d, v)
+
+	// The value is copied, changes to the copy do not affect the metric.
+	m2 := m.Copy()
+	v2, _ := m2.GetField("duration")
+	v2.(*telegraf.Distribution).Buckets[0].Count = 3
+	require.Equal(t, uint64(2), v.(*telegraf.Distribution).Buckets[0].Count)
+}
+
+func TestFlattenDistributions(t *testing.T) {
+	now := time.Unix(0, 0)
+	plain, err := New("cpu", nil, map[string]interface{}{"value": 42.0}, now)
+	require.NoError(t, err)
+	m, err := New("http",
+		map[string]string{"host": "example.org"},
+		map[string]interface{}{
+			"requests": int64(3),
+			"duration": &telegraf.Distribution{
+				Count:   10,
+				Sum:     4.5,
+				Buckets: []telegraf.Bucket{{UpperBound: 0.5, Count: 7}},
+			},
+		},
+		now,
+	)
+	require.NoError(t, err)
+	s, err := New("http",
+		map[string]string{"host": "example.org"},
+		map[string]interface{}{
+			"size": &telegraf.Distribution{
+				Count:     10,
+				Sum:       2048,
+				Quantiles: []telegraf.Quantile{{Quantile: 0.5, Value: 128}},
+			},
+		},
+		now,
+	)
+	require.NoError(t, err)
+
+	metrics := []telegraf.Metric{plain, m, s}
+	require.Equal(t, []telegraf.Metric{plain}, FlattenDistributions(metrics[:1]))
+
+	type flat struct {
+		tp     telegraf.ValueType
+		tags   map[string]string
+		fields map[string]interface{}
+	}
+	var actual []flat
+	for _, f := range FlattenDistributions(metrics) {
+		actual = append(actual, flat{f.Type(), f.Tags(), f.Fields()})
+	}
+
+	host := map[string]string{"host": "example.org"}
+	require.Equal(t, []flat{
+		{telegraf.Untyped, map[string]string{}, map[string]interface{}{"value": 42.0}},
+		{telegraf.Untyped, host, map[string]interface{}{"requests": int64(3)}},
+		{telegraf.Histogram, host, map[string]interface{}{"duration_count": 10.0, "duration_sum": 4.5}},
+		{telegraf.Histogram, map[string]string{"host": "example.org", "le": "0.5"}, map[string]interface{}{"duration_bucket": 7.0}},
+		{telegraf.Histogram, map[string]string{"host": "example.org", "le": "+Inf"}, map[string]interface{}{"duration_bucket": 10.0}},
+		{telegraf.Summary, host, map[string]interface{}{"size_count": 10.0, "size_sum": 2048.0}},
+		{telegraf.Summary, map[string]string{"host": "example.org", "quantile": "0.5"}, map[string]interface{}{"size": 128.0}},
+	}, actual)
+
+	// The argument is left untouched.
+	require.Equal(t, []telegraf.Metric{plain, m, s}, metrics)
+	require.True(t, HasDistribution(m))
+}
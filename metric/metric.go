@@ -81,7 +81,11 @@ func FromMetric(other telegraf.Metric) telegraf.Metric {
 	}
 
 	for i, field := range other.FieldList() {
-		m.fields[i] = &telegraf.Field{Key: field.Key, Value: field.Value}
+		value := field.Value
+		if d, ok := value.(*telegraf.Distribution); ok {
+			value = d.Copy()
+		}
+		m.fields[i] = &telegraf.Field{Key: field.Key, Value: value}
 	}
 	return m
 }
@@ -246,7 +250,11 @@ func (m *metric) Copy() telegraf.Metric {
 	}
 
 	for i, field := range m.fields {
-		m2.fields[i] = &telegraf.Field{Key: field.Key, Value: field.Value}
+		value := field.Value
+		if d, ok := value.(*telegraf.Distribution); ok {
+			value = d.Copy()
+		}
+		m2.fields[i] = &telegraf.Field{Key: field.Key, Value: value}
 	}
 	return m2
 }
@@ -374,6 +382,12 @@ func convertField(v interface{}) interface{} {
 		if v != nil {
 			return float64(*v)
 		}
+	case *telegraf.Distribution:
+		if v != nil {
+			return v
+		}
+	case telegraf.Distribution:
+		return v.Copy()
 	default:
 		return nil
 	}
@@ -24,6 +24,13 @@ func WithGroupTracking(metric []telegraf.Metric, fn NotifyFunc) ([]telegraf.Metr
 	return newTrackingMetricGroup(metric, fn)
 }
 
+// IsTracking returns true if the metric reports its delivery once it is
+// accepted, rejected or dropped.
+func IsTracking(metric telegraf.Metric) bool {
+	_, ok := metric.(*trackingMetric)
+	return ok
+}
+
 func EnableDebugFinalizer() {
 	finalizer = debugFinalizer
 }
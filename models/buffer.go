@@ -12,6 +12,19 @@ var (
 	AgentMetricsDropped = selfstat.Register("agent", "metrics_dropped", map[string]string{})
 )
 
+// MetricBuffer holds the metrics of an output that are waiting to be written.
+//
+// Batch hands out the oldest metrics, which must then be returned with either
+// Accept, once they are written, or Reject, to keep them for a later attempt.
+type MetricBuffer interface {
+	Len() int
+	Add(metrics ...telegraf.Metric) int
+	Batch(batchSize int) []telegraf.Metric
+	Accept(batch []telegraf.Metric)
+	Reject(batch []telegraf.Metric)
+	Close() error
+}
+
 // Buffer stores metrics in a circular buffer.
 type Buffer struct {
 	sync.Mutex
@@ -220,6 +233,32 @@ func (b *Buffer) Reject(batch []telegraf.Metric) {
 	b.BufferSize.Set(int64(b.length()))
 }
 
+// drain removes all metrics from the buffer without accepting or rejecting
+// them, the caller takes ownership of the metrics.  It must not be called
+// while a batch is outstanding.
+func (b *Buffer) drain() []telegraf.Metric {
+	b.Lock()
+	defer b.Unlock()
+
+	out := make([]telegraf.Metric, 0, b.size)
+	for i := b.first; len(out) < b.size; i = b.next(i) {
+		out = append(out, b.buf[i])
+		b.buf[i] = nil
+	}
+
+	b.first = 0
+	b.last = 0
+	b.size = 0
+	b.resetBatch()
+	b.BufferSize.Set(0)
+	return out
+}
+
+// Close releases the buffer; metrics still held by it are discarded.
+func (b *Buffer) Close() error {
+	return nil
+}
+
 // dist returns the distance between two indexes.  Because this data structure
 // uses a half open range the arguments must both either left side or right
 // side pairs.
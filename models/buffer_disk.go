@@ -0,0 +1,1030 @@
+package models
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/selfstat"
+	"github.com/tinylib/msgp/msgp"
+)
+
+const (
+	// BufferStrategyMemory keeps unsent metrics in an in-memory ring.
+	BufferStrategyMemory = "memory"
+
+	// BufferStrategyDisk keeps unsent metrics in segment files on disk.
+	BufferStrategyDisk = "disk"
+
+	// FsyncAlways syncs the active segment after every write.
+	FsyncAlways = "always"
+
+	// FsyncInterval syncs the active segment at most once per interval.
+	FsyncInterval = "interval"
+
+	// FsyncNever leaves flushing the segments to the operating system.
+	FsyncNever = "never"
+
+	// Default size at which a new segment file is started.
+	DefaultBufferSegmentSize = 8 * 1024 * 1024
+
+	// Default time between syncs with the interval fsync policy.
+	DefaultBufferFsyncInterval = time.Second
+
+	segmentExt     = ".seg"
+	checkpointFile = "checkpoint.json"
+	recordHeader   = 8 // uint32 payload length followed by its crc32
+)
+
+// DiskBufferConfig configures the on-disk buffer of an output.
+type DiskBufferConfig struct {
+	// Directory holding the segment files, it is created if missing.
+	Directory string
+
+	// MaxSize is the maximum number of bytes kept on disk; when exceeded the
+	// oldest segment is dropped.  The segment being written to is never
+	// dropped, so the effective limit is at least SegmentSize.  Zero means
+	// unlimited.
+	MaxSize int64
+
+	// SegmentSize is the size at which a new segment file is started.
+	SegmentSize int64
+
+	// MaxMetrics is the maximum number of metrics kept; when exceeded the
+	// oldest metrics are dropped.  Zero means unlimited.
+	MaxMetrics int
+
+	// Fsync is the fsync policy, one of "always", "interval" or "never".
+	Fsync         string
+	FsyncInterval time.Duration
+	// Log receives the messages of the buffer, defaults to a logger of the
+	// output.
+	Log telegraf.Logger
+}
+
+type diskSegment struct {
+	seq   uint64
+	path  string
+	size  int64 // bytes of valid records
+	count int   // number of valid records
+}
+
+// diskPosition is the location of a record within the segments.
+type diskPosition struct {
+	Segment uint64 `json:"segment"`
+	Offset  int64  `json:"offset"`
+	Index   int    `json:"index"` // number of records before Offset
+}
+
+// diskRecord identifies a record by its segment and its index within the
+// segment.
+type diskRecord struct {
+	segment uint64
+	index   int
+}
+
+// DiskBuffer stores metrics in append-only segment files so that unsent
+// metrics survive a restart of Telegraf.  Metrics are replayed in the order
+// they were added.
+//
+// Tracking metrics are also kept in memory until they are written by the
+// output, they are accepted once written and rejected when dropped.  Those
+// still unwritten when the buffer is closed are accepted, they are kept on
+// disk and written by the next buffer opened in the directory.
+type DiskBuffer struct {
+	sync.Mutex
+	cfg DiskBufferConfig
+	log telegraf.Logger
+
+	segments []*diskSegment // ordered from oldest to newest, the last is active
+	file     *os.File       // active segment
+	lastSync time.Time
+
+	read  diskPosition  // first record not yet accepted
+	batch *diskPosition // one after the last record of the outstanding batch
+
+	batchCount   int // records covered by the outstanding batch
+	batchSkipped int // records in the batch that could not be decoded
+
+	size  int   // number of records not yet accepted
+	bytes int64 // total size of all segments
+
+	tracked      map[diskRecord]telegraf.Metric // unwritten tracking metrics
+	batchTracked []diskRecord                   // tracking metrics of the outstanding batch
+
+	encoded []byte // reused for encoding the metrics
+
+	MetricsAdded   selfstat.Stat
+	MetricsWritten selfstat.Stat
+	MetricsDropped selfstat.Stat
+	BufferSize     selfstat.Stat
+}
+
+// NewDiskBuffer opens the disk buffer in the configured directory, picking up
+// any metrics left by a previous run.
+func NewDiskBuffer(name string, alias string, cfg DiskBufferConfig) (*DiskBuffer, error) {
+	if cfg.Directory == "" {
+		return nil, errors.New("no buffer directory specified")
+	}
+	if cfg.SegmentSize <= 0 {
+		cfg.SegmentSize = DefaultBufferSegmentSize
+	}
+	if cfg.FsyncInterval <= 0 {
+		cfg.FsyncInterval = DefaultBufferFsyncInterval
+	}
+	switch cfg.Fsync {
+	case "":
+		cfg.Fsync = FsyncAlways
+	case FsyncAlways, FsyncInterval, FsyncNever:
+	default:
+		return nil, fmt.Errorf("unknown fsync policy %q", cfg.Fsync)
+	}
+
+	tags := map[string]string{"output": name}
+	if alias != "" {
+		tags["alias"] = alias
+	}
+
+	if cfg.Log == nil {
+		cfg.Log = NewLogger("outputs", name, alias)
+	}
+
+	b := &DiskBuffer{
+		cfg:     cfg,
+		log:     cfg.Log,
+		tracked: make(map[diskRecord]telegraf.Metric),
+
+		MetricsAdded: selfstat.Register(
+			"write",
+			"metrics_added",
+			tags,
+		),
+		MetricsWritten: selfstat.Register(
+			"write",
+			"metrics_written",
+			tags,
+		),
+		MetricsDropped: selfstat.Register(
+			"write",
+			"metrics_dropped",
+			tags,
+		),
+		BufferSize: selfstat.Register(
+			"write",
+			"buffer_size",
+			tags,
+		),
+	}
+
+	if err := b.open(); err != nil {
+		return nil, err
+	}
+	b.BufferSize.Set(int64(b.size))
+	return b, nil
+}
+
+// open loads the existing segments and starts a new active segment.
+func (b *DiskBuffer) open() error {
+	if err := os.MkdirAll(b.cfg.Directory, 0700); err != nil {
+		return err
+	}
+
+	entries, err := ioutil.ReadDir(b.cfg.Directory)
+	if err != nil {
+		return err
+	}
+
+	var checkpoint diskPosition
+	data, err := ioutil.ReadFile(filepath.Join(b.cfg.Directory, checkpointFile))
+	if err == nil {
+		if err := json.Unmarshal(data, &checkpoint); err != nil {
+			b.log.Warnf("Ignoring invalid buffer checkpoint: %v", err)
+			checkpoint = diskPosition{}
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	var nextSeq uint64
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), segmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(entry.Name(), segmentExt), 16, 64)
+		if err != nil {
+			continue
+		}
+
+		seg := &diskSegment{seq: seq, path: filepath.Join(b.cfg.Directory, entry.Name())}
+		if seq >= nextSeq {
+			nextSeq = seq + 1
+		}
+
+		// Segments older than the checkpoint were fully written already.
+		if seq < checkpoint.Segment {
+			if err := os.Remove(seg.path); err != nil {
+				return err
+			}
+			continue
+		}
+
+		seg.size, seg.count, err = scanSegment(seg.path, entry.Size())
+		if err != nil {
+			return err
+		}
+		if seg.size != entry.Size() {
+			b.log.Warnf("Buffer segment %s is truncated or corrupt after %d bytes", seg.path, seg.size)
+		}
+		b.segments = append(b.segments, seg)
+	}
+	sort.Slice(b.segments, func(i, j int) bool {
+		return b.segments[i].seq < b.segments[j].seq
+	})
+
+	for _, seg := range b.segments {
+		b.size += seg.count
+		b.bytes += seg.size
+	}
+
+	if len(b.segments) > 0 && b.segments[0].seq == checkpoint.Segment &&
+		checkpoint.Offset <= b.segments[0].size && checkpoint.Index <= b.segments[0].count {
+		b.read = checkpoint
+		b.size -= checkpoint.Index
+	}
+
+	if checkpoint.Segment >= nextSeq {
+		nextSeq = checkpoint.Segment + 1
+	}
+	if err := b.createSegment(nextSeq); err != nil {
+		return err
+	}
+
+	if b.read.Segment != b.segments[0].seq {
+		b.read = diskPosition{Segment: b.segments[0].seq}
+	}
+
+	if b.size > 0 {
+		b.log.Infof("Recovered %d unsent metrics from buffer", b.size)
+	}
+	return nil
+}
+
+// createSegment starts a new active segment with the given sequence number.
+func (b *DiskBuffer) createSegment(seq uint64) error {
+	path := filepath.Join(b.cfg.Directory, fmt.Sprintf("%016x%s", seq, segmentExt))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if b.file != nil {
+		if err := b.closeFile(); err != nil {
+			b.log.Errorf("Closing buffer segment: %v", err)
+		}
+	}
+
+	b.file = f
+	b.segments = append(b.segments, &diskSegment{seq: seq, path: path})
+	return nil
+}
+
+func (b *DiskBuffer) closeFile() error {
+	if b.cfg.Fsync != FsyncNever {
+		if err := b.file.Sync(); err != nil {
+			b.file.Close()
+			return err
+		}
+	}
+	return b.file.Close()
+}
+
+func (b *DiskBuffer) active() *diskSegment {
+	return b.segments[len(b.segments)-1]
+}
+
+// Len returns the number of metrics currently in the buffer.
+func (b *DiskBuffer) Len() int {
+	b.Lock()
+	defer b.Unlock()
+
+	return b.size
+}
+
+func (b *DiskBuffer) metricAdded() {
+	b.MetricsAdded.Incr(1)
+}
+
+func (b *DiskBuffer) metricWritten(metric telegraf.Metric) {
+	AgentMetricsWritten.Incr(1)
+	b.MetricsWritten.Incr(1)
+	metric.Accept()
+}
+
+func (b *DiskBuffer) metricsDropped(count int) {
+	AgentMetricsDropped.Incr(int64(count))
+	b.MetricsDropped.Incr(int64(count))
+}
+
+func (b *DiskBuffer) add(m telegraf.Metric) int {
+	data, err := appendMetric(b.encoded[:0], m)
+	b.encoded = data
+	if err != nil {
+		b.log.Errorf("Could not encode metric for buffer: %v", err)
+		b.metricsDropped(1)
+		m.Reject()
+		return 1
+	}
+
+	active := b.active()
+	if active.size > 0 && active.size+int64(recordHeader+len(data)) > b.cfg.SegmentSize {
+		if err := b.createSegment(active.seq + 1); err != nil {
+			b.log.Errorf("Could not create buffer segment: %v", err)
+			b.metricsDropped(1)
+			m.Reject()
+			return 1
+		}
+		active = b.active()
+	}
+
+	n, err := writeRecord(b.file, data)
+	if err != nil {
+		b.log.Errorf("Could not write metric to buffer: %v", err)
+		b.metricsDropped(1)
+		m.Reject()
+		return 1
+	}
+
+	// Tracking metrics report their delivery once written by the output.
+	if metric.IsTracking(m) {
+		b.tracked[diskRecord{segment: active.seq, index: active.count}] = m
+	}
+
+	b.metricAdded()
+	active.size += n
+	active.count++
+	b.bytes += n
+	b.size++
+	return 0
+}
+
+// Add adds metrics to the buffer and returns number of dropped metrics.
+func (b *DiskBuffer) Add(metrics ...telegraf.Metric) int {
+	b.Lock()
+	defer b.Unlock()
+
+	dropped := 0
+	for i := range metrics {
+		dropped += b.add(metrics[i])
+	}
+	b.sync()
+	dropped += b.enforceLimit()
+
+	b.BufferSize.Set(int64(b.size))
+	return dropped
+}
+
+// sync flushes the active segment according to the fsync policy.
+func (b *DiskBuffer) sync() {
+	switch b.cfg.Fsync {
+	case FsyncNever:
+		return
+	case FsyncInterval:
+		if time.Since(b.lastSync) < b.cfg.FsyncInterval {
+			return
+		}
+	}
+
+	if err := b.file.Sync(); err != nil {
+		b.log.Errorf("Could not sync buffer segment: %v", err)
+		return
+	}
+	b.lastSync = time.Now()
+}
+
+// enforceLimit drops the oldest metrics while the buffer holds more than its
+// maximum number of metrics or is larger than its maximum size.  Nothing is
+// dropped while a batch is outstanding, the limit is checked again once the
+// batch is returned.
+func (b *DiskBuffer) enforceLimit() int {
+	if b.batch != nil {
+		return 0
+	}
+
+	dropped := 0
+	if b.cfg.MaxMetrics > 0 && b.size > b.cfg.MaxMetrics {
+		dropped += b.dropOldest(b.size - b.cfg.MaxMetrics)
+	}
+
+	for b.cfg.MaxSize > 0 && b.bytes > b.cfg.MaxSize && len(b.segments) > 1 {
+		count, err := b.dropSegment()
+		if err != nil {
+			b.log.Errorf("Could not remove buffer segment: %v", err)
+			break
+		}
+		dropped += count
+	}
+
+	if dropped > 0 {
+		b.writeCheckpoint()
+	}
+	return dropped
+}
+
+// dropOldest drops the oldest n metrics, whole segments are removed and the
+// read position is moved past the records of a partially dropped segment.
+func (b *DiskBuffer) dropOldest(n int) int {
+	dropped := 0
+	for n > 0 {
+		oldest := b.segments[0]
+		if count := oldest.count - b.read.Index; count <= n && len(b.segments) > 1 {
+			count, err := b.dropSegment()
+			if err != nil {
+				b.log.Errorf("Could not remove buffer segment: %v", err)
+				break
+			}
+			dropped += count
+			n -= count
+			continue
+		}
+
+		pos, err := skipRecords(oldest, b.read, n)
+		if err != nil {
+			b.log.Errorf("Could not read buffer segment: %v", err)
+			break
+		}
+		count := pos.Index - b.read.Index
+		b.rejectTracked(oldest.seq, b.read.Index, pos.Index)
+		b.read = pos
+		b.size -= count
+		b.metricsDropped(count)
+		dropped += count
+		n -= count
+		if count == 0 {
+			break
+		}
+	}
+	return dropped
+}
+
+// dropSegment removes the oldest segment and returns the number of metrics
+// dropped with it.
+func (b *DiskBuffer) dropSegment() (int, error) {
+	oldest := b.segments[0]
+	count := oldest.count - b.read.Index
+	if err := os.Remove(oldest.path); err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	b.rejectTracked(oldest.seq, b.read.Index, oldest.count)
+	b.segments = b.segments[1:]
+	b.read = diskPosition{Segment: b.segments[0].seq}
+	b.bytes -= oldest.size
+	b.size -= count
+	b.metricsDropped(count)
+	return count, nil
+}
+
+// dropUnreadable drops the records of the segment from pos on, they can not
+// be read anymore.  No more metrics are added to the segment.
+func (b *DiskBuffer) dropUnreadable(seg *diskSegment, pos diskPosition, reason error) {
+	count := seg.count - pos.Index
+	b.log.Errorf("Dropping %d unreadable metrics of buffer segment %s: %v", count, seg.path, reason)
+
+	if seg == b.active() {
+		if err := b.createSegment(seg.seq + 1); err != nil {
+			b.log.Errorf("Could not create buffer segment: %v", err)
+		}
+	}
+
+	b.rejectTracked(seg.seq, pos.Index, seg.count)
+	b.bytes -= seg.size - pos.Offset
+	seg.size = pos.Offset
+	seg.count = pos.Index
+	b.size -= count
+	b.metricsDropped(count)
+	b.BufferSize.Set(int64(b.size))
+}
+
+// rejectTracked rejects the tracking metrics of the records of the segment
+// with an index from the first up to the last, exclusive.
+func (b *DiskBuffer) rejectTracked(segment uint64, first, last int) {
+	for record, m := range b.tracked {
+		if record.segment == segment && record.index >= first && record.index < last {
+			m.Reject()
+			delete(b.tracked, record)
+		}
+	}
+}
+
+// Batch returns a slice containing up to batchSize of the oldest metrics not
+// yet dropped.  Metrics are ordered from oldest to newest in the batch.  The
+// batch must not be modified by the client.
+func (b *DiskBuffer) Batch(batchSize int) []telegraf.Metric {
+	b.Lock()
+	defer b.Unlock()
+
+	out := make([]telegraf.Metric, 0, min(b.size, batchSize))
+	if b.size == 0 || batchSize <= 0 {
+		return out
+	}
+
+	pos := b.read
+	count := 0
+	skipped := 0
+	var tracked []diskRecord
+	for i := 0; i < len(b.segments) && count < batchSize; i++ {
+		seg := b.segments[i]
+		if seg.seq != pos.Segment {
+			pos = diskPosition{Segment: seg.seq}
+		}
+		if pos.Offset >= seg.size {
+			continue
+		}
+
+		if seg == b.active() {
+			b.sync()
+		}
+
+		f, err := os.Open(seg.path)
+		if err == nil {
+			_, err = f.Seek(pos.Offset, io.SeekStart)
+			if err != nil {
+				f.Close()
+			}
+		}
+		if err != nil {
+			// The segment can not be read, continue with the next one.
+			b.dropUnreadable(seg, pos, err)
+			continue
+		}
+
+		r := bufio.NewReader(f)
+		for count < batchSize && pos.Offset < seg.size {
+			record := diskRecord{segment: seg.seq, index: pos.Index}
+			data, n, err := readRecord(r, seg.size-pos.Offset)
+			if err != nil && n == 0 {
+				// The following records can not be found anymore.
+				b.dropUnreadable(seg, pos, err)
+				break
+			}
+			pos.Offset += n
+			pos.Index++
+			count++
+
+			if m, ok := b.tracked[record]; ok {
+				tracked = append(tracked, record)
+				out = append(out, m)
+				continue
+			}
+
+			var m telegraf.Metric
+			if err == nil {
+				m, err = readMetric(data)
+			}
+			if err != nil {
+				b.log.Errorf("Could not decode buffered metric: %v", err)
+				skipped++
+				continue
+			}
+			out = append(out, m)
+		}
+		f.Close()
+	}
+
+	if count == 0 {
+		return out
+	}
+
+	b.batch = &pos
+	b.batchCount = count
+	b.batchSkipped = skipped
+	b.batchTracked = tracked
+	return out
+}
+
+// Accept marks the batch, acquired from Batch(), as successfully written.
+func (b *DiskBuffer) Accept(batch []telegraf.Metric) {
+	b.Lock()
+	defer b.Unlock()
+
+	for _, m := range batch {
+		b.metricWritten(m)
+	}
+
+	if b.batch != nil {
+		if b.batchSkipped > 0 {
+			b.metricsDropped(b.batchSkipped)
+		}
+		for _, record := range b.batchTracked {
+			delete(b.tracked, record)
+		}
+		b.size -= b.batchCount
+		b.read = *b.batch
+		b.resetBatch()
+		b.removeWritten()
+		b.writeCheckpoint()
+	}
+
+	b.enforceLimit()
+	b.BufferSize.Set(int64(b.size))
+}
+
+// Reject returns the batch, acquired from Batch(), to the buffer and marks it
+// as unsent.
+func (b *DiskBuffer) Reject(batch []telegraf.Metric) {
+	b.Lock()
+	defer b.Unlock()
+
+	// The metrics are still on disk and will be read again.
+	b.resetBatch()
+	b.enforceLimit()
+	b.BufferSize.Set(int64(b.size))
+}
+
+// removeWritten deletes the segments that have been completely written.
+func (b *DiskBuffer) removeWritten() {
+	for len(b.segments) > 0 {
+		oldest := b.segments[0]
+		done := oldest.seq < b.read.Segment ||
+			(oldest.seq == b.read.Segment && b.read.Offset >= oldest.size &&
+				(oldest.size > 0 || len(b.segments) > 1))
+		if !done {
+			return
+		}
+
+		if len(b.segments) == 1 {
+			// Start over with a fresh segment rather than keeping an
+			// already written one around.
+			if err := b.createSegment(oldest.seq + 1); err != nil {
+				b.log.Errorf("Could not create buffer segment: %v", err)
+				return
+			}
+		}
+
+		if err := os.Remove(oldest.path); err != nil && !os.IsNotExist(err) {
+			b.log.Errorf("Could not remove buffer segment: %v", err)
+			return
+		}
+		b.segments = b.segments[1:]
+		b.bytes -= oldest.size
+		if b.read.Segment <= oldest.seq {
+			b.read = diskPosition{Segment: b.segments[0].seq}
+		}
+	}
+}
+
+// writeCheckpoint records the read position so that written metrics are not
+// replayed after a restart.
+func (b *DiskBuffer) writeCheckpoint() {
+	data, err := json.Marshal(b.read)
+	if err != nil {
+		b.log.Errorf("Could not encode buffer checkpoint: %v", err)
+		return
+	}
+
+	path := filepath.Join(b.cfg.Directory, checkpointFile)
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		b.log.Errorf("Could not write buffer checkpoint: %v", err)
+		return
+	}
+	_, err = f.Write(data)
+	if err == nil && b.cfg.Fsync != FsyncNever {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		b.log.Errorf("Could not write buffer checkpoint: %v", err)
+	}
+}
+
+func (b *DiskBuffer) resetBatch() {
+	b.batch = nil
+	b.batchCount = 0
+	b.batchSkipped = 0
+	b.batchTracked = nil
+}
+
+// Close syncs and closes the active segment and writes the checkpoint.
+// Metrics remain on disk and are picked up when the buffer is opened again.
+func (b *DiskBuffer) Close() error {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.file == nil {
+		return nil
+	}
+
+	b.resetBatch()
+	err := b.closeFile()
+	b.file = nil
+	b.writeCheckpoint()
+
+	// The unwritten tracking metrics are delivered from disk, unless the
+	// segment could not be synced.
+	for record, m := range b.tracked {
+		if err == nil {
+			m.Accept()
+		} else {
+			m.Reject()
+		}
+		delete(b.tracked, record)
+	}
+	return err
+}
+
+// scanSegment returns the size and number of the valid records in a segment
+// of the given file size.  Reading stops at the first incomplete or corrupt
+// record.
+func scanSegment(path string, fileSize int64) (int64, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	var size int64
+	var count int
+	r := bufio.NewReader(f)
+	for {
+		_, n, err := readRecord(r, fileSize-size)
+		if err != nil {
+			break
+		}
+		size += n
+		count++
+	}
+	return size, count, nil
+}
+
+func writeRecord(w io.Writer, data []byte) (int64, error) {
+	record := make([]byte, recordHeader+len(data))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(data))
+	copy(record[recordHeader:], data)
+
+	n, err := w.Write(record)
+	return int64(n), err
+}
+
+// readRecord reads the next record, at most remaining bytes long.  The length
+// in the header is checked before allocating, a corrupt header must not cause
+// a huge allocation.  If only the checksum does not match, the length of the
+// record is returned along with the error so the record can be skipped.
+func readRecord(r io.Reader, remaining int64) ([]byte, int64, error) {
+	var header [recordHeader]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, 0, err
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	if int64(length) > remaining-recordHeader {
+		return nil, 0, fmt.Errorf("record length %d exceeds the %d remaining bytes of the segment", length, remaining-recordHeader)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, 0, err
+	}
+
+	n := int64(recordHeader) + int64(length)
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, n, errors.New("checksum mismatch")
+	}
+	return data, n, nil
+}
+
+// skipRecords returns the position n records after pos in the segment, or
+// the end of the segment if it holds less records.
+func skipRecords(seg *diskSegment, pos diskPosition, n int) (diskPosition, error) {
+	f, err := os.Open(seg.path)
+	if err != nil {
+		return pos, err
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(pos.Offset, io.SeekStart); err != nil {
+		return pos, err
+	}
+
+	r := bufio.NewReader(f)
+	for i := 0; i < n && pos.Offset < seg.size; i++ {
+		_, size, err := readRecord(r, seg.size-pos.Offset)
+		if size == 0 {
+			return pos, err
+		}
+		pos.Offset += size
+		pos.Index++
+	}
+	return pos, nil
+}
+
+// appendMetric appends the metric as a MessagePack array of its name, its
+// time in nanoseconds, its value type, its tags and its fields.  The time is
+// always encoded as int64, so the size of a record does not depend on it.
+func appendMetric(b []byte, m telegraf.Metric) ([]byte, error) {
+	b = msgp.AppendArrayHeader(b, 5)
+	b = msgp.AppendString(b, m.Name())
+	b = append(b, 0xd3, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint64(b[len(b)-8:], uint64(m.Time().UnixNano()))
+	b = msgp.AppendInt(b, int(m.Type()))
+
+	b = msgp.AppendMapHeader(b, uint32(len(m.TagList())))
+	for _, tag := range m.TagList() {
+		b = msgp.AppendString(b, tag.Key)
+		b = msgp.AppendString(b, tag.Value)
+	}
+
+	b = msgp.AppendMapHeader(b, uint32(len(m.FieldList())))
+	for _, field := range m.FieldList() {
+		b = msgp.AppendString(b, field.Key)
+		switch v := field.Value.(type) {
+		case int64:
+			b = msgp.AppendInt64(b, v)
+		case uint64:
+			b = appendUint64(b, v)
+		case float64:
+			b = msgp.AppendFloat64(b, v)
+		case bool:
+			b = msgp.AppendBool(b, v)
+		case string:
+			b = msgp.AppendString(b, v)
+		case *telegraf.Distribution:
+			b = appendDistribution(b, v)
+		default:
+			return b, fmt.Errorf("field %q has unsupported type %T", field.Key, field.Value)
+		}
+	}
+	return b, nil
+}
+
+// appendUint64 appends the value as a uint type, msgp.AppendUint64 uses a
+// positive fixint for small values which is read back as an integer.
+func appendUint64(b []byte, v uint64) []byte {
+	b = append(b, 0xcf, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint64(b[len(b)-8:], v)
+	return b
+}
+
+// appendDistribution appends the distribution as an array of its count, its
+// sum, and the bounds and counts of its buckets and the quantiles and values
+// of its quantiles each as a flat array.
+func appendDistribution(b []byte, d *telegraf.Distribution) []byte {
+	b = msgp.AppendArrayHeader(b, 4)
+	b = msgp.AppendUint64(b, d.Count)
+	b = msgp.AppendFloat64(b, d.Sum)
+
+	b = msgp.AppendArrayHeader(b, uint32(2*len(d.Buckets)))
+	for _, bucket := range d.Buckets {
+		b = msgp.AppendFloat64(b, bucket.UpperBound)
+		b = msgp.AppendUint64(b, bucket.Count)
+	}
+
+	b = msgp.AppendArrayHeader(b, uint32(2*len(d.Quantiles)))
+	for _, quantile := range d.Quantiles {
+		b = msgp.AppendFloat64(b, quantile.Quantile)
+		b = msgp.AppendFloat64(b, quantile.Value)
+	}
+	return b
+}
+
+// readMetric reads a metric appended by appendMetric.
+func readMetric(b []byte) (telegraf.Metric, error) {
+	size, b, err := msgp.ReadArrayHeaderBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if size != 5 {
+		return nil, fmt.Errorf("metric has %d instead of 5 elements", size)
+	}
+
+	var name string
+	if name, b, err = msgp.ReadStringBytes(b); err != nil {
+		return nil, err
+	}
+	var nsec int64
+	if nsec, b, err = msgp.ReadInt64Bytes(b); err != nil {
+		return nil, err
+	}
+	var valueType int
+	if valueType, b, err = msgp.ReadIntBytes(b); err != nil {
+		return nil, err
+	}
+
+	if size, b, err = msgp.ReadMapHeaderBytes(b); err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string, size)
+	for i := uint32(0); i < size; i++ {
+		var key, value string
+		if key, b, err = msgp.ReadStringBytes(b); err != nil {
+			return nil, err
+		}
+		if value, b, err = msgp.ReadStringBytes(b); err != nil {
+			return nil, err
+		}
+		tags[key] = value
+	}
+
+	if size, b, err = msgp.ReadMapHeaderBytes(b); err != nil {
+		return nil, err
+	}
+	fields := make(map[string]interface{}, size)
+	for i := uint32(0); i < size; i++ {
+		var key string
+		if key, b, err = msgp.ReadStringBytes(b); err != nil {
+			return nil, err
+		}
+
+		var value interface{}
+		switch t := msgp.NextType(b); t {
+		case msgp.IntType:
+			value, b, err = msgp.ReadInt64Bytes(b)
+		case msgp.UintType:
+			value, b, err = msgp.ReadUint64Bytes(b)
+		case msgp.Float64Type:
+			value, b, err = msgp.ReadFloat64Bytes(b)
+		case msgp.BoolType:
+			value, b, err = msgp.ReadBoolBytes(b)
+		case msgp.StrType:
+			value, b, err = msgp.ReadStringBytes(b)
+		case msgp.ArrayType:
+			value, b, err = readDistribution(b)
+		default:
+			return nil, fmt.Errorf("field %q has unsupported type %s", key, t)
+		}
+		if err != nil {
+			return nil, err
+		}
+		fields[key] = value
+	}
+
+	return metric.New(name, tags, fields, time.Unix(0, nsec), telegraf.ValueType(valueType))
+}
+
+// readDistribution reads a distribution appended by appendDistribution.
+func readDistribution(b []byte) (*telegraf.Distribution, []byte, error) {
+	size, b, err := msgp.ReadArrayHeaderBytes(b)
+	if err != nil {
+		return nil, b, err
+	}
+	if size != 4 {
+		return nil, b, fmt.Errorf("distribution has %d instead of 4 elements", size)
+	}
+
+	d := &telegraf.Distribution{}
+	if d.Count, b, err = msgp.ReadUint64Bytes(b); err != nil {
+		return nil, b, err
+	}
+	if d.Sum, b, err = msgp.ReadFloat64Bytes(b); err != nil {
+		return nil, b, err
+	}
+
+	if size, b, err = msgp.ReadArrayHeaderBytes(b); err != nil {
+		return nil, b, err
+	}
+	for i := uint32(0); i < size/2; i++ {
+		var bucket telegraf.Bucket
+		if bucket.UpperBound, b, err = msgp.ReadFloat64Bytes(b); err != nil {
+			return nil, b, err
+		}
+		if bucket.Count, b, err = msgp.ReadUint64Bytes(b); err != nil {
+			return nil, b, err
+		}
+		d.Buckets = append(d.Buckets, bucket)
+	}
+
+	if size, b, err = msgp.ReadArrayHeaderBytes(b); err != nil {
+		return nil, b, err
+	}
+	for i := uint32(0); i < size/2; i++ {
+		var quantile telegraf.Quantile
+		if quantile.Quantile, b, err = msgp.ReadFloat64Bytes(b); err != nil {
+			return nil, b, err
+		}
+		if quantile.Value, b, err = msgp.ReadFloat64Bytes(b); err != nil {
+			return nil, b, err
+		}
+		d.Quantiles = append(d.Quantiles, quantile)
+	}
+	return d, b, nil
+}
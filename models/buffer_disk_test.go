@@ -0,0 +1,414 @@
+package models
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func newDiskBuffer(t *testing.T, cfg DiskBufferConfig) *DiskBuffer {
+	if cfg.Directory == "" {
+		cfg.Directory = t.TempDir()
+	}
+	b, err := NewDiskBuffer("test", "", cfg)
+	require.NoError(t, err)
+	b.MetricsAdded.Set(0)
+	b.MetricsWritten.Set(0)
+	b.MetricsDropped.Set(0)
+	return b
+}
+
+// recordSize returns the size of the record of the metric on disk.
+func recordSize(t *testing.T, m telegraf.Metric) int64 {
+	data, err := appendMetric(nil, m)
+	require.NoError(t, err)
+	return int64(recordHeader + len(data))
+}
+
+func TestDiskBuffer_LenEmpty(t *testing.T) {
+	b := newDiskBuffer(t, DiskBufferConfig{})
+
+	require.Equal(t, 0, b.Len())
+	require.Len(t, b.Batch(5), 0)
+}
+
+func TestDiskBuffer_BatchAccept(t *testing.T) {
+	b := newDiskBuffer(t, DiskBufferConfig{})
+	b.Add(MetricTime(1), MetricTime(2), MetricTime(3))
+	require.Equal(t, 3, b.Len())
+
+	batch := b.Batch(2)
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(1),
+			MetricTime(2),
+		}, batch)
+	require.Equal(t, 3, b.Len())
+
+	b.Accept(batch)
+	require.Equal(t, 1, b.Len())
+	require.Equal(t, int64(2), b.MetricsWritten.Get())
+
+	batch = b.Batch(2)
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(3),
+		}, batch)
+	b.Accept(batch)
+	require.Equal(t, 0, b.Len())
+}
+
+func TestDiskBuffer_RejectReturnsBatch(t *testing.T) {
+	b := newDiskBuffer(t, DiskBufferConfig{})
+	b.Add(MetricTime(1), MetricTime(2), MetricTime(3))
+
+	batch := b.Batch(2)
+	b.Add(MetricTime(4))
+	b.Reject(batch)
+	require.Equal(t, int64(0), b.MetricsDropped.Get())
+
+	batch = b.Batch(5)
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(1),
+			MetricTime(2),
+			MetricTime(3),
+			MetricTime(4),
+		}, batch)
+}
+
+func TestDiskBuffer_PreservesFieldTypes(t *testing.T) {
+	m := testutil.MustMetric("cpu",
+		map[string]string{"host": "localhost"},
+		map[string]interface{}{
+			"int":    int64(-42),
+			"uint":   uint64(42),
+			"float":  42.5,
+			"bool":   true,
+			"string": "value",
+		},
+		time.Unix(0, 1234567890),
+		telegraf.Counter,
+	)
+
+	b := newDiskBuffer(t, DiskBufferConfig{})
+	b.Add(m)
+
+	batch := b.Batch(1)
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{m}, batch)
+	require.Equal(t, telegraf.Counter, batch[0].Type())
+}
+
+func TestDiskBuffer_PreservesDistributions(t *testing.T) {
+	d := &telegraf.Distribution{
+		Count:   5,
+		Sum:     12.5,
+		Buckets: []telegraf.Bucket{{UpperBound: 1, Count: 2}, {UpperBound: 5, Count: 4}},
+	}
+	m := testutil.MustMetric("http",
+		map[string]string{"host": "localhost"},
+		map[string]interface{}{"latency": d, "requests": int64(5)},
+		time.Unix(0, 1234567890),
+		telegraf.Histogram,
+	)
+
+	b := newDiskBuffer(t, DiskBufferConfig{})
+	b.Add(m)
+	require.Equal(t, 1, b.Len())
+	require.Equal(t, int64(0), b.MetricsDropped.Get())
+
+	batch := b.Batch(1)
+	require.Len(t, batch, 1)
+	v, ok := batch[0].GetField("latency")
+	require.True(t, ok)
+	require.Equal(t, d, v)
+	v, ok = batch[0].GetField("requests")
+	require.True(t, ok)
+	require.Equal(t, int64(5), v)
+	require.Equal(t, telegraf.Histogram, batch[0].Type())
+}
+
+func TestDiskBuffer_ReplayAfterReopen(t *testing.T) {
+	dir := t.TempDir()
+	b := newDiskBuffer(t, DiskBufferConfig{Directory: dir})
+	b.Add(MetricTime(1), MetricTime(2), MetricTime(3), MetricTime(4))
+
+	batch := b.Batch(2)
+	b.Accept(batch)
+
+	// An outstanding batch is not lost when closing.
+	b.Batch(1)
+	require.NoError(t, b.Close())
+
+	b = newDiskBuffer(t, DiskBufferConfig{Directory: dir})
+	require.Equal(t, 2, b.Len())
+
+	batch = b.Batch(5)
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(3),
+			MetricTime(4),
+		}, batch)
+	b.Accept(batch)
+	require.NoError(t, b.Close())
+
+	b = newDiskBuffer(t, DiskBufferConfig{Directory: dir})
+	require.Equal(t, 0, b.Len())
+}
+
+func TestDiskBuffer_BatchAcrossSegments(t *testing.T) {
+	dir := t.TempDir()
+	size := recordSize(t, MetricTime(1))
+	b := newDiskBuffer(t, DiskBufferConfig{Directory: dir, SegmentSize: 2 * size})
+	b.Add(MetricTime(1), MetricTime(2), MetricTime(3), MetricTime(4), MetricTime(5))
+	require.Len(t, b.segments, 3)
+
+	batch := b.Batch(3)
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(1),
+			MetricTime(2),
+			MetricTime(3),
+		}, batch)
+	b.Accept(batch)
+	require.Len(t, b.segments, 2)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	batch = b.Batch(3)
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(4),
+			MetricTime(5),
+		}, batch)
+	b.Accept(batch)
+	require.Equal(t, 0, b.Len())
+}
+
+func TestDiskBuffer_MaxSizeDropsOldestSegment(t *testing.T) {
+	size := recordSize(t, MetricTime(1))
+	b := newDiskBuffer(t, DiskBufferConfig{SegmentSize: 2 * size, MaxSize: 4 * size})
+
+	dropped := b.Add(MetricTime(1), MetricTime(2), MetricTime(3), MetricTime(4), MetricTime(5))
+	require.Equal(t, 2, dropped)
+	require.Equal(t, int64(2), b.MetricsDropped.Get())
+	require.Equal(t, 3, b.Len())
+
+	batch := b.Batch(5)
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(3),
+			MetricTime(4),
+			MetricTime(5),
+		}, batch)
+}
+
+func TestDiskBuffer_NoDropWhileBatchOutstanding(t *testing.T) {
+	size := recordSize(t, MetricTime(1))
+	b := newDiskBuffer(t, DiskBufferConfig{SegmentSize: 2 * size, MaxSize: 4 * size})
+	b.Add(MetricTime(1), MetricTime(2), MetricTime(3))
+
+	batch := b.Batch(2)
+	require.Equal(t, 0, b.Add(MetricTime(4), MetricTime(5)))
+
+	b.Reject(batch)
+	require.Equal(t, int64(2), b.MetricsDropped.Get())
+
+	batch = b.Batch(5)
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(3),
+			MetricTime(4),
+			MetricTime(5),
+		}, batch)
+}
+
+func TestDiskBuffer_TruncatedSegment(t *testing.T) {
+	dir := t.TempDir()
+	b := newDiskBuffer(t, DiskBufferConfig{Directory: dir})
+	b.Add(MetricTime(1), MetricTime(2))
+	path := b.active().path
+	require.NoError(t, b.Close())
+
+	// Simulate a crash in the middle of writing a record.
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(path, data[:len(data)-3], 0600))
+
+	b = newDiskBuffer(t, DiskBufferConfig{Directory: dir})
+	require.Equal(t, 1, b.Len())
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(1),
+		}, b.Batch(5))
+}
+
+func TestDiskBuffer_CorruptRecordLength(t *testing.T) {
+	dir := t.TempDir()
+	b := newDiskBuffer(t, DiskBufferConfig{Directory: dir})
+	b.Add(MetricTime(1))
+	path := b.active().path
+	require.NoError(t, b.Close())
+
+	// A garbage header claiming a 4 GiB record is rejected before the
+	// record is allocated.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0, 1, 2, 3})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	b = newDiskBuffer(t, DiskBufferConfig{Directory: dir})
+	require.Equal(t, 1, b.Len())
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(1),
+		}, b.Batch(5))
+}
+
+func TestReadRecordLengthLimit(t *testing.T) {
+	var buf bytes.Buffer
+	_, err := writeRecord(&buf, []byte("metric"))
+	require.NoError(t, err)
+	size := int64(buf.Len())
+
+	_, _, err = readRecord(bytes.NewReader(buf.Bytes()), size-1)
+	require.Error(t, err)
+
+	data, n, err := readRecord(bytes.NewReader(buf.Bytes()), size)
+	require.NoError(t, err)
+	require.Equal(t, "metric", string(data))
+	require.Equal(t, size, n)
+}
+
+func newTrackingMetric(delivered *[]bool) telegraf.Metric {
+	m, _ := metric.WithTracking(Metric(), func(info telegraf.DeliveryInfo) {
+		*delivered = append(*delivered, info.Delivered())
+	})
+	return m
+}
+
+func TestDiskBuffer_AcceptsTrackingMetricOnWrite(t *testing.T) {
+	var delivered []bool
+	b := newDiskBuffer(t, DiskBufferConfig{})
+	b.Add(newTrackingMetric(&delivered))
+	require.Empty(t, delivered)
+
+	b.Reject(b.Batch(1))
+	require.Empty(t, delivered)
+
+	b.Accept(b.Batch(1))
+	require.Equal(t, []bool{true}, delivered)
+}
+
+func TestDiskBuffer_RejectsDroppedTrackingMetric(t *testing.T) {
+	var delivered []bool
+	b := newDiskBuffer(t, DiskBufferConfig{MaxMetrics: 1})
+	b.Add(newTrackingMetric(&delivered))
+	require.Equal(t, 1, b.Add(MetricTime(2)))
+	require.Equal(t, []bool{false}, delivered)
+}
+
+func TestDiskBuffer_CloseAcceptsTrackingMetric(t *testing.T) {
+	var delivered []bool
+	dir := t.TempDir()
+	b := newDiskBuffer(t, DiskBufferConfig{Directory: dir})
+	b.Add(newTrackingMetric(&delivered))
+	require.NoError(t, b.Close())
+	require.Equal(t, []bool{true}, delivered)
+
+	// The metric is written by the next buffer opened in the directory.
+	b = newDiskBuffer(t, DiskBufferConfig{Directory: dir})
+	require.Equal(t, 1, b.Len())
+}
+
+func TestDiskBuffer_MaxMetricsDropsOldest(t *testing.T) {
+	size := recordSize(t, MetricTime(1))
+	b := newDiskBuffer(t, DiskBufferConfig{SegmentSize: 2 * size, MaxMetrics: 3})
+
+	dropped := b.Add(MetricTime(1), MetricTime(2), MetricTime(3), MetricTime(4), MetricTime(5))
+	require.Equal(t, 2, dropped)
+	require.Equal(t, int64(2), b.MetricsDropped.Get())
+	require.Equal(t, 3, b.Len())
+
+	batch := b.Batch(5)
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(3),
+			MetricTime(4),
+			MetricTime(5),
+		}, batch)
+	b.Accept(batch)
+	require.Equal(t, 0, b.Len())
+}
+
+func TestDiskBuffer_SkipsCorruptRecord(t *testing.T) {
+	b := newDiskBuffer(t, DiskBufferConfig{})
+	b.Add(MetricTime(1), MetricTime(2))
+
+	// Damage the payload of the first record.
+	f, err := os.OpenFile(b.active().path, os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte{0xff}, recordHeader+1)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	batch := b.Batch(5)
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(2),
+		}, batch)
+	b.Accept(batch)
+	require.Equal(t, 0, b.Len())
+	require.Equal(t, int64(1), b.MetricsDropped.Get())
+}
+
+func TestDiskBuffer_DropsUnreadableSegment(t *testing.T) {
+	size := recordSize(t, MetricTime(1))
+	b := newDiskBuffer(t, DiskBufferConfig{SegmentSize: 2 * size})
+	b.Add(MetricTime(1), MetricTime(2), MetricTime(3))
+	require.NoError(t, os.Remove(b.segments[0].path))
+
+	batch := b.Batch(5)
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(3),
+		}, batch)
+	require.Equal(t, int64(2), b.MetricsDropped.Get())
+	b.Accept(batch)
+	require.Equal(t, 0, b.Len())
+
+	b.Add(MetricTime(4))
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			MetricTime(4),
+		}, b.Batch(5))
+}
+
+func TestDiskBuffer_CloseWritesCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	b := newDiskBuffer(t, DiskBufferConfig{Directory: dir})
+	b.Add(MetricTime(1))
+	require.NoError(t, b.Close())
+
+	_, err := os.Stat(filepath.Join(dir, checkpointFile))
+	require.NoError(t, err)
+}
+
+func TestDiskBuffer_InvalidFsync(t *testing.T) {
+	_, err := NewDiskBuffer("test", "", DiskBufferConfig{
+		Directory: filepath.Join(os.TempDir(), "unused"),
+		Fsync:     "sometimes",
+	})
+	require.Error(t, err)
+}
@@ -34,6 +34,9 @@ type Filter struct {
 	TagInclude []string
 	tagInclude filter.Filter
 
+	MetricPass string
+	metricPass *filter.Expression
+
 	isActive bool
 }
 
@@ -46,7 +49,8 @@ func (f *Filter) Compile() error {
 		len(f.TagInclude) == 0 &&
 		len(f.TagExclude) == 0 &&
 		len(f.TagPass) == 0 &&
-		len(f.TagDrop) == 0 {
+		len(f.TagDrop) == 0 &&
+		f.MetricPass == "" {
 		return nil
 	}
 
@@ -91,11 +95,19 @@ func (f *Filter) Compile() error {
 			return fmt.Errorf("Error compiling 'tagpass', %s", err)
 		}
 	}
+
+	if f.MetricPass != "" {
+		f.metricPass, err = filter.CompileExpression(f.MetricPass)
+		if err != nil {
+			return fmt.Errorf("Error compiling 'metricpass', %s", err)
+		}
+	}
 	return nil
 }
 
 // Select returns true if the metric matches according to the
-// namepass/namedrop and tagpass/tagdrop filters.  The metric is not modified.
+// namepass/namedrop and tagpass/tagdrop filters and the metricpass
+// expression.  The metric is not modified.
 func (f *Filter) Select(metric telegraf.Metric) bool {
 	if !f.isActive {
 		return true
@@ -109,9 +121,50 @@ func (f *Filter) Select(metric telegraf.Metric) bool {
 		return false
 	}
 
+	if f.metricPass != nil && !f.metricPass.Select(metric) {
+		return false
+	}
+
 	return true
 }
 
+// Explain returns the part of the filter that rejects the metric in Select,
+// or an empty string if the metric is selected.
+func (f *Filter) Explain(metric telegraf.Metric) string {
+	if !f.isActive {
+		return ""
+	}
+
+	if !f.shouldNamePass(metric.Name()) {
+		return "namepass/namedrop"
+	}
+
+	if !f.shouldTagsPass(metric.TagList()) {
+		return "tagpass/tagdrop"
+	}
+
+	if f.metricPass != nil && !f.metricPass.Select(metric) {
+		return "metricpass"
+	}
+
+	return ""
+}
+
+// SelectFields returns true if any field of the metric passes the
+// fieldpass/fielddrop filters.  The metric is not modified.
+func (f *Filter) SelectFields(metric telegraf.Metric) bool {
+	if !f.isActive {
+		return true
+	}
+
+	for _, field := range metric.FieldList() {
+		if f.shouldFieldPass(field.Key) {
+			return true
+		}
+	}
+	return false
+}
+
 // Modify removes any tags and fields from the metric according to the
 // fieldpass/fielddrop and taginclude/tagexclude filters.
 func (f *Filter) Modify(metric telegraf.Metric) {
@@ -482,6 +482,35 @@ func TestFilter_FilterTagsPassAndDrop(t *testing.T) {
 
 }
 
+func TestFilter_MetricPass(t *testing.T) {
+	f := Filter{
+		NamePass:   []string{"cpu"},
+		MetricPass: `tags.cpu != "cpu-total" && fields.usage_idle < 90`,
+	}
+	require.NoError(t, f.Compile())
+	require.True(t, f.IsActive())
+
+	require.True(t, f.Select(testutil.MustMetric("cpu",
+		map[string]string{"cpu": "cpu0"},
+		map[string]interface{}{"usage_idle": 42.0},
+		time.Unix(0, 0))))
+	require.False(t, f.Select(testutil.MustMetric("cpu",
+		map[string]string{"cpu": "cpu-total"},
+		map[string]interface{}{"usage_idle": 42.0},
+		time.Unix(0, 0))))
+	require.False(t, f.Select(testutil.MustMetric("cpu",
+		map[string]string{"cpu": "cpu0"},
+		map[string]interface{}{"usage_idle": 99.0},
+		time.Unix(0, 0))))
+	require.False(t, f.Select(testutil.MustMetric("mem",
+		map[string]string{"cpu": "cpu0"},
+		map[string]interface{}{"usage_idle": 42.0},
+		time.Unix(0, 0))))
+
+	f = Filter{MetricPass: `fields.usage_idle <`}
+	require.EqualError(t, f.Compile(), "Error compiling 'metricpass', unexpected end of expression at position 20")
+}
+
 func BenchmarkFilter(b *testing.B) {
 	tests := []struct {
 		name   string
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+// UnroutedRoute is the name of the route receiving the metrics that match no
+// other route.
+const UnroutedRoute = "unrouted"
+
+// RouteConfig containing the name, outputs and filter of a route
+type RouteConfig struct {
+	Name string
+	// Outputs are referenced by alias, unique id or plugin name.
+	Outputs []string
+	Filter  Filter
+}
+
+// Route sends the metrics selected by its filter to a set of outputs.
+type Route struct {
+	Config *RouteConfig
+
+	MetricsRouted  selfstat.Stat
+	MetricsDropped selfstat.Stat
+}
+
+func NewRoute(config *RouteConfig) *Route {
+	tags := map[string]string{"route": config.Name}
+	return &Route{
+		Config: config,
+		MetricsRouted: selfstat.Register(
+			"routes",
+			"metrics_routed",
+			tags,
+		),
+		MetricsDropped: selfstat.Register(
+			"routes",
+			"metrics_dropped",
+			tags,
+		),
+	}
+}
+
+// IsUnrouted returns true if the route is the fallback for the metrics that
+// match no other route.
+func (r *Route) IsUnrouted() bool {
+	return r.Config.Name == UnroutedRoute
+}
+
+// Match returns true if the route selects the metric by its name and tags, a
+// route with fieldpass/fielddrop only matches metrics with a passing field.
+// The metric is not modified.
+func (r *Route) Match(metric telegraf.Metric) bool {
+	return r.Config.Filter.Select(metric) && r.Config.Filter.SelectFields(metric)
+}
+
+// HasOutput returns true if the route sends metrics to the output.
+func (r *Route) HasOutput(output *RunningOutput) bool {
+	for _, ref := range r.Config.Outputs {
+		if IsOutputRef(output, ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOutputRef returns true if ref references the output by its alias, unique
+// id or plugin name.
+func IsOutputRef(output *RunningOutput, ref string) bool {
+	return ref == output.UniqueId || ref == output.Config.Name ||
+		(output.Config.Alias != "" && ref == output.Config.Alias)
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoute_Match(t *testing.T) {
+	cpu := testutil.MustMetric("cpu",
+		map[string]string{"region": "eu-west"},
+		map[string]interface{}{"usage_idle": 42.0},
+		time.Unix(0, 0))
+	mem := testutil.MustMetric("mem",
+		map[string]string{"region": "us-east"},
+		map[string]interface{}{"used": 42},
+		time.Unix(0, 0))
+
+	tests := []struct {
+		name   string
+		filter Filter
+		cpu    bool
+		mem    bool
+	}{
+		{
+			name:   "no filter",
+			filter: Filter{},
+			cpu:    true,
+			mem:    true,
+		},
+		{
+			name:   "name",
+			filter: Filter{NamePass: []string{"cpu"}},
+			cpu:    true,
+		},
+		{
+			name:   "tags",
+			filter: Filter{TagPass: []TagFilter{{Name: "region", Filter: []string{"us-*"}}}},
+			mem:    true,
+		},
+		{
+			name:   "fields",
+			filter: Filter{FieldPass: []string{"usage_*"}},
+			cpu:    true,
+		},
+		{
+			name:   "dropped fields",
+			filter: Filter{FieldDrop: []string{"usage_*"}},
+			mem:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, tt.filter.Compile())
+			route := NewRoute(&RouteConfig{Name: tt.name, Filter: tt.filter})
+			require.Equal(t, tt.cpu, route.Match(cpu))
+			require.Equal(t, tt.mem, route.Match(mem))
+		})
+	}
+
+	// The fields of the metric are not removed.
+	require.Len(t, cpu.FieldList(), 1)
+}
+
+func TestRoute_HasOutput(t *testing.T) {
+	influx := &RunningOutput{Config: &OutputConfig{Name: "influxdb", Alias: "eu"}, UniqueId: "influx-1"}
+	file := &RunningOutput{Config: &OutputConfig{Name: "file"}, UniqueId: "file-1"}
+
+	route := NewRoute(&RouteConfig{Name: "eu", Outputs: []string{"eu"}})
+	require.True(t, route.HasOutput(influx))
+	require.False(t, route.HasOutput(file))
+
+	route = NewRoute(&RouteConfig{Name: "ids", Outputs: []string{"file-1"}})
+	require.False(t, route.HasOutput(influx))
+	require.True(t, route.HasOutput(file))
+
+	route = NewRoute(&RouteConfig{Name: "names", Outputs: []string{"influxdb", "file"}})
+	require.True(t, route.HasOutput(influx))
+	require.True(t, route.HasOutput(file))
+
+	require.True(t, NewRoute(&RouteConfig{Name: UnroutedRoute}).IsUnrouted())
+	require.False(t, route.IsUnrouted())
+}
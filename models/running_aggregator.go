@@ -2,6 +2,7 @@ package models
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/influxdata/telegraf"
@@ -10,6 +11,9 @@ import (
 )
 
 type RunningAggregator struct {
+	// Must be 64-bit aligned
+	errors int64
+
 	sync.Mutex
 	Aggregator  telegraf.Aggregator
 	Config      *AggregatorConfig
@@ -21,9 +25,11 @@ type RunningAggregator struct {
 	MetricsFiltered selfstat.Stat
 	MetricsDropped  selfstat.Stat
 	PushTime        selfstat.Stat
+
+	UniqueId string
 }
 
-func NewRunningAggregator(aggregator telegraf.Aggregator, config *AggregatorConfig) *RunningAggregator {
+func NewRunningAggregator(aggregator telegraf.Aggregator, config *AggregatorConfig, uniqueId string) *RunningAggregator {
 	tags := map[string]string{"aggregator": config.Name}
 	if config.Alias != "" {
 		tags["alias"] = config.Alias
@@ -31,13 +37,9 @@ func NewRunningAggregator(aggregator telegraf.Aggregator, config *AggregatorConf
 
 	aggErrorsRegister := selfstat.Register("aggregate", "errors", tags)
 	logger := NewLogger("aggregators", config.Name, config.Alias)
-	logger.OnErr(func() {
-		aggErrorsRegister.Incr(1)
-	})
-
 	SetLoggerOnPlugin(aggregator, logger)
 
-	return &RunningAggregator{
+	ra := &RunningAggregator{
 		Aggregator: aggregator,
 		Config:     config,
 		MetricsPushed: selfstat.Register(
@@ -60,7 +62,31 @@ func NewRunningAggregator(aggregator telegraf.Aggregator, config *AggregatorConf
 			"push_time_ns",
 			tags,
 		),
-		log: logger,
+		UniqueId: uniqueId,
+		log:      logger,
+	}
+
+	logger.OnErr(func() {
+		aggErrorsRegister.Incr(1)
+		atomic.AddInt64(&ra.errors, 1)
+	})
+	return ra
+}
+
+// AggregatorStatus is a snapshot of the health of a running aggregator.  The
+// metric counts are shared by all aggregators with the same name and alias.
+type AggregatorStatus struct {
+	Errors         int64 `json:"errors"`
+	MetricsPushed  int64 `json:"metrics_pushed"`
+	MetricsDropped int64 `json:"metrics_dropped"`
+}
+
+// Status returns a snapshot of the health of the aggregator.
+func (r *RunningAggregator) Status() AggregatorStatus {
+	return AggregatorStatus{
+		Errors:         atomic.LoadInt64(&r.errors),
+		MetricsPushed:  r.MetricsPushed.Get(),
+		MetricsDropped: r.MetricsDropped.Get(),
 	}
 }
 
@@ -68,6 +94,7 @@ func NewRunningAggregator(aggregator telegraf.Aggregator, config *AggregatorConf
 type AggregatorConfig struct {
 	Name         string
 	Alias        string
+	Pipeline     string
 	DropOriginal bool
 	Period       time.Duration
 	Delay        time.Duration
@@ -129,7 +156,11 @@ func (r *RunningAggregator) MakeMetric(metric telegraf.Metric) telegraf.Metric {
 // Add a metric to the aggregator and return true if the original metric
 // should be dropped.
 func (r *RunningAggregator) Add(m telegraf.Metric) bool {
+	trace := traceMetric(m, r, r.UniqueId)
 	if ok := r.Config.Filter.Select(m); !ok {
+		if trace != nil {
+			trace.Done(TraceSkipped, r.Config.Filter.Explain(m), nil)
+		}
 		return false
 	}
 
@@ -141,6 +172,7 @@ func (r *RunningAggregator) Add(m telegraf.Metric) bool {
 
 	r.Config.Filter.Modify(m)
 	if len(m.FieldList()) == 0 {
+		trace.Done(TraceFiltered, r.dropReason(noFieldsLeft), nil)
 		r.MetricsFiltered.Incr(1)
 		return r.Config.DropOriginal
 	}
@@ -151,14 +183,28 @@ func (r *RunningAggregator) Add(m telegraf.Metric) bool {
 	if m.Time().Before(r.periodStart.Add(-r.Config.Grace)) || m.Time().After(r.periodEnd.Add(r.Config.Delay)) {
 		r.log.Debugf("Metric is outside aggregation window; discarding. %s: m: %s e: %s g: %s",
 			m.Time(), r.periodStart, r.periodEnd, r.Config.Grace)
+		trace.Done(TraceDropped, r.dropReason("outside of the aggregation window"), nil)
 		r.MetricsDropped.Incr(1)
 		return r.Config.DropOriginal
 	}
 
+	trace.Done(TraceAggregated, r.dropReason(""), m)
 	r.Aggregator.Add(m)
 	return r.Config.DropOriginal
 }
 
+// dropReason adds to the reason of a trace hop that the original metric is
+// dropped.
+func (r *RunningAggregator) dropReason(reason string) string {
+	if !r.Config.DropOriginal {
+		return reason
+	}
+	if reason == "" {
+		return "original dropped by drop_original"
+	}
+	return reason + ", original dropped by drop_original"
+}
+
 func (r *RunningAggregator) Push(acc telegraf.Accumulator) {
 	r.Lock()
 	defer r.Unlock()
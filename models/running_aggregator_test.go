@@ -17,7 +17,7 @@ func TestAdd(t *testing.T) {
 			NamePass: []string{"*"},
 		},
 		Period: time.Millisecond * 500,
-	})
+	}, "")
 	require.NoError(t, ra.Config.Filter.Compile())
 	acc := testutil.Accumulator{}
 
@@ -46,7 +46,7 @@ func TestAddMetricsOutsideCurrentPeriod(t *testing.T) {
 			NamePass: []string{"*"},
 		},
 		Period: time.Millisecond * 500,
-	})
+	}, "")
 	require.NoError(t, ra.Config.Filter.Compile())
 	acc := testutil.Accumulator{}
 	now := time.Now()
@@ -97,7 +97,7 @@ func TestAddMetricsOutsideCurrentPeriodWithGrace(t *testing.T) {
 		},
 		Period: time.Millisecond * 1500,
 		Grace:  time.Millisecond * 500,
-	})
+	}, "")
 	require.NoError(t, ra.Config.Filter.Compile())
 	acc := testutil.Accumulator{}
 	now := time.Now()
@@ -158,7 +158,7 @@ func TestAddAndPushOnePeriod(t *testing.T) {
 			NamePass: []string{"*"},
 		},
 		Period: time.Millisecond * 500,
-	})
+	}, "")
 	require.NoError(t, ra.Config.Filter.Compile())
 	acc := testutil.Accumulator{}
 
@@ -186,7 +186,7 @@ func TestAddDropOriginal(t *testing.T) {
 			NamePass: []string{"RI*"},
 		},
 		DropOriginal: true,
-	})
+	}, "")
 	require.NoError(t, ra.Config.Filter.Compile())
 
 	now := time.Now()
@@ -219,7 +219,7 @@ func TestAddDoesNotModifyMetric(t *testing.T) {
 			FieldPass: []string{"a"},
 		},
 		DropOriginal: true,
-	})
+	}, "")
 	require.NoError(t, ra.Config.Filter.Compile())
 
 	now := time.Now()
@@ -22,10 +22,43 @@ type RunningInput struct {
 
 	MetricsGathered selfstat.Stat
 	GatherTime      selfstat.Stat
+	GathersSkipped  selfstat.Stat
+	GatherBackoff   selfstat.Stat
 
 	UniqueId     string
 	ShutdownChan chan struct{}
 	Wg           *sync.WaitGroup
+
+	// Prepare applies the settings that are not stored in the fields of the
+	// plugin, such as the parser, to a new instance of the input.
+	Prepare func(telegraf.Input) error
+
+	statusLock sync.Mutex
+	status     InputStatus
+
+	// gatherFailed is set if the last gather returned or added an error.
+	gatherFailed bool
+	// backoffTolerance allows ticks that are a little early to gather once
+	// the backoff ends.
+	backoffTolerance time.Duration
+}
+
+// InputStatus is a snapshot of the health of a running input.
+type InputStatus struct {
+	GatherErrors       int64         `json:"gather_errors"`
+	LastError          string        `json:"last_error,omitempty"`
+	LastErrorTime      time.Time     `json:"last_error_time"`
+	LastGather         time.Time     `json:"last_gather"`
+	LastGatherDuration time.Duration `json:"last_gather_duration_ns"`
+
+	// ConsecutiveFailures is the number of gathers in a row that failed or
+	// did not complete within their interval.
+	ConsecutiveFailures int64 `json:"consecutive_failures"`
+	// GathersSkipped is the number of ticks skipped because the previous
+	// gather was still running or the input was backing off.
+	GathersSkipped int64         `json:"gathers_skipped"`
+	Backoff        time.Duration `json:"backoff_ns"`
+	BackoffUntil   time.Time     `json:"backoff_until"`
 }
 
 func (ri *RunningInput) Stop() {
@@ -41,15 +74,11 @@ func NewRunningInput(input telegraf.Input, config *InputConfig, uniqueId string)
 
 	inputErrorsRegister := selfstat.Register("gather", "errors", tags)
 	logger := NewLogger("inputs", config.Name, config.Alias)
-	logger.OnErr(func() {
-		inputErrorsRegister.Incr(1)
-		GlobalGatherErrors.Incr(1)
-	})
 	SetLoggerOnPlugin(input, logger)
 
 	runningWg := &sync.WaitGroup{}
 	runningWg.Add(1)
-	return &RunningInput{
+	ri := &RunningInput{
 		Input:  input,
 		Config: config,
 		MetricsGathered: selfstat.Register(
@@ -62,20 +91,42 @@ func NewRunningInput(input telegraf.Input, config *InputConfig, uniqueId string)
 			"gather_time_ns",
 			tags,
 		),
+		GathersSkipped: selfstat.Register(
+			"gather",
+			"gathers_skipped",
+			tags,
+		),
+		GatherBackoff: selfstat.Register(
+			"gather",
+			"backoff_ns",
+			tags,
+		),
 		UniqueId:     uniqueId,
 		ShutdownChan: make(chan struct{}),
 		Wg:           runningWg,
 		log:          logger,
 	}
+
+	logger.OnErr(func() {
+		inputErrorsRegister.Incr(1)
+		GlobalGatherErrors.Incr(1)
+		ri.statusLock.Lock()
+		ri.status.GatherErrors++
+		ri.statusLock.Unlock()
+	})
+	return ri
 }
 
 // InputConfig is the common config for all inputs.
 type InputConfig struct {
 	Name             string
 	Alias            string
+	Pipeline         string
 	Interval         time.Duration
 	CollectionJitter time.Duration
 	Precision        time.Duration
+	Schedule         []string
+	MaxGatherBackoff time.Duration
 
 	NameOverride      string
 	MeasurementPrefix string
@@ -103,7 +154,11 @@ func (r *RunningInput) Init() error {
 }
 
 func (r *RunningInput) MakeMetric(metric telegraf.Metric) telegraf.Metric {
+	trace := startTrace(metric, r, r.UniqueId)
 	if ok := r.Config.Filter.Select(metric); !ok {
+		if trace != nil {
+			trace.Done(TraceFiltered, r.Config.Filter.Explain(metric), nil)
+		}
 		r.metricFiltered(metric)
 		return nil
 	}
@@ -118,23 +173,113 @@ func (r *RunningInput) MakeMetric(metric telegraf.Metric) telegraf.Metric {
 
 	r.Config.Filter.Modify(metric)
 	if len(metric.FieldList()) == 0 {
+		trace.Done(TraceFiltered, noFieldsLeft, nil)
 		r.metricFiltered(metric)
 		return nil
 	}
 
+	trace.Done(TraceGathered, "", m)
 	r.MetricsGathered.Incr(1)
 	GlobalMetricsGathered.Incr(1)
 	return m
 }
 
+// Gather gathers the input once.  The gather failed if it returned an error
+// or errors were added to the accumulator meanwhile.
 func (r *RunningInput) Gather(acc telegraf.Accumulator) error {
+	r.statusLock.Lock()
+	errors := r.status.GatherErrors
+	r.statusLock.Unlock()
+
 	start := time.Now()
 	err := r.Input.Gather(acc)
 	elapsed := time.Since(start)
 	r.GatherTime.Incr(elapsed.Nanoseconds())
+
+	r.statusLock.Lock()
+	r.status.LastGather = start
+	r.status.LastGatherDuration = elapsed
+	r.gatherFailed = err != nil || r.status.GatherErrors > errors
+	r.statusLock.Unlock()
 	return err
 }
 
+// SkipGather counts a tick on which the input was not gathered.
+func (r *RunningInput) SkipGather() {
+	r.GathersSkipped.Incr(1)
+	r.statusLock.Lock()
+	r.status.GathersSkipped++
+	r.statusLock.Unlock()
+}
+
+// BackingOff returns true if the input should not be gathered on the tick.
+func (r *RunningInput) BackingOff(tick time.Time) bool {
+	r.statusLock.Lock()
+	defer r.statusLock.Unlock()
+	return tick.Before(r.status.BackoffUntil.Add(-r.backoffTolerance))
+}
+
+// UpdateBackoff updates the backoff after the gather on the tick.  Each
+// consecutive failed or overrun gather doubles the time between the gathers
+// starting from the interval, up to MaxGatherBackoff.  A successful gather
+// ends the backoff.
+func (r *RunningInput) UpdateBackoff(tick time.Time, interval time.Duration, overrun bool) {
+	r.statusLock.Lock()
+	defer r.statusLock.Unlock()
+
+	if !r.gatherFailed && !overrun {
+		if r.status.Backoff > 0 {
+			r.log.Infof("Gather succeeded, ending backoff after %d failures", r.status.ConsecutiveFailures)
+		}
+		r.status.ConsecutiveFailures = 0
+		r.status.Backoff = 0
+		r.status.BackoffUntil = time.Time{}
+		r.GatherBackoff.Set(0)
+		return
+	}
+
+	r.status.ConsecutiveFailures++
+	if r.Config.MaxGatherBackoff <= 0 || interval <= 0 {
+		return
+	}
+
+	backoff := interval
+	for i := int64(1); i < r.status.ConsecutiveFailures && backoff < r.Config.MaxGatherBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > r.Config.MaxGatherBackoff {
+		backoff = r.Config.MaxGatherBackoff
+	}
+	// The first failure does not delay the next gather.
+	if backoff <= interval {
+		return
+	}
+	if backoff != r.status.Backoff {
+		r.log.Warnf("Gather failed %d times in a row, backing off for %s", r.status.ConsecutiveFailures, backoff)
+	}
+
+	r.status.Backoff = backoff
+	r.status.BackoffUntil = tick.Add(backoff)
+	r.backoffTolerance = interval / 2
+	r.GatherBackoff.Set(backoff.Nanoseconds())
+}
+
+// RecordError keeps the error as the last error of the input, the error is
+// counted when it is logged.
+func (r *RunningInput) RecordError(err error) {
+	r.statusLock.Lock()
+	r.status.LastError = err.Error()
+	r.status.LastErrorTime = time.Now()
+	r.statusLock.Unlock()
+}
+
+// Status returns a snapshot of the health of the input.
+func (r *RunningInput) Status() InputStatus {
+	r.statusLock.Lock()
+	defer r.statusLock.Unlock()
+	return r.status
+}
+
 func (r *RunningInput) SetDefaultTags(tags map[string]string) {
 	r.defaultTags = tags
 }
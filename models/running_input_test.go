@@ -1,6 +1,7 @@
 package models
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -287,6 +288,108 @@ func TestMetricErrorCounters(t *testing.T) {
 	require.GreaterOrEqual(t, int64(1), GlobalGatherErrors.Get())
 }
 
+func TestRunningInputStatus(t *testing.T) {
+	ri := NewRunningInput(&testInput{}, &InputConfig{
+		Name: "TestRunningInputStatus",
+	}, "123")
+
+	require.NoError(t, ri.Gather(nil))
+	status := ri.Status()
+	require.False(t, status.LastGather.IsZero())
+	require.Equal(t, int64(0), status.GatherErrors)
+
+	ri.RecordError(errors.New("connection refused"))
+	ri.Log().Error("connection refused")
+
+	status = ri.Status()
+	require.Equal(t, int64(1), status.GatherErrors)
+	require.Equal(t, "connection refused", status.LastError)
+	require.False(t, status.LastErrorTime.IsZero())
+}
+
+func TestRunningInputBackoff(t *testing.T) {
+	input := &failingInput{err: errors.New("connection refused")}
+	ri := NewRunningInput(input, &InputConfig{
+		Name:             "TestRunningInputBackoff",
+		MaxGatherBackoff: 40 * time.Second,
+	}, "123")
+
+	interval := 10 * time.Second
+	tick := time.Unix(0, 0)
+	var gathered []int64
+	for i := 0; i < 16; i++ {
+		if ri.BackingOff(tick) {
+			ri.SkipGather()
+		} else {
+			require.Error(t, ri.Gather(nil))
+			ri.UpdateBackoff(tick, interval, false)
+			gathered = append(gathered, tick.Unix())
+		}
+		tick = tick.Add(interval)
+	}
+
+	// The time between the gathers doubles up to the maximum.
+	require.Equal(t, []int64{0, 10, 30, 70, 110, 150}, gathered)
+	status := ri.Status()
+	require.Equal(t, int64(6), status.ConsecutiveFailures)
+	require.Equal(t, int64(10), status.GathersSkipped)
+	require.Equal(t, 40*time.Second, status.Backoff)
+	require.Equal(t, time.Unix(190, 0), status.BackoffUntil)
+	require.Equal(t, int64(40*time.Second), ri.GatherBackoff.Get())
+
+	// Errors added to the accumulator fail the gather as well.
+	input.err = nil
+	input.log = ri.Log()
+	require.NoError(t, ri.Gather(nil))
+	ri.UpdateBackoff(time.Unix(170, 0), interval, false)
+	require.Equal(t, int64(7), ri.Status().ConsecutiveFailures)
+
+	// A successful gather ends the backoff.
+	input.log = nil
+	require.NoError(t, ri.Gather(nil))
+	ri.UpdateBackoff(time.Unix(210, 0), interval, false)
+	status = ri.Status()
+	require.Equal(t, int64(0), status.ConsecutiveFailures)
+	require.Equal(t, time.Duration(0), status.Backoff)
+	require.False(t, ri.BackingOff(time.Unix(220, 0)))
+	require.Equal(t, int64(0), ri.GatherBackoff.Get())
+
+	// Overrunning the interval counts as a failure.
+	require.NoError(t, ri.Gather(nil))
+	ri.UpdateBackoff(time.Unix(220, 0), interval, true)
+	require.Equal(t, int64(1), ri.Status().ConsecutiveFailures)
+}
+
+func TestRunningInputBackoffDisabled(t *testing.T) {
+	ri := NewRunningInput(&failingInput{err: errors.New("connection refused")}, &InputConfig{
+		Name: "TestRunningInputBackoffDisabled",
+	}, "123")
+
+	tick := time.Unix(0, 0)
+	for i := 0; i < 5; i++ {
+		require.False(t, ri.BackingOff(tick))
+		require.Error(t, ri.Gather(nil))
+		ri.UpdateBackoff(tick, 10*time.Second, false)
+		tick = tick.Add(10 * time.Second)
+	}
+	require.Equal(t, int64(5), ri.Status().ConsecutiveFailures)
+	require.Equal(t, time.Duration(0), ri.Status().Backoff)
+}
+
+type failingInput struct {
+	err error
+	log telegraf.Logger
+}
+
+func (f *failingInput) Description() string  { return "" }
+func (f *failingInput) SampleConfig() string { return "" }
+func (f *failingInput) Gather(acc telegraf.Accumulator) error {
+	if f.log != nil {
+		f.log.Error("partial failure")
+	}
+	return f.err
+}
+
 type testInput struct{}
 
 func (t *testInput) Description() string                   { return "" }
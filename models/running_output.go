@@ -1,6 +1,9 @@
 package models
 
 import (
+	"errors"
+	"fmt"
+	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -17,17 +20,40 @@ const (
 	DEFAULT_METRIC_BUFFER_LIMIT = 10000
 )
 
+// Connection states of an output.
+const (
+	ConnectionStateConnecting = "connecting"
+	ConnectionStateConnected  = "connected"
+	ConnectionStateFailed     = "failed"
+	ConnectionStateClosed     = "closed"
+)
+
 // OutputConfig containing name and filter
 type OutputConfig struct {
-	Name   string
-	Alias  string
-	Filter Filter
+	Name     string
+	Alias    string
+	Pipeline string
+	Filter   Filter
 
 	FlushInterval     time.Duration
 	FlushJitter       time.Duration
 	MetricBufferLimit int
 	MetricBatchSize   int
 
+	// BufferStrategy selects where unsent metrics are kept, either "memory"
+	// (the default) or "disk".  The Buffer* settings below only apply to the
+	// disk strategy.
+	BufferStrategy      string
+	BufferDirectory     string
+	BufferID            string
+	BufferMaxSize       int64
+	BufferSegmentSize   int64
+	BufferFsync         string
+	BufferFsyncInterval time.Duration
+
+	// SeriesLimit limits the number of series written by the output.
+	SeriesLimit SeriesLimitConfig
+
 	NameOverride string
 	NamePrefix   string
 	NameSuffix   string
@@ -38,6 +64,9 @@ type RunningOutput struct {
 	// Must be 64-bit aligned
 	newMetricsCount int64
 	droppedMetrics  int64
+	// metricsDropped counts all dropped metrics, while droppedMetrics is
+	// reset once the overflow is logged.
+	metricsDropped int64
 
 	Output            telegraf.Output
 	Config            *OutputConfig
@@ -53,10 +82,42 @@ type RunningOutput struct {
 	ShutdownChan chan struct{}
 	Wg           *sync.WaitGroup
 
-	buffer *Buffer
+	// Prepare applies the settings that are not stored in the fields of the
+	// plugin, such as the serializer, to a new instance of the output.
+	Prepare func(telegraf.Output) error
+
+	buffer MetricBuffer
+	series *SeriesLimiter
 	log    telegraf.Logger
 
+	// The buffer selected by the config is opened when the output starts,
+	// until then metrics are kept in memory.  previous is the instance this
+	// output replaces, its buffer is taken over when the buffer is opened.
+	// handover is set on the previous instance, so that its buffer is not
+	// closed with it.
+	bufferOpen bool
+	previous   *RunningOutput
+	handover   bool
+	closed     bool
+
 	aggMutex sync.Mutex
+
+	statusLock sync.Mutex
+	status     OutputStatus
+}
+
+// OutputStatus is a snapshot of the health of a running output.
+type OutputStatus struct {
+	ConnectionState   string        `json:"connection_state"`
+	BufferSize        int           `json:"buffer_size"`
+	BufferLimit       int           `json:"buffer_limit"`
+	MetricsDropped    int64         `json:"metrics_dropped"`
+	WriteErrors       int64         `json:"write_errors"`
+	LastError         string        `json:"last_error,omitempty"`
+	LastErrorTime     time.Time     `json:"last_error_time"`
+	FailingSince      time.Time     `json:"failing_since"`
+	LastWrite         time.Time     `json:"last_write"`
+	LastWriteDuration time.Duration `json:"last_write_duration_ns"`
 }
 
 func (ro *RunningOutput) Stop() {
@@ -97,9 +158,15 @@ func NewRunningOutput(
 		batchSize = DEFAULT_METRIC_BATCH_SIZE
 	}
 
+	var series *SeriesLimiter
+	if config.SeriesLimit.IsActive() {
+		series = NewSeriesLimiter(&config.SeriesLimit, tags)
+	}
+
 	runningWg := &sync.WaitGroup{}
 	runningWg.Add(1)
 	ro := &RunningOutput{
+		series:            series,
 		buffer:            NewBuffer(config.Name, config.Alias, bufferLimit),
 		BatchReady:        make(chan time.Time, 1),
 		Output:            output,
@@ -120,11 +187,125 @@ func NewRunningOutput(
 		ShutdownChan: make(chan struct{}),
 		Wg:           runningWg,
 		log:          logger,
+		status:       OutputStatus{ConnectionState: ConnectionStateConnecting},
 	}
 
 	return ro
 }
 
+// BufferPath returns the directory of the disk buffer of the output.  It is
+// named after the buffer id, or the name and alias of the output, so that it
+// is found again after a restart.
+func (c *OutputConfig) BufferPath() string {
+	dir := c.BufferID
+	if dir == "" {
+		dir = c.Name
+		if c.Alias != "" {
+			dir += "-" + c.Alias
+		}
+	}
+	return filepath.Join(c.BufferDirectory, dir)
+}
+
+// checkBufferConfig verifies the buffer settings of the output config.
+func checkBufferConfig(config *OutputConfig) error {
+	switch config.BufferStrategy {
+	case "", BufferStrategyMemory:
+		return nil
+	case BufferStrategyDisk:
+		if config.BufferDirectory == "" {
+			return errors.New("buffer_directory is required for the disk buffer strategy")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown buffer strategy %q", config.BufferStrategy)
+	}
+}
+
+// usesDiskBuffer returns true if both configs select the same disk buffer.
+func usesDiskBuffer(config, other *OutputConfig) bool {
+	return config.BufferStrategy == BufferStrategyDisk &&
+		other.BufferStrategy == BufferStrategyDisk &&
+		config.BufferPath() == other.BufferPath()
+}
+
+// TakeBuffer hands the buffer of the previous instance of the output over to
+// this one.  The previous instance keeps its buffer until it is closed, the
+// buffer is taken over by OpenBuffer.
+func (ro *RunningOutput) TakeBuffer(previous *RunningOutput) {
+	previous.handover = true
+	ro.previous = previous
+}
+
+// OpenBuffer opens the buffer selected by the output config, it has to be
+// called before the output starts and after the instance it replaces is
+// closed.  The metrics added before and those of the buffer of the replaced
+// instance are moved to it.  If the buffer can not be opened the metrics are
+// kept in memory and the error is returned.
+func (ro *RunningOutput) OpenBuffer() error {
+	if ro.bufferOpen {
+		return nil
+	}
+	ro.bufferOpen = true
+
+	previous := ro.previous
+	ro.previous = nil
+
+	// A disk buffer is reopened by the new instance, it must not be open
+	// twice.
+	if previous != nil && usesDiskBuffer(previous.Config, ro.Config) {
+		previous.closeBuffer()
+		previous = nil
+	}
+
+	var err error
+	if ro.Config.BufferStrategy == BufferStrategyDisk {
+		var b *DiskBuffer
+		b, err = NewDiskBuffer(ro.Config.Name, ro.Config.Alias, DiskBufferConfig{
+			Directory:     ro.Config.BufferPath(),
+			MaxMetrics:    ro.MetricBufferLimit,
+			MaxSize:       ro.Config.BufferMaxSize,
+			SegmentSize:   ro.Config.BufferSegmentSize,
+			Fsync:         ro.Config.BufferFsync,
+			FsyncInterval: ro.Config.BufferFsyncInterval,
+			Log:           ro.log,
+		})
+		if err != nil {
+			err = fmt.Errorf("could not open buffer: %w", err)
+			ro.log.Errorf("%v, falling back to memory", err)
+		} else {
+			// Until now the metrics were kept in memory.
+			ro.moveBuffer(ro.buffer.(*Buffer), b)
+			ro.buffer = b
+		}
+	}
+
+	if previous != nil {
+		if b, ok := previous.buffer.(*Buffer); ok {
+			ro.moveBuffer(b, ro.buffer)
+		} else if previous.buffer.Len() > 0 {
+			ro.log.Warnf("Keeping %d unsent metrics in %s until an output uses it again",
+				previous.buffer.Len(), previous.Config.BufferPath())
+		}
+		previous.closeBuffer()
+	}
+	return err
+}
+
+// moveBuffer moves the metrics of the memory buffer to the buffer of the
+// output.
+func (ro *RunningOutput) moveBuffer(from *Buffer, to MetricBuffer) {
+	dropped := to.Add(from.drain()...)
+	atomic.AddInt64(&ro.droppedMetrics, int64(dropped))
+	atomic.AddInt64(&ro.metricsDropped, int64(dropped))
+}
+
+func (ro *RunningOutput) closeBuffer() {
+	if err := ro.buffer.Close(); err != nil {
+		ro.log.Errorf("Error closing buffer: %v", err)
+	}
+}
+
 func (r *RunningOutput) LogName() string {
 	return logName("outputs", r.Config.Name, r.Config.Alias)
 }
@@ -135,6 +316,10 @@ func (ro *RunningOutput) metricFiltered(metric telegraf.Metric) {
 }
 
 func (r *RunningOutput) Init() error {
+	if err := checkBufferConfig(r.Config); err != nil {
+		return err
+	}
+
 	if p, ok := r.Output.(telegraf.Initializer); ok {
 		err := p.Init()
 		if err != nil {
@@ -149,18 +334,24 @@ func (r *RunningOutput) Init() error {
 //
 // Takes ownership of metric
 func (ro *RunningOutput) AddMetric(metric telegraf.Metric) {
+	trace := traceMetric(metric, ro, ro.UniqueId)
 	if ok := ro.Config.Filter.Select(metric); !ok {
+		if trace != nil {
+			trace.Done(TraceFiltered, ro.Config.Filter.Explain(metric), nil)
+		}
 		ro.metricFiltered(metric)
 		return
 	}
 
 	ro.Config.Filter.Modify(metric)
 	if len(metric.FieldList()) == 0 {
+		trace.Done(TraceFiltered, noFieldsLeft, nil)
 		ro.metricFiltered(metric)
 		return
 	}
 
 	if output, ok := ro.Output.(telegraf.AggregatingOutput); ok {
+		trace.Done(TraceAggregated, "", metric)
 		ro.aggMutex.Lock()
 		output.Add(metric)
 		ro.aggMutex.Unlock()
@@ -179,8 +370,29 @@ func (ro *RunningOutput) AddMetric(metric telegraf.Metric) {
 		metric.AddSuffix(ro.Config.NameSuffix)
 	}
 
+	if ro.series != nil {
+		ok, reason := ro.series.Apply(metric)
+		if !ok {
+			trace.Done(TraceDropped, reason, nil)
+			metric.Drop()
+			return
+		}
+		if reason != "" {
+			ro.log.Debugf("Series limit: %s", reason)
+		}
+	}
+
+	if trace != nil {
+		var reason string
+		if ro.buffer.Len() >= ro.MetricBufferLimit {
+			reason = "buffer full, the oldest metric is dropped"
+		}
+		trace.Done(TraceBuffered, reason, metric)
+	}
+
 	dropped := ro.buffer.Add(metric)
 	atomic.AddInt64(&ro.droppedMetrics, int64(dropped))
+	atomic.AddInt64(&ro.metricsDropped, int64(dropped))
 
 	count := atomic.AddInt64(&ro.newMetricsCount, 1)
 	if count == int64(ro.MetricBatchSize) {
@@ -198,11 +410,17 @@ func (ro *RunningOutput) Write() error {
 	if output, ok := ro.Output.(telegraf.AggregatingOutput); ok {
 		ro.aggMutex.Lock()
 		metrics := output.Push()
-		ro.buffer.Add(metrics...)
+		dropped := ro.buffer.Add(metrics...)
+		atomic.AddInt64(&ro.metricsDropped, int64(dropped))
 		output.Reset()
 		ro.aggMutex.Unlock()
 	}
 
+	if ro.series != nil {
+		dropped := ro.buffer.Add(ro.series.Overflow()...)
+		atomic.AddInt64(&ro.metricsDropped, int64(dropped))
+	}
+
 	atomic.StoreInt64(&ro.newMetricsCount, 0)
 
 	// Only process the metrics in the buffer now.  Metrics added while we are
@@ -248,9 +466,30 @@ func (r *RunningOutput) Close() {
 	if err != nil {
 		r.log.Errorf("Error closing output: %v", err)
 	}
+	if !r.handover {
+		r.closeBuffer()
+	}
+	r.closed = true
+	r.SetConnectionState(ConnectionStateClosed)
 	r.Wg.Done()
 }
 
+// Discard releases the buffer of an output that was never started, such as an
+// output of a reloaded config that is not used.  A buffer handed over by
+// TakeBuffer is returned to the previous instance, or closed if the previous
+// instance is closed already.
+func (r *RunningOutput) Discard() {
+	if previous := r.previous; previous != nil {
+		r.previous = nil
+		if previous.closed {
+			previous.closeBuffer()
+		} else {
+			previous.handover = false
+		}
+	}
+	r.closeBuffer()
+}
+
 func (r *RunningOutput) write(metrics []telegraf.Metric) error {
 	dropped := atomic.LoadInt64(&r.droppedMetrics)
 	if dropped > 0 {
@@ -262,6 +501,20 @@ func (r *RunningOutput) write(metrics []telegraf.Metric) error {
 	err := r.Output.Write(metrics)
 	elapsed := time.Since(start)
 	r.WriteTime.Incr(elapsed.Nanoseconds())
+	r.traceWrite(metrics, err)
+
+	r.statusLock.Lock()
+	r.status.LastWrite = start
+	r.status.LastWriteDuration = elapsed
+	if err != nil {
+		r.status.WriteErrors++
+		r.status.LastError = err.Error()
+		r.status.LastErrorTime = time.Now()
+		r.status.setConnectionState(ConnectionStateFailed)
+	} else {
+		r.status.setConnectionState(ConnectionStateConnected)
+	}
+	r.statusLock.Unlock()
 
 	if err == nil {
 		r.log.Debugf("Wrote batch of %d metrics in %s", len(metrics), elapsed)
@@ -269,6 +522,59 @@ func (r *RunningOutput) write(metrics []telegraf.Metric) error {
 	return err
 }
 
+// traceWrite records the write of the traced metrics of the batch.
+func (r *RunningOutput) traceWrite(metrics []telegraf.Metric, err error) {
+	if CurrentTracer() == nil {
+		return
+	}
+
+	for _, m := range metrics {
+		trace := traceMetric(m, r, r.UniqueId)
+		if trace == nil {
+			continue
+		}
+		if err != nil {
+			trace.Done(TraceWriteFailed, err.Error(), nil)
+		} else {
+			trace.Done(TraceWritten, "", nil)
+		}
+	}
+}
+
+// SetConnectionState sets the state of the connection of the output, it is
+// updated by each write once the output is connected.
+func (r *RunningOutput) SetConnectionState(state string) {
+	r.statusLock.Lock()
+	r.status.setConnectionState(state)
+	r.statusLock.Unlock()
+}
+
+// setConnectionState sets the state and keeps track of when the output
+// started failing.
+func (s *OutputStatus) setConnectionState(state string) {
+	s.ConnectionState = state
+	switch state {
+	case ConnectionStateFailed:
+		if s.FailingSince.IsZero() {
+			s.FailingSince = time.Now()
+		}
+	case ConnectionStateConnected:
+		s.FailingSince = time.Time{}
+	}
+}
+
+// Status returns a snapshot of the health of the output.
+func (r *RunningOutput) Status() OutputStatus {
+	r.statusLock.Lock()
+	status := r.status
+	r.statusLock.Unlock()
+
+	status.BufferSize = r.buffer.Len()
+	status.BufferLimit = r.MetricBufferLimit
+	status.MetricsDropped = atomic.LoadInt64(&r.metricsDropped)
+	return status
+}
+
 func (r *RunningOutput) LogBufferStatus() {
 	nBuffer := r.buffer.Len()
 	r.log.Debugf("Buffer fullness: %d / %d metrics", nBuffer, r.MetricBufferLimit)
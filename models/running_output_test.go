@@ -326,6 +326,119 @@ func TestRunningOutputWriteFail(t *testing.T) {
 	assert.Len(t, m.Metrics(), 10)
 }
 
+func TestRunningOutputStatus(t *testing.T) {
+	conf := &OutputConfig{
+		Filter: Filter{},
+	}
+
+	m := &mockOutput{}
+	m.failWrite = true
+	ro := NewRunningOutput("test", m, conf, 4, 8, "123")
+	require.Equal(t, ConnectionStateConnecting, ro.Status().ConnectionState)
+
+	for _, metric := range append(first5, next5...) {
+		ro.AddMetric(metric)
+	}
+
+	require.Error(t, ro.Write())
+	status := ro.Status()
+	require.Equal(t, ConnectionStateFailed, status.ConnectionState)
+	require.Equal(t, 8, status.BufferSize)
+	require.Equal(t, 8, status.BufferLimit)
+	require.Equal(t, int64(2), status.MetricsDropped)
+	require.Equal(t, int64(1), status.WriteErrors)
+	require.Equal(t, "Failed Write!", status.LastError)
+	failingSince := status.FailingSince
+	require.False(t, failingSince.IsZero())
+
+	// Further failures keep the time the output started failing.
+	require.Error(t, ro.Write())
+	require.Equal(t, failingSince, ro.Status().FailingSince)
+
+	m.failWrite = false
+	require.NoError(t, ro.Write())
+	status = ro.Status()
+	require.Equal(t, ConnectionStateConnected, status.ConnectionState)
+	require.True(t, status.FailingSince.IsZero())
+	require.Equal(t, 0, status.BufferSize)
+	// Dropped metrics are still counted after the overflow was logged.
+	require.Equal(t, int64(2), status.MetricsDropped)
+}
+
+func TestRunningOutputUnknownBufferStrategy(t *testing.T) {
+	conf := &OutputConfig{
+		Filter:         Filter{},
+		BufferStrategy: "tape",
+	}
+
+	ro := NewRunningOutput("test", &mockOutput{}, conf, 4, 12, "123")
+	require.Error(t, ro.Init())
+}
+
+// Verify that the disk buffer is only opened when the output starts.
+func TestRunningOutputOpenBuffer(t *testing.T) {
+	dir := t.TempDir()
+	conf := &OutputConfig{
+		Name:            "test",
+		Filter:          Filter{},
+		BufferStrategy:  BufferStrategyDisk,
+		BufferDirectory: dir,
+	}
+
+	ro := NewRunningOutput("test", &mockOutput{}, conf, 4, 12, "123")
+	require.NoError(t, ro.Init())
+	ro.AddMetric(testutil.TestMetric(1))
+	require.NoDirExists(t, conf.BufferPath())
+
+	require.NoError(t, ro.OpenBuffer())
+	defer ro.Discard()
+	require.DirExists(t, conf.BufferPath())
+	require.Equal(t, 1, ro.BufferLength())
+}
+
+// Verify that the unsent metrics are taken over by a new instance of the
+// output.
+func TestRunningOutputTakeBuffer(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		previous OutputConfig
+		current  OutputConfig
+	}{
+		{
+			name:     "memory",
+			previous: OutputConfig{Name: "test"},
+			current:  OutputConfig{Name: "test"},
+		},
+		{
+			name:     "memory to disk",
+			previous: OutputConfig{Name: "test"},
+			current:  OutputConfig{Name: "test", BufferStrategy: BufferStrategyDisk, BufferDirectory: dir + "/a"},
+		},
+		{
+			name:     "disk",
+			previous: OutputConfig{Name: "test", BufferStrategy: BufferStrategyDisk, BufferDirectory: dir + "/b"},
+			current:  OutputConfig{Name: "test", BufferStrategy: BufferStrategyDisk, BufferDirectory: dir + "/b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := NewRunningOutput("test", &mockOutput{}, &tt.previous, 4, 12, "123")
+			require.NoError(t, previous.OpenBuffer())
+			for _, m := range first5 {
+				previous.AddMetric(m)
+			}
+
+			ro := NewRunningOutput("test", &mockOutput{}, &tt.current, 4, 12, "123")
+			ro.TakeBuffer(previous)
+			previous.Close()
+			require.NoError(t, ro.OpenBuffer())
+			defer ro.Discard()
+			require.Equal(t, 5, ro.BufferLength())
+		})
+	}
+}
+
 // Verify that the order of points is preserved during a write failure.
 func TestRunningOutputWriteFailOrder(t *testing.T) {
 	conf := &OutputConfig{
@@ -588,3 +701,50 @@ func (m *perfOutput) Write(metrics []telegraf.Metric) error {
 	}
 	return nil
 }
+
+func TestRunningOutputSeriesLimit(t *testing.T) {
+	conf := &OutputConfig{
+		Filter:      Filter{},
+		SeriesLimit: SeriesLimitConfig{MaxSeriesPerMeasurement: 1},
+	}
+
+	m := &mockOutput{}
+	ro := NewRunningOutput("test", m, conf, 1000, 10000, "123")
+
+	ro.AddMetric(seriesMetric("docker", "1"))
+	ro.AddMetric(seriesMetric("docker", "2"))
+	ro.AddMetric(seriesMetric("docker", "1"))
+	require.NoError(t, ro.Write())
+
+	require.Len(t, m.Metrics(), 2)
+	require.Equal(t, int64(1), ro.series.MetricsLimited.Get())
+}
+
+func TestRunningOutputSeriesLimitAggregate(t *testing.T) {
+	conf := &OutputConfig{
+		Filter: Filter{},
+		SeriesLimit: SeriesLimitConfig{
+			MaxSeriesPerMeasurement: 1,
+			Action:                  SeriesLimitAggregate,
+		},
+	}
+
+	m := &mockOutput{}
+	ro := NewRunningOutput("test", m, conf, 1000, 10000, "123")
+	limited := ro.series.MetricsLimited.Get()
+
+	ro.AddMetric(seriesMetric("docker", "1"))
+	ro.AddMetric(seriesMetric("docker", "2"))
+	ro.AddMetric(seriesMetric("docker", "3"))
+	require.NoError(t, ro.Write())
+
+	expected := []telegraf.Metric{
+		seriesMetric("docker", "1"),
+		testutil.MustMetric("docker",
+			map[string]string{SeriesOverflowTag: "exceeded"},
+			map[string]interface{}{"value": 2, SeriesOverflowCountField: 2},
+			time.Unix(0, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, m.Metrics())
+	require.Equal(t, limited+2, ro.series.MetricsLimited.Get())
+}
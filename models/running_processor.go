@@ -2,16 +2,22 @@ package models
 
 import (
 	"sync"
+	"sync/atomic"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/selfstat"
 )
 
 type RunningProcessor struct {
+	// Must be 64-bit aligned
+	errors int64
+
 	sync.Mutex
 	log       telegraf.Logger
 	Processor telegraf.StreamingProcessor
 	Config    *ProcessorConfig
+
+	UniqueId string
 }
 
 type RunningProcessors []*RunningProcessor
@@ -22,13 +28,14 @@ func (rp RunningProcessors) Less(i, j int) bool { return rp[i].Config.Order < rp
 
 // FilterConfig containing a name and filter
 type ProcessorConfig struct {
-	Name   string
-	Alias  string
-	Order  int64
-	Filter Filter
+	Name     string
+	Alias    string
+	Pipeline string
+	Order    int64
+	Filter   Filter
 }
 
-func NewRunningProcessor(processor telegraf.StreamingProcessor, config *ProcessorConfig) *RunningProcessor {
+func NewRunningProcessor(processor telegraf.StreamingProcessor, config *ProcessorConfig, uniqueId string) *RunningProcessor {
 	tags := map[string]string{"processor": config.Name}
 	if config.Alias != "" {
 		tags["alias"] = config.Alias
@@ -36,16 +43,32 @@ func NewRunningProcessor(processor telegraf.StreamingProcessor, config *Processo
 
 	processErrorsRegister := selfstat.Register("process", "errors", tags)
 	logger := NewLogger("processors", config.Name, config.Alias)
-	logger.OnErr(func() {
-		processErrorsRegister.Incr(1)
-	})
 	SetLoggerOnPlugin(processor, logger)
 
-	return &RunningProcessor{
+	rp := &RunningProcessor{
 		Processor: processor,
 		Config:    config,
+		UniqueId:  uniqueId,
 		log:       logger,
 	}
+
+	logger.OnErr(func() {
+		processErrorsRegister.Incr(1)
+		atomic.AddInt64(&rp.errors, 1)
+	})
+	return rp
+}
+
+// ProcessorStatus is a snapshot of the health of a running processor.
+type ProcessorStatus struct {
+	Errors int64 `json:"errors"`
+}
+
+// Status returns a snapshot of the health of the processor.
+func (r *RunningProcessor) Status() ProcessorStatus {
+	return ProcessorStatus{
+		Errors: atomic.LoadInt64(&r.errors),
+	}
 }
 
 func (rp *RunningProcessor) metricFiltered(metric telegraf.Metric) {
@@ -79,8 +102,12 @@ func (r *RunningProcessor) Start(acc telegraf.Accumulator) error {
 }
 
 func (r *RunningProcessor) Add(m telegraf.Metric, acc telegraf.Accumulator) error {
+	trace := traceMetric(m, r, r.UniqueId)
 	if ok := r.Config.Filter.Select(m); !ok {
 		// pass downstream
+		if trace != nil {
+			trace.Done(TraceSkipped, r.Config.Filter.Explain(m), m)
+		}
 		acc.AddMetric(m)
 		return nil
 	}
@@ -88,11 +115,22 @@ func (r *RunningProcessor) Add(m telegraf.Metric, acc telegraf.Accumulator) erro
 	r.Config.Filter.Modify(m)
 	if len(m.FieldList()) == 0 {
 		// drop metric
+		trace.Done(TraceFiltered, noFieldsLeft, nil)
 		r.metricFiltered(m)
 		return nil
 	}
 
-	return r.Processor.Add(m, acc)
+	if trace == nil {
+		return r.Processor.Add(m, acc)
+	}
+
+	err := r.Processor.Add(m, trace.Accumulator(acc))
+	if trace.passed() {
+		trace.Done(TraceProcessed, "", nil)
+	} else {
+		trace.Done(TraceDropped, "dropped or held back by the processor", nil)
+	}
+	return err
 }
 
 func (r *RunningProcessor) Stop() {
@@ -0,0 +1,278 @@
+package models
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+// Actions taken on metrics of new series once a series limit is reached.
+const (
+	// SeriesLimitDrop drops the metrics.
+	SeriesLimitDrop = "drop"
+	// SeriesLimitStripTags removes the configured tags from the metrics, the
+	// metrics are dropped if they are still a new series over the limit.
+	SeriesLimitStripTags = "strip_tags"
+	// SeriesLimitAggregate aggregates the metrics into a single overflow
+	// series of their measurement, without tags other than
+	// SeriesOverflowTag.  The metrics of a measurement with the same
+	// timestamp are combined into one metric until the next write.
+	SeriesLimitAggregate = "aggregate"
+)
+
+// SeriesOverflowTag is the tag of the overflow series of the aggregate
+// action.
+const SeriesOverflowTag = "series_limit"
+
+// SeriesOverflowCountField is the field of the overflow series holding the
+// number of aggregated metrics.
+const SeriesOverflowCountField = "series_limit_count"
+
+// maxSeriesOffenders is the number of measurements reported with their own
+// statistic of limited metrics.
+const maxSeriesOffenders = 100
+
+// DefaultSeriesLimitReset is the default interval after which the known
+// series are forgotten.
+const DefaultSeriesLimitReset = 24 * time.Hour
+
+// SeriesLimitConfig limits the number of series written by an output.
+type SeriesLimitConfig struct {
+	// MaxSeries is the number of series of the output, 0 for no limit.
+	MaxSeries int
+	// MaxSeriesPerMeasurement is the number of series of each measurement,
+	// 0 for no limit.
+	MaxSeriesPerMeasurement int
+	// Action is the action taken on metrics of new series over a limit.
+	Action string
+	// StripTags are the tags removed by the strip_tags action.
+	StripTags []string
+	// Reset is the interval after which the known series are forgotten.
+	Reset time.Duration
+}
+
+// IsActive reports if a series limit is set.
+func (c *SeriesLimitConfig) IsActive() bool {
+	return c.MaxSeries > 0 || c.MaxSeriesPerMeasurement > 0
+}
+
+// Validate checks the action of the series limit.
+func (c *SeriesLimitConfig) Validate() error {
+	switch c.Action {
+	case "", SeriesLimitDrop, SeriesLimitAggregate:
+	case SeriesLimitStripTags:
+		if len(c.StripTags) == 0 {
+			return fmt.Errorf("series_limit_strip_tags is required for the %s action", SeriesLimitStripTags)
+		}
+	default:
+		return fmt.Errorf("unknown series limit action %q", c.Action)
+	}
+	if c.MaxSeries < 0 || c.MaxSeriesPerMeasurement < 0 {
+		return fmt.Errorf("series limits must not be negative")
+	}
+	return nil
+}
+
+// SeriesLimiter tracks the series, identified by the HashID of the metrics,
+// and applies the action of the config to the metrics of new series once a
+// limit is reached.  Only the series within the limits are kept, so memory
+// is bounded by the limits.  The known series are forgotten every Reset
+// interval to let series that are gone make room for new ones.
+type SeriesLimiter struct {
+	config *SeriesLimitConfig
+	tags   map[string]string
+
+	mu           sync.Mutex
+	series       map[uint64]struct{}
+	measurements map[string]int
+	lastReset    time.Time
+	// overflow holds the metrics aggregated since the last write.
+	overflow map[overflowKey]*overflowMetric
+
+	Series         selfstat.Stat
+	MetricsLimited selfstat.Stat
+	// offenders counts the limited metrics of each measurement.
+	offenders map[string]selfstat.Stat
+}
+
+// NewSeriesLimiter returns a SeriesLimiter reporting its statistics with the
+// tags.
+func NewSeriesLimiter(config *SeriesLimitConfig, tags map[string]string) *SeriesLimiter {
+	if config.Action == "" {
+		config.Action = SeriesLimitDrop
+	}
+	if config.Reset == 0 {
+		config.Reset = DefaultSeriesLimitReset
+	}
+	return &SeriesLimiter{
+		config:         config,
+		tags:           tags,
+		series:         make(map[uint64]struct{}),
+		measurements:   make(map[string]int),
+		lastReset:      time.Now(),
+		overflow:       make(map[overflowKey]*overflowMetric),
+		Series:         selfstat.Register("cardinality", "series", tags),
+		MetricsLimited: selfstat.Register("cardinality", "metrics_limited", tags),
+		offenders:      make(map[string]selfstat.Stat),
+	}
+}
+
+// Apply admits the metric, or applies the action if it is of a new series
+// over a limit.  It returns false if the metric is dropped, and the reason
+// the metric was changed or dropped.
+func (l *SeriesLimiter) Apply(m telegraf.Metric) (bool, string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if time.Since(l.lastReset) >= l.config.Reset {
+		l.series = make(map[uint64]struct{})
+		l.measurements = make(map[string]int)
+		l.lastReset = time.Now()
+		l.Series.Set(0)
+	}
+
+	reason, ok := l.admit(m)
+	if ok {
+		return true, ""
+	}
+	l.limited(m.Name())
+
+	switch l.config.Action {
+	case SeriesLimitStripTags:
+		for _, key := range l.config.StripTags {
+			m.RemoveTag(key)
+		}
+		if _, ok := l.admit(m); ok {
+			return true, reason + ", tags stripped"
+		}
+		return false, reason
+	case SeriesLimitAggregate:
+		key := overflowKey{name: m.Name(), time: m.Time().UnixNano()}
+		agg, ok := l.overflow[key]
+		if !ok {
+			agg = &overflowMetric{
+				name:   m.Name(),
+				time:   m.Time(),
+				fields: make(map[string]interface{}, len(m.FieldList())),
+			}
+			l.overflow[key] = agg
+		}
+		agg.add(m)
+		return false, reason + ", aggregated into the overflow series"
+	default:
+		return false, reason
+	}
+}
+
+// Overflow returns the overflow series aggregated by the aggregate action
+// since the last call, one metric per measurement and timestamp.
+func (l *SeriesLimiter) Overflow() []telegraf.Metric {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.overflow) == 0 {
+		return nil
+	}
+
+	metrics := make([]telegraf.Metric, 0, len(l.overflow))
+	for key, agg := range l.overflow {
+		delete(l.overflow, key)
+
+		agg.fields[SeriesOverflowCountField] = agg.count
+		tags := map[string]string{SeriesOverflowTag: "exceeded"}
+		m, err := metric.New(agg.name, tags, agg.fields, agg.time)
+		if err != nil {
+			continue
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+// overflowKey identifies an overflow metric by measurement and timestamp.
+type overflowKey struct {
+	name string
+	time int64
+}
+
+// overflowMetric is the aggregate of the metrics of a measurement with the
+// same timestamp over the series limit.
+type overflowMetric struct {
+	name   string
+	time   time.Time
+	fields map[string]interface{}
+	count  int64
+}
+
+// add aggregates the fields of the metric, numeric fields of the same type
+// are summed and other fields keep the last value.
+func (o *overflowMetric) add(m telegraf.Metric) {
+	o.count++
+	for _, field := range m.FieldList() {
+		switch v := field.Value.(type) {
+		case int64:
+			if sum, ok := o.fields[field.Key].(int64); ok {
+				o.fields[field.Key] = sum + v
+				continue
+			}
+		case uint64:
+			if sum, ok := o.fields[field.Key].(uint64); ok {
+				o.fields[field.Key] = sum + v
+				continue
+			}
+		case float64:
+			if sum, ok := o.fields[field.Key].(float64); ok {
+				o.fields[field.Key] = sum + v
+				continue
+			}
+		}
+		o.fields[field.Key] = field.Value
+	}
+}
+
+// admit returns true if the series of the metric is known or within the
+// limits, it returns the exceeded limit otherwise.
+func (l *SeriesLimiter) admit(m telegraf.Metric) (string, bool) {
+	id := m.HashID()
+	if _, ok := l.series[id]; ok {
+		return "", true
+	}
+
+	if l.config.MaxSeries > 0 && len(l.series) >= l.config.MaxSeries {
+		return fmt.Sprintf("max_series of %d reached", l.config.MaxSeries), false
+	}
+	count := l.measurements[m.Name()]
+	if l.config.MaxSeriesPerMeasurement > 0 && count >= l.config.MaxSeriesPerMeasurement {
+		return fmt.Sprintf("max_series_per_measurement of %d reached", l.config.MaxSeriesPerMeasurement), false
+	}
+
+	l.series[id] = struct{}{}
+	l.measurements[m.Name()] = count + 1
+	l.Series.Set(int64(len(l.series)))
+	return "", true
+}
+
+// limited counts a limited metric of the measurement, the first
+// maxSeriesOffenders measurements are reported with their own statistic.
+func (l *SeriesLimiter) limited(measurement string) {
+	l.MetricsLimited.Incr(1)
+
+	stat, ok := l.offenders[measurement]
+	if !ok {
+		if len(l.offenders) >= maxSeriesOffenders {
+			return
+		}
+		tags := make(map[string]string, len(l.tags)+1)
+		for k, v := range l.tags {
+			tags[k] = v
+		}
+		tags["measurement"] = measurement
+		stat = selfstat.Register("cardinality", "metrics_limited", tags)
+		l.offenders[measurement] = stat
+	}
+	stat.Incr(1)
+}
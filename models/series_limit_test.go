@@ -0,0 +1,136 @@
+package models
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func seriesMetric(name, container string) telegraf.Metric {
+	return testutil.MustMetric(name,
+		map[string]string{"host": "a", "container_id": container},
+		map[string]interface{}{"value": 1},
+		time.Unix(0, 0))
+}
+
+func TestSeriesLimiter_Drop(t *testing.T) {
+	l := NewSeriesLimiter(&SeriesLimitConfig{MaxSeriesPerMeasurement: 2}, map[string]string{"output": "test"})
+
+	for _, container := range []string{"1", "2", "1"} {
+		ok, _ := l.Apply(seriesMetric("docker", container))
+		require.True(t, ok)
+	}
+	ok, reason := l.Apply(seriesMetric("docker", "3"))
+	require.False(t, ok)
+	require.Equal(t, "max_series_per_measurement of 2 reached", reason)
+
+	// The limit applies to each measurement.
+	ok, _ = l.Apply(seriesMetric("cpu", "3"))
+	require.True(t, ok)
+
+	require.Equal(t, int64(3), l.Series.Get())
+	require.Equal(t, int64(1), l.MetricsLimited.Get())
+	require.Equal(t, int64(1), l.offenders["docker"].Get())
+}
+
+func TestSeriesLimiter_StripTags(t *testing.T) {
+	l := NewSeriesLimiter(&SeriesLimitConfig{
+		MaxSeries: 2,
+		Action:    SeriesLimitStripTags,
+		StripTags: []string{"container_id"},
+	}, map[string]string{"output": "test"})
+
+	host := testutil.MustMetric("docker",
+		map[string]string{"host": "a"},
+		map[string]interface{}{"value": 1},
+		time.Unix(0, 0))
+	ok, _ := l.Apply(host)
+	require.True(t, ok)
+	ok, _ = l.Apply(seriesMetric("docker", "1"))
+	require.True(t, ok)
+
+	// New containers fold into the known series without the tag.
+	m := seriesMetric("docker", "2")
+	ok, reason := l.Apply(m)
+	require.True(t, ok)
+	require.Equal(t, "max_series of 2 reached, tags stripped", reason)
+	require.Equal(t, map[string]string{"host": "a"}, m.Tags())
+
+	// Metrics that are still a new series after stripping are dropped.
+	ok, _ = l.Apply(seriesMetric("cpu", "3"))
+	require.False(t, ok)
+}
+
+func TestSeriesLimiter_Aggregate(t *testing.T) {
+	l := NewSeriesLimiter(&SeriesLimitConfig{
+		MaxSeriesPerMeasurement: 1,
+		Action:                  SeriesLimitAggregate,
+	}, map[string]string{"output": "test"})
+
+	ok, _ := l.Apply(seriesMetric("docker", "1"))
+	require.True(t, ok)
+	require.Empty(t, l.Overflow())
+
+	// The overflow metrics share a timestamp and are aggregated into one
+	// metric instead of overwriting each other.
+	ok, _ = l.Apply(seriesMetric("docker", "2"))
+	require.False(t, ok)
+	ok, _ = l.Apply(testutil.MustMetric("docker",
+		map[string]string{"host": "b", "container_id": "3"},
+		map[string]interface{}{"value": 2, "state": "running"},
+		time.Unix(0, 0)))
+	require.False(t, ok)
+	ok, _ = l.Apply(seriesMetric("docker", "4"))
+	require.False(t, ok)
+	ok, _ = l.Apply(testutil.MustMetric("docker",
+		map[string]string{"host": "a", "container_id": "5"},
+		map[string]interface{}{"value": 1},
+		time.Unix(10, 0)))
+	require.False(t, ok)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("docker",
+			map[string]string{SeriesOverflowTag: "exceeded"},
+			map[string]interface{}{
+				"value":                  int64(4),
+				"state":                  "running",
+				SeriesOverflowCountField: int64(3),
+			},
+			time.Unix(0, 0)),
+		testutil.MustMetric("docker",
+			map[string]string{SeriesOverflowTag: "exceeded"},
+			map[string]interface{}{
+				"value":                  int64(1),
+				SeriesOverflowCountField: int64(1),
+			},
+			time.Unix(10, 0)),
+	}
+	actual := l.Overflow()
+	sort.Slice(actual, func(i, j int) bool {
+		return actual[i].Time().Before(actual[j].Time())
+	})
+	testutil.RequireMetricsEqual(t, expected, actual)
+
+	// The overflow series are only returned once.
+	require.Empty(t, l.Overflow())
+}
+
+func TestSeriesLimiter_Reset(t *testing.T) {
+	l := NewSeriesLimiter(&SeriesLimitConfig{MaxSeries: 1, Reset: time.Hour}, map[string]string{"output": "test"})
+
+	ok, _ := l.Apply(seriesMetric("docker", "1"))
+	require.True(t, ok)
+	ok, _ = l.Apply(seriesMetric("docker", "2"))
+	require.False(t, ok)
+
+	// After the reset new series fill the limit again.
+	l.lastReset = time.Now().Add(-time.Hour)
+	ok, _ = l.Apply(seriesMetric("docker", "2"))
+	require.True(t, ok)
+	ok, _ = l.Apply(seriesMetric("docker", "1"))
+	require.False(t, ok)
+}
@@ -0,0 +1,351 @@
+package models
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// DefaultMaxTraces is the number of traces kept by default.
+const DefaultMaxTraces = 100
+
+// The actions of a metric in a plugin recorded in a trace.
+const (
+	// TraceGathered is the metric leaving the input that gathered it.
+	TraceGathered = "gathered"
+	// TraceFiltered is the metric dropped by the filter of the plugin.
+	TraceFiltered = "filtered"
+	// TraceSkipped is the metric not selected by the filter of a processor
+	// or aggregator, it is passed on unchanged.
+	TraceSkipped = "skipped"
+	// TraceProcessed is the metric handled by a processor, the metrics
+	// the processor passed on are in the After states.
+	TraceProcessed = "processed"
+	// TraceDropped is the metric dropped by a processor, an aggregator or
+	// the routes.
+	TraceDropped = "dropped"
+	// TraceAggregated is the metric added to an aggregator.
+	TraceAggregated = "aggregated"
+	// TraceRouted is the metric sent to the outputs of the routes it
+	// matches.
+	TraceRouted = "routed"
+	// TraceBuffered is the metric added to the buffer of an output.
+	TraceBuffered = "buffered"
+	// TraceWritten is the metric written by an output.
+	TraceWritten = "written"
+	// TraceWriteFailed is a failed write of the metric by an output.
+	TraceWriteFailed = "write_failed"
+)
+
+// noFieldsLeft is the reason of metrics filtered by fieldpass/fielddrop.
+const noFieldsLeft = "no fields left after fieldpass/fielddrop"
+
+// TraceConfig selects the metrics to trace.
+type TraceConfig struct {
+	// SampleRate is the fraction of the gathered metrics traced, from 0 to
+	// 1.  With an active filter it is the fraction of the selected metrics,
+	// all of them if zero.
+	SampleRate float64
+	// Filter selects the metrics to trace if it is active.
+	Filter Filter
+	// MaxTraces is the number of traces kept, older traces are discarded.
+	MaxTraces int
+}
+
+// Trace is the way of a gathered metric, and of the metrics derived from it
+// by processors, through the plugins.
+type Trace struct {
+	ID    uint64      `json:"id"`
+	Start time.Time   `json:"start"`
+	Hops  []*TraceHop `json:"hops"`
+
+	// metrics are the traced metrics, known by their identity.
+	metrics []telegraf.Metric
+}
+
+// TraceHop is the passage of a traced metric through a plugin.
+type TraceHop struct {
+	Time     time.Time      `json:"time"`
+	Plugin   string         `json:"plugin"`
+	UniqueId string         `json:"unique_id,omitempty"`
+	Action   string         `json:"action"`
+	Reason   string         `json:"reason,omitempty"`
+	Before   *MetricState   `json:"before,omitempty"`
+	After    []*MetricState `json:"after,omitempty"`
+}
+
+// MetricState is a copy of a metric at a hop.
+type MetricState struct {
+	Name   string                 `json:"name"`
+	Tags   map[string]string      `json:"tags"`
+	Fields map[string]interface{} `json:"fields"`
+	Time   time.Time              `json:"time"`
+}
+
+func newMetricState(m telegraf.Metric) *MetricState {
+	fields := m.Fields()
+	for k, v := range fields {
+		if d, ok := v.(*telegraf.Distribution); ok {
+			fields[k] = d.Copy()
+		}
+	}
+	return &MetricState{
+		Name:   m.Name(),
+		Tags:   m.Tags(),
+		Fields: fields,
+		Time:   m.Time(),
+	}
+}
+
+// Tracer records the hops of the traced metrics.  Metrics are followed by
+// their identity, metrics copied or created by a plugin are only traced if
+// they are passed on to the tracer with TraceCopy or a traced accumulator.
+type Tracer struct {
+	config *TraceConfig
+
+	mu     sync.Mutex
+	nextID uint64
+	traces []*Trace
+	active map[telegraf.Metric]*Trace
+}
+
+// NewTracer returns a Tracer for the config.
+func NewTracer(config *TraceConfig) *Tracer {
+	if config.MaxTraces <= 0 {
+		config.MaxTraces = DefaultMaxTraces
+	}
+	return &Tracer{
+		config: config,
+		active: make(map[telegraf.Metric]*Trace),
+	}
+}
+
+var tracer atomic.Value
+
+func init() {
+	tracer.Store((*Tracer)(nil))
+}
+
+// SetTracer sets the tracer used by the running plugins, nil disables
+// tracing.
+func SetTracer(t *Tracer) {
+	tracer.Store(t)
+}
+
+// CurrentTracer returns the tracer used by the running plugins, or nil if
+// tracing is disabled.
+func CurrentTracer() *Tracer {
+	return tracer.Load().(*Tracer)
+}
+
+// Traces returns a copy of the kept traces, oldest first.
+func (t *Tracer) Traces() []*Trace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	traces := make([]*Trace, 0, len(t.traces))
+	for _, trace := range t.traces {
+		traces = append(traces, &Trace{
+			ID:    trace.ID,
+			Start: trace.Start,
+			Hops:  append([]*TraceHop{}, trace.Hops...),
+		})
+	}
+	return traces
+}
+
+// start begins a trace of the metric if it is selected.
+func (t *Tracer) start(m telegraf.Metric) *Trace {
+	rate := t.config.SampleRate
+	if t.config.Filter.IsActive() {
+		if !t.config.Filter.Select(m) {
+			return nil
+		}
+		if rate == 0 {
+			rate = 1
+		}
+	}
+	if rate < 1 && rand.Float64() >= rate {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.nextID++
+	trace := &Trace{ID: t.nextID, Start: time.Now(), Hops: []*TraceHop{}}
+	t.traces = append(t.traces, trace)
+	t.active[m] = trace
+	trace.metrics = append(trace.metrics, m)
+
+	// Stop following the metrics of discarded traces.
+	for len(t.traces) > t.config.MaxTraces {
+		for _, m := range t.traces[0].metrics {
+			delete(t.active, m)
+		}
+		t.traces[0] = nil
+		t.traces = t.traces[1:]
+	}
+	return trace
+}
+
+func (t *Tracer) lookup(m telegraf.Metric) *Trace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.active[m]
+}
+
+func (t *Tracer) follow(trace *Trace, m telegraf.Metric) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// The trace was discarded.
+	if len(t.traces) == 0 || t.traces[0].ID > trace.ID {
+		return
+	}
+	if _, ok := t.active[m]; !ok {
+		t.active[m] = trace
+		trace.metrics = append(trace.metrics, m)
+	}
+}
+
+func (t *Tracer) record(trace *Trace, hop *TraceHop) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	trace.Hops = append(trace.Hops, hop)
+}
+
+// TraceRecorder records the hop of a traced metric through a plugin.  The
+// methods of a nil TraceRecorder do nothing.
+type TraceRecorder struct {
+	tracer *Tracer
+	trace  *Trace
+	hop    *TraceHop
+
+	mu   sync.Mutex
+	done bool
+}
+
+func newTraceRecorder(t *Tracer, trace *Trace, m telegraf.Metric, plugin, uniqueId string) *TraceRecorder {
+	return &TraceRecorder{
+		tracer: t,
+		trace:  trace,
+		hop: &TraceHop{
+			Plugin:   plugin,
+			UniqueId: uniqueId,
+			Before:   newMetricState(m),
+		},
+	}
+}
+
+// tracedPlugin is the plugin of a hop, its name is only built for the metrics
+// that are traced.
+type tracedPlugin interface {
+	LogName() string
+}
+
+// pluginName is a plugin name known in advance.
+type pluginName string
+
+func (n pluginName) LogName() string { return string(n) }
+
+// startTrace begins the trace of a gathered metric if it is selected by the
+// tracer, it returns nil otherwise.
+func startTrace(m telegraf.Metric, plugin tracedPlugin, uniqueId string) *TraceRecorder {
+	t := CurrentTracer()
+	if t == nil {
+		return nil
+	}
+	trace := t.start(m)
+	if trace == nil {
+		return nil
+	}
+	return newTraceRecorder(t, trace, m, plugin.LogName(), uniqueId)
+}
+
+// TraceMetric returns a recorder for the hop of the metric through the
+// plugin, or nil if the metric is not traced.
+func TraceMetric(m telegraf.Metric, plugin, uniqueId string) *TraceRecorder {
+	return traceMetric(m, pluginName(plugin), uniqueId)
+}
+
+func traceMetric(m telegraf.Metric, plugin tracedPlugin, uniqueId string) *TraceRecorder {
+	t := CurrentTracer()
+	if t == nil {
+		return nil
+	}
+	trace := t.lookup(m)
+	if trace == nil {
+		return nil
+	}
+	return newTraceRecorder(t, trace, m, plugin.LogName(), uniqueId)
+}
+
+// TraceCopy follows the copy of a metric in the trace of the metric.
+func TraceCopy(from, to telegraf.Metric) {
+	t := CurrentTracer()
+	if t == nil {
+		return
+	}
+	if trace := t.lookup(from); trace != nil {
+		t.follow(trace, to)
+	}
+}
+
+// Done records the hop with the state of the metric after the plugin, after
+// is nil if the metric did not leave the plugin.
+func (r *TraceRecorder) Done(action, reason string, after telegraf.Metric) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	r.done = true
+	hop := r.hop
+	hop.Time = time.Now()
+	hop.Action = action
+	hop.Reason = reason
+	if after != nil {
+		hop.After = append(hop.After, newMetricState(after))
+	}
+	r.mu.Unlock()
+
+	r.tracer.record(r.trace, hop)
+}
+
+// Accumulator wraps the accumulator of a processor, the metrics passed on
+// by the processor are recorded as After states and traced further.
+func (r *TraceRecorder) Accumulator(acc telegraf.Accumulator) telegraf.Accumulator {
+	if r == nil {
+		return acc
+	}
+	return &traceAccumulator{Accumulator: acc, recorder: r}
+}
+
+// passed reports if the plugin passed on any metric.
+func (r *TraceRecorder) passed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.hop.After) > 0
+}
+
+type traceAccumulator struct {
+	telegraf.Accumulator
+	recorder *TraceRecorder
+}
+
+func (a *traceAccumulator) AddMetric(m telegraf.Metric) {
+	// Metrics passed on after the hop was recorded are not traced.
+	r := a.recorder
+	r.mu.Lock()
+	if !r.done {
+		r.hop.After = append(r.hop.After, newMetricState(m))
+		r.tracer.follow(r.trace, m)
+	}
+	r.mu.Unlock()
+
+	a.Accumulator.AddMetric(m)
+}
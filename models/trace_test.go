@@ -0,0 +1,119 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// tagProcessor tags the metrics and passes them on.
+type tagProcessor struct{}
+
+func (p *tagProcessor) SampleConfig() string                 { return "" }
+func (p *tagProcessor) Description() string                  { return "" }
+func (p *tagProcessor) Start(acc telegraf.Accumulator) error { return nil }
+func (p *tagProcessor) Stop() error                          { return nil }
+func (p *tagProcessor) Add(m telegraf.Metric, acc telegraf.Accumulator) error {
+	m.AddTag("processed", "true")
+	acc.AddMetric(m)
+	return nil
+}
+
+func traceActions(trace *Trace) []string {
+	var actions []string
+	for _, hop := range trace.Hops {
+		actions = append(actions, hop.Action)
+	}
+	return actions
+}
+
+func TestTracer(t *testing.T) {
+	SetTracer(NewTracer(&TraceConfig{SampleRate: 1}))
+	defer SetTracer(nil)
+
+	ri := NewRunningInput(&testInput{}, &InputConfig{
+		Name:   "test",
+		Filter: Filter{NameDrop: []string{"mem"}},
+	}, "input")
+	require.NoError(t, ri.Config.Filter.Compile())
+	rp := NewRunningProcessor(&tagProcessor{}, &ProcessorConfig{Name: "test"}, "processor")
+	out := &mockOutput{}
+	ro := NewRunningOutput("test", out, &OutputConfig{}, 1000, 10000, "output")
+
+	acc := testutil.Accumulator{}
+	m := ri.MakeMetric(testutil.MustMetric("cpu",
+		map[string]string{},
+		map[string]interface{}{"value": 42},
+		time.Unix(0, 0)))
+	require.NotNil(t, m)
+	require.NoError(t, rp.Add(m, &acc))
+	require.Len(t, acc.GetTelegrafMetrics(), 1)
+	// The test accumulator copies the metrics, the processor passed on m.
+	ro.AddMetric(m)
+	require.NoError(t, ro.Write())
+
+	require.Nil(t, ri.MakeMetric(testutil.MustMetric("mem",
+		map[string]string{},
+		map[string]interface{}{"value": 42},
+		time.Unix(0, 0))))
+
+	traces := CurrentTracer().Traces()
+	require.Len(t, traces, 2)
+
+	cpu := traces[0]
+	require.Equal(t, []string{TraceGathered, TraceProcessed, TraceBuffered, TraceWritten}, traceActions(cpu))
+	require.Equal(t, "input", cpu.Hops[0].UniqueId)
+	processed := cpu.Hops[1]
+	require.Equal(t, "processor", processed.UniqueId)
+	require.Empty(t, processed.Before.Tags)
+	require.Len(t, processed.After, 1)
+	require.Equal(t, map[string]string{"processed": "true"}, processed.After[0].Tags)
+
+	mem := traces[1]
+	require.Equal(t, []string{TraceFiltered}, traceActions(mem))
+	require.Equal(t, "namepass/namedrop", mem.Hops[0].Reason)
+	require.Nil(t, mem.Hops[0].After)
+}
+
+func TestTracer_Filter(t *testing.T) {
+	config := &TraceConfig{Filter: Filter{NamePass: []string{"cpu"}}}
+	require.NoError(t, config.Filter.Compile())
+	tracer := NewTracer(config)
+
+	require.NotNil(t, tracer.start(testutil.MustMetric("cpu",
+		map[string]string{},
+		map[string]interface{}{"value": 42},
+		time.Unix(0, 0))))
+	require.Nil(t, tracer.start(testutil.MustMetric("mem",
+		map[string]string{},
+		map[string]interface{}{"value": 42},
+		time.Unix(0, 0))))
+	require.Len(t, tracer.Traces(), 1)
+}
+
+func TestTracer_MaxTraces(t *testing.T) {
+	SetTracer(NewTracer(&TraceConfig{SampleRate: 1, MaxTraces: 1}))
+	defer SetTracer(nil)
+
+	first := testutil.MustMetric("cpu",
+		map[string]string{},
+		map[string]interface{}{"value": 1},
+		time.Unix(0, 0))
+	second := testutil.MustMetric("cpu",
+		map[string]string{},
+		map[string]interface{}{"value": 2},
+		time.Unix(0, 0))
+	startTrace(first, pluginName("inputs.test"), "").Done(TraceGathered, "", first)
+	startTrace(second, pluginName("inputs.test"), "").Done(TraceGathered, "", second)
+
+	// The metrics of discarded traces are no longer followed.
+	require.Nil(t, TraceMetric(first, "outputs.test", ""))
+	require.NotNil(t, TraceMetric(second, "outputs.test", ""))
+
+	traces := CurrentTracer().Traces()
+	require.Len(t, traces, 1)
+	require.Equal(t, uint64(2), traces[0].ID)
+}
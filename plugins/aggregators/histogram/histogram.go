@@ -25,6 +25,7 @@ type HistogramAggregator struct {
 	Configs      []config `toml:"config"`
 	ResetBuckets bool     `toml:"reset"`
 	Cumulative   bool     `toml:"cumulative"`
+	Distribution bool     `toml:"distribution"`
 
 	buckets bucketsByMetrics
 	cache   map[uint64]metricHistogramCollection
@@ -49,6 +50,7 @@ type buckets []float64
 // metricHistogramCollection aggregates the histogram data
 type metricHistogramCollection struct {
 	histogramCollection map[string]counts
+	sums                map[string]float64
 	name                string
 	tags                map[string]string
 }
@@ -90,6 +92,13 @@ var sampleConfig = `
   ## Defaults to true.
   cumulative = true
 
+  ## If true, each field is emitted as a native histogram with the count and
+  ## sum of the values, in one metric per series, instead of a metric per
+  ## bucket tagged with "le".  Outputs without native support flatten it into
+  ## the "le" tagged <field>_bucket, <field>_count and <field>_sum fields.
+  ## The buckets are always cumulative.
+  # distribution = false
+
   ## Example config that aggregates all fields of the metric.
   # [[aggregators.histogram.config]]
   #   ## Right borders of buckets (with +Inf implicitly added).
@@ -138,6 +147,7 @@ func (h *HistogramAggregator) Add(in telegraf.Metric) {
 			name:                in.Name(),
 			tags:                in.Tags(),
 			histogramCollection: make(map[string]counts),
+			sums:                make(map[string]float64),
 		}
 	}
 
@@ -150,6 +160,7 @@ func (h *HistogramAggregator) Add(in telegraf.Metric) {
 			if value, ok := convert(value); ok {
 				index := sort.SearchFloat64s(buckets, value)
 				agr.histogramCollection[field][index]++
+				agr.sums[field] += value
 			}
 		}
 	}
@@ -159,6 +170,11 @@ func (h *HistogramAggregator) Add(in telegraf.Metric) {
 
 // Push returns histogram values for metrics
 func (h *HistogramAggregator) Push(acc telegraf.Accumulator) {
+	if h.Distribution {
+		h.pushDistributions(acc)
+		return
+	}
+
 	metricsWithGroupedFields := []groupedByCountFields{}
 
 	for _, aggregate := range h.cache {
@@ -172,6 +188,36 @@ func (h *HistogramAggregator) Push(acc telegraf.Accumulator) {
 	}
 }
 
+// pushDistributions adds a metric per series with a distribution field per
+// aggregated field
+func (h *HistogramAggregator) pushDistributions(acc telegraf.Accumulator) {
+	for _, aggregate := range h.cache {
+		fields := make(map[string]interface{}, len(aggregate.histogramCollection))
+		for field, counts := range aggregate.histogramCollection {
+			fields[field] = h.makeDistribution(aggregate.name, field, counts, aggregate.sums[field])
+		}
+		acc.AddHistogram(aggregate.name, fields, copyTags(aggregate.tags))
+	}
+}
+
+// makeDistribution creates the distribution of the counts, the +Inf bucket is
+// implied by the count
+func (h *HistogramAggregator) makeDistribution(name string, field string, counts []int64, sum float64) *telegraf.Distribution {
+	buckets := h.getBuckets(name, field) // note that len(buckets) + 1 == len(counts)
+
+	d := &telegraf.Distribution{
+		Sum:     sum,
+		Buckets: make([]telegraf.Bucket, 0, len(buckets)),
+	}
+	for index, count := range counts {
+		d.Count += uint64(count)
+		if index < len(buckets) {
+			d.Buckets = append(d.Buckets, telegraf.Bucket{UpperBound: buckets[index], Count: d.Count})
+		}
+	}
+	return d
+}
+
 // groupFieldsByBuckets groups fields by metric buckets which are represented as tags
 func (h *HistogramAggregator) groupFieldsByBuckets(
 	metricsWithGroupedFields *[]groupedByCountFields,
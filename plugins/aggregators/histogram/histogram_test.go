@@ -238,6 +238,78 @@ func TestHistogramWithTwoPeriodsAndAllFields(t *testing.T) {
 	assertContainsTaggedField(t, acc, "first_metric_name", fields{"a_bucket": int64(2), "b_bucket": int64(1), "c_bucket": int64(1)}, tags{bucketRightTag: bucketPosInf})
 }
 
+// TestHistogramDistribution tests that the fields are emitted as distributions
+func TestHistogramDistribution(t *testing.T) {
+	var cfg []config
+	cfg = append(cfg, config{Metric: "first_metric_name", Buckets: []float64{0.0, 15.5, 20.0, 30.0, 40.0}})
+	histogram := NewHistogramAggregator()
+	histogram.Configs = cfg
+	histogram.Distribution = true
+
+	acc := &testutil.Accumulator{}
+
+	histogram.Add(firstMetric1)
+	histogram.Add(firstMetric2)
+	histogram.Push(acc)
+
+	sum := firstMetric1.Fields()["a"].(float64) + firstMetric2.Fields()["a"].(float64)
+	buckets := func(counts ...uint64) []telegraf.Bucket {
+		var b []telegraf.Bucket
+		for i, bound := range cfg[0].Buckets {
+			b = append(b, telegraf.Bucket{UpperBound: bound, Count: counts[i]})
+		}
+		return b
+	}
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"first_metric_name",
+			map[string]string{},
+			map[string]interface{}{
+				"a": &telegraf.Distribution{Count: 2, Sum: sum, Buckets: buckets(0, 1, 2, 2, 2)},
+				"b": &telegraf.Distribution{Count: 1, Sum: 40, Buckets: buckets(0, 0, 0, 0, 1)},
+				"c": &telegraf.Distribution{Count: 1, Sum: 40, Buckets: buckets(0, 0, 0, 0, 1)},
+			},
+			time.Unix(0, 0),
+			telegraf.Histogram,
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+}
+
+// TestHistogramDistributionFlattened tests that flattened distributions hold
+// the cumulative buckets of the default output
+func TestHistogramDistributionFlattened(t *testing.T) {
+	var cfg []config
+	cfg = append(cfg, config{Metric: "first_metric_name", Fields: []string{"a"}, Buckets: []float64{0.0, 10.0, 20.0, 30.0, 40.0}})
+	histogram := NewHistogramAggregator()
+	histogram.Configs = cfg
+	histogram.Distribution = true
+
+	acc := &testutil.Accumulator{}
+
+	histogram.Add(firstMetric1)
+	histogram.Add(firstMetric2)
+	histogram.Push(acc)
+
+	sum := firstMetric1.Fields()["a"].(float64) + firstMetric2.Fields()["a"].(float64)
+	flattened := metric.FlattenDistributions(acc.GetTelegrafMetrics())
+	if len(flattened) != 7 {
+		assert.Fail(t, "Incorrect number of metrics")
+	}
+
+	acc.ClearMetrics()
+	for _, m := range flattened {
+		acc.AddMetric(m)
+	}
+	assertContainsTaggedField(t, acc, "first_metric_name", fields{"a_count": float64(2), "a_sum": sum}, tags{})
+	assertContainsTaggedField(t, acc, "first_metric_name", fields{"a_bucket": float64(0)}, tags{bucketRightTag: "0"})
+	assertContainsTaggedField(t, acc, "first_metric_name", fields{"a_bucket": float64(0)}, tags{bucketRightTag: "10"})
+	assertContainsTaggedField(t, acc, "first_metric_name", fields{"a_bucket": float64(2)}, tags{bucketRightTag: "20"})
+	assertContainsTaggedField(t, acc, "first_metric_name", fields{"a_bucket": float64(2)}, tags{bucketRightTag: "30"})
+	assertContainsTaggedField(t, acc, "first_metric_name", fields{"a_bucket": float64(2)}, tags{bucketRightTag: "40"})
+	assertContainsTaggedField(t, acc, "first_metric_name", fields{"a_bucket": float64(2)}, tags{bucketRightTag: bucketPosInf})
+}
+
 // TestWrongBucketsOrder tests the calling panic with incorrect order of buckets
 func TestWrongBucketsOrder(t *testing.T) {
 	defer func() {
@@ -0,0 +1,220 @@
+// Package delivery provides at-least-once delivery for inputs consuming from
+// a message broker.
+//
+// A Tracker limits the number of messages whose metrics have not been
+// written by the outputs yet and calls back once a message is delivered:
+//
+//	err := tracker.Reserve(ctx)        // wait for a free slot
+//	msg := receive()                   // then read from the broker
+//	metrics, err := parse(msg)
+//	if err != nil {
+//		tracker.Release()              // the slot was not used
+//	}
+//	tracker.Add(metrics, func(delivered bool) {
+//		if delivered {
+//			msg.Ack()
+//		} else {
+//			msg.Nack()
+//		}
+//	})
+//
+// Brokers that consider a message consumed once it is received set
+// Redeliver, the rejected metrics are then added again and the callback only
+// runs once they are delivered.
+package delivery
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// DefaultDrainTimeout is how long Stop waits for undelivered messages by
+// default.
+const DefaultDrainTimeout = 5 * time.Second
+
+// ErrStopped is returned by Reserve once the tracker is stopping.
+var ErrStopped = errors.New("delivery tracker stopped")
+
+// DeliveryFunc is called once the metrics of a message are delivered or
+// rejected.  It is called from the goroutine of the tracker and must not
+// block on Reserve.
+type DeliveryFunc func(delivered bool)
+
+// Tracker tracks the delivery of the messages consumed by an input.  The
+// fields must be set before the first call to Reserve.
+type Tracker struct {
+	// Redeliver adds a copy of the metrics of a message again when they are
+	// rejected, for brokers that cannot redeliver the message.
+	Redeliver bool
+
+	// DrainTimeout is how long Stop waits for the undelivered messages.
+	DrainTimeout time.Duration
+
+	acc telegraf.TrackingAccumulator
+	sem chan struct{}
+
+	mu      sync.Mutex
+	pending map[telegraf.TrackingID]*message
+
+	stopping chan struct{}
+	quit     chan struct{}
+	idle     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+type message struct {
+	onDelivery DeliveryFunc
+	backup     []telegraf.Metric
+}
+
+// NewTracker returns a tracker allowing up to maxUndelivered messages in
+// flight and starts processing the deliveries.
+func NewTracker(acc telegraf.Accumulator, maxUndelivered int) *Tracker {
+	if maxUndelivered < 1 {
+		maxUndelivered = 1
+	}
+
+	t := &Tracker{
+		DrainTimeout: DefaultDrainTimeout,
+		acc:          acc.WithTracking(maxUndelivered),
+		sem:          make(chan struct{}, maxUndelivered),
+		pending:      make(map[telegraf.TrackingID]*message, maxUndelivered),
+		stopping:     make(chan struct{}),
+		quit:         make(chan struct{}),
+		idle:         make(chan struct{}, 1),
+	}
+
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		t.run()
+	}()
+	return t
+}
+
+// Reserve blocks until there is a free slot for a message.  The slot is
+// used by Add or given back with Release.
+func (t *Tracker) Reserve(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.stopping:
+		return ErrStopped
+	case t.sem <- struct{}{}:
+		return nil
+	}
+}
+
+// Release gives back a reserved slot that was not used.
+func (t *Tracker) Release() {
+	<-t.sem
+}
+
+// Add adds the metrics of a message in a reserved slot.  The onDelivery
+// function, if not nil, is called once the metrics are delivered.  A message
+// without metrics is delivered right away.
+func (t *Tracker) Add(metrics []telegraf.Metric, onDelivery DeliveryFunc) telegraf.TrackingID {
+	msg := &message{onDelivery: onDelivery}
+	if t.Redeliver {
+		msg.backup = copyMetrics(metrics)
+	}
+
+	// Hold the lock until the message is stored, the metrics may be
+	// delivered before AddTrackingMetricGroup returns.
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	id := t.acc.AddTrackingMetricGroup(metrics)
+	t.pending[id] = msg
+	return id
+}
+
+// Undelivered returns the number of messages in flight.
+func (t *Tracker) Undelivered() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.pending)
+}
+
+// Stop stops reserving slots and waits up to DrainTimeout for the messages
+// in flight to be delivered.  Messages still undelivered afterwards are
+// never acknowledged and left to the broker to redeliver.
+//
+// The agent stops service inputs one after another, so each consumer with
+// messages in flight can delay the shutdown by up to its DrainTimeout.
+func (t *Tracker) Stop() {
+	t.stopOnce.Do(func() {
+		close(t.stopping)
+
+		timeout := time.NewTimer(t.DrainTimeout)
+		defer timeout.Stop()
+
+	drain:
+		for t.Undelivered() > 0 {
+			select {
+			case <-t.idle:
+			case <-timeout.C:
+				break drain
+			}
+		}
+
+		close(t.quit)
+		t.wg.Wait()
+	})
+}
+
+func (t *Tracker) run() {
+	for {
+		select {
+		case <-t.quit:
+			return
+		case info := <-t.acc.Delivered():
+			t.onDelivery(info)
+		}
+	}
+}
+
+func (t *Tracker) onDelivery(info telegraf.DeliveryInfo) {
+	t.mu.Lock()
+	msg, ok := t.pending[info.ID()]
+	if !ok {
+		t.mu.Unlock()
+		return
+	}
+	delete(t.pending, info.ID())
+
+	if !info.Delivered() && msg.backup != nil {
+		metrics := msg.backup
+		msg.backup = copyMetrics(metrics)
+		id := t.acc.AddTrackingMetricGroup(metrics)
+		t.pending[id] = msg
+		t.mu.Unlock()
+		return
+	}
+	idle := len(t.pending) == 0
+	t.mu.Unlock()
+
+	if msg.onDelivery != nil {
+		msg.onDelivery(info.Delivered())
+	}
+	t.Release()
+
+	if idle {
+		select {
+		case t.idle <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func copyMetrics(in []telegraf.Metric) []telegraf.Metric {
+	metrics := make([]telegraf.Metric, 0, len(in))
+	for _, m := range in {
+		metrics = append(metrics, m.Copy())
+	}
+	return metrics
+}
@@ -0,0 +1,115 @@
+package delivery
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func newMetrics() []telegraf.Metric {
+	return []telegraf.Metric{
+		testutil.MustMetric("cpu", map[string]string{}, map[string]interface{}{"value": 42}, time.Unix(0, 0)),
+	}
+}
+
+func TestTracker_Deliver(t *testing.T) {
+	acc := &testutil.TrackingAccumulator{}
+	tracker := NewTracker(acc, 1)
+	defer tracker.Stop()
+
+	ctx := context.Background()
+	results := make(chan bool, 2)
+	onDelivery := func(delivered bool) { results <- delivered }
+
+	require.NoError(t, tracker.Reserve(ctx))
+	tracker.Add(newMetrics(), onDelivery)
+
+	// The slot is in use until the message is delivered.
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	require.Equal(t, context.DeadlineExceeded, tracker.Reserve(timeout))
+	require.Equal(t, 1, tracker.Undelivered())
+
+	acc.NextTracked(t).Accept()
+	require.True(t, <-results)
+	require.NoError(t, tracker.Reserve(ctx))
+
+	tracker.Add(newMetrics(), onDelivery)
+	acc.NextTracked(t).Reject()
+	require.False(t, <-results)
+	require.Equal(t, 0, tracker.Undelivered())
+}
+
+func TestTracker_Empty(t *testing.T) {
+	acc := &testutil.TrackingAccumulator{}
+	tracker := NewTracker(acc, 1)
+	defer tracker.Stop()
+
+	results := make(chan bool, 1)
+	require.NoError(t, tracker.Reserve(context.Background()))
+	tracker.Add(nil, func(delivered bool) { results <- delivered })
+	require.True(t, <-results)
+}
+
+func TestTracker_Redeliver(t *testing.T) {
+	acc := &testutil.TrackingAccumulator{}
+	tracker := NewTracker(acc, 1)
+	tracker.Redeliver = true
+	defer tracker.Stop()
+
+	results := make(chan bool, 1)
+	require.NoError(t, tracker.Reserve(context.Background()))
+	tracker.Add(newMetrics(), func(delivered bool) { results <- delivered })
+
+	// The rejected metric is added again.
+	acc.NextTracked(t).Reject()
+	m := acc.NextTracked(t)
+	require.Empty(t, results)
+	testutil.RequireMetricsEqual(t, newMetrics(), []telegraf.Metric{m})
+
+	m.Accept()
+	require.True(t, <-results)
+}
+
+func TestTracker_StopDrains(t *testing.T) {
+	acc := &testutil.TrackingAccumulator{}
+	tracker := NewTracker(acc, 2)
+	tracker.DrainTimeout = time.Minute
+
+	results := make(chan bool, 1)
+	require.NoError(t, tracker.Reserve(context.Background()))
+	tracker.Add(newMetrics(), func(delivered bool) { results <- delivered })
+
+	stopped := make(chan struct{})
+	go func() {
+		tracker.Stop()
+		close(stopped)
+	}()
+
+	// No more slots are handed out once stopping.
+	require.Eventually(t, func() bool {
+		return tracker.Reserve(context.Background()) == ErrStopped
+	}, time.Second, time.Millisecond)
+
+	acc.NextTracked(t).Accept()
+	require.True(t, <-results)
+	<-stopped
+}
+
+func TestTracker_StopTimeout(t *testing.T) {
+	acc := &testutil.TrackingAccumulator{}
+	tracker := NewTracker(acc, 1)
+	tracker.DrainTimeout = 10 * time.Millisecond
+
+	called := false
+	require.NoError(t, tracker.Reserve(context.Background()))
+	tracker.Add(newMetrics(), func(bool) { called = true })
+
+	tracker.Stop()
+	require.Equal(t, 1, tracker.Undelivered())
+	require.False(t, called)
+}
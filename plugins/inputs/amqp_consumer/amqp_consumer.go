@@ -11,6 +11,7 @@ import (
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/delivery"
 	"github.com/influxdata/telegraf/plugins/common/tls"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/parsers"
@@ -21,9 +22,6 @@ const (
 	defaultMaxUndeliveredMessages = 1000
 )
 
-type empty struct{}
-type semaphore chan empty
-
 // AMQPConsumer is the top level struct for this plugin
 type AMQPConsumer struct {
 	URL                    string            `toml:"url"` // deprecated in 1.7; use brokers
@@ -36,6 +34,7 @@ type AMQPConsumer struct {
 	ExchangePassive        bool              `toml:"exchange_passive"`
 	ExchangeArguments      map[string]string `toml:"exchange_arguments"`
 	MaxUndeliveredMessages int               `toml:"max_undelivered_messages"`
+	RequeueUndelivered     bool              `toml:"requeue_undelivered"`
 
 	// Queue Name
 	Queue           string `toml:"queue"`
@@ -56,8 +55,7 @@ type AMQPConsumer struct {
 	ContentEncoding string `toml:"content_encoding"`
 	Log             telegraf.Logger
 
-	deliveries map[telegraf.TrackingID]amqp.Delivery
-
+	tracker *delivery.Tracker
 	parser  parsers.Parser
 	conn    *amqp.Connection
 	wg      *sync.WaitGroup
@@ -144,6 +142,13 @@ func (a *AMQPConsumer) SampleConfig() string {
   ## waiting until the next flush_interval.
   # max_undelivered_messages = 1000
 
+  ## Messages are acknowledged once their metrics are written.  Messages whose
+  ## metrics are dropped, for example by a full output buffer, are rejected
+  ## and discarded by the broker.  Set to true to return them to the queue
+  ## instead; a message whose metrics are always dropped is then redelivered
+  ## forever.
+  # requeue_undelivered = false
+
   ## Auth method. PLAIN and EXTERNAL are supported
   ## Using EXTERNAL requires enabling the rabbitmq_auth_mechanism_ssl plugin as
   ## described here: https://www.rabbitmq.com/plugins.html
@@ -227,6 +232,8 @@ func (a *AMQPConsumer) Start(acc telegraf.Accumulator) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	a.cancel = cancel
 
+	a.tracker = delivery.NewTracker(acc, a.MaxUndeliveredMessages)
+
 	a.wg = &sync.WaitGroup{}
 	a.wg.Add(1)
 	go func() {
@@ -443,44 +450,32 @@ func declareQueue(
 }
 
 // Read messages from queue and add them to the Accumulator
-func (a *AMQPConsumer) process(ctx context.Context, msgs <-chan amqp.Delivery, ac telegraf.Accumulator) {
-	a.deliveries = make(map[telegraf.TrackingID]amqp.Delivery)
-
-	acc := ac.WithTracking(a.MaxUndeliveredMessages)
-	sem := make(semaphore, a.MaxUndeliveredMessages)
-
+func (a *AMQPConsumer) process(ctx context.Context, msgs <-chan amqp.Delivery, acc telegraf.Accumulator) {
 	for {
+		err := a.tracker.Reserve(ctx)
+		if err != nil {
+			return
+		}
+
 		select {
 		case <-ctx.Done():
+			a.tracker.Release()
 			return
-		case track := <-acc.Delivered():
-			if a.onDelivery(track) {
-				<-sem
-			}
-		case sem <- empty{}:
-			select {
-			case <-ctx.Done():
+		case d, ok := <-msgs:
+			if !ok {
+				a.tracker.Release()
 				return
-			case track := <-acc.Delivered():
-				if a.onDelivery(track) {
-					<-sem
-					<-sem
-				}
-			case d, ok := <-msgs:
-				if !ok {
-					return
-				}
-				err := a.onMessage(acc, d)
-				if err != nil {
-					acc.AddError(err)
-					<-sem
-				}
+			}
+			err := a.onMessage(d)
+			if err != nil {
+				acc.AddError(err)
+				a.tracker.Release()
 			}
 		}
 	}
 }
 
-func (a *AMQPConsumer) onMessage(acc telegraf.TrackingAccumulator, d amqp.Delivery) error {
+func (a *AMQPConsumer) onMessage(d amqp.Delivery) error {
 	onError := func() {
 		// Discard the message from the queue; will never be able to process
 		// this message.
@@ -503,39 +498,36 @@ func (a *AMQPConsumer) onMessage(acc telegraf.TrackingAccumulator, d amqp.Delive
 		return err
 	}
 
-	id := acc.AddTrackingMetricGroup(metrics)
-	a.deliveries[id] = d
+	a.tracker.Add(metrics, func(delivered bool) {
+		a.onDelivery(d, delivered)
+	})
 	return nil
 }
 
-func (a *AMQPConsumer) onDelivery(track telegraf.DeliveryInfo) bool {
-	delivery, ok := a.deliveries[track.ID()]
-	if !ok {
-		// Added by a previous connection
-		return false
-	}
-
-	if track.Delivered() {
-		err := delivery.Ack(false)
-		if err != nil {
-			a.Log.Errorf("Unable to ack written delivery: %d: %v", delivery.DeliveryTag, err)
-			a.conn.Close()
-		}
+// onDelivery acknowledges the delivery once written, undelivered deliveries
+// are rejected and only requeued if requeue_undelivered is set.
+func (a *AMQPConsumer) onDelivery(d amqp.Delivery, delivered bool) {
+	var err error
+	if delivered {
+		err = d.Ack(false)
 	} else {
-		err := delivery.Reject(false)
-		if err != nil {
-			a.Log.Errorf("Unable to reject failed delivery: %d: %v", delivery.DeliveryTag, err)
-			a.conn.Close()
-		}
+		err = d.Reject(a.RequeueUndelivered)
 	}
 
-	delete(a.deliveries, track.ID())
-	return true
+	switch {
+	case err == amqp.ErrClosed:
+		// Received on a previous connection, the broker redelivers it.
+		a.Log.Debugf("Unable to acknowledge delivery %d from a closed channel", d.DeliveryTag)
+	case err != nil:
+		a.Log.Errorf("Unable to acknowledge delivery: %d: %v", d.DeliveryTag, err)
+		a.conn.Close()
+	}
 }
 
 func (a *AMQPConsumer) Stop() {
 	a.cancel()
 	a.wg.Wait()
+	a.tracker.Stop()
 	err := a.conn.Close()
 	if err != nil && err != amqp.ErrClosed {
 		a.Log.Errorf("Error closing AMQP connection: %s", err)
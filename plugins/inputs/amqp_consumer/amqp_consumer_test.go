@@ -0,0 +1,115 @@
+package amqp_consumer
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/delivery"
+	"github.com/influxdata/telegraf/plugins/parsers/value"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/require"
+)
+
+// acknowledger records how the deliveries are acknowledged.
+type acknowledger struct {
+	sync.Mutex
+	acked    []uint64
+	rejected []uint64
+	requeued []uint64
+}
+
+func (a *acknowledger) Ack(tag uint64, multiple bool) error {
+	a.Lock()
+	defer a.Unlock()
+	a.acked = append(a.acked, tag)
+	return nil
+}
+
+func (a *acknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	return a.Reject(tag, requeue)
+}
+
+func (a *acknowledger) Reject(tag uint64, requeue bool) error {
+	a.Lock()
+	defer a.Unlock()
+	if requeue {
+		a.requeued = append(a.requeued, tag)
+	} else {
+		a.rejected = append(a.rejected, tag)
+	}
+	return nil
+}
+
+func (a *acknowledger) count() int {
+	a.Lock()
+	defer a.Unlock()
+	return len(a.acked) + len(a.rejected) + len(a.requeued)
+}
+
+func TestProcess_Delivery(t *testing.T) {
+	tests := []struct {
+		name     string
+		requeue  bool
+		rejected []uint64
+		requeued []uint64
+	}{
+		{
+			name:     "rejected messages are discarded",
+			rejected: []uint64{2},
+		},
+		{
+			name:     "rejected messages are requeued",
+			requeue:  true,
+			requeued: []uint64{2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoder, err := internal.NewContentDecoder("identity")
+			require.NoError(t, err)
+
+			acc := &testutil.TrackingAccumulator{}
+			plugin := &AMQPConsumer{
+				RequeueUndelivered: tt.requeue,
+				Log:                testutil.Logger{},
+				parser:             &value.ValueParser{MetricName: "cpu", DataType: "int"},
+				decoder:            decoder,
+				tracker:            delivery.NewTracker(acc, 1),
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			msgs := make(chan amqp.Delivery)
+			done := make(chan struct{})
+			go func() {
+				plugin.process(ctx, msgs, acc)
+				close(done)
+			}()
+
+			ack := &acknowledger{}
+			msgs <- amqp.Delivery{Acknowledger: ack, DeliveryTag: 1, Body: []byte("42")}
+			acc.NextTracked(t).Accept()
+			require.Eventually(t, func() bool { return ack.count() == 1 }, time.Second, time.Millisecond)
+
+			msgs <- amqp.Delivery{Acknowledger: ack, DeliveryTag: 2, Body: []byte("43")}
+			acc.NextTracked(t).Reject()
+			require.Eventually(t, func() bool { return ack.count() == 2 }, time.Second, time.Millisecond)
+
+			// A message that cannot be parsed is removed from the queue.
+			msgs <- amqp.Delivery{Acknowledger: ack, DeliveryTag: 3, Body: []byte("not a number")}
+			require.Eventually(t, func() bool { return ack.count() == 3 }, time.Second, time.Millisecond)
+
+			cancel()
+			<-done
+			plugin.tracker.Stop()
+
+			require.Equal(t, []uint64{1, 3}, ack.acked)
+			require.Equal(t, tt.rejected, ack.rejected)
+			require.Equal(t, tt.requeued, ack.requeued)
+		})
+	}
+}
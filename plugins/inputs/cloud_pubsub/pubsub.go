@@ -11,21 +11,17 @@ import (
 	"cloud.google.com/go/pubsub"
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/delivery"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/parsers"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/option"
 )
 
-type empty struct{}
-type semaphore chan empty
-
 const defaultMaxUndeliveredMessages = 1000
 const defaultRetryDelaySeconds = 5
 
 type PubSub struct {
-	sync.Mutex
-
 	CredentialsFile string `toml:"credentials_file"`
 	Project         string `toml:"project"`
 	Subscription    string `toml:"subscription"`
@@ -50,12 +46,10 @@ type PubSub struct {
 
 	cancel context.CancelFunc
 
-	parser parsers.Parser
-	wg     *sync.WaitGroup
-	acc    telegraf.TrackingAccumulator
-
-	undelivered map[telegraf.TrackingID]message
-	sem         semaphore
+	parser  parsers.Parser
+	wg      *sync.WaitGroup
+	acc     telegraf.Accumulator
+	tracker *delivery.Tracker
 }
 
 func (ps *PubSub) Description() string {
@@ -77,8 +71,8 @@ func (ps *PubSub) SetParser(parser parsers.Parser) {
 }
 
 // Start initializes the plugin and processing messages from Google PubSub.
-// Two goroutines are started - one pulling for the subscription, one
-// receiving delivery notifications from the accumulator.
+// A goroutine is started pulling for the subscription, the messages are
+// acknowledged once delivered.
 func (ps *PubSub) Start(ac telegraf.Accumulator) error {
 	if ps.Subscription == "" {
 		return fmt.Errorf(`"subscription" is required`)
@@ -88,8 +82,8 @@ func (ps *PubSub) Start(ac telegraf.Accumulator) error {
 		return fmt.Errorf(`"project" is required`)
 	}
 
-	ps.sem = make(semaphore, ps.MaxUndeliveredMessages)
-	ps.acc = ac.WithTracking(ps.MaxUndeliveredMessages)
+	ps.acc = ac
+	ps.tracker = delivery.NewTracker(ac, ps.MaxUndeliveredMessages)
 
 	// Create top-level context with cancel that will be called on Stop().
 	ctx, cancel := context.WithCancel(context.Background())
@@ -106,13 +100,6 @@ func (ps *PubSub) Start(ac telegraf.Accumulator) error {
 	}
 
 	ps.wg = &sync.WaitGroup{}
-	// Start goroutine to handle delivery notifications from accumulator.
-	ps.wg.Add(1)
-	go func() {
-		defer ps.wg.Done()
-		ps.waitForDelivery(ctx)
-	}()
-
 	// Start goroutine for subscription receiver.
 	ps.wg.Add(1)
 	go func() {
@@ -128,6 +115,7 @@ func (ps *PubSub) Start(ac telegraf.Accumulator) error {
 func (ps *PubSub) Stop() {
 	ps.cancel()
 	ps.wg.Wait()
+	ps.tracker.Stop()
 }
 
 // startReceiver is called within a goroutine and manages keeping a
@@ -196,51 +184,20 @@ func (ps *PubSub) onMessage(ctx context.Context, msg message) error {
 		return nil
 	}
 
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case ps.sem <- empty{}:
-		break
-	}
-
-	ps.Lock()
-	defer ps.Unlock()
-
-	id := ps.acc.AddTrackingMetricGroup(metrics)
-	if ps.undelivered == nil {
-		ps.undelivered = make(map[telegraf.TrackingID]message)
+	if err := ps.tracker.Reserve(ctx); err != nil {
+		msg.Nack()
+		return err
 	}
-	ps.undelivered[id] = msg
 
-	return nil
-}
-
-func (ps *PubSub) waitForDelivery(parentCtx context.Context) {
-	for {
-		select {
-		case <-parentCtx.Done():
-			return
-		case info := <-ps.acc.Delivered():
-			<-ps.sem
-			msg := ps.removeDelivered(info.ID())
-
-			if msg != nil {
-				msg.Ack()
-			}
+	// Rejected messages are redelivered by Pub/Sub.
+	ps.tracker.Add(metrics, func(delivered bool) {
+		if delivered {
+			msg.Ack()
+		} else {
+			msg.Nack()
 		}
-	}
-}
-
-func (ps *PubSub) removeDelivered(id telegraf.TrackingID) message {
-	ps.Lock()
-	defer ps.Unlock()
-
-	msg, ok := ps.undelivered[id]
-	if !ok {
-		return nil
-	}
-	delete(ps.undelivered, id)
-	return msg
+	})
+	return nil
 }
 
 func (ps *PubSub) getPubSubClient() (*pubsub.Client, error) {
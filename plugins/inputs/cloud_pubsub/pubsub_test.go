@@ -237,3 +237,41 @@ func validateTestInfluxMetric(t *testing.T, m *testutil.Metric) {
 	assert.Equal(t, 23422.0, m.Fields["value"])
 	assert.Equal(t, int64(1422568543702900257), m.Time.UnixNano())
 }
+
+func TestRunDelivery(t *testing.T) {
+	subId := "sub-run-delivery"
+
+	testParser, _ := parsers.NewInfluxParser()
+
+	sub := &stubSub{
+		id:       subId,
+		messages: make(chan *testMsg, 100),
+	}
+	sub.receiver = testMessagesReceive(sub)
+
+	ps := &PubSub{
+		Log:                    testutil.Logger{},
+		parser:                 testParser,
+		stubSub:                func() subscription { return sub },
+		Project:                "projectIDontMatterForTests",
+		Subscription:           subId,
+		MaxUndeliveredMessages: 1,
+	}
+
+	acc := &testutil.TrackingAccumulator{}
+	if err := ps.Start(acc); err != nil {
+		t.Fatalf("test PubSub failed to start: %s", err)
+	}
+	defer ps.Stop()
+
+	// The message is acknowledged once its metrics are written.
+	testTracker := &testTracker{}
+	sub.messages <- &testMsg{value: msgInflux, tracker: testTracker}
+	acc.NextTracked(t).Accept()
+	testTracker.WaitForAck(1)
+
+	// Pub/Sub redelivers the messages whose metrics are rejected.
+	sub.messages <- &testMsg{value: msgInflux, tracker: testTracker}
+	acc.NextTracked(t).Reject()
+	testTracker.WaitForNack(1)
+}
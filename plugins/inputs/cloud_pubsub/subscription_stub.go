@@ -109,6 +109,9 @@ func (t *testTracker) Ack() {
 	defer t.Unlock()
 
 	t.numAcks++
+	if t.Cond != nil {
+		t.Broadcast()
+	}
 }
 
 func (t *testTracker) Nack() {
@@ -116,4 +119,7 @@ func (t *testTracker) Nack() {
 	defer t.Unlock()
 
 	t.numNacks++
+	if t.Cond != nil {
+		t.Broadcast()
+	}
 }
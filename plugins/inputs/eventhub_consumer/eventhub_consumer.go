@@ -3,13 +3,13 @@ package eventhub
 import (
 	"context"
 	"fmt"
-	"sync"
 	"time"
 
 	eventhub "github.com/Azure/azure-event-hubs-go/v3"
 	"github.com/Azure/azure-event-hubs-go/v3/persist"
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/delivery"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/parsers"
 )
@@ -18,9 +18,6 @@ const (
 	defaultMaxUndeliveredMessages = 1000
 )
 
-type empty struct{}
-type semaphore chan empty
-
 // EventHub is the top level struct for this plugin
 type EventHub struct {
 	// Configuration
@@ -56,10 +53,9 @@ type EventHub struct {
 	// Azure
 	hub    *eventhub.Hub
 	cancel context.CancelFunc
-	wg     sync.WaitGroup
 
-	parser parsers.Parser
-	in     chan []telegraf.Metric
+	parser  parsers.Parser
+	tracker *delivery.Tracker
 }
 
 // SampleConfig is provided here
@@ -194,18 +190,14 @@ func (e *EventHub) Init() (err error) {
 
 // Start the EventHub ServiceInput
 func (e *EventHub) Start(acc telegraf.Accumulator) error {
-	e.in = make(chan []telegraf.Metric)
+	// The event is accepted once onMessage returns, rejected metrics are
+	// added again instead.
+	e.tracker = delivery.NewTracker(acc, e.MaxUndeliveredMessages)
+	e.tracker.Redeliver = true
 
 	var ctx context.Context
 	ctx, e.cancel = context.WithCancel(context.Background())
 
-	// Start tracking
-	e.wg.Add(1)
-	go func() {
-		defer e.wg.Done()
-		e.startTracking(ctx, acc)
-	}()
-
 	// Configure receiver options
 	receiveOpts, err := e.configureReceiver()
 	if err != nil {
@@ -261,84 +253,18 @@ func (e *EventHub) configureReceiver() ([]eventhub.ReceiveOption, error) {
 // Event is immediately accepted and the offset is updated.  If an error is
 // returned the Event is marked for redelivery.
 func (e *EventHub) onMessage(ctx context.Context, event *eventhub.Event) error {
-	metrics, err := e.createMetrics(event)
-	if err != nil {
+	if err := e.tracker.Reserve(ctx); err != nil {
 		return err
 	}
 
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case e.in <- metrics:
-		return nil
-	}
-}
-
-// OnDelivery returns true if a new slot has opened up in the TrackingAccumulator.
-func (e *EventHub) onDelivery(
-	acc telegraf.TrackingAccumulator,
-	groups map[telegraf.TrackingID][]telegraf.Metric,
-	track telegraf.DeliveryInfo,
-) bool {
-	if track.Delivered() {
-		delete(groups, track.ID())
-		return true
-	}
-
-	// The metric was already accepted when onMessage completed, so we can't
-	// fallback on redelivery from Event Hub.  Add a new copy of the metric for
-	// reprocessing.
-	metrics, ok := groups[track.ID()]
-	delete(groups, track.ID())
-	if !ok {
-		// The metrics should always be found, this message indicates a programming error.
-		e.Log.Errorf("Could not find delivery: %d", track.ID())
-		return true
-	}
-
-	backup := deepCopyMetrics(metrics)
-	id := acc.AddTrackingMetricGroup(metrics)
-	groups[id] = backup
-	return false
-}
-
-func (e *EventHub) startTracking(ctx context.Context, ac telegraf.Accumulator) {
-	acc := ac.WithTracking(e.MaxUndeliveredMessages)
-	sem := make(semaphore, e.MaxUndeliveredMessages)
-	groups := make(map[telegraf.TrackingID][]telegraf.Metric, e.MaxUndeliveredMessages)
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case track := <-acc.Delivered():
-			if e.onDelivery(acc, groups, track) {
-				<-sem
-			}
-		case sem <- empty{}:
-			select {
-			case <-ctx.Done():
-				return
-			case track := <-acc.Delivered():
-				if e.onDelivery(acc, groups, track) {
-					<-sem
-					<-sem
-				}
-			case metrics := <-e.in:
-				backup := deepCopyMetrics(metrics)
-				id := acc.AddTrackingMetricGroup(metrics)
-				groups[id] = backup
-			}
-		}
+	metrics, err := e.createMetrics(event)
+	if err != nil {
+		e.tracker.Release()
+		return err
 	}
-}
 
-func deepCopyMetrics(in []telegraf.Metric) []telegraf.Metric {
-	metrics := make([]telegraf.Metric, 0, len(in))
-	for _, m := range in {
-		metrics = append(metrics, m.Copy())
-	}
-	return metrics
+	e.tracker.Add(metrics, nil)
+	return nil
 }
 
 // CreateMetrics returns the Metrics from the Event.
@@ -412,7 +338,7 @@ func (e *EventHub) Stop() {
 		e.Log.Errorf("Error closing Event Hub connection: %v", err)
 	}
 	e.cancel()
-	e.wg.Wait()
+	e.tracker.Stop()
 }
 
 func init() {
@@ -0,0 +1,50 @@
+package eventhub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	eventhub "github.com/Azure/azure-event-hubs-go/v3"
+	"github.com/influxdata/telegraf/plugins/common/delivery"
+	"github.com/influxdata/telegraf/plugins/parsers/value"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnMessage_Delivery(t *testing.T) {
+	acc := &testutil.TrackingAccumulator{}
+	e := &EventHub{
+		Log:     testutil.Logger{},
+		parser:  &value.ValueParser{MetricName: "cpu", DataType: "int"},
+		tracker: delivery.NewTracker(acc, 1),
+	}
+	e.tracker.Redeliver = true
+	defer e.tracker.Stop()
+
+	ctx := context.Background()
+	newEvent := func(data string) *eventhub.Event {
+		return &eventhub.Event{Data: []byte(data), SystemProperties: &eventhub.SystemProperties{}}
+	}
+
+	require.NoError(t, e.onMessage(ctx, newEvent("42")))
+
+	// Event Hubs cannot redeliver the event, the rejected metrics are added
+	// again instead.
+	acc.NextTracked(t).Reject()
+	m := acc.NextTracked(t)
+	require.Equal(t, int64(42), m.Fields()["value"])
+
+	// The next event waits until the first one is delivered.
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	require.Equal(t, context.DeadlineExceeded, e.onMessage(timeout, newEvent("43")))
+
+	m.Accept()
+	require.NoError(t, e.onMessage(ctx, newEvent("43")))
+	acc.NextTracked(t).Accept()
+
+	// An event that cannot be parsed gives back its slot.
+	require.Error(t, e.onMessage(ctx, newEvent("not a number")))
+	require.NoError(t, e.onMessage(ctx, newEvent("44")))
+}
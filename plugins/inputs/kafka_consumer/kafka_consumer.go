@@ -3,7 +3,6 @@ package kafka_consumer
 import (
 	"context"
 	"fmt"
-	"log"
 	"strings"
 	"sync"
 	"time"
@@ -11,6 +10,7 @@ import (
 	"github.com/Shopify/sarama"
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/delivery"
 	"github.com/influxdata/telegraf/plugins/common/kafka"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/parsers"
@@ -112,9 +112,6 @@ const (
 	reconnectDelay                = 5 * time.Second
 )
 
-type empty struct{}
-type semaphore chan empty
-
 type KafkaConsumer struct {
 	Brokers                []string `toml:"brokers"`
 	ConsumerGroup          string   `toml:"consumer_group"`
@@ -234,6 +231,7 @@ func (k *KafkaConsumer) Start(acc telegraf.Accumulator) error {
 			handler.MaxMessageLen = k.MaxMessageLen
 			handler.TopicTag = k.TopicTag
 			err := k.consumer.Consume(ctx, k.Topics, handler)
+			handler.stop()
 			if err != nil {
 				acc.AddError(err)
 				internal.SleepContext(ctx, reconnectDelay)
@@ -265,19 +263,16 @@ func (k *KafkaConsumer) Stop() {
 	k.wg.Wait()
 }
 
-// Message is an aggregate type binding the Kafka message and the session so
-// that offsets can be updated.
-type Message struct {
-	message *sarama.ConsumerMessage
-	session sarama.ConsumerGroupSession
-}
-
 func NewConsumerGroupHandler(acc telegraf.Accumulator, maxUndelivered int, parser parsers.Parser) *ConsumerGroupHandler {
+	// Kafka only tracks the offset of the partitions, rejected metrics are
+	// added again instead.
+	tracker := delivery.NewTracker(acc, maxUndelivered)
+	tracker.Redeliver = true
+
 	handler := &ConsumerGroupHandler{
-		acc:         acc.WithTracking(maxUndelivered),
-		sem:         make(chan empty, maxUndelivered),
-		undelivered: make(map[telegraf.TrackingID]Message, maxUndelivered),
-		parser:      parser,
+		acc:     acc,
+		tracker: tracker,
+		parser:  parser,
 	}
 	return handler
 }
@@ -287,74 +282,23 @@ type ConsumerGroupHandler struct {
 	MaxMessageLen int
 	TopicTag      string
 
-	acc    telegraf.TrackingAccumulator
-	sem    semaphore
-	parser parsers.Parser
-	wg     sync.WaitGroup
-	cancel context.CancelFunc
-
-	mu          sync.Mutex
-	undelivered map[telegraf.TrackingID]Message
+	acc     telegraf.Accumulator
+	tracker *delivery.Tracker
+	parser  parsers.Parser
 }
 
-// Setup is called once when a new session is opened.  It setups up the handler
-// and begins processing delivered messages.
+// Setup is called once when a new session is opened.
 func (h *ConsumerGroupHandler) Setup(sarama.ConsumerGroupSession) error {
-	h.undelivered = make(map[telegraf.TrackingID]Message)
-
-	ctx, cancel := context.WithCancel(context.Background())
-	h.cancel = cancel
-
-	h.wg.Add(1)
-	go func() {
-		defer h.wg.Done()
-		h.run(ctx)
-	}()
 	return nil
 }
 
-// Run processes any delivered metrics during the lifetime of the session.
-func (h *ConsumerGroupHandler) run(ctx context.Context) error {
-	for {
-		select {
-		case <-ctx.Done():
-			return nil
-		case track := <-h.acc.Delivered():
-			h.onDelivery(track)
-		}
-	}
-}
-
-func (h *ConsumerGroupHandler) onDelivery(track telegraf.DeliveryInfo) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-
-	msg, ok := h.undelivered[track.ID()]
-	if !ok {
-		log.Printf("E! [inputs.kafka_consumer] Could not mark message delivered: %d", track.ID())
-		return
-	}
-
-	if track.Delivered() {
-		msg.session.MarkMessage(msg.message, "")
-	}
-
-	delete(h.undelivered, track.ID())
-	<-h.sem
-}
-
 // Reserve blocks until there is an available slot for a new message.
 func (h *ConsumerGroupHandler) Reserve(ctx context.Context) error {
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case h.sem <- empty{}:
-		return nil
-	}
+	return h.tracker.Reserve(ctx)
 }
 
 func (h *ConsumerGroupHandler) release() {
-	<-h.sem
+	h.tracker.Release()
 }
 
 // Handle processes a message and if successful saves it to be acknowledged
@@ -379,10 +323,9 @@ func (h *ConsumerGroupHandler) Handle(session sarama.ConsumerGroupSession, msg *
 		}
 	}
 
-	h.mu.Lock()
-	id := h.acc.AddTrackingMetricGroup(metrics)
-	h.undelivered[id] = Message{session: session, message: msg}
-	h.mu.Unlock()
+	h.tracker.Add(metrics, func(bool) {
+		session.MarkMessage(msg, "")
+	})
 	return nil
 }
 
@@ -399,9 +342,11 @@ func (h *ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 
 		select {
 		case <-ctx.Done():
+			h.release()
 			return nil
 		case msg, ok := <-claim.Messages():
 			if !ok {
+				h.release()
 				return nil
 			}
 			err := h.Handle(session, msg)
@@ -412,14 +357,19 @@ func (h *ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 	}
 }
 
-// Cleanup stops the internal goroutine and is called after all ConsumeClaim
-// functions have completed.
+// Cleanup waits for the undelivered messages so their offsets are committed
+// with the session and is called after all ConsumeClaim functions have
+// completed.
 func (h *ConsumerGroupHandler) Cleanup(sarama.ConsumerGroupSession) error {
-	h.cancel()
-	h.wg.Wait()
+	h.tracker.Stop()
 	return nil
 }
 
+// stop stops the tracking if the session failed to start.
+func (h *ConsumerGroupHandler) stop() {
+	h.tracker.Stop()
+}
+
 func init() {
 	inputs.Add("kafka_consumer", func() telegraf.Input {
 		return &KafkaConsumer{}
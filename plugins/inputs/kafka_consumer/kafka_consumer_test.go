@@ -2,6 +2,7 @@ package kafka_consumer
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
@@ -199,6 +200,9 @@ func TestStartStop(t *testing.T) {
 
 type FakeConsumerGroupSession struct {
 	ctx context.Context
+
+	sync.Mutex
+	marked []*sarama.ConsumerMessage
 }
 
 func (s *FakeConsumerGroupSession) Claims() map[string][]int32 {
@@ -222,6 +226,15 @@ func (s *FakeConsumerGroupSession) ResetOffset(topic string, partition int32, of
 }
 
 func (s *FakeConsumerGroupSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.Lock()
+	defer s.Unlock()
+	s.marked = append(s.marked, msg)
+}
+
+func (s *FakeConsumerGroupSession) markedMessages() int {
+	s.Lock()
+	defer s.Unlock()
+	return len(s.marked)
 }
 
 func (s *FakeConsumerGroupSession) Context() context.Context {
@@ -407,3 +420,30 @@ func TestConsumerGroupHandler_Handle(t *testing.T) {
 		})
 	}
 }
+
+func TestConsumerGroupHandler_Delivery(t *testing.T) {
+	acc := &testutil.TrackingAccumulator{}
+	parser := &value.ValueParser{MetricName: "cpu", DataType: "int"}
+	cg := NewConsumerGroupHandler(acc, 1, parser)
+	defer cg.stop()
+
+	ctx := context.Background()
+	session := &FakeConsumerGroupSession{ctx: ctx}
+
+	require.NoError(t, cg.Reserve(ctx))
+	require.NoError(t, cg.Handle(session, &sarama.ConsumerMessage{Topic: "telegraf", Value: []byte("42")}))
+
+	// Kafka cannot redeliver the message, the rejected metrics are added
+	// again and the offset is only marked once they are written.
+	acc.NextTracked(t).Reject()
+	m := acc.NextTracked(t)
+	require.Equal(t, 0, session.markedMessages())
+
+	m.Accept()
+	require.Eventually(t, func() bool {
+		return session.markedMessages() == 1
+	}, time.Second, time.Millisecond)
+
+	// The slot is free again.
+	require.NoError(t, cg.Reserve(ctx))
+}
@@ -15,6 +15,7 @@ import (
 
 	"github.com/influxdata/telegraf"
 	internalaws "github.com/influxdata/telegraf/config/aws"
+	"github.com/influxdata/telegraf/plugins/common/delivery"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/parsers"
 )
@@ -41,18 +42,15 @@ type (
 
 		Log telegraf.Logger
 
-		cons   *consumer.Consumer
-		parser parsers.Parser
-		cancel context.CancelFunc
-		ctx    context.Context
-		acc    telegraf.TrackingAccumulator
-		sem    chan struct{}
+		cons    *consumer.Consumer
+		parser  parsers.Parser
+		cancel  context.CancelFunc
+		ctx     context.Context
+		tracker *delivery.Tracker
 
 		checkpoint    consumer.Checkpoint
 		checkpoints   map[string]checkpoint
-		records       map[telegraf.TrackingID]string
 		checkpointTex sync.Mutex
-		recordsTex    sync.Mutex
 		wg            sync.WaitGroup
 
 		lastSeqNum *big.Int
@@ -187,33 +185,26 @@ func (k *KinesisConsumer) connect(ac telegraf.Accumulator) error {
 
 	k.cons = cons
 
-	k.acc = ac.WithTracking(k.MaxUndeliveredMessages)
-	k.records = make(map[telegraf.TrackingID]string, k.MaxUndeliveredMessages)
+	// The checkpoint only advances past delivered records, rejected metrics
+	// are added again instead.
+	tracker := delivery.NewTracker(ac, k.MaxUndeliveredMessages)
+	tracker.Redeliver = true
+	k.tracker = tracker
 	k.checkpoints = make(map[string]checkpoint, k.MaxUndeliveredMessages)
-	k.sem = make(chan struct{}, k.MaxUndeliveredMessages)
 
 	ctx := context.Background()
 	ctx, k.cancel = context.WithCancel(ctx)
 
-	k.wg.Add(1)
-	go func() {
-		defer k.wg.Done()
-		k.onDelivery(ctx)
-	}()
-
 	k.wg.Add(1)
 	go func() {
 		defer k.wg.Done()
 		err := k.cons.Scan(ctx, func(r *consumer.Record) consumer.ScanStatus {
-			select {
-			case <-ctx.Done():
-				return consumer.ScanStatus{Error: ctx.Err()}
-			case k.sem <- struct{}{}:
-				break
+			if err := tracker.Reserve(ctx); err != nil {
+				return consumer.ScanStatus{Error: err}
 			}
-			err := k.onMessage(k.acc, r)
+			err := k.onMessage(tracker, r)
 			if err != nil {
-				k.sem <- struct{}{}
+				tracker.Release()
 				return consumer.ScanStatus{Error: err}
 			}
 
@@ -222,6 +213,7 @@ func (k *KinesisConsumer) connect(ac telegraf.Accumulator) error {
 		if err != nil {
 			k.cancel()
 			k.Log.Errorf("Scan encountered an error: %s", err.Error())
+			tracker.Stop()
 			k.cons = nil
 		}
 	}()
@@ -238,58 +230,36 @@ func (k *KinesisConsumer) Start(ac telegraf.Accumulator) error {
 	return nil
 }
 
-func (k *KinesisConsumer) onMessage(acc telegraf.TrackingAccumulator, r *consumer.Record) error {
+func (k *KinesisConsumer) onMessage(tracker *delivery.Tracker, r *consumer.Record) error {
 	metrics, err := k.parser.Parse(r.Data)
 	if err != nil {
 		return err
 	}
 
-	k.recordsTex.Lock()
-	id := acc.AddTrackingMetricGroup(metrics)
-	k.records[id] = *r.SequenceNumber
-	k.recordsTex.Unlock()
-
+	sequenceNum := *r.SequenceNumber
+	tracker.Add(metrics, func(bool) {
+		k.onDelivery(sequenceNum)
+	})
 	return nil
 }
 
-func (k *KinesisConsumer) onDelivery(ctx context.Context) {
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case info := <-k.acc.Delivered():
-			k.recordsTex.Lock()
-			sequenceNum, ok := k.records[info.ID()]
-			if !ok {
-				k.recordsTex.Unlock()
-				continue
-			}
-			<-k.sem
-			delete(k.records, info.ID())
-			k.recordsTex.Unlock()
-
-			if info.Delivered() {
-				k.checkpointTex.Lock()
-				chk, ok := k.checkpoints[sequenceNum]
-				if !ok {
-					k.checkpointTex.Unlock()
-					continue
-				}
-				delete(k.checkpoints, sequenceNum)
-				k.checkpointTex.Unlock()
-
-				// at least once
-				if strToBint(sequenceNum).Cmp(k.lastSeqNum) > 0 {
-					continue
-				}
-
-				k.lastSeqNum = strToBint(sequenceNum)
-				k.checkpoint.Set(chk.streamName, chk.shardID, sequenceNum)
-			} else {
-				k.Log.Debug("Metric group failed to process")
-			}
-		}
+func (k *KinesisConsumer) onDelivery(sequenceNum string) {
+	k.checkpointTex.Lock()
+	chk, ok := k.checkpoints[sequenceNum]
+	if !ok {
+		k.checkpointTex.Unlock()
+		return
 	}
+	delete(k.checkpoints, sequenceNum)
+	k.checkpointTex.Unlock()
+
+	// at least once
+	if strToBint(sequenceNum).Cmp(k.lastSeqNum) > 0 {
+		return
+	}
+
+	k.lastSeqNum = strToBint(sequenceNum)
+	k.checkpoint.Set(chk.streamName, chk.shardID, sequenceNum)
 }
 
 var negOne *big.Int
@@ -305,6 +275,7 @@ func strToBint(s string) *big.Int {
 func (k *KinesisConsumer) Stop() {
 	k.cancel()
 	k.wg.Wait()
+	k.tracker.Stop()
 }
 
 func (k *KinesisConsumer) Gather(acc telegraf.Accumulator) error {
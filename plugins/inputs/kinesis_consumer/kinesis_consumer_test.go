@@ -0,0 +1,66 @@
+package kinesis_consumer
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	consumer "github.com/harlow/kinesis-consumer"
+	"github.com/influxdata/telegraf/plugins/common/delivery"
+	"github.com/influxdata/telegraf/plugins/parsers/value"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingCheckpoint struct {
+	sync.Mutex
+	sequenceNumbers []string
+}
+
+func (c *recordingCheckpoint) Set(_, _, sequenceNumber string) error {
+	c.Lock()
+	defer c.Unlock()
+	c.sequenceNumbers = append(c.sequenceNumbers, sequenceNumber)
+	return nil
+}
+
+func (c *recordingCheckpoint) Get(string, string) (string, error) { return "", nil }
+
+func (c *recordingCheckpoint) set() []string {
+	c.Lock()
+	defer c.Unlock()
+	return append([]string(nil), c.sequenceNumbers...)
+}
+
+func TestOnMessage_Delivery(t *testing.T) {
+	acc := &testutil.TrackingAccumulator{}
+	chk := &recordingCheckpoint{}
+	k := &KinesisConsumer{
+		Log:         testutil.Logger{},
+		parser:      &value.ValueParser{MetricName: "cpu", DataType: "int"},
+		checkpoint:  chk,
+		checkpoints: make(map[string]checkpoint),
+		lastSeqNum:  maxSeq,
+	}
+	tracker := delivery.NewTracker(acc, 1)
+	tracker.Redeliver = true
+	defer tracker.Stop()
+
+	require.NoError(t, k.Set("stream", "shard", "1"))
+	record := &consumer.Record{Data: []byte("42"), SequenceNumber: aws.String("1")}
+	require.NoError(t, tracker.Reserve(context.Background()))
+	require.NoError(t, k.onMessage(tracker, record))
+
+	// A rejected record is added again and not checkpointed.
+	acc.NextTracked(t).Reject()
+	m := acc.NextTracked(t)
+	require.Empty(t, chk.set())
+
+	m.Accept()
+	require.Eventually(t, func() bool {
+		return len(chk.set()) == 1
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"1"}, chk.set())
+}
@@ -5,12 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"strings"
-	"sync"
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/delivery"
 	"github.com/influxdata/telegraf/plugins/common/tls"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/parsers"
@@ -24,8 +24,6 @@ var (
 )
 
 type ConnectionState int
-type empty struct{}
-type semaphore chan empty
 
 const (
 	Disconnected ConnectionState = iota
@@ -66,11 +64,9 @@ type MQTTConsumer struct {
 	clientFactory ClientFactory
 	client        Client
 	opts          *mqtt.ClientOptions
-	acc           telegraf.TrackingAccumulator
+	acc           telegraf.Accumulator
+	tracker       *delivery.Tracker
 	state         ConnectionState
-	sem           semaphore
-	messages      map[telegraf.TrackingID]bool
-	messagesMutex sync.Mutex
 	topicTag      string
 
 	ctx    context.Context
@@ -191,8 +187,11 @@ func (m *MQTTConsumer) Init() error {
 func (m *MQTTConsumer) Start(acc telegraf.Accumulator) error {
 	m.state = Disconnected
 
-	m.acc = acc.WithTracking(m.MaxUndeliveredMessages)
-	m.sem = make(semaphore, m.MaxUndeliveredMessages)
+	m.acc = acc
+	// MQTT does not support durable handling, rejected metrics are added
+	// again instead.
+	m.tracker = delivery.NewTracker(acc, m.MaxUndeliveredMessages)
+	m.tracker.Redeliver = true
 	m.ctx, m.cancel = context.WithCancel(context.Background())
 
 	m.client = m.clientFactory(m.opts)
@@ -221,9 +220,6 @@ func (m *MQTTConsumer) connect() error {
 
 	m.Log.Infof("Connected %v", m.Servers)
 	m.state = Connected
-	m.messagesMutex.Lock()
-	m.messages = make(map[telegraf.TrackingID]bool)
-	m.messagesMutex.Unlock()
 
 	// Persistent sessions should skip subscription if a session is present, as
 	// the subscriptions are stored by the server.
@@ -258,31 +254,18 @@ func (m *MQTTConsumer) onConnectionLost(c mqtt.Client, err error) {
 }
 
 func (m *MQTTConsumer) recvMessage(c mqtt.Client, msg mqtt.Message) {
-	for {
-		select {
-		case track := <-m.acc.Delivered():
-			<-m.sem
-			m.messagesMutex.Lock()
-			_, ok := m.messages[track.ID()]
-			if !ok {
-				// Added by a previous connection
-				continue
-			}
-			// No ack, MQTT does not support durable handling
-			delete(m.messages, track.ID())
-			m.messagesMutex.Unlock()
-		case m.sem <- empty{}:
-			err := m.onMessage(m.acc, msg)
-			if err != nil {
-				m.acc.AddError(err)
-				<-m.sem
-			}
-			return
-		}
+	if err := m.tracker.Reserve(m.ctx); err != nil {
+		return
+	}
+
+	err := m.onMessage(msg)
+	if err != nil {
+		m.acc.AddError(err)
+		m.tracker.Release()
 	}
 }
 
-func (m *MQTTConsumer) onMessage(acc telegraf.TrackingAccumulator, msg mqtt.Message) error {
+func (m *MQTTConsumer) onMessage(msg mqtt.Message) error {
 	metrics, err := m.parser.Parse(msg.Payload())
 	if err != nil {
 		return err
@@ -295,10 +278,7 @@ func (m *MQTTConsumer) onMessage(acc telegraf.TrackingAccumulator, msg mqtt.Mess
 		}
 	}
 
-	id := acc.AddTrackingMetricGroup(metrics)
-	m.messagesMutex.Lock()
-	m.messages[id] = true
-	m.messagesMutex.Unlock()
+	m.tracker.Add(metrics, nil)
 	return nil
 }
 
@@ -310,6 +290,7 @@ func (m *MQTTConsumer) Stop() {
 		m.state = Disconnected
 	}
 	m.cancel()
+	m.tracker.Stop()
 }
 
 func (m *MQTTConsumer) Gather(acc telegraf.Accumulator) error {
@@ -376,3 +376,60 @@ func TestSubscribeNotCalledIfSession(t *testing.T) {
 
 	require.Equal(t, client.subscribeCallCount, 0)
 }
+
+func TestDelivery(t *testing.T) {
+	var handler mqtt.MessageHandler
+	client := &FakeClient{
+		ConnectF: func() mqtt.Token {
+			return &FakeToken{}
+		},
+		AddRouteF: func(topic string, callback mqtt.MessageHandler) {
+			handler = callback
+		},
+		SubscribeMultipleF: func(filters map[string]byte, callback mqtt.MessageHandler) mqtt.Token {
+			return &FakeToken{}
+		},
+		DisconnectF: func(quiesce uint) {
+		},
+	}
+
+	plugin := New(func(o *mqtt.ClientOptions) Client {
+		return client
+	})
+	plugin.Log = testutil.Logger{}
+	plugin.Topics = []string{"telegraf"}
+	plugin.MaxUndeliveredMessages = 1
+
+	parser, err := parsers.NewInfluxParser()
+	require.NoError(t, err)
+	plugin.SetParser(parser)
+	require.NoError(t, plugin.Init())
+
+	acc := &testutil.TrackingAccumulator{}
+	require.NoError(t, plugin.Start(acc))
+	defer plugin.Stop()
+
+	handler(nil, &Message{})
+
+	// The next message waits until the first one is delivered.
+	received := make(chan struct{})
+	go func() {
+		handler(nil, &Message{})
+		close(received)
+	}()
+
+	// MQTT cannot redeliver the message, the rejected metrics are added
+	// again instead.
+	acc.NextTracked(t).Reject()
+	m := acc.NextTracked(t)
+	require.Equal(t, "cpu", m.Name())
+	select {
+	case <-received:
+		t.Fatal("message received before the previous one was delivered")
+	default:
+	}
+
+	m.Accept()
+	<-received
+	acc.NextTracked(t).Accept()
+}
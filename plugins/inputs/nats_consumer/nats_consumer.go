@@ -7,6 +7,7 @@ import (
 	"sync"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/common/delivery"
 	"github.com/influxdata/telegraf/plugins/common/tls"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/parsers"
@@ -17,9 +18,6 @@ var (
 	defaultMaxUndeliveredMessages = 1000
 )
 
-type empty struct{}
-type semaphore chan empty
-
 type natsError struct {
 	conn *nats.Conn
 	sub  *nats.Subscription
@@ -60,10 +58,10 @@ type natsConsumer struct {
 	// channel for all incoming NATS messages
 	in chan *nats.Msg
 	// channel for all NATS read errors
-	errs   chan error
-	acc    telegraf.TrackingAccumulator
-	wg     sync.WaitGroup
-	cancel context.CancelFunc
+	errs    chan error
+	tracker *delivery.Tracker
+	wg      sync.WaitGroup
+	cancel  context.CancelFunc
 }
 
 var sampleConfig = `
@@ -137,7 +135,10 @@ func (n *natsConsumer) natsErrHandler(c *nats.Conn, s *nats.Subscription, e erro
 
 // Start the nats consumer. Caller must call *natsConsumer.Stop() to clean up.
 func (n *natsConsumer) Start(acc telegraf.Accumulator) error {
-	n.acc = acc.WithTracking(n.MaxUndeliveredMessages)
+	// NATS does not acknowledge messages, rejected metrics are added again
+	// instead.
+	n.tracker = delivery.NewTracker(acc, n.MaxUndeliveredMessages)
+	n.tracker.Redeliver = true
 
 	var connectErr error
 
@@ -199,7 +200,7 @@ func (n *natsConsumer) Start(acc telegraf.Accumulator) error {
 	n.wg.Add(1)
 	go func() {
 		defer n.wg.Done()
-		go n.receiver(ctx)
+		n.receiver(ctx)
 	}()
 
 	n.Log.Infof("Started the NATS consumer service, nats: %v, subjects: %v, queue: %v",
@@ -211,36 +212,28 @@ func (n *natsConsumer) Start(acc telegraf.Accumulator) error {
 // receiver() reads all incoming messages from NATS, and parses them into
 // telegraf metrics.
 func (n *natsConsumer) receiver(ctx context.Context) {
-	sem := make(semaphore, n.MaxUndeliveredMessages)
-
 	for {
+		err := n.tracker.Reserve(ctx)
+		if err != nil {
+			return
+		}
+
 		select {
 		case <-ctx.Done():
+			n.tracker.Release()
 			return
-		case <-n.acc.Delivered():
-			<-sem
 		case err := <-n.errs:
+			n.tracker.Release()
 			n.Log.Error(err)
-		case sem <- empty{}:
-			select {
-			case <-ctx.Done():
-				return
-			case err := <-n.errs:
-				<-sem
-				n.Log.Error(err)
-			case <-n.acc.Delivered():
-				<-sem
-				<-sem
-			case msg := <-n.in:
-				metrics, err := n.parser.Parse(msg.Data)
-				if err != nil {
-					n.Log.Errorf("Subject: %s, error: %s", msg.Subject, err.Error())
-					<-sem
-					continue
-				}
-
-				n.acc.AddTrackingMetricGroup(metrics)
+		case msg := <-n.in:
+			metrics, err := n.parser.Parse(msg.Data)
+			if err != nil {
+				n.Log.Errorf("Subject: %s, error: %s", msg.Subject, err.Error())
+				n.tracker.Release()
+				continue
 			}
+
+			n.tracker.Add(metrics, nil)
 		}
 	}
 }
@@ -262,6 +255,7 @@ func (n *natsConsumer) Stop() {
 	n.cancel()
 	n.wg.Wait()
 	n.clean()
+	n.tracker.Stop()
 }
 
 func (n *natsConsumer) Gather(acc telegraf.Accumulator) error {
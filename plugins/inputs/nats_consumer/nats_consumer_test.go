@@ -0,0 +1,55 @@
+package natsconsumer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/plugins/common/delivery"
+	"github.com/influxdata/telegraf/plugins/parsers/value"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReceiver_Delivery(t *testing.T) {
+	acc := &testutil.TrackingAccumulator{}
+	n := &natsConsumer{
+		Log:     testutil.Logger{},
+		parser:  &value.ValueParser{MetricName: "cpu", DataType: "int"},
+		in:      make(chan *nats.Msg, 2),
+		errs:    make(chan error),
+		tracker: delivery.NewTracker(acc, 1),
+	}
+	n.tracker.Redeliver = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		n.receiver(ctx)
+		close(done)
+	}()
+
+	n.in <- &nats.Msg{Subject: "telegraf", Data: []byte("42")}
+	n.in <- &nats.Msg{Subject: "telegraf", Data: []byte("43")}
+
+	// NATS cannot redeliver the message, the rejected metrics are added
+	// again instead.
+	acc.NextTracked(t).Reject()
+	m := acc.NextTracked(t)
+	require.Equal(t, int64(42), m.Fields()["value"])
+
+	// The next message is only read once the first one is delivered.
+	time.Sleep(10 * time.Millisecond)
+	require.Len(t, n.in, 1)
+
+	m.Accept()
+	m = acc.NextTracked(t)
+	require.Equal(t, int64(43), m.Fields()["value"])
+	m.Accept()
+
+	cancel()
+	<-done
+	n.tracker.Stop()
+	require.Equal(t, 0, n.tracker.Undelivered())
+}
@@ -2,9 +2,9 @@ package nsq_consumer
 
 import (
 	"context"
-	"sync"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/common/delivery"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/parsers"
 	nsq "github.com/nsqio/go-nsq"
@@ -14,9 +14,6 @@ const (
 	defaultMaxUndeliveredMessages = 1000
 )
 
-type empty struct{}
-type semaphore chan empty
-
 type logger struct {
 	log telegraf.Logger
 }
@@ -42,10 +39,8 @@ type NSQConsumer struct {
 
 	Log telegraf.Logger
 
-	mu       sync.Mutex
-	messages map[telegraf.TrackingID]*nsq.Message
-	wg       sync.WaitGroup
-	cancel   context.CancelFunc
+	tracker *delivery.Tracker
+	cancel  context.CancelFunc
 }
 
 var sampleConfig = `
@@ -94,10 +89,8 @@ func (n *NSQConsumer) Description() string {
 }
 
 // Start pulls data from nsq
-func (n *NSQConsumer) Start(ac telegraf.Accumulator) error {
-	acc := ac.WithTracking(n.MaxUndeliveredMessages)
-	sem := make(semaphore, n.MaxUndeliveredMessages)
-	n.messages = make(map[telegraf.TrackingID]*nsq.Message, n.MaxUndeliveredMessages)
+func (n *NSQConsumer) Start(acc telegraf.Accumulator) error {
+	n.tracker = delivery.NewTracker(acc, n.MaxUndeliveredMessages)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	n.cancel = cancel
@@ -105,75 +98,52 @@ func (n *NSQConsumer) Start(ac telegraf.Accumulator) error {
 	n.connect()
 	n.consumer.SetLogger(&logger{log: n.Log}, nsq.LogLevelInfo)
 	n.consumer.AddHandler(nsq.HandlerFunc(func(message *nsq.Message) error {
-		metrics, err := n.parser.Parse(message.Body)
-		if err != nil {
-			acc.AddError(err)
-			// Remove the message from the queue
-			message.Finish()
-			return nil
-		}
-		if len(metrics) == 0 {
-			message.Finish()
-			return nil
-		}
-
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case sem <- empty{}:
-			break
-		}
-
-		n.mu.Lock()
-		id := acc.AddTrackingMetricGroup(metrics)
-		n.messages[id] = message
-		n.mu.Unlock()
-		message.DisableAutoResponse()
-		return nil
+		return n.onMessage(ctx, acc, message)
 	}))
 
 	if len(n.Nsqlookupd) > 0 {
 		n.consumer.ConnectToNSQLookupds(n.Nsqlookupd)
 	}
 	n.consumer.ConnectToNSQDs(append(n.Nsqd, n.Server))
-
-	n.wg.Add(1)
-	go func() {
-		defer n.wg.Done()
-		n.onDelivery(ctx, acc, sem)
-	}()
 	return nil
 }
 
-func (n *NSQConsumer) onDelivery(ctx context.Context, acc telegraf.TrackingAccumulator, sem semaphore) {
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case info := <-acc.Delivered():
-			n.mu.Lock()
-			msg, ok := n.messages[info.ID()]
-			if !ok {
-				n.mu.Unlock()
-				continue
-			}
-			<-sem
-			delete(n.messages, info.ID())
-			n.mu.Unlock()
-
-			if info.Delivered() {
-				msg.Finish()
-			} else {
-				msg.Requeue(-1)
-			}
-		}
+// onMessage adds the metrics of the message, the message is finished once
+// they are delivered and requeued if they are rejected.
+func (n *NSQConsumer) onMessage(ctx context.Context, acc telegraf.Accumulator, message *nsq.Message) error {
+	metrics, err := n.parser.Parse(message.Body)
+	if err != nil {
+		acc.AddError(err)
+		// Remove the message from the queue
+		message.Finish()
+		return nil
+	}
+	if len(metrics) == 0 {
+		message.Finish()
+		return nil
+	}
+
+	// Returning an error requeues the message.
+	if err := n.tracker.Reserve(ctx); err != nil {
+		return err
 	}
+
+	message.DisableAutoResponse()
+	n.tracker.Add(metrics, func(delivered bool) {
+		if delivered {
+			message.Finish()
+		} else {
+			message.Requeue(-1)
+		}
+	})
+	return nil
 }
 
 // Stop processing messages
 func (n *NSQConsumer) Stop() {
 	n.cancel()
-	n.wg.Wait()
+	// Finish the undelivered messages before closing the connections.
+	n.tracker.Stop()
 	n.consumer.Stop()
 	<-n.consumer.StopChan
 }
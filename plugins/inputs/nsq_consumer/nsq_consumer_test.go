@@ -3,18 +3,22 @@ package nsq_consumer
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/binary"
 	"io"
 	"log"
 	"net"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/influxdata/telegraf/plugins/common/delivery"
 	"github.com/influxdata/telegraf/plugins/parsers"
 	"github.com/influxdata/telegraf/testutil"
 	"github.com/nsqio/go-nsq"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // This test is modeled after the kafka consumer integration test
@@ -71,6 +75,75 @@ func TestReadsMetricsFromNSQ(t *testing.T) {
 
 }
 
+// messageDelegate records how the messages are responded to.
+type messageDelegate struct {
+	sync.Mutex
+	finished int
+	requeued int
+}
+
+func (d *messageDelegate) OnFinish(*nsq.Message) {
+	d.Lock()
+	defer d.Unlock()
+	d.finished++
+}
+
+func (d *messageDelegate) OnRequeue(*nsq.Message, time.Duration, bool) {
+	d.Lock()
+	defer d.Unlock()
+	d.requeued++
+}
+
+func (d *messageDelegate) OnTouch(*nsq.Message) {}
+
+func (d *messageDelegate) responses() (int, int) {
+	d.Lock()
+	defer d.Unlock()
+	return d.finished, d.requeued
+}
+
+func TestOnMessage_Delivery(t *testing.T) {
+	acc := &testutil.TrackingAccumulator{}
+	p, _ := parsers.NewInfluxParser()
+	consumer := &NSQConsumer{
+		Log:     testutil.Logger{},
+		parser:  p,
+		tracker: delivery.NewTracker(acc, 1),
+	}
+	defer consumer.tracker.Stop()
+
+	ctx := context.Background()
+	delegate := &messageDelegate{}
+	newMessage := func(body string) *nsq.Message {
+		msg := nsq.NewMessage(nsq.MessageID{}, []byte(body))
+		msg.Delegate = delegate
+		return msg
+	}
+
+	// The message is finished once its metrics are written.
+	require.NoError(t, consumer.onMessage(ctx, acc, newMessage("cpu value=42")))
+	acc.NextTracked(t).Accept()
+	require.Eventually(t, func() bool {
+		finished, _ := delegate.responses()
+		return finished == 1
+	}, time.Second, time.Millisecond)
+
+	// The message is requeued if its metrics are rejected.
+	require.NoError(t, consumer.onMessage(ctx, acc, newMessage("cpu value=43")))
+	acc.NextTracked(t).Reject()
+	require.Eventually(t, func() bool {
+		_, requeued := delegate.responses()
+		return requeued == 1
+	}, time.Second, time.Millisecond)
+
+	// A message that cannot be parsed is finished right away.
+	require.NoError(t, consumer.onMessage(ctx, acc, newMessage("not line protocol")))
+	finished, requeued := delegate.responses()
+	require.Equal(t, 2, finished)
+	require.Equal(t, 1, requeued)
+	require.Len(t, acc.Errors, 1)
+}
+
 // Waits for the metric that was sent to the kafka broker to arrive at the kafka
 // consumer
 func waitForPoint(acc *testutil.Accumulator, t *testing.T) {
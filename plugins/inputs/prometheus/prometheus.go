@@ -48,6 +48,8 @@ type Prometheus struct {
 
 	MetricVersion int `toml:"metric_version"`
 
+	Distribution bool `toml:"distribution"`
+
 	URLTag string `toml:"url_tag"`
 
 	tls.ClientConfig
@@ -78,6 +80,11 @@ var sampleConfig = `
   ##            metric_version = 2; recommended version
   # metric_version = 1
 
+  ## If true, each histogram and summary is gathered as a single field
+  ## holding the buckets or quantiles, the count and the sum.  Requires
+  ## metric_version = 2.
+  # distribution = false
+
   ## Url tag name (tag containing scrapped url. optional, default is "url")
   # url_tag = "scrapeUrl"
 
@@ -330,7 +337,7 @@ func (p *Prometheus) gatherURL(u URLAndAddress, acc telegraf.Accumulator) error
 	}
 
 	if p.MetricVersion == 2 {
-		parser := parser_v2.Parser{Header: resp.Header}
+		parser := parser_v2.Parser{Header: resp.Header, Distribution: p.Distribution}
 		metrics, err = parser.Parse(body)
 	} else {
 		metrics, err = Parse(body, resp.Header)
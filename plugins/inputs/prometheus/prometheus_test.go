@@ -213,6 +213,49 @@ go_gc_duration_seconds_count 42
 		testutil.IgnoreTime(), testutil.SortMetrics())
 }
 
+func TestPrometheusGeneratesSummaryDistributionV2(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, sampleSummaryTextFormat)
+	}))
+	defer ts.Close()
+
+	p := &Prometheus{
+		URLs:          []string{ts.URL},
+		URLTag:        "",
+		MetricVersion: 2,
+		Distribution:  true,
+	}
+
+	var acc testutil.Accumulator
+
+	err := acc.GatherError(p.Gather)
+	require.NoError(t, err)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"prometheus",
+			map[string]string{},
+			map[string]interface{}{
+				"go_gc_duration_seconds": &telegraf.Distribution{
+					Count: 7,
+					Sum:   0.0018183950000000002,
+					Quantiles: []telegraf.Quantile{
+						{Quantile: 0, Value: 0.00010425500000000001},
+						{Quantile: 0.25, Value: 0.000139108},
+						{Quantile: 0.5, Value: 0.00015749400000000002},
+						{Quantile: 0.75, Value: 0.000331463},
+						{Quantile: 1, Value: 0.000667154},
+					},
+				},
+			},
+			time.Unix(0, 0),
+			telegraf.Summary,
+		),
+	}
+
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+}
+
 func TestPrometheusGeneratesGaugeMetricsV2(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintln(w, sampleGaugeTextFormat)
@@ -237,6 +237,79 @@ rpc_duration_seconds{quantile="0.9"} 9001
 rpc_duration_seconds{quantile="0.99"} 76656
 rpc_duration_seconds_sum 1.7560473e+07
 rpc_duration_seconds_count 2693
+`),
+		},
+		{
+			name: "histogram distribution",
+			output: &PrometheusClient{
+				Listen:            ":0",
+				MetricVersion:     1,
+				CollectorsExclude: []string{"gocollector", "process"},
+				Path:              "/metrics",
+				Log:               Logger,
+			},
+			metrics: []telegraf.Metric{
+				testutil.MustMetric(
+					"http_request",
+					map[string]string{},
+					map[string]interface{}{
+						"duration_seconds": &telegraf.Distribution{
+							Count: 144320,
+							Sum:   53423,
+							Buckets: []telegraf.Bucket{
+								{UpperBound: 0.05, Count: 24054},
+								{UpperBound: 0.1, Count: 33444},
+							},
+						},
+					},
+					time.Unix(0, 0),
+					telegraf.Histogram,
+				),
+			},
+			expected: []byte(`
+# HELP http_request_duration_seconds Telegraf collected metric
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{le="0.05"} 24054
+http_request_duration_seconds_bucket{le="0.1"} 33444
+http_request_duration_seconds_bucket{le="+Inf"} 144320
+http_request_duration_seconds_sum 53423
+http_request_duration_seconds_count 144320
+`),
+		},
+		{
+			name: "summary distribution",
+			output: &PrometheusClient{
+				Listen:            ":0",
+				MetricVersion:     1,
+				CollectorsExclude: []string{"gocollector", "process"},
+				Path:              "/metrics",
+				Log:               Logger,
+			},
+			metrics: []telegraf.Metric{
+				testutil.MustMetric(
+					"rpc",
+					map[string]string{},
+					map[string]interface{}{
+						"duration_seconds": &telegraf.Distribution{
+							Count: 2693,
+							Sum:   17560473,
+							Quantiles: []telegraf.Quantile{
+								{Quantile: 0.5, Value: 4773},
+								{Quantile: 0.99, Value: 76656},
+							},
+						},
+					},
+					time.Unix(0, 0),
+					telegraf.Summary,
+				),
+			},
+			expected: []byte(`
+# HELP rpc_duration_seconds Telegraf collected metric
+# TYPE rpc_duration_seconds summary
+rpc_duration_seconds{quantile="0.5"} 4773
+rpc_duration_seconds{quantile="0.99"} 76656
+rpc_duration_seconds_sum 1.7560473e+07
+rpc_duration_seconds_count 2693
 `),
 		},
 	}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
 	serializer "github.com/influxdata/telegraf/plugins/serializers/prometheus"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -161,13 +162,13 @@ func addSample(fam *MetricFamily, sample *Sample, sampleID SampleID) {
 	fam.Samples[sampleID] = sample
 }
 
-func (c *Collector) addMetricFamily(point telegraf.Metric, sample *Sample, mname string, sampleID SampleID) {
+func (c *Collector) addMetricFamily(tp telegraf.ValueType, sample *Sample, mname string, sampleID SampleID) {
 	var fam *MetricFamily
 	var ok bool
 	if fam, ok = c.fam[mname]; !ok {
 		fam = &MetricFamily{
 			Samples:           make(map[SampleID]*Sample),
-			TelegrafValueType: point.Type(),
+			TelegrafValueType: tp,
 			LabelSet:          make(map[string]int),
 		}
 		c.fam[mname] = fam
@@ -224,6 +225,13 @@ func (c *Collector) Add(metrics []telegraf.Metric) error {
 			}
 		}
 
+		// Metrics holding distributions are added as a histogram or summary
+		// per distribution field.
+		if metric.HasDistribution(point) {
+			c.addDistributions(point, labels, sampleID, now)
+			continue
+		}
+
 		switch point.Type() {
 		case telegraf.Summary:
 			var mname string
@@ -269,7 +277,7 @@ func (c *Collector) Add(metrics []telegraf.Metric) error {
 				continue
 			}
 
-			c.addMetricFamily(point, sample, mname, sampleID)
+			c.addMetricFamily(point.Type(), sample, mname, sampleID)
 
 		case telegraf.Histogram:
 			var mname string
@@ -315,7 +323,7 @@ func (c *Collector) Add(metrics []telegraf.Metric) error {
 				continue
 			}
 
-			c.addMetricFamily(point, sample, mname, sampleID)
+			c.addMetricFamily(point.Type(), sample, mname, sampleID)
 
 		default:
 			for fn, fv := range point.Fields() {
@@ -362,7 +370,7 @@ func (c *Collector) Add(metrics []telegraf.Metric) error {
 				if !isValidTagName(mname) {
 					continue
 				}
-				c.addMetricFamily(point, sample, mname, sampleID)
+				c.addMetricFamily(point.Type(), sample, mname, sampleID)
 
 			}
 		}
@@ -370,6 +378,47 @@ func (c *Collector) Add(metrics []telegraf.Metric) error {
 	return nil
 }
 
+// addDistributions adds the distribution fields of the metric, named after
+// the metric and the field like other fields.  Other fields of the metric are
+// ignored.
+func (c *Collector) addDistributions(point telegraf.Metric, labels map[string]string, sampleID SampleID, now time.Time) {
+	for _, field := range point.FieldList() {
+		d, ok := field.Value.(*telegraf.Distribution)
+		if !ok {
+			continue
+		}
+
+		mname := sanitize(fmt.Sprintf("%s_%s", point.Name(), field.Key))
+		if !isValidTagName(mname) {
+			continue
+		}
+
+		sample := &Sample{
+			Labels:     labels,
+			Count:      d.Count,
+			Sum:        d.Sum,
+			Timestamp:  point.Time(),
+			Expiration: now.Add(c.ExpirationInterval),
+		}
+
+		tp := telegraf.Histogram
+		if d.IsSummary() {
+			tp = telegraf.Summary
+			sample.SummaryValue = make(map[float64]float64, len(d.Quantiles))
+			for _, q := range d.Quantiles {
+				sample.SummaryValue[q.Quantile] = q.Value
+			}
+		} else {
+			sample.HistogramValue = make(map[float64]uint64, len(d.Buckets))
+			for _, b := range d.Buckets {
+				sample.HistogramValue[b.UpperBound] = b.Count
+			}
+		}
+
+		c.addMetricFamily(tp, sample, mname, sampleID)
+	}
+}
+
 func (c *Collector) Expire(now time.Time, age time.Duration) {
 	if age == 0 {
 		return
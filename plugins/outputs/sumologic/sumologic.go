@@ -116,7 +116,7 @@ func (s *SumoLogic) SetSerializer(serializer serializers.Serializer) {
 		s.headers = make(map[string]string)
 	}
 
-	switch sr := serializer.(type) {
+	switch sr := serializers.Unwrap(serializer).(type) {
 	case *carbon2.Serializer:
 		s.headers[contentTypeHeader] = carbon2ContentType
 
@@ -133,7 +133,7 @@ func (s *SumoLogic) SetSerializer(serializer serializers.Serializer) {
 		s.headers[contentTypeHeader] = prometheusContentType
 
 	default:
-		s.err = errors.Errorf("unsupported serializer %T", serializer)
+		s.err = errors.Errorf("unsupported serializer %T", sr)
 	}
 
 	s.serializer = serializer
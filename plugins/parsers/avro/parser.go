@@ -0,0 +1,381 @@
+package avro
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/parsers/schema_registry"
+	"github.com/linkedin/goavro/v2"
+)
+
+type Config struct {
+	MetricName string
+	// Schema is the Avro schema of the messages, in JSON.
+	Schema string
+	// SchemaFile is the file of the Avro schema of the messages.
+	SchemaFile string
+	// SchemaRegistry is the url of the schema registry of the messages, the
+	// messages are in the Confluent wire format.
+	SchemaRegistry string
+	// Measurement is the name of the metrics, defaults to MetricName.
+	Measurement string
+	// MeasurementField is the field holding the name of the metrics.
+	MeasurementField string
+	// Tags are the fields added as tags.
+	Tags []string
+	// Fields are the fields added as fields, all other fields by default.
+	Fields []string
+	// Timestamp is the field holding the timestamp, defaults to the current
+	// time.
+	Timestamp string
+	// TimestampFormat is the format of the timestamp, defaults to "unix".
+	TimestampFormat string
+	// FieldSeparator joins the names of nested fields, defaults to "_".
+	FieldSeparator string
+	DefaultTags    map[string]string
+}
+
+// Parser decodes Avro binary messages into metrics, one metric per message.
+type Parser struct {
+	config   *Config
+	registry *schema_registry.Client
+	// codec decodes the messages without schema registry.
+	codec *codec
+
+	mu     sync.Mutex
+	codecs map[int]*codec
+}
+
+// codec is an Avro codec with the full names of the named types of its
+// schema, needed to recognize union values.
+type codec struct {
+	*goavro.Codec
+	named map[string]bool
+}
+
+func New(config *Config) (*Parser, error) {
+	if config.FieldSeparator == "" {
+		config.FieldSeparator = "_"
+	}
+	if config.TimestampFormat == "" {
+		config.TimestampFormat = "unix"
+	}
+
+	p := &Parser{
+		config: config,
+		codecs: make(map[int]*codec),
+	}
+
+	if config.SchemaFile != "" {
+		if config.Schema != "" {
+			return nil, errors.New("avro_schema and avro_schema_file are mutually exclusive")
+		}
+		data, err := ioutil.ReadFile(config.SchemaFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Schema = string(data)
+	}
+
+	switch {
+	case config.SchemaRegistry != "":
+		registry, err := schema_registry.NewClient(config.SchemaRegistry)
+		if err != nil {
+			return nil, err
+		}
+		p.registry = registry
+	case config.Schema != "":
+		c, err := newCodec(config.Schema)
+		if err != nil {
+			return nil, err
+		}
+		p.codec = c
+	default:
+		return nil, errors.New("one of avro_schema, avro_schema_file or avro_schema_registry is required")
+	}
+	return p, nil
+}
+
+func newCodec(schema string) (*codec, error) {
+	c, err := goavro.NewCodec(schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid avro schema: %v", err)
+	}
+
+	var s interface{}
+	if err := json.Unmarshal([]byte(schema), &s); err != nil {
+		return nil, fmt.Errorf("invalid avro schema: %v", err)
+	}
+	named := make(map[string]bool)
+	namedTypes(s, "", named)
+
+	return &codec{Codec: c, named: named}, nil
+}
+
+// namedTypes collects the full names of the records, enums and fixed types of
+// the schema.
+func namedTypes(schema interface{}, namespace string, named map[string]bool) {
+	switch s := schema.(type) {
+	case []interface{}:
+		for _, t := range s {
+			namedTypes(t, namespace, named)
+		}
+	case map[string]interface{}:
+		if ns, ok := s["namespace"].(string); ok {
+			namespace = ns
+		}
+		if name, ok := s["name"].(string); ok {
+			switch s["type"] {
+			case "record", "enum", "fixed":
+				if !strings.Contains(name, ".") && namespace != "" {
+					name = namespace + "." + name
+				}
+				named[name] = true
+				if i := strings.LastIndex(name, "."); i >= 0 {
+					namespace = name[:i]
+				}
+			}
+		}
+		namedTypes(s["type"], namespace, named)
+		namedTypes(s["items"], namespace, named)
+		namedTypes(s["values"], namespace, named)
+		if fields, ok := s["fields"].([]interface{}); ok {
+			for _, field := range fields {
+				namedTypes(field, namespace, named)
+			}
+		}
+	}
+}
+
+// codecByID returns the codec of the registry schema with the id.
+func (p *Parser) codecByID(id int) (*codec, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if c, ok := p.codecs[id]; ok {
+		return c, nil
+	}
+
+	schema, err := p.registry.SchemaByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if schema.Type != schema_registry.TypeAvro {
+		return nil, fmt.Errorf("schema %d has type %s, not %s", id, schema.Type, schema_registry.TypeAvro)
+	}
+	c, err := newCodec(schema.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("schema %d: %v", id, err)
+	}
+	p.codecs[id] = c
+	return c, nil
+}
+
+func (p *Parser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	c := p.codec
+	if p.registry != nil {
+		id, payload, err := schema_registry.SplitHeader(buf)
+		if err != nil {
+			return nil, err
+		}
+		if c, err = p.codecByID(id); err != nil {
+			return nil, err
+		}
+		buf = payload
+	}
+
+	native, _, err := c.NativeFromBinary(buf)
+	if err != nil {
+		return nil, fmt.Errorf("decoding avro message: %v", err)
+	}
+	record, ok := native.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("avro message is a %T, not a record", native)
+	}
+
+	values := make(map[string]interface{})
+	c.flatten(values, "", record, p.config.FieldSeparator)
+
+	m, err := p.createMetric(values)
+	if err != nil {
+		return nil, err
+	}
+	return []telegraf.Metric{m}, nil
+}
+
+func (p *Parser) ParseLine(line string) (telegraf.Metric, error) {
+	metrics, err := p.Parse([]byte(line))
+	if err != nil {
+		return nil, err
+	}
+	return metrics[0], nil
+}
+
+func (p *Parser) SetDefaultTags(tags map[string]string) {
+	p.config.DefaultTags = tags
+}
+
+// flatten adds the values of nested records, maps and arrays to the values,
+// their names joined with the field separator.
+func (c *codec) flatten(values map[string]interface{}, prefix string, value interface{}, sep string) {
+	switch v := value.(type) {
+	case nil:
+	case map[string]interface{}:
+		// Non-null union values are maps with the type name as single key.
+		if len(v) == 1 {
+			for k, inner := range v {
+				if c.isUnionKey(k) {
+					c.flatten(values, prefix, inner, sep)
+					return
+				}
+			}
+		}
+		for k, inner := range v {
+			c.flatten(values, join(prefix, k, sep), inner, sep)
+		}
+	case []interface{}:
+		for i, inner := range v {
+			c.flatten(values, join(prefix, strconv.Itoa(i), sep), inner, sep)
+		}
+	default:
+		values[prefix] = v
+	}
+}
+
+// isUnionKey reports if the key is the name of a union type, a primitive
+// type with an optional logical type, or a named type of the schema.
+func (c *codec) isUnionKey(key string) bool {
+	primitive := key
+	if i := strings.Index(key, "."); i >= 0 {
+		primitive = key[:i]
+	}
+	switch primitive {
+	case "boolean", "int", "long", "float", "double", "bytes", "string", "array", "map":
+		return true
+	default:
+		return c.named[key]
+	}
+}
+
+func join(prefix, key, sep string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + sep + key
+}
+
+func (p *Parser) createMetric(values map[string]interface{}) (telegraf.Metric, error) {
+	name := p.config.MetricName
+	if p.config.Measurement != "" {
+		name = p.config.Measurement
+	}
+	if p.config.MeasurementField != "" {
+		if v, ok := values[p.config.MeasurementField]; ok {
+			name = fmt.Sprint(v)
+		}
+	}
+
+	timestamp := time.Now()
+	if p.config.Timestamp != "" {
+		v, ok := values[p.config.Timestamp]
+		if !ok {
+			return nil, fmt.Errorf("timestamp field %q not found", p.config.Timestamp)
+		}
+		t, err := parseTimestamp(p.config.TimestampFormat, v)
+		if err != nil {
+			return nil, fmt.Errorf("parsing timestamp field %q: %v", p.config.Timestamp, err)
+		}
+		timestamp = t
+	}
+
+	tags := make(map[string]string)
+	for k, v := range p.config.DefaultTags {
+		tags[k] = v
+	}
+	for _, tag := range p.config.Tags {
+		if v, ok := values[tag]; ok {
+			tags[tag] = toString(v)
+		}
+	}
+
+	fields := make(map[string]interface{})
+	if len(p.config.Fields) != 0 {
+		for _, field := range p.config.Fields {
+			if v, ok := values[field]; ok {
+				fields[field] = toField(v)
+			}
+		}
+	} else {
+		for k, v := range values {
+			if k == p.config.Timestamp || k == p.config.MeasurementField || contains(p.config.Tags, k) {
+				continue
+			}
+			fields[k] = toField(v)
+		}
+	}
+
+	return metric.New(name, tags, fields, timestamp)
+}
+
+func parseTimestamp(format string, value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case int32:
+		value = int64(v)
+	case float32:
+		value = float64(v)
+	}
+	return internal.ParseTimestamp(format, value, "")
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(toField(v))
+	}
+}
+
+// toField converts the Avro native types without field type.
+func toField(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int32:
+		return int64(v)
+	case float32:
+		return float64(v)
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.UnixNano()
+	case time.Duration:
+		return v.Nanoseconds()
+	case *big.Rat:
+		f, _ := v.Float64()
+		return f
+	default:
+		return v
+	}
+}
+
+func contains(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
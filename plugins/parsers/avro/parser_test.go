@@ -0,0 +1,152 @@
+package avro
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/linkedin/goavro/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func encode(t *testing.T, schema string, native map[string]interface{}) []byte {
+	codec, err := goavro.NewCodec(schema)
+	require.NoError(t, err)
+	buf, err := codec.BinaryFromNative(nil, native)
+	require.NoError(t, err)
+	return buf
+}
+
+func reading() map[string]interface{} {
+	return map[string]interface{}{
+		"device":   "plc1",
+		"time":     int64(1600000000000),
+		"value":    21.5,
+		"count":    goavro.Union("int", int32(3)),
+		"location": map[string]interface{}{"site": "north", "rack": int32(2)},
+		"unit":     "CELSIUS",
+	}
+}
+
+func TestParseSchemaFile(t *testing.T) {
+	schema, err := ioutil.ReadFile("testdata/reading.avsc")
+	require.NoError(t, err)
+
+	parser, err := New(&Config{
+		MetricName:      "avro",
+		SchemaFile:      "testdata/reading.avsc",
+		Measurement:     "reading",
+		Tags:            []string{"device", "location_site"},
+		Timestamp:       "time",
+		TimestampFormat: "unix_ms",
+		DefaultTags:     map[string]string{"host": "localhost"},
+	})
+	require.NoError(t, err)
+
+	metrics, err := parser.Parse(encode(t, string(schema), reading()))
+	require.NoError(t, err)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("reading",
+			map[string]string{"host": "localhost", "device": "plc1", "location_site": "north"},
+			map[string]interface{}{
+				"value":         21.5,
+				"count":         int64(3),
+				"location_rack": int64(2),
+				"unit":          "CELSIUS",
+			},
+			time.Unix(1600000000, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, metrics)
+
+	// Null union values are omitted.
+	native := reading()
+	native["count"] = nil
+	parser, err = New(&Config{
+		MetricName:       "avro",
+		Schema:           string(schema),
+		MeasurementField: "device",
+		Fields:           []string{"value", "count", "location.rack"},
+		FieldSeparator:   ".",
+	})
+	require.NoError(t, err)
+
+	metrics, err = parser.Parse(encode(t, string(schema), native))
+	require.NoError(t, err)
+
+	expected = []telegraf.Metric{
+		testutil.MustMetric("plc1",
+			map[string]string{},
+			map[string]interface{}{"value": 21.5, "location.rack": int64(2)},
+			time.Unix(0, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, metrics, testutil.IgnoreTime())
+}
+
+func TestParseSchemaRegistry(t *testing.T) {
+	schema, err := ioutil.ReadFile("testdata/reading.avsc")
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/schemas/ids/7":
+			json.NewEncoder(w).Encode(map[string]string{"schema": string(schema)})
+		case "/schemas/ids/8":
+			json.NewEncoder(w).Encode(map[string]string{"schemaType": "PROTOBUF", "schema": "syntax = \"proto3\";"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	parser, err := New(&Config{
+		MetricName:     "avro",
+		SchemaRegistry: ts.URL,
+		Tags:           []string{"device"},
+		Fields:         []string{"value"},
+		Timestamp:      "time",
+	})
+	require.NoError(t, err)
+
+	native := reading()
+	native["time"] = int64(1600000000)
+	msg := append([]byte{0, 0, 0, 0, 7}, encode(t, string(schema), native)...)
+
+	metrics, err := parser.Parse(msg)
+	require.NoError(t, err)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("avro",
+			map[string]string{"device": "plc1"},
+			map[string]interface{}{"value": 21.5},
+			time.Unix(1600000000, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, metrics)
+
+	_, err = parser.Parse([]byte{0, 0, 0, 0, 8, 0})
+	require.EqualError(t, err, "schema 8 has type PROTOBUF, not AVRO")
+
+	_, err = parser.Parse([]byte{0, 0, 0, 0, 9, 0})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "fetching schema 9")
+
+	_, err = parser.Parse(encode(t, string(schema), native))
+	require.Error(t, err)
+}
+
+func TestNewErrors(t *testing.T) {
+	_, err := New(&Config{})
+	require.Error(t, err)
+
+	_, err = New(&Config{Schema: `{"type": "unknown"}`})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid avro schema")
+
+	_, err = New(&Config{Schema: `"string"`, SchemaFile: "testdata/reading.avsc"})
+	require.Error(t, err)
+}
@@ -0,0 +1,568 @@
+package json_v2
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/tidwall/gjson"
+)
+
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// Config selects metrics from a JSON document through GJSON paths.
+type Config struct {
+	// MeasurementName is the metric name, defaults to the name of the parser.
+	MeasurementName string `toml:"measurement_name"`
+	// MeasurementNamePath is the path of the metric name, it takes
+	// precedence over MeasurementName if found.
+	MeasurementNamePath string `toml:"measurement_name_path"`
+	// TimestampPath is the path of the timestamp, defaults to the current
+	// time.
+	TimestampPath string `toml:"timestamp_path"`
+	// TimestampFormat is the format of the timestamp, "unix", "unix_ms",
+	// "unix_us", "unix_ns" or a Go time layout.  Defaults to "unix".
+	TimestampFormat string `toml:"timestamp_format"`
+	// TimestampTimezone is the location of timestamps without time zone.
+	TimestampTimezone string `toml:"timestamp_timezone"`
+
+	// Fields and Tags select values added to all metrics of the config,
+	// arrays are expanded into one metric per element.
+	Fields []DataSet `toml:"field"`
+	Tags   []DataSet `toml:"tag"`
+	// Objects select objects, or arrays of objects, converted to metrics.
+	Objects []Object `toml:"object"`
+}
+
+// DataSet selects a single value, or an array of values.
+type DataSet struct {
+	// Path is the GJSON path of the value.
+	Path string `toml:"path"`
+	// Rename is the name of the value, defaults to the last key of the path.
+	Rename string `toml:"rename"`
+	// Type converts the value to "int", "uint", "float", "string" or
+	// "bool".  Defaults to the JSON type.
+	Type string `toml:"type"`
+}
+
+// Object selects an object converted to metrics.  Nested objects are
+// flattened and nested arrays are expanded into one metric per element,
+// carrying the values of their parents.
+type Object struct {
+	// Path is the GJSON path of the object.
+	Path string `toml:"path"`
+	// TimestampKey is the key of the timestamp of the metrics.
+	TimestampKey string `toml:"timestamp_key"`
+	// TimestampFormat is the format of the timestamp, defaults to "unix".
+	TimestampFormat string `toml:"timestamp_format"`
+	// TimestampTimezone is the location of timestamps without time zone.
+	TimestampTimezone string `toml:"timestamp_timezone"`
+	// DisablePrependKeys disables prefixing the keys of nested values with
+	// the keys of their parents.
+	DisablePrependKeys bool `toml:"disable_prepend_keys"`
+	// IncludedKeys restricts the values to the keys, if set.
+	IncludedKeys []string `toml:"included_keys"`
+	// ExcludedKeys are the keys of the values ignored.
+	ExcludedKeys []string `toml:"excluded_keys"`
+	// Tags are the keys of the values added as tags.
+	Tags []string `toml:"tags"`
+	// Renames are the names of the values by key.
+	Renames map[string]string `toml:"renames"`
+	// Fields are the types of the fields by key.
+	Fields map[string]string `toml:"fields"`
+}
+
+// Parser converts the values selected by each config to metrics.
+type Parser struct {
+	MetricName  string
+	Configs     []Config
+	DefaultTags map[string]string
+}
+
+// New returns a parser for the configs, or an error if a config is invalid.
+func New(metricName string, configs []Config, defaultTags map[string]string) (*Parser, error) {
+	if len(configs) == 0 {
+		return nil, errors.New("no json_v2 configuration, add a [[inputs.<name>.json_v2]] table")
+	}
+
+	for i := range configs {
+		config := &configs[i]
+		for _, sets := range [][]DataSet{config.Fields, config.Tags} {
+			for j := range sets {
+				set := &sets[j]
+				if set.Path == "" {
+					return nil, errors.New("path is required for field and tag selections")
+				}
+				if set.Rename == "" {
+					set.Rename = lastKey(set.Path)
+				}
+				if set.Rename == "" {
+					return nil, fmt.Errorf("rename is required for path %q", set.Path)
+				}
+				if err := checkType(set.Type); err != nil {
+					return nil, err
+				}
+			}
+		}
+		for _, object := range config.Objects {
+			if object.Path == "" {
+				return nil, errors.New("path is required for object selections")
+			}
+			for _, typ := range object.Fields {
+				if err := checkType(typ); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return &Parser{
+		MetricName:  metricName,
+		Configs:     configs,
+		DefaultTags: defaultTags,
+	}, nil
+}
+
+// Parse converts the values selected by each config to metrics.
+func (p *Parser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	buf = bytes.TrimPrefix(bytes.TrimSpace(buf), utf8BOM)
+	if len(buf) == 0 {
+		return make([]telegraf.Metric, 0), nil
+	}
+	if !gjson.ValidBytes(buf) {
+		return nil, errors.New("invalid JSON")
+	}
+
+	now := time.Now()
+	metrics := make([]telegraf.Metric, 0)
+	for i := range p.Configs {
+		m, err := p.parseConfig(buf, &p.Configs[i], now)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m...)
+	}
+	return metrics, nil
+}
+
+// ParseLine parses the line as a JSON document, it must result in a single
+// metric.
+func (p *Parser) ParseLine(line string) (telegraf.Metric, error) {
+	metrics, err := p.Parse([]byte(line))
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(metrics) {
+	case 0:
+		return nil, nil
+	case 1:
+		return metrics[0], nil
+	default:
+		return metrics[0], fmt.Errorf("cannot parse line with multiple (%d) metrics", len(metrics))
+	}
+}
+
+func (p *Parser) SetDefaultTags(tags map[string]string) {
+	p.DefaultTags = tags
+}
+
+func (p *Parser) parseConfig(buf []byte, config *Config, now time.Time) ([]telegraf.Metric, error) {
+	name := p.MetricName
+	if config.MeasurementName != "" {
+		name = config.MeasurementName
+	}
+	if config.MeasurementNamePath != "" {
+		if result := gjson.GetBytes(buf, config.MeasurementNamePath); result.String() != "" {
+			name = result.String()
+		}
+	}
+
+	timestamp := now
+	if config.TimestampPath != "" {
+		result := gjson.GetBytes(buf, config.TimestampPath)
+		if !result.Exists() {
+			return nil, fmt.Errorf("timestamp_path %q not found", config.TimestampPath)
+		}
+		var err error
+		timestamp, err = parseTimestamp(result, config.TimestampFormat, config.TimestampTimezone)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The values of the field and tag selections are shared by all metrics.
+	shared := []*row{{}}
+	for _, set := range config.Tags {
+		rows, err := selectDataSet(buf, set, true)
+		if err != nil {
+			return nil, err
+		}
+		shared = product(shared, rows)
+	}
+	for _, set := range config.Fields {
+		rows, err := selectDataSet(buf, set, false)
+		if err != nil {
+			return nil, err
+		}
+		shared = product(shared, rows)
+	}
+
+	rows := shared
+	if len(config.Objects) != 0 {
+		rows = nil
+		for i := range config.Objects {
+			object := &config.Objects[i]
+			result := gjson.GetBytes(buf, object.Path)
+			if !result.Exists() {
+				continue
+			}
+			objectRows, err := walk(object, "", result)
+			if err != nil {
+				return nil, err
+			}
+			if len(objectRows) == 0 {
+				continue
+			}
+			rows = append(rows, product(shared, objectRows)...)
+		}
+	}
+
+	metrics := make([]telegraf.Metric, 0, len(rows))
+	for _, r := range rows {
+		if len(r.fields) == 0 {
+			continue
+		}
+
+		tags := make(map[string]string, len(p.DefaultTags)+len(r.tags))
+		for k, v := range p.DefaultTags {
+			tags[k] = v
+		}
+		for k, v := range r.tags {
+			tags[k] = v
+		}
+
+		t := timestamp
+		if r.time != nil {
+			t = *r.time
+		}
+
+		m, err := metric.New(name, tags, r.fields, t)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, nil
+}
+
+// row holds the values of a metric.
+type row struct {
+	tags   map[string]string
+	fields map[string]interface{}
+	time   *time.Time
+}
+
+func (r *row) addTag(key, value string) {
+	if r.tags == nil {
+		r.tags = make(map[string]string)
+	}
+	r.tags[key] = value
+}
+
+func (r *row) addField(key string, value interface{}) {
+	if r.fields == nil {
+		r.fields = make(map[string]interface{})
+	}
+	r.fields[key] = value
+}
+
+// merge returns a new row with the values of both rows, the values of other
+// take precedence.
+func (r *row) merge(other *row) *row {
+	merged := &row{time: r.time}
+	for k, v := range r.tags {
+		merged.addTag(k, v)
+	}
+	for k, v := range r.fields {
+		merged.addField(k, v)
+	}
+	for k, v := range other.tags {
+		merged.addTag(k, v)
+	}
+	for k, v := range other.fields {
+		merged.addField(k, v)
+	}
+	if other.time != nil {
+		merged.time = other.time
+	}
+	return merged
+}
+
+// product returns the merge of each row of a with each row of b.  Empty
+// selections leave the rows unchanged.
+func product(a, b []*row) []*row {
+	if len(b) == 0 {
+		return a
+	}
+	rows := make([]*row, 0, len(a)*len(b))
+	for _, ra := range a {
+		for _, rb := range b {
+			rows = append(rows, ra.merge(rb))
+		}
+	}
+	return rows
+}
+
+// nonEmpty returns the rows with values, so nested arrays whose values are
+// all excluded do not multiply the rows of their parent.
+func nonEmpty(rows []*row) []*row {
+	var result []*row
+	for _, r := range rows {
+		if len(r.tags) != 0 || len(r.fields) != 0 || r.time != nil {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// selectDataSet returns a row per value selected by the data set, arrays
+// are expanded into a row per element.
+func selectDataSet(buf []byte, set DataSet, tag bool) ([]*row, error) {
+	var rows []*row
+	var err error
+	var each func(result gjson.Result) bool
+	each = func(result gjson.Result) bool {
+		switch {
+		case result.IsArray():
+			result.ForEach(func(_, elem gjson.Result) bool {
+				return each(elem)
+			})
+		case result.IsObject(), result.Type == gjson.Null:
+		default:
+			r := &row{}
+			if tag {
+				r.addTag(set.Rename, result.String())
+			} else {
+				var value interface{}
+				value, err = convert(result, set.Type)
+				if err != nil {
+					err = fmt.Errorf("converting %q: %v", set.Rename, err)
+					return false
+				}
+				r.addField(set.Rename, value)
+			}
+			rows = append(rows, r)
+		}
+		return err == nil
+	}
+	each(gjson.GetBytes(buf, set.Path))
+	return rows, err
+}
+
+// walk returns the rows of the result, whose values are named after key.
+// Objects are flattened and arrays are expanded into a row per element, the
+// rows of nested arrays carry the values of their parents.
+func walk(object *Object, key string, result gjson.Result) ([]*row, error) {
+	switch {
+	case result.IsArray():
+		var rows []*row
+		var err error
+		result.ForEach(func(_, elem gjson.Result) bool {
+			var elemRows []*row
+			elemRows, err = walk(object, key, elem)
+			rows = append(rows, elemRows...)
+			return err == nil
+		})
+		return rows, err
+	case result.IsObject():
+		rows := []*row{{}}
+		var err error
+		result.ForEach(func(k, value gjson.Result) bool {
+			childKey := k.String()
+			if key != "" && !object.DisablePrependKeys {
+				childKey = key + "_" + childKey
+			}
+
+			if value.IsArray() || value.IsObject() {
+				var childRows []*row
+				childRows, err = walk(object, childKey, value)
+				rows = product(rows, nonEmpty(childRows))
+				return err == nil
+			}
+			for _, r := range rows {
+				if err = addValue(object, r, childKey, value); err != nil {
+					return false
+				}
+			}
+			return true
+		})
+		return rows, err
+	case result.Type == gjson.Null || !result.Exists():
+		return nil, nil
+	default:
+		if key == "" {
+			key = lastKey(object.Path)
+		}
+		r := &row{}
+		if err := addValue(object, r, key, result); err != nil {
+			return nil, err
+		}
+		return []*row{r}, nil
+	}
+}
+
+// addValue adds the value to the row as the timestamp, a tag or a field
+// according to the key.
+func addValue(object *Object, r *row, key string, value gjson.Result) error {
+	if value.Type == gjson.Null || key == "" {
+		return nil
+	}
+
+	if object.TimestampKey != "" && key == object.TimestampKey {
+		t, err := parseTimestamp(value, object.TimestampFormat, object.TimestampTimezone)
+		if err != nil {
+			return err
+		}
+		r.time = &t
+		return nil
+	}
+
+	if len(object.IncludedKeys) != 0 && !contains(object.IncludedKeys, key) {
+		return nil
+	}
+	if contains(object.ExcludedKeys, key) {
+		return nil
+	}
+
+	name := key
+	if rename, ok := object.Renames[key]; ok {
+		name = rename
+	}
+
+	if contains(object.Tags, key) {
+		r.addTag(name, value.String())
+		return nil
+	}
+
+	v, err := convert(value, object.Fields[key])
+	if err != nil {
+		return fmt.Errorf("converting %q: %v", key, err)
+	}
+	r.addField(name, v)
+	return nil
+}
+
+func parseTimestamp(result gjson.Result, format, timezone string) (time.Time, error) {
+	if format == "" {
+		format = "unix"
+	}
+	t, err := internal.ParseTimestamp(format, result.String(), timezone)
+	if err != nil {
+		return t, fmt.Errorf("parsing timestamp %q: %v", result.String(), err)
+	}
+	return t, nil
+}
+
+func checkType(typ string) error {
+	switch typ {
+	case "", "int", "uint", "float", "string", "bool":
+		return nil
+	default:
+		return fmt.Errorf("unknown type %q", typ)
+	}
+}
+
+// convert returns the value of the result converted to the type, or its
+// JSON type if typ is empty.
+func convert(result gjson.Result, typ string) (interface{}, error) {
+	switch typ {
+	case "int":
+		switch result.Type {
+		case gjson.Number:
+			if v, err := strconv.ParseInt(result.Raw, 10, 64); err == nil {
+				return v, nil
+			}
+			return int64(result.Num), nil
+		case gjson.True:
+			return int64(1), nil
+		case gjson.False:
+			return int64(0), nil
+		default:
+			return strconv.ParseInt(strings.TrimSpace(result.Str), 10, 64)
+		}
+	case "uint":
+		switch result.Type {
+		case gjson.Number:
+			if v, err := strconv.ParseUint(result.Raw, 10, 64); err == nil {
+				return v, nil
+			}
+			if result.Num < 0 {
+				return nil, fmt.Errorf("negative value %s", result.Raw)
+			}
+			return uint64(result.Num), nil
+		case gjson.True:
+			return uint64(1), nil
+		case gjson.False:
+			return uint64(0), nil
+		default:
+			return strconv.ParseUint(strings.TrimSpace(result.Str), 10, 64)
+		}
+	case "float":
+		switch result.Type {
+		case gjson.Number:
+			return result.Num, nil
+		case gjson.True:
+			return float64(1), nil
+		case gjson.False:
+			return float64(0), nil
+		default:
+			return strconv.ParseFloat(strings.TrimSpace(result.Str), 64)
+		}
+	case "string":
+		return result.String(), nil
+	case "bool":
+		switch result.Type {
+		case gjson.Number:
+			return result.Num != 0, nil
+		case gjson.True, gjson.False:
+			return result.Bool(), nil
+		default:
+			return strconv.ParseBool(strings.TrimSpace(result.Str))
+		}
+	default:
+		return result.Value(), nil
+	}
+}
+
+// lastKey returns the last key of the path, skipping array indexes and
+// queries.
+func lastKey(path string) string {
+	parts := strings.Split(path, ".")
+	for i := len(parts) - 1; i >= 0; i-- {
+		part := parts[i]
+		if part == "" || strings.HasPrefix(part, "#") || strings.HasPrefix(part, "@") {
+			continue
+		}
+		if _, err := strconv.Atoi(part); err == nil {
+			continue
+		}
+		return part
+	}
+	return ""
+}
+
+func contains(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,197 @@
+package json_v2
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+const sites = `
+{
+  "name": "weather",
+  "time": 1600000000,
+  "version": "1.2",
+  "sites": [
+    {
+      "site": "north",
+      "updated": "2020-09-13 12:26:40",
+      "stations": [
+        {"id": "n1", "readings": {"temp": 21.5, "humidity": "40"}, "online": true},
+        {"id": "n2", "readings": {"temp": 19, "humidity": "45"}, "online": false}
+      ]
+    },
+    {
+      "site": "south",
+      "updated": "2020-09-13 12:26:50",
+      "stations": [
+        {"id": "s1", "readings": {"temp": 25.25, "humidity": null}, "online": true}
+      ]
+    }
+  ]
+}
+`
+
+func TestParseFieldsAndTags(t *testing.T) {
+	parser, err := New("json_v2", []Config{
+		{
+			MeasurementNamePath: "name",
+			TimestampPath:       "time",
+			Tags:                []DataSet{{Path: "sites.#.site"}},
+			Fields: []DataSet{
+				{Path: "version", Type: "float"},
+				{Path: "sites.#.stations.#", Rename: "stations", Type: "int"},
+			},
+		},
+	}, map[string]string{"host": "localhost"})
+	require.NoError(t, err)
+
+	metrics, err := parser.Parse([]byte(sites))
+	require.NoError(t, err)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("weather",
+			map[string]string{"host": "localhost", "site": "north"},
+			map[string]interface{}{"version": 1.2, "stations": int64(2)},
+			time.Unix(1600000000, 0)),
+		testutil.MustMetric("weather",
+			map[string]string{"host": "localhost", "site": "north"},
+			map[string]interface{}{"version": 1.2, "stations": int64(1)},
+			time.Unix(1600000000, 0)),
+		testutil.MustMetric("weather",
+			map[string]string{"host": "localhost", "site": "south"},
+			map[string]interface{}{"version": 1.2, "stations": int64(2)},
+			time.Unix(1600000000, 0)),
+		testutil.MustMetric("weather",
+			map[string]string{"host": "localhost", "site": "south"},
+			map[string]interface{}{"version": 1.2, "stations": int64(1)},
+			time.Unix(1600000000, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, metrics)
+}
+
+func TestParseObjects(t *testing.T) {
+	parser, err := New("station", []Config{
+		{
+			Tags: []DataSet{{Path: "name", Rename: "source"}},
+			Objects: []Object{
+				{
+					Path:            "sites",
+					TimestampKey:    "updated",
+					TimestampFormat: "2006-01-02 15:04:05",
+					Tags:            []string{"site", "stations_id"},
+					Renames:         map[string]string{"stations_id": "id"},
+					Fields: map[string]string{
+						"stations_readings_humidity": "int",
+						"stations_online":            "bool",
+					},
+				},
+			},
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	metrics, err := parser.Parse([]byte(sites))
+	require.NoError(t, err)
+
+	north := time.Date(2020, 9, 13, 12, 26, 40, 0, time.UTC)
+	south := time.Date(2020, 9, 13, 12, 26, 50, 0, time.UTC)
+	expected := []telegraf.Metric{
+		testutil.MustMetric("station",
+			map[string]string{"source": "weather", "site": "north", "id": "n1"},
+			map[string]interface{}{
+				"stations_readings_temp":     21.5,
+				"stations_readings_humidity": int64(40),
+				"stations_online":            true,
+			},
+			north),
+		testutil.MustMetric("station",
+			map[string]string{"source": "weather", "site": "north", "id": "n2"},
+			map[string]interface{}{
+				"stations_readings_temp":     float64(19),
+				"stations_readings_humidity": int64(45),
+				"stations_online":            false,
+			},
+			north),
+		testutil.MustMetric("station",
+			map[string]string{"source": "weather", "site": "south", "id": "s1"},
+			map[string]interface{}{
+				"stations_readings_temp": 25.25,
+				"stations_online":        true,
+			},
+			south),
+	}
+	testutil.RequireMetricsEqual(t, expected, metrics)
+}
+
+func TestParseObjectKeys(t *testing.T) {
+	parser, err := New("station", []Config{
+		{
+			TimestampPath: "time",
+			Objects: []Object{
+				{
+					Path:               "sites.#.stations",
+					DisablePrependKeys: true,
+					ExcludedKeys:       []string{"humidity"},
+					Tags:               []string{"id"},
+				},
+				{
+					Path:         "sites.0",
+					IncludedKeys: []string{"site"},
+					Fields:       map[string]string{"site": "string"},
+				},
+			},
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	metrics, err := parser.Parse([]byte(sites))
+	require.NoError(t, err)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("station",
+			map[string]string{"id": "n1"},
+			map[string]interface{}{"temp": 21.5, "online": true},
+			time.Unix(1600000000, 0)),
+		testutil.MustMetric("station",
+			map[string]string{"id": "n2"},
+			map[string]interface{}{"temp": float64(19), "online": false},
+			time.Unix(1600000000, 0)),
+		testutil.MustMetric("station",
+			map[string]string{"id": "s1"},
+			map[string]interface{}{"temp": 25.25, "online": true},
+			time.Unix(1600000000, 0)),
+		testutil.MustMetric("station",
+			map[string]string{},
+			map[string]interface{}{"site": "north"},
+			time.Unix(1600000000, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, metrics)
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := New("json_v2", nil, nil)
+	require.Error(t, err)
+
+	_, err = New("json_v2", []Config{{Fields: []DataSet{{Path: "a", Type: "time"}}}}, nil)
+	require.EqualError(t, err, `unknown type "time"`)
+
+	_, err = New("json_v2", []Config{{Fields: []DataSet{{Path: "#"}}}}, nil)
+	require.EqualError(t, err, `rename is required for path "#"`)
+
+	parser, err := New("json_v2", []Config{{Fields: []DataSet{{Path: "site", Type: "int"}}}}, nil)
+	require.NoError(t, err)
+	_, err = parser.Parse([]byte(`{"site": "north"}`))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `converting "site"`)
+
+	_, err = parser.Parse([]byte(`{"site": `))
+	require.EqualError(t, err, "invalid JSON")
+
+	parser, err = New("json_v2", []Config{{TimestampPath: "time", Fields: []DataSet{{Path: "site"}}}}, nil)
+	require.NoError(t, err)
+	_, err = parser.Parse([]byte(`{"site": "north"}`))
+	require.EqualError(t, err, `timestamp_path "time" not found`)
+}
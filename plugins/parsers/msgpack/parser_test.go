@@ -0,0 +1,89 @@
+package msgpack
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/serializers/msgpack"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/tinylib/msgp/msgp"
+)
+
+func TestParse(t *testing.T) {
+	metrics := []telegraf.Metric{
+		testutil.MustMetric("cpu",
+			map[string]string{"cpu": "cpu0", "host": "server01"},
+			map[string]interface{}{
+				"usage_idle": 91.5,
+				"count":      int64(3),
+				"ticks":      uint64(42),
+				"online":     true,
+				"state":      "idle",
+			},
+			time.Unix(1600000000, 123456789)),
+		testutil.MustMetric("mem",
+			map[string]string{},
+			map[string]interface{}{"used": uint64(1 << 20)},
+			time.Unix(1600000001, 0)),
+	}
+	buf, err := msgpack.NewSerializer().SerializeBatch(metrics)
+	require.NoError(t, err)
+
+	parser := &Parser{DefaultTags: map[string]string{"host": "localhost"}}
+	actual, err := parser.Parse(buf)
+	require.NoError(t, err)
+
+	// Default tags do not override the tags of the metrics.
+	metrics[1].AddTag("host", "localhost")
+	testutil.RequireMetricsEqual(t, metrics, actual)
+}
+
+func TestParseForeignEncoding(t *testing.T) {
+	// Encoded with the generic msgp functions: a 32 bit timestamp, an unknown
+	// key, a float32 and a nil field.
+	buf := msgp.AppendMapHeader(nil, 5)
+	buf = msgp.AppendString(buf, "name")
+	buf = msgp.AppendString(buf, "cpu")
+	buf = msgp.AppendString(buf, "time")
+	buf = append(buf, 0xd6, 0xff, 0x5f, 0x5e, 0x10, 0x00)
+	buf = msgp.AppendString(buf, "version")
+	buf = msgp.AppendInt(buf, 1)
+	buf = msgp.AppendString(buf, "tags")
+	buf = msgp.AppendMapStrStr(buf, map[string]string{"cpu": "cpu0"})
+	buf = msgp.AppendString(buf, "fields")
+	buf = msgp.AppendMapHeader(buf, 3)
+	buf = msgp.AppendString(buf, "usage_idle")
+	buf = msgp.AppendFloat32(buf, 91.5)
+	buf = msgp.AppendString(buf, "count")
+	buf = msgp.AppendUint64(buf, 3)
+	buf = msgp.AppendString(buf, "error")
+	buf = msgp.AppendNil(buf)
+
+	parser := &Parser{}
+	m, err := parser.ParseLine(string(buf))
+	require.NoError(t, err)
+
+	// Positive fixints cannot be told apart from integers.
+	expected := testutil.MustMetric("cpu",
+		map[string]string{"cpu": "cpu0"},
+		map[string]interface{}{"usage_idle": 91.5, "count": int64(3)},
+		time.Unix(1600000000, 0))
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{expected}, []telegraf.Metric{m})
+}
+
+func TestParseErrors(t *testing.T) {
+	parser := &Parser{}
+
+	_, err := parser.Parse([]byte("cpu value=42"))
+	require.Error(t, err)
+
+	buf, err := msgpack.NewSerializer().Serialize(testutil.TestMetric(42))
+	require.NoError(t, err)
+	_, err = parser.Parse(buf[:len(buf)-1])
+	require.Error(t, err)
+
+	_, err = parser.ParseLine(string(append(buf, buf...)))
+	require.EqualError(t, err, "more than one metric in line")
+}
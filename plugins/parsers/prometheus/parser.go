@@ -22,6 +22,9 @@ import (
 type Parser struct {
 	DefaultTags map[string]string
 	Header      http.Header
+	// Distribution parses histograms and summaries into a single
+	// distribution field instead of a metric per bucket or quantile.
+	Distribution bool
 }
 
 func (p *Parser) Parse(buf []byte) ([]telegraf.Metric, error) {
@@ -65,7 +68,13 @@ func (p *Parser) Parse(buf []byte) ([]telegraf.Metric, error) {
 			// reading tags
 			tags := MakeLabels(m, p.DefaultTags)
 
-			if mf.GetType() == dto.MetricType_SUMMARY {
+			if p.Distribution && (mf.GetType() == dto.MetricType_SUMMARY || mf.GetType() == dto.MetricType_HISTOGRAM) {
+				// summary or histogram metric as a distribution
+				metric, err := makeDistribution(m, tags, metricName, mf.GetType(), now)
+				if err == nil {
+					metrics = append(metrics, metric)
+				}
+			} else if mf.GetType() == dto.MetricType_SUMMARY {
 				// summary metric
 				telegrafMetrics := makeQuantiles(m, tags, metricName, mf.GetType(), now)
 				metrics = append(metrics, telegrafMetrics...)
@@ -170,6 +179,37 @@ func makeBuckets(m *dto.Metric, tags map[string]string, metricName string, metri
 	return metrics
 }
 
+// Get the distribution of a summary or histogram metric, the +Inf bucket is
+// implied by the count
+func makeDistribution(m *dto.Metric, tags map[string]string, metricName string, metricType dto.MetricType, now time.Time) (telegraf.Metric, error) {
+	var d *telegraf.Distribution
+	if metricType == dto.MetricType_SUMMARY {
+		d = &telegraf.Distribution{
+			Count:     m.GetSummary().GetSampleCount(),
+			Sum:       m.GetSummary().GetSampleSum(),
+			Quantiles: make([]telegraf.Quantile, 0, len(m.GetSummary().Quantile)),
+		}
+		for _, q := range m.GetSummary().Quantile {
+			d.Quantiles = append(d.Quantiles, telegraf.Quantile{Quantile: q.GetQuantile(), Value: q.GetValue()})
+		}
+	} else {
+		d = &telegraf.Distribution{
+			Count:   m.GetHistogram().GetSampleCount(),
+			Sum:     m.GetHistogram().GetSampleSum(),
+			Buckets: make([]telegraf.Bucket, 0, len(m.GetHistogram().Bucket)),
+		}
+		for _, b := range m.GetHistogram().Bucket {
+			if math.IsInf(b.GetUpperBound(), 1) {
+				continue
+			}
+			d.Buckets = append(d.Buckets, telegraf.Bucket{UpperBound: b.GetUpperBound(), Count: b.GetCumulativeCount()})
+		}
+	}
+
+	fields := map[string]interface{}{metricName: d}
+	return metric.New("prometheus", tags, fields, getTimestamp(m, now), ValueType(metricType))
+}
+
 // Get name and value from metric
 func getNameAndValue(m *dto.Metric, metricName string) map[string]interface{} {
 	fields := make(map[string]interface{})
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
 	"github.com/influxdata/telegraf/testutil"
 
 	"github.com/stretchr/testify/assert"
@@ -284,6 +285,77 @@ func TestParsingValidHistogram(t *testing.T) {
 	testutil.RequireMetricsEqual(t, expected, metrics, testutil.IgnoreTime(), testutil.SortMetrics())
 }
 
+func TestParsingValidHistogramDistribution(t *testing.T) {
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"prometheus",
+			map[string]string{
+				"verb":     "POST",
+				"resource": "bindings",
+			},
+			map[string]interface{}{
+				"apiserver_request_latencies": &telegraf.Distribution{
+					Count: 2025,
+					Sum:   1.02726334e+08,
+					Buckets: []telegraf.Bucket{
+						{UpperBound: 125000, Count: 1994},
+						{UpperBound: 250000, Count: 1997},
+						{UpperBound: 500000, Count: 2000},
+						{UpperBound: 1e+06, Count: 2005},
+						{UpperBound: 2e+06, Count: 2012},
+						{UpperBound: 4e+06, Count: 2017},
+						{UpperBound: 8e+06, Count: 2024},
+					},
+				},
+			},
+			time.Unix(0, 0),
+			telegraf.Histogram,
+		),
+	}
+
+	parser := Parser{Distribution: true}
+	metrics, err := parser.Parse([]byte(validUniqueHistogram))
+
+	assert.NoError(t, err)
+	testutil.RequireMetricsEqual(t, expected, metrics, testutil.IgnoreTime())
+
+	// Flattened, the distribution has the layout of the parser without
+	// distributions.
+	flat, err := parse([]byte(validUniqueHistogram))
+	assert.NoError(t, err)
+	testutil.RequireMetricsEqual(t, flat, metric.FlattenDistributions(metrics), testutil.IgnoreTime(), testutil.SortMetrics())
+}
+
+func TestParsingValidSummaryDistribution(t *testing.T) {
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"prometheus",
+			map[string]string{
+				"handler": "prometheus",
+			},
+			map[string]interface{}{
+				"http_request_duration_microseconds": &telegraf.Distribution{
+					Count: 9,
+					Sum:   1.8909097205e+07,
+					Quantiles: []telegraf.Quantile{
+						{Quantile: 0.5, Value: 552048.506},
+						{Quantile: 0.9, Value: 5.876804288e+06},
+						{Quantile: 0.99, Value: 5.876804288e+06},
+					},
+				},
+			},
+			time.Unix(0, 0),
+			telegraf.Summary,
+		),
+	}
+
+	parser := Parser{Distribution: true}
+	metrics, err := parser.Parse([]byte(validUniqueSummary))
+
+	assert.NoError(t, err)
+	testutil.RequireMetricsEqual(t, expected, metrics, testutil.IgnoreTime())
+}
+
 func TestDefautTags(t *testing.T) {
 	expected := []telegraf.Metric{
 		testutil.MustMetric(
@@ -0,0 +1,96 @@
+package prometheusremotewrite
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/prometheus/prometheus/pkg/value"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// Parser decodes Prometheus remote write requests, snappy compressed
+// WriteRequest messages, into metrics, one metric per sample.
+type Parser struct {
+	// MetricVersion selects the metric layout of the prometheus input, 1 names
+	// the metrics after the series with a "value" field, 2 names them
+	// "prometheus" with a field named after the series.  Defaults to 2.
+	MetricVersion int
+	DefaultTags   map[string]string
+}
+
+func (p *Parser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	data, err := snappy.Decode(nil, buf)
+	if err != nil {
+		return nil, fmt.Errorf("decompressing write request: %v", err)
+	}
+
+	var req prompb.WriteRequest
+	if err := proto.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("decoding write request: %v", err)
+	}
+
+	var metrics []telegraf.Metric
+	for _, ts := range req.Timeseries {
+		tags := make(map[string]string, len(p.DefaultTags)+len(ts.Labels))
+		for k, v := range p.DefaultTags {
+			tags[k] = v
+		}
+		var name string
+		for _, l := range ts.Labels {
+			if l.Name == "__name__" {
+				name = l.Value
+				continue
+			}
+			tags[l.Name] = l.Value
+		}
+		if name == "" {
+			return nil, errors.New("series without __name__ label")
+		}
+
+		for _, s := range ts.Samples {
+			// Stale markers mark the end of a series, they are not values.
+			if value.IsStaleNaN(s.Value) {
+				continue
+			}
+
+			measurement, field := "prometheus", name
+			if p.MetricVersion == 1 {
+				measurement, field = name, "value"
+			}
+			fields := map[string]interface{}{field: s.Value}
+			t := time.Unix(0, s.Timestamp*int64(time.Millisecond))
+			m, err := metric.New(measurement, tags, fields, t, telegraf.Untyped)
+			if err != nil {
+				return nil, err
+			}
+			metrics = append(metrics, m)
+		}
+	}
+	return metrics, nil
+}
+
+func (p *Parser) ParseLine(line string) (telegraf.Metric, error) {
+	metrics, err := p.Parse([]byte(line))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(metrics) < 1 {
+		return nil, errors.New("no metrics in line")
+	}
+
+	if len(metrics) > 1 {
+		return nil, errors.New("more than one metric in line")
+	}
+
+	return metrics[0], nil
+}
+
+func (p *Parser) SetDefaultTags(tags map[string]string) {
+	p.DefaultTags = tags
+}
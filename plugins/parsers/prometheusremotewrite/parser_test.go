@@ -0,0 +1,149 @@
+package prometheusremotewrite
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/serializers/prometheusremotewrite"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/prometheus/prometheus/pkg/value"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/require"
+)
+
+func writeRequest(t *testing.T, series ...*prompb.TimeSeries) []byte {
+	data, err := proto.Marshal(&prompb.WriteRequest{Timeseries: series})
+	require.NoError(t, err)
+	return snappy.Encode(nil, data)
+}
+
+func series() []*prompb.TimeSeries {
+	return []*prompb.TimeSeries{
+		{
+			Labels: []*prompb.Label{
+				{Name: "__name__", Value: "go_goroutines"},
+				{Name: "instance", Value: "localhost:9090"},
+			},
+			Samples: []prompb.Sample{
+				{Value: 42, Timestamp: 1600000000000},
+				{Value: math.Float64frombits(value.StaleNaN), Timestamp: 1600000001000},
+				{Value: 43, Timestamp: 1600000002000},
+				{Value: math.NaN(), Timestamp: 1600000003000},
+			},
+		},
+		{
+			Labels: []*prompb.Label{
+				{Name: "__name__", Value: "http_requests_total"},
+				{Name: "code", Value: "200"},
+			},
+			Samples: []prompb.Sample{
+				{Value: 1027, Timestamp: 1600000000500},
+			},
+		},
+	}
+}
+
+func TestParse(t *testing.T) {
+	parser := &Parser{DefaultTags: map[string]string{"host": "localhost"}}
+
+	metrics, err := parser.Parse(writeRequest(t, series()...))
+	require.NoError(t, err)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("prometheus",
+			map[string]string{"host": "localhost", "instance": "localhost:9090"},
+			map[string]interface{}{"go_goroutines": 42.0},
+			time.Unix(1600000000, 0),
+			telegraf.Untyped),
+		testutil.MustMetric("prometheus",
+			map[string]string{"host": "localhost", "instance": "localhost:9090"},
+			map[string]interface{}{"go_goroutines": 43.0},
+			time.Unix(1600000002, 0),
+			telegraf.Untyped),
+		testutil.MustMetric("prometheus",
+			map[string]string{"host": "localhost", "instance": "localhost:9090"},
+			map[string]interface{}{"go_goroutines": math.NaN()},
+			time.Unix(1600000003, 0),
+			telegraf.Untyped),
+		testutil.MustMetric("prometheus",
+			map[string]string{"host": "localhost", "code": "200"},
+			map[string]interface{}{"http_requests_total": 1027.0},
+			time.Unix(1600000000, 500*int64(time.Millisecond)),
+			telegraf.Untyped),
+	}
+	testutil.RequireMetricsEqual(t, expected, metrics)
+}
+
+func TestParseMetricVersion1(t *testing.T) {
+	parser := &Parser{MetricVersion: 1}
+
+	metrics, err := parser.Parse(writeRequest(t, series()[1]))
+	require.NoError(t, err)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("http_requests_total",
+			map[string]string{"code": "200"},
+			map[string]interface{}{"value": 1027.0},
+			time.Unix(1600000000, 500*int64(time.Millisecond)),
+			telegraf.Untyped),
+	}
+	testutil.RequireMetricsEqual(t, expected, metrics)
+}
+
+func TestParseSerializerOutput(t *testing.T) {
+	serializer, err := prometheusremotewrite.NewSerializer(prometheusremotewrite.FormatConfig{})
+	require.NoError(t, err)
+
+	buf, err := serializer.Serialize(testutil.MustMetric("cpu",
+		map[string]string{"cpu": "cpu0"},
+		map[string]interface{}{"time_idle": 42.5},
+		time.Unix(1600000000, 0),
+		telegraf.Gauge))
+	require.NoError(t, err)
+
+	parser := &Parser{}
+	metrics, err := parser.Parse(buf)
+	require.NoError(t, err)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("prometheus",
+			map[string]string{"cpu": "cpu0"},
+			map[string]interface{}{"cpu_time_idle": 42.5},
+			time.Unix(1600000000, 0),
+			telegraf.Untyped),
+	}
+	testutil.RequireMetricsEqual(t, expected, metrics)
+}
+
+func TestParseLine(t *testing.T) {
+	parser := &Parser{}
+
+	m, err := parser.ParseLine(string(writeRequest(t, series()[1])))
+	require.NoError(t, err)
+	require.Equal(t, "prometheus", m.Name())
+
+	_, err = parser.ParseLine(string(writeRequest(t, series()[0])))
+	require.Error(t, err)
+}
+
+func TestParseErrors(t *testing.T) {
+	parser := &Parser{}
+
+	_, err := parser.Parse([]byte("go_goroutines 42"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "decompressing write request")
+
+	_, err = parser.Parse(snappy.Encode(nil, []byte{0xff}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "decoding write request")
+
+	_, err = parser.Parse(writeRequest(t, &prompb.TimeSeries{
+		Labels:  []*prompb.Label{{Name: "job", Value: "telegraf"}},
+		Samples: []prompb.Sample{{Value: 1}},
+	}))
+	require.EqualError(t, err, "series without __name__ label")
+}
@@ -0,0 +1,407 @@
+package protobuf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/parsers/schema_registry"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/dynamic"
+)
+
+// timestampType is the well-known type converted to time values.
+const timestampType = "google.protobuf.Timestamp"
+
+type Config struct {
+	MetricName string
+	// ProtoFile is the .proto file of the messages.
+	ProtoFile string
+	// ImportPaths are the directories searched for the imports of the
+	// .proto file, after its directory.
+	ImportPaths []string
+	// MessageType is the fully qualified name of the message type, defaults
+	// to the first message type of the file.
+	MessageType string
+	// SchemaRegistry is the url of the schema registry of the messages, the
+	// messages are in the Confluent wire format.
+	SchemaRegistry string
+	// Measurement is the name of the metrics, defaults to MetricName.
+	Measurement string
+	// MeasurementField is the field holding the name of the metrics.
+	MeasurementField string
+	// Tags are the fields added as tags.
+	Tags []string
+	// Fields are the fields added as fields, all other fields by default.
+	Fields []string
+	// Timestamp is the field holding the timestamp, defaults to the current
+	// time.
+	Timestamp string
+	// TimestampFormat is the format of the timestamp, defaults to "unix".
+	TimestampFormat string
+	// FieldSeparator joins the names of nested fields, defaults to "_".
+	FieldSeparator string
+	DefaultTags    map[string]string
+}
+
+// Parser decodes Protocol Buffers messages into metrics, one metric per
+// message.
+type Parser struct {
+	config   *Config
+	registry *schema_registry.Client
+	// message is the message type without schema registry.
+	message *desc.MessageDescriptor
+
+	mu    sync.Mutex
+	files map[int]*desc.FileDescriptor
+}
+
+func New(config *Config) (*Parser, error) {
+	if config.FieldSeparator == "" {
+		config.FieldSeparator = "_"
+	}
+	if config.TimestampFormat == "" {
+		config.TimestampFormat = "unix"
+	}
+
+	p := &Parser{
+		config: config,
+		files:  make(map[int]*desc.FileDescriptor),
+	}
+
+	switch {
+	case config.SchemaRegistry != "":
+		registry, err := schema_registry.NewClient(config.SchemaRegistry)
+		if err != nil {
+			return nil, err
+		}
+		p.registry = registry
+	case config.ProtoFile != "":
+		// The directory of the file is searched first for its imports.
+		importPaths := append([]string{filepath.Dir(config.ProtoFile)}, config.ImportPaths...)
+		parser := protoparse.Parser{ImportPaths: importPaths}
+		files, err := parser.ParseFiles(filepath.Base(config.ProtoFile))
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %v", config.ProtoFile, err)
+		}
+
+		p.message, err = findMessage(files[0], config.MessageType)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("one of protobuf_file or protobuf_schema_registry is required")
+	}
+	return p, nil
+}
+
+// findMessage returns the message type of the file with the name, or its
+// first message type if name is empty.
+func findMessage(file *desc.FileDescriptor, name string) (*desc.MessageDescriptor, error) {
+	if name == "" {
+		if len(file.GetMessageTypes()) == 0 {
+			return nil, fmt.Errorf("no message type in %s", file.GetName())
+		}
+		return file.GetMessageTypes()[0], nil
+	}
+
+	message := file.FindMessage(name)
+	if message == nil {
+		return nil, fmt.Errorf("message type %q not found in %s", name, file.GetName())
+	}
+	return message, nil
+}
+
+// fileByID returns the file of the registry schema with the id, the
+// referenced schemas are its imports.
+func (p *Parser) fileByID(id int) (*desc.FileDescriptor, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if file, ok := p.files[id]; ok {
+		return file, nil
+	}
+
+	schema, err := p.registry.SchemaByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if schema.Type != schema_registry.TypeProtobuf {
+		return nil, fmt.Errorf("schema %d has type %s, not %s", id, schema.Type, schema_registry.TypeProtobuf)
+	}
+
+	filename := fmt.Sprintf("schema-%d.proto", id)
+	contents := map[string]string{filename: schema.Schema}
+	if err := p.addReferences(contents, schema.References); err != nil {
+		return nil, fmt.Errorf("schema %d: %v", id, err)
+	}
+
+	parser := protoparse.Parser{Accessor: protoparse.FileContentsFromMap(contents)}
+	files, err := parser.ParseFiles(filename)
+	if err != nil {
+		return nil, fmt.Errorf("parsing schema %d: %v", id, err)
+	}
+	p.files[id] = files[0]
+	return files[0], nil
+}
+
+// addReferences adds the referenced schemas, and their references, to the
+// contents by import name.
+func (p *Parser) addReferences(contents map[string]string, refs []schema_registry.Reference) error {
+	for _, ref := range refs {
+		if _, ok := contents[ref.Name]; ok {
+			continue
+		}
+		schema, err := p.registry.SchemaByReference(ref)
+		if err != nil {
+			return err
+		}
+		contents[ref.Name] = schema.Schema
+		if err := p.addReferences(contents, schema.References); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// registryMessage returns the message type and payload of a message in the
+// Confluent wire format.  The schema id is followed by the indexes of the
+// message type in the file, the first message type if none.
+func (p *Parser) registryMessage(buf []byte) (*desc.MessageDescriptor, []byte, error) {
+	id, buf, err := schema_registry.SplitHeader(buf)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := p.fileByID(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	count, n := binary.Varint(buf)
+	if n <= 0 || count < 0 {
+		return nil, nil, errors.New("invalid message indexes")
+	}
+	buf = buf[n:]
+	indexes := []int64{0}
+	if count > 0 {
+		indexes = make([]int64, count)
+		for i := range indexes {
+			indexes[i], n = binary.Varint(buf)
+			if n <= 0 {
+				return nil, nil, errors.New("invalid message indexes")
+			}
+			buf = buf[n:]
+		}
+	}
+
+	types := file.GetMessageTypes()
+	var message *desc.MessageDescriptor
+	for _, index := range indexes {
+		if index < 0 || index >= int64(len(types)) {
+			return nil, nil, fmt.Errorf("message index %d out of range in schema %d", index, id)
+		}
+		message = types[index]
+		types = message.GetNestedMessageTypes()
+	}
+	return message, buf, nil
+}
+
+func (p *Parser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	message := p.message
+	if p.registry != nil {
+		var err error
+		if message, buf, err = p.registryMessage(buf); err != nil {
+			return nil, err
+		}
+	}
+
+	msg := dynamic.NewMessage(message)
+	if err := msg.Unmarshal(buf); err != nil {
+		return nil, fmt.Errorf("decoding %s message: %v", message.GetFullyQualifiedName(), err)
+	}
+
+	values := make(map[string]interface{})
+	p.flatten(values, "", msg)
+
+	m, err := p.createMetric(values)
+	if err != nil {
+		return nil, err
+	}
+	return []telegraf.Metric{m}, nil
+}
+
+func (p *Parser) ParseLine(line string) (telegraf.Metric, error) {
+	metrics, err := p.Parse([]byte(line))
+	if err != nil {
+		return nil, err
+	}
+	return metrics[0], nil
+}
+
+func (p *Parser) SetDefaultTags(tags map[string]string) {
+	p.config.DefaultTags = tags
+}
+
+// flatten adds the fields of the message to the values.  The names of
+// nested messages, map entries and repeated fields are joined with the field
+// separator.  Unset message and oneof fields are skipped.
+func (p *Parser) flatten(values map[string]interface{}, prefix string, msg *dynamic.Message) {
+	md := msg.GetMessageDescriptor()
+	if md.GetFullyQualifiedName() == timestampType {
+		seconds, _ := msg.GetFieldByName("seconds").(int64)
+		nanos, _ := msg.GetFieldByName("nanos").(int32)
+		values[prefix] = time.Unix(seconds, int64(nanos)).UTC()
+		return
+	}
+
+	for _, fd := range md.GetFields() {
+		key := p.join(prefix, fd.GetName())
+		v := msg.GetField(fd)
+		switch {
+		case fd.IsMap():
+			entries, _ := v.(map[interface{}]interface{})
+			for k, entry := range entries {
+				p.addValue(values, p.join(key, fmt.Sprint(k)), fd.GetMapValueType(), entry)
+			}
+		case fd.IsRepeated():
+			elems, _ := v.([]interface{})
+			for i, elem := range elems {
+				p.addValue(values, p.join(key, strconv.Itoa(i)), fd, elem)
+			}
+		default:
+			if (fd.GetMessageType() != nil || fd.GetOneOf() != nil) && !msg.HasField(fd) {
+				continue
+			}
+			p.addValue(values, key, fd, v)
+		}
+	}
+}
+
+func (p *Parser) addValue(values map[string]interface{}, key string, fd *desc.FieldDescriptor, value interface{}) {
+	switch v := value.(type) {
+	case *dynamic.Message:
+		p.flatten(values, key, v)
+	case proto.Message:
+		if msg, err := dynamic.AsDynamicMessage(v); err == nil {
+			p.flatten(values, key, msg)
+		}
+	case int32:
+		if enum := fd.GetEnumType(); enum != nil {
+			if ev := enum.FindValueByNumber(v); ev != nil {
+				values[key] = ev.GetName()
+				return
+			}
+		}
+		values[key] = int64(v)
+	case uint32:
+		values[key] = uint64(v)
+	case float32:
+		values[key] = float64(v)
+	case []byte:
+		values[key] = string(v)
+	default:
+		values[key] = v
+	}
+}
+
+func (p *Parser) join(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + p.config.FieldSeparator + key
+}
+
+func (p *Parser) createMetric(values map[string]interface{}) (telegraf.Metric, error) {
+	name := p.config.MetricName
+	if p.config.Measurement != "" {
+		name = p.config.Measurement
+	}
+	if p.config.MeasurementField != "" {
+		if v, ok := values[p.config.MeasurementField]; ok {
+			name = fmt.Sprint(v)
+		}
+	}
+
+	timestamp := time.Now()
+	if p.config.Timestamp != "" {
+		v, ok := values[p.config.Timestamp]
+		if !ok {
+			return nil, fmt.Errorf("timestamp field %q not found", p.config.Timestamp)
+		}
+		t, err := parseTimestamp(p.config.TimestampFormat, v)
+		if err != nil {
+			return nil, fmt.Errorf("parsing timestamp field %q: %v", p.config.Timestamp, err)
+		}
+		timestamp = t
+	}
+
+	tags := make(map[string]string)
+	for k, v := range p.config.DefaultTags {
+		tags[k] = v
+	}
+	for _, tag := range p.config.Tags {
+		if v, ok := values[tag]; ok {
+			tags[tag] = toString(v)
+		}
+	}
+
+	fields := make(map[string]interface{})
+	if len(p.config.Fields) != 0 {
+		for _, field := range p.config.Fields {
+			if v, ok := values[field]; ok {
+				fields[field] = toField(v)
+			}
+		}
+	} else {
+		for k, v := range values {
+			if k == p.config.Timestamp || k == p.config.MeasurementField || contains(p.config.Tags, k) {
+				continue
+			}
+			fields[k] = toField(v)
+		}
+	}
+
+	return metric.New(name, tags, fields, timestamp)
+}
+
+func parseTimestamp(format string, value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case uint64:
+		value = int64(v)
+	}
+	return internal.ParseTimestamp(format, value, "")
+}
+
+func toString(value interface{}) string {
+	if t, ok := value.(time.Time); ok {
+		return t.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(value)
+}
+
+func toField(value interface{}) interface{} {
+	if t, ok := value.(time.Time); ok {
+		return t.UnixNano()
+	}
+	return value
+}
+
+func contains(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
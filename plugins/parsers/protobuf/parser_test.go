@@ -0,0 +1,180 @@
+package protobuf
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/stretchr/testify/require"
+)
+
+// reading returns an encoded telegraf.test.Reading message.
+func reading(t *testing.T) []byte {
+	parser := protoparse.Parser{ImportPaths: []string{"testdata"}}
+	files, err := parser.ParseFiles("sensor.proto")
+	require.NoError(t, err)
+
+	md := files[0].FindMessage("telegraf.test.Reading")
+	msg := dynamic.NewMessage(md)
+	msg.SetFieldByName("device", "plc1")
+	msg.SetFieldByName("value", 21.5)
+	msg.SetFieldByName("unit", int32(1))
+	msg.SetFieldByName("samples", []int32{1, 2})
+	msg.SetFieldByName("labels", map[string]string{"line": "a"})
+	msg.SetFieldByName("ok", true)
+
+	ts := dynamic.NewMessage(md.FindFieldByName("time").GetMessageType())
+	ts.SetFieldByName("seconds", int64(1600000000))
+	msg.SetFieldByName("time", ts)
+
+	location := dynamic.NewMessage(md.FindFieldByName("location").GetMessageType())
+	location.SetFieldByName("site", "north")
+	location.SetFieldByName("rack", uint32(2))
+	msg.SetFieldByName("location", location)
+
+	buf, err := msg.Marshal()
+	require.NoError(t, err)
+	return buf
+}
+
+func TestParseProtoFile(t *testing.T) {
+	parser, err := New(&Config{
+		MetricName:  "protobuf",
+		ProtoFile:   "testdata/sensor.proto",
+		MessageType: "telegraf.test.Reading",
+		Measurement: "reading",
+		Tags:        []string{"device", "location_site", "labels_line"},
+		Timestamp:   "time",
+		DefaultTags: map[string]string{"host": "localhost"},
+	})
+	require.NoError(t, err)
+
+	metrics, err := parser.Parse(reading(t))
+	require.NoError(t, err)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("reading",
+			map[string]string{
+				"host":          "localhost",
+				"device":        "plc1",
+				"location_site": "north",
+				"labels_line":   "a",
+			},
+			map[string]interface{}{
+				"value":         21.5,
+				"unit":          "FAHRENHEIT",
+				"samples_0":     int64(1),
+				"samples_1":     int64(2),
+				"location_rack": uint64(2),
+				"ok":            true,
+			},
+			time.Unix(1600000000, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, metrics)
+}
+
+func TestParseFields(t *testing.T) {
+	parser, err := New(&Config{
+		MetricName:       "protobuf",
+		ProtoFile:        "testdata/sensor.proto",
+		ImportPaths:      []string{"testdata"},
+		MeasurementField: "location.site",
+		Fields:           []string{"value", "error"},
+		FieldSeparator:   ".",
+	})
+	require.NoError(t, err)
+
+	metrics, err := parser.Parse(reading(t))
+	require.NoError(t, err)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("north",
+			map[string]string{},
+			map[string]interface{}{"value": 21.5},
+			time.Unix(0, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, metrics, testutil.IgnoreTime())
+}
+
+func TestParseSchemaRegistry(t *testing.T) {
+	sensor, err := ioutil.ReadFile("testdata/sensor.proto")
+	require.NoError(t, err)
+	location, err := ioutil.ReadFile("testdata/location.proto")
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/schemas/ids/3":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"schemaType": "PROTOBUF",
+				"schema":     string(sensor),
+				"references": []map[string]interface{}{
+					{"name": "location.proto", "subject": "location", "version": 1},
+				},
+			})
+		case "/subjects/location/versions/1":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"schemaType": "PROTOBUF",
+				"schema":     string(location),
+			})
+		case "/schemas/ids/4":
+			json.NewEncoder(w).Encode(map[string]interface{}{"schema": `"string"`})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	parser, err := New(&Config{
+		MetricName:     "protobuf",
+		SchemaRegistry: ts.URL,
+		Tags:           []string{"device"},
+		Fields:         []string{"value"},
+		Timestamp:      "time",
+	})
+	require.NoError(t, err)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("protobuf",
+			map[string]string{"device": "plc1"},
+			map[string]interface{}{"value": 21.5},
+			time.Unix(1600000000, 0)),
+	}
+
+	// Without message indexes the first message type is used.
+	msg := append([]byte{0, 0, 0, 0, 3, 0}, reading(t)...)
+	metrics, err := parser.Parse(msg)
+	require.NoError(t, err)
+	testutil.RequireMetricsEqual(t, expected, metrics)
+
+	// One index 0, zigzag encoded.
+	msg = append([]byte{0, 0, 0, 0, 3, 2, 0}, reading(t)...)
+	metrics, err = parser.Parse(msg)
+	require.NoError(t, err)
+	testutil.RequireMetricsEqual(t, expected, metrics)
+
+	_, err = parser.Parse(append([]byte{0, 0, 0, 0, 3, 2, 4}, reading(t)...))
+	require.EqualError(t, err, "message index 2 out of range in schema 3")
+
+	_, err = parser.Parse([]byte{0, 0, 0, 0, 4, 0})
+	require.EqualError(t, err, "schema 4 has type AVRO, not PROTOBUF")
+}
+
+func TestNewErrors(t *testing.T) {
+	_, err := New(&Config{})
+	require.Error(t, err)
+
+	_, err = New(&Config{ProtoFile: "testdata/missing.proto"})
+	require.Error(t, err)
+
+	_, err = New(&Config{ProtoFile: "testdata/sensor.proto", MessageType: "telegraf.test.Missing"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not found")
+}
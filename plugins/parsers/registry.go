@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/parsers/avro"
 	"github.com/influxdata/telegraf/plugins/parsers/collectd"
 	"github.com/influxdata/telegraf/plugins/parsers/csv"
 	"github.com/influxdata/telegraf/plugins/parsers/dropwizard"
@@ -12,11 +13,16 @@ import (
 	"github.com/influxdata/telegraf/plugins/parsers/grok"
 	"github.com/influxdata/telegraf/plugins/parsers/influx"
 	"github.com/influxdata/telegraf/plugins/parsers/json"
+	"github.com/influxdata/telegraf/plugins/parsers/json_v2"
 	"github.com/influxdata/telegraf/plugins/parsers/logfmt"
+	"github.com/influxdata/telegraf/plugins/parsers/msgpack"
 	"github.com/influxdata/telegraf/plugins/parsers/nagios"
 	"github.com/influxdata/telegraf/plugins/parsers/prometheus"
+	"github.com/influxdata/telegraf/plugins/parsers/prometheusremotewrite"
+	"github.com/influxdata/telegraf/plugins/parsers/protobuf"
 	"github.com/influxdata/telegraf/plugins/parsers/value"
 	"github.com/influxdata/telegraf/plugins/parsers/wavefront"
+	"github.com/influxdata/telegraf/plugins/parsers/xml"
 )
 
 type ParserFunc func() (Parser, error)
@@ -92,6 +98,9 @@ type Config struct {
 	// Whether to continue if a JSON object can't be coerced
 	JSONStrict bool `toml:"json_strict"`
 
+	// selections of the json_v2 parser
+	JSONV2Config []json_v2.Config `toml:"json_v2"`
+
 	// Authentication file for collectd
 	CollectdAuthFile string `toml:"collectd_auth_file"`
 	// One of none (default), sign, or encrypt
@@ -150,6 +159,40 @@ type Config struct {
 
 	// FormData configuration
 	FormUrlencodedTagKeys []string `toml:"form_urlencoded_tag_keys"`
+
+	// Prometheus configuration
+	PrometheusDistribution bool `toml:"prometheus_distribution"`
+
+	// Prometheus remote write configuration
+	PrometheusMetricVersion int `toml:"prometheus_metric_version"`
+
+	// Avro configuration
+	AvroSchema           string   `toml:"avro_schema"`
+	AvroSchemaFile       string   `toml:"avro_schema_file"`
+	AvroSchemaRegistry   string   `toml:"avro_schema_registry"`
+	AvroMeasurement      string   `toml:"avro_measurement"`
+	AvroMeasurementField string   `toml:"avro_measurement_field"`
+	AvroTags             []string `toml:"avro_tags"`
+	AvroFields           []string `toml:"avro_fields"`
+	AvroTimestamp        string   `toml:"avro_timestamp"`
+	AvroTimestampFormat  string   `toml:"avro_timestamp_format"`
+	AvroFieldSeparator   string   `toml:"avro_field_separator"`
+
+	// Protobuf configuration
+	ProtobufFile             string   `toml:"protobuf_file"`
+	ProtobufImportPaths      []string `toml:"protobuf_import_paths"`
+	ProtobufMessageType      string   `toml:"protobuf_message_type"`
+	ProtobufSchemaRegistry   string   `toml:"protobuf_schema_registry"`
+	ProtobufMeasurement      string   `toml:"protobuf_measurement"`
+	ProtobufMeasurementField string   `toml:"protobuf_measurement_field"`
+	ProtobufTags             []string `toml:"protobuf_tags"`
+	ProtobufFields           []string `toml:"protobuf_fields"`
+	ProtobufTimestamp        string   `toml:"protobuf_timestamp"`
+	ProtobufTimestampFormat  string   `toml:"protobuf_timestamp_format"`
+	ProtobufFieldSeparator   string   `toml:"protobuf_field_separator"`
+
+	// XML configuration
+	XMLConfig []xml.Config `toml:"xml"`
 }
 
 // NewParser returns a Parser interface based on the given config.
@@ -172,6 +215,8 @@ func NewParser(config *Config) (Parser, error) {
 				Strict:       config.JSONStrict,
 			},
 		)
+	case "json_v2":
+		parser, err = NewJSONV2Parser(config.MetricName, config.DefaultTags, config.JSONV2Config)
 	case "value":
 		parser, err = NewValueParser(config.MetricName,
 			config.DataType, config.DefaultTags)
@@ -236,7 +281,44 @@ func NewParser(config *Config) (Parser, error) {
 			config.FormUrlencodedTagKeys,
 		)
 	case "prometheus":
-		parser, err = NewPrometheusParser(config.DefaultTags)
+		parser, err = NewPrometheusParser(config.DefaultTags, config.PrometheusDistribution)
+	case "prometheusremotewrite":
+		parser, err = NewPrometheusRemoteWriteParser(config.DefaultTags, config.PrometheusMetricVersion)
+	case "msgpack":
+		parser, err = NewMsgpackParser(config.DefaultTags)
+	case "avro":
+		parser, err = avro.New(&avro.Config{
+			MetricName:       config.MetricName,
+			Schema:           config.AvroSchema,
+			SchemaFile:       config.AvroSchemaFile,
+			SchemaRegistry:   config.AvroSchemaRegistry,
+			Measurement:      config.AvroMeasurement,
+			MeasurementField: config.AvroMeasurementField,
+			Tags:             config.AvroTags,
+			Fields:           config.AvroFields,
+			Timestamp:        config.AvroTimestamp,
+			TimestampFormat:  config.AvroTimestampFormat,
+			FieldSeparator:   config.AvroFieldSeparator,
+			DefaultTags:      config.DefaultTags,
+		})
+	case "protobuf":
+		parser, err = protobuf.New(&protobuf.Config{
+			MetricName:       config.MetricName,
+			ProtoFile:        config.ProtobufFile,
+			ImportPaths:      config.ProtobufImportPaths,
+			MessageType:      config.ProtobufMessageType,
+			SchemaRegistry:   config.ProtobufSchemaRegistry,
+			Measurement:      config.ProtobufMeasurement,
+			MeasurementField: config.ProtobufMeasurementField,
+			Tags:             config.ProtobufTags,
+			Fields:           config.ProtobufFields,
+			Timestamp:        config.ProtobufTimestamp,
+			TimestampFormat:  config.ProtobufTimestampFormat,
+			FieldSeparator:   config.ProtobufFieldSeparator,
+			DefaultTags:      config.DefaultTags,
+		})
+	case "xml":
+		parser, err = NewXMLParser(config.MetricName, config.DefaultTags, config.XMLConfig)
 	default:
 		err = fmt.Errorf("Invalid data format: %s", config.DataFormat)
 	}
@@ -345,8 +427,30 @@ func NewFormUrlencodedParser(
 	}, nil
 }
 
-func NewPrometheusParser(defaultTags map[string]string) (Parser, error) {
+func NewPrometheusParser(defaultTags map[string]string, distribution bool) (Parser, error) {
 	return &prometheus.Parser{
+		DefaultTags:  defaultTags,
+		Distribution: distribution,
+	}, nil
+}
+
+func NewPrometheusRemoteWriteParser(defaultTags map[string]string, metricVersion int) (Parser, error) {
+	return &prometheusremotewrite.Parser{
+		MetricVersion: metricVersion,
+		DefaultTags:   defaultTags,
+	}, nil
+}
+
+func NewMsgpackParser(defaultTags map[string]string) (Parser, error) {
+	return &msgpack.Parser{
 		DefaultTags: defaultTags,
 	}, nil
 }
+
+func NewJSONV2Parser(metricName string, defaultTags map[string]string, jsonV2Configs []json_v2.Config) (Parser, error) {
+	return json_v2.New(metricName, jsonV2Configs, defaultTags)
+}
+
+func NewXMLParser(metricName string, defaultTags map[string]string, xmlConfigs []xml.Config) (Parser, error) {
+	return xml.New(metricName, xmlConfigs, defaultTags)
+}
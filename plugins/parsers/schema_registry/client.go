@@ -0,0 +1,146 @@
+package schema_registry
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Schema types of the registry, schemas without type are Avro schemas.
+const (
+	TypeAvro     = "AVRO"
+	TypeProtobuf = "PROTOBUF"
+)
+
+// magicByte starts the messages of the Confluent wire format.
+const magicByte = 0
+
+// Schema is a schema of the registry.
+type Schema struct {
+	Type       string      `json:"schemaType"`
+	Schema     string      `json:"schema"`
+	References []Reference `json:"references"`
+}
+
+// Reference is a schema imported by a schema, by its subject and version.
+type Reference struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+// Client fetches schemas from a Confluent compatible schema registry.
+// Schemas are immutable, they are cached once fetched.
+type Client struct {
+	url    string
+	client *http.Client
+
+	mu       sync.Mutex
+	schemas  map[int]*Schema
+	versions map[string]*Schema
+}
+
+// NewClient returns a client of the registry at the url.  Credentials in the
+// url are sent with basic authentication.
+func NewClient(registryURL string) (*Client, error) {
+	u, err := url.Parse(registryURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema registry url: %v", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid schema registry url %q: scheme must be http or https", registryURL)
+	}
+
+	return &Client{
+		url:      strings.TrimSuffix(registryURL, "/"),
+		client:   &http.Client{Timeout: 10 * time.Second},
+		schemas:  make(map[int]*Schema),
+		versions: make(map[string]*Schema),
+	}, nil
+}
+
+// SchemaByID returns the schema with the id.
+func (c *Client) SchemaByID(id int) (*Schema, error) {
+	c.mu.Lock()
+	schema, ok := c.schemas[id]
+	c.mu.Unlock()
+	if ok {
+		return schema, nil
+	}
+
+	schema = &Schema{}
+	if err := c.get(fmt.Sprintf("/schemas/ids/%d", id), schema); err != nil {
+		return nil, fmt.Errorf("fetching schema %d: %v", id, err)
+	}
+	if schema.Type == "" {
+		schema.Type = TypeAvro
+	}
+
+	c.mu.Lock()
+	c.schemas[id] = schema
+	c.mu.Unlock()
+	return schema, nil
+}
+
+// SchemaByReference returns the schema of the reference.
+func (c *Client) SchemaByReference(ref Reference) (*Schema, error) {
+	key := fmt.Sprintf("%s/%d", ref.Subject, ref.Version)
+
+	c.mu.Lock()
+	schema, ok := c.versions[key]
+	c.mu.Unlock()
+	if ok {
+		return schema, nil
+	}
+
+	schema = &Schema{}
+	path := fmt.Sprintf("/subjects/%s/versions/%d", url.PathEscape(ref.Subject), ref.Version)
+	if err := c.get(path, schema); err != nil {
+		return nil, fmt.Errorf("fetching schema %s version %d: %v", ref.Subject, ref.Version, err)
+	}
+	if schema.Type == "" {
+		schema.Type = TypeAvro
+	}
+
+	c.mu.Lock()
+	c.versions[key] = schema
+	c.mu.Unlock()
+	return schema, nil
+}
+
+func (c *Client) get(path string, v interface{}) error {
+	resp, err := c.client.Get(c.url + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("received status code %d (%s): %s", resp.StatusCode,
+			http.StatusText(resp.StatusCode), strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, v)
+}
+
+// SplitHeader splits a message in the Confluent wire format into the id of
+// its schema and its payload.
+func SplitHeader(buf []byte) (int, []byte, error) {
+	if len(buf) < 5 {
+		return 0, nil, errors.New("message too short for the schema registry header")
+	}
+	if buf[0] != magicByte {
+		return 0, nil, fmt.Errorf("unknown magic byte %d, the message is not in the schema registry format", buf[0])
+	}
+	return int(binary.BigEndian.Uint32(buf[1:5])), buf[5:], nil
+}
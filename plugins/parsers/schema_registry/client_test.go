@@ -0,0 +1,72 @@
+package schema_registry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/schemas/ids/1":
+			w.Write([]byte(`{"schema": "{\"type\": \"string\"}"}`))
+		case "/schemas/ids/2":
+			w.Write([]byte(`{"schemaType": "PROTOBUF", "schema": "syntax = \"proto3\";",
+				"references": [{"name": "other.proto", "subject": "other", "version": 3}]}`))
+		case "/subjects/other/versions/3":
+			w.Write([]byte(`{"schemaType": "PROTOBUF", "schema": "message Other {}"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error_code": 40403, "message": "Schema not found"}`))
+		}
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(ts.URL + "/")
+	require.NoError(t, err)
+
+	schema, err := client.SchemaByID(1)
+	require.NoError(t, err)
+	require.Equal(t, &Schema{Type: TypeAvro, Schema: `{"type": "string"}`}, schema)
+
+	// Schemas are cached.
+	_, err = client.SchemaByID(1)
+	require.NoError(t, err)
+	require.Equal(t, 1, requests)
+
+	schema, err = client.SchemaByID(2)
+	require.NoError(t, err)
+	require.Equal(t, TypeProtobuf, schema.Type)
+	require.Equal(t, []Reference{{Name: "other.proto", Subject: "other", Version: 3}}, schema.References)
+
+	schema, err = client.SchemaByReference(schema.References[0])
+	require.NoError(t, err)
+	require.Equal(t, "message Other {}", schema.Schema)
+
+	_, err = client.SchemaByID(3)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "received status code 404")
+}
+
+func TestNewClientErrors(t *testing.T) {
+	_, err := NewClient("localhost:8081")
+	require.Error(t, err)
+}
+
+func TestSplitHeader(t *testing.T) {
+	id, payload, err := SplitHeader([]byte{0, 0, 0, 1, 2, 42})
+	require.NoError(t, err)
+	require.Equal(t, 258, id)
+	require.Equal(t, []byte{42}, payload)
+
+	_, _, err = SplitHeader([]byte{0, 0})
+	require.Error(t, err)
+
+	_, _, err = SplitHeader([]byte{1, 0, 0, 0, 1})
+	require.Error(t, err)
+}
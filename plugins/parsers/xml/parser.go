@@ -0,0 +1,307 @@
+package xml
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
+)
+
+// Config selects metrics from an XML document through XPath queries.
+type Config struct {
+	// MetricQuery is the query of the metric name, defaults to the name of
+	// the parser.
+	MetricQuery string `toml:"metric_name"`
+	// Selection selects the nodes converted to metrics, defaults to the
+	// document root.
+	Selection string `toml:"metric_selection"`
+	// Timestamp is the query of the timestamp, defaults to the current time.
+	Timestamp string `toml:"timestamp"`
+	// TimestampFmt is the format of the timestamp, "unix", "unix_ms",
+	// "unix_us", "unix_ns" or a Go time layout.  Defaults to "unix".
+	TimestampFmt string `toml:"timestamp_format"`
+	// Tags are the queries of the tag values by tag key.
+	Tags map[string]string `toml:"tags"`
+	// Fields are the queries of the field values by field key, the type of
+	// the field is the type of the query result.
+	Fields map[string]string `toml:"fields"`
+	// FieldsInt are the queries of the integer field values by field key.
+	FieldsInt map[string]string `toml:"fields_int"`
+
+	// FieldSelection selects nodes, relative to the metric node, converted
+	// to string fields in bulk.
+	FieldSelection string `toml:"field_selection"`
+	// FieldNameQuery is the query of the name of the selected fields,
+	// defaults to the node name.
+	FieldNameQuery string `toml:"field_name"`
+	// FieldValueQuery is the query of the value of the selected fields,
+	// defaults to the node text.
+	FieldValueQuery string `toml:"field_value"`
+	// FieldNameExpand prefixes the names of the selected fields with the
+	// path from the metric node.
+	FieldNameExpand bool `toml:"field_name_expansion"`
+}
+
+// Parser converts the nodes of an XML document selected by each config to
+// metrics.
+type Parser struct {
+	MetricName  string
+	Configs     []Config
+	DefaultTags map[string]string
+
+	// exprs are the compiled queries of the configs.
+	exprs map[string]*xpath.Expr
+}
+
+// New returns a parser for the configs, or an error if a query is invalid.
+func New(metricName string, configs []Config, defaultTags map[string]string) (*Parser, error) {
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("no xml configuration, add a [[inputs.<name>.xml]] table")
+	}
+
+	p := &Parser{
+		MetricName:  metricName,
+		Configs:     configs,
+		DefaultTags: defaultTags,
+		exprs:       make(map[string]*xpath.Expr),
+	}
+	for _, config := range configs {
+		queries := []string{config.MetricQuery, config.Selection, config.Timestamp,
+			config.FieldSelection, config.FieldNameQuery, config.FieldValueQuery}
+		for _, query := range config.Tags {
+			queries = append(queries, query)
+		}
+		for _, query := range config.Fields {
+			queries = append(queries, query)
+		}
+		for _, query := range config.FieldsInt {
+			queries = append(queries, query)
+		}
+		for _, query := range queries {
+			if err := p.compile(query); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := p.compile("name()"); err != nil {
+		return nil, err
+	}
+	if err := p.compile("."); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Parser) compile(query string) error {
+	if query == "" {
+		return nil
+	}
+	if _, ok := p.exprs[query]; ok {
+		return nil
+	}
+	expr, err := xpath.Compile(query)
+	if err != nil {
+		return fmt.Errorf("invalid xpath %q: %v", query, err)
+	}
+	p.exprs[query] = expr
+	return nil
+}
+
+// Parse converts the nodes selected by each config to metrics.
+func (p *Parser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	t := time.Now()
+
+	doc, err := xmlquery.Parse(bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := make([]telegraf.Metric, 0)
+	for i := range p.Configs {
+		config := &p.Configs[i]
+
+		root := xmlquery.CreateXPathNavigator(doc)
+		nodes := []*xmlquery.NodeNavigator{root}
+		if config.Selection != "" {
+			nodes = p.selectNodes(root, config.Selection)
+		}
+
+		for _, node := range nodes {
+			m, err := p.parseNode(t, node, config)
+			if err != nil {
+				return nil, err
+			}
+			metrics = append(metrics, m)
+		}
+	}
+	return metrics, nil
+}
+
+// ParseLine parses the line as an XML document, it must result in a single
+// metric.
+func (p *Parser) ParseLine(line string) (telegraf.Metric, error) {
+	metrics, err := p.Parse([]byte(line))
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(metrics) {
+	case 0:
+		return nil, nil
+	case 1:
+		return metrics[0], nil
+	default:
+		return metrics[0], fmt.Errorf("cannot parse line with multiple (%d) metrics", len(metrics))
+	}
+}
+
+func (p *Parser) SetDefaultTags(tags map[string]string) {
+	p.DefaultTags = tags
+}
+
+func (p *Parser) parseNode(t time.Time, node *xmlquery.NodeNavigator, config *Config) (telegraf.Metric, error) {
+	name := p.MetricName
+	if config.MetricQuery != "" {
+		name = p.queryString(node, config.MetricQuery)
+		if name == "" {
+			return nil, fmt.Errorf("metric name query %q returned no name", config.MetricQuery)
+		}
+	}
+
+	timestamp := t
+	if config.Timestamp != "" {
+		value := p.queryString(node, config.Timestamp)
+		format := config.TimestampFmt
+		if format == "" {
+			format = "unix"
+		}
+		var err error
+		timestamp, err = internal.ParseTimestamp(format, value, "")
+		if err != nil {
+			return nil, fmt.Errorf("parsing timestamp %q: %v", value, err)
+		}
+	}
+
+	tags := make(map[string]string)
+	for key, value := range p.DefaultTags {
+		tags[key] = value
+	}
+	for key, query := range config.Tags {
+		if value := p.queryString(node, query); value != "" {
+			tags[key] = value
+		}
+	}
+
+	fields := make(map[string]interface{})
+	if config.FieldSelection != "" {
+		nameQuery := config.FieldNameQuery
+		if nameQuery == "" {
+			nameQuery = "name()"
+		}
+		valueQuery := config.FieldValueQuery
+		if valueQuery == "" {
+			valueQuery = "."
+		}
+
+		for _, selected := range p.selectNodes(node, config.FieldSelection) {
+			key := p.queryString(selected, nameQuery)
+			if key == "" {
+				continue
+			}
+			if config.FieldNameExpand {
+				key = expandName(node, selected, key)
+			}
+			fields[key] = p.queryString(selected, valueQuery)
+		}
+	}
+	for key, query := range config.FieldsInt {
+		value, err := toInt(p.query(node, query))
+		if err != nil {
+			return nil, fmt.Errorf("converting field %q to integer: %v", key, err)
+		}
+		fields[key] = value
+	}
+	for key, query := range config.Fields {
+		fields[key] = p.query(node, query)
+	}
+
+	return metric.New(name, tags, fields, timestamp)
+}
+
+// selectNodes returns the nodes matching the query relative to the node.
+// Navigators are kept instead of nodes, so attributes can be selected.
+func (p *Parser) selectNodes(node *xmlquery.NodeNavigator, query string) []*xmlquery.NodeNavigator {
+	var nodes []*xmlquery.NodeNavigator
+	iter := p.exprs[query].Select(node.Copy())
+	for iter.MoveNext() {
+		nodes = append(nodes, iter.Current().Copy().(*xmlquery.NodeNavigator))
+	}
+	return nodes
+}
+
+// query evaluates the query relative to the node.  The result is a float64,
+// bool or string, the text of the first node of node-set results.
+func (p *Parser) query(node *xmlquery.NodeNavigator, query string) interface{} {
+	result := p.exprs[query].Evaluate(node.Copy())
+	switch r := result.(type) {
+	case *xpath.NodeIterator:
+		if r.MoveNext() {
+			return r.Current().Value()
+		}
+		return ""
+	case float64, bool, string:
+		return r
+	default:
+		return fmt.Sprint(r)
+	}
+}
+
+func (p *Parser) queryString(node *xmlquery.NodeNavigator, query string) string {
+	switch v := p.query(node, query).(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return v.(string)
+	}
+}
+
+func toInt(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case float64:
+		return int64(v), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	default:
+		return strconv.ParseInt(strings.TrimSpace(v.(string)), 10, 64)
+	}
+}
+
+// expandName prefixes the name with the names of the ancestors of the node
+// up to the metric node, separated by underscores.  The current node of an
+// attribute navigator is the element of the attribute.
+func expandName(root, node *xmlquery.NodeNavigator, name string) string {
+	n := node.Current()
+	if node.NodeType() != xpath.AttributeNode {
+		n = n.Parent
+	}
+
+	parts := []string{name}
+	for ; n != nil && n != root.Current(); n = n.Parent {
+		if n.Type == xmlquery.ElementNode {
+			parts = append([]string{n.Data}, parts...)
+		}
+	}
+	return strings.Join(parts, "_")
+}
@@ -0,0 +1,173 @@
+package xml
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+const deviceStatus = `<?xml version="1.0"?>
+<Device>
+  <Name>plc1</Name>
+  <Timestamp>1600000000</Timestamp>
+  <Uptime>1234</Uptime>
+  <Status ok="true"/>
+  <Sensors>
+    <Sensor id="temp1" unit="C">
+      <Value>21.5</Value>
+      <Limits><Min>0</Min><Max>80</Max></Limits>
+    </Sensor>
+    <Sensor id="temp2" unit="F">
+      <Value>70.1</Value>
+      <Limits><Min>32</Min><Max>176</Max></Limits>
+    </Sensor>
+  </Sensors>
+</Device>
+`
+
+func TestParse(t *testing.T) {
+	parser, err := New("xml", []Config{
+		{
+			Timestamp: "/Device/Timestamp",
+			Tags:      map[string]string{"device": "/Device/Name"},
+			Fields: map[string]string{
+				"uptime": "number(/Device/Uptime)",
+				"ok":     "/Device/Status/@ok = 'true'",
+				"name":   "/Device/Name",
+			},
+			FieldsInt: map[string]string{"sensors": "count(//Sensor)"},
+		},
+	}, map[string]string{"host": "localhost"})
+	require.NoError(t, err)
+
+	metrics, err := parser.Parse([]byte(deviceStatus))
+	require.NoError(t, err)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("xml",
+			map[string]string{"host": "localhost", "device": "plc1"},
+			map[string]interface{}{
+				"uptime":  float64(1234),
+				"ok":      true,
+				"name":    "plc1",
+				"sensors": int64(2),
+			},
+			time.Unix(1600000000, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, metrics)
+}
+
+func TestParseSelections(t *testing.T) {
+	parser, err := New("xml", []Config{
+		{
+			MetricQuery:  "'sensor'",
+			Selection:    "//Sensor",
+			Timestamp:    "/Device/Timestamp",
+			TimestampFmt: "unix",
+			Tags: map[string]string{
+				"id":     "@id",
+				"device": "/Device/Name",
+			},
+			Fields:    map[string]string{"value": "number(Value)"},
+			FieldsInt: map[string]string{"max": "Limits/Max"},
+		},
+		{
+			MetricQuery: "name(/Device)",
+			Timestamp:   "/Device/Timestamp",
+			FieldsInt:   map[string]string{"uptime": "/Device/Uptime"},
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	metrics, err := parser.Parse([]byte(deviceStatus))
+	require.NoError(t, err)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("sensor",
+			map[string]string{"id": "temp1", "device": "plc1"},
+			map[string]interface{}{"value": 21.5, "max": int64(80)},
+			time.Unix(1600000000, 0)),
+		testutil.MustMetric("sensor",
+			map[string]string{"id": "temp2", "device": "plc1"},
+			map[string]interface{}{"value": 70.1, "max": int64(176)},
+			time.Unix(1600000000, 0)),
+		testutil.MustMetric("Device",
+			map[string]string{},
+			map[string]interface{}{"uptime": int64(1234)},
+			time.Unix(1600000000, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, metrics)
+}
+
+func TestParseFieldSelection(t *testing.T) {
+	parser, err := New("sensor", []Config{
+		{
+			Selection:       "//Sensor",
+			Tags:            map[string]string{"id": "@id"},
+			FieldSelection:  "descendant::*[not(*)]",
+			FieldNameExpand: true,
+		},
+		{
+			Selection:      "//Sensor[@id='temp1']",
+			FieldSelection: "@*",
+			FieldNameQuery: "concat('attr_', name())",
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	metrics, err := parser.Parse([]byte(deviceStatus))
+	require.NoError(t, err)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("sensor",
+			map[string]string{"id": "temp1"},
+			map[string]interface{}{"Value": "21.5", "Limits_Min": "0", "Limits_Max": "80"},
+			time.Unix(0, 0)),
+		testutil.MustMetric("sensor",
+			map[string]string{"id": "temp2"},
+			map[string]interface{}{"Value": "70.1", "Limits_Min": "32", "Limits_Max": "176"},
+			time.Unix(0, 0)),
+		testutil.MustMetric("sensor",
+			map[string]string{},
+			map[string]interface{}{"attr_id": "temp1", "attr_unit": "C"},
+			time.Unix(0, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, metrics, testutil.IgnoreTime())
+}
+
+func TestParseTimestampFormat(t *testing.T) {
+	parser, err := New("xml", []Config{
+		{
+			Timestamp:    "/Event/@time",
+			TimestampFmt: "2006-01-02T15:04:05Z07:00",
+			Fields:       map[string]string{"code": "number(/Event/Code)"},
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	m, err := parser.ParseLine(`<Event time="2020-09-13T12:26:40Z"><Code>3</Code></Event>`)
+	require.NoError(t, err)
+	require.Equal(t, time.Unix(1600000000, 0).UTC(), m.Time())
+	require.Equal(t, map[string]interface{}{"code": float64(3)}, m.Fields())
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := New("xml", nil, nil)
+	require.Error(t, err)
+
+	_, err = New("xml", []Config{{Fields: map[string]string{"value": "Value["}}}, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid xpath")
+
+	parser, err := New("xml", []Config{{FieldsInt: map[string]string{"name": "/Device/Name"}}}, nil)
+	require.NoError(t, err)
+	_, err = parser.Parse([]byte(deviceStatus))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `converting field "name" to integer`)
+
+	_, err = parser.Parse([]byte("<Device>"))
+	require.Error(t, err)
+}
@@ -0,0 +1,89 @@
+package exec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/secretstores"
+)
+
+var sampleConfig = `
+  ## Unique identifier of the store, referenced as "@{<id>:<key>}".
+  id = "vault"
+
+  ## Command printing the secret to stdout, the key of the secret is appended
+  ## as the last argument.
+  command = ["/usr/local/bin/get-secret"]
+
+  ## Timeout for the command to complete.
+  # timeout = "5s"
+`
+
+// ErrNotSupported is returned for operations the helper command does not
+// provide.
+var ErrNotSupported = errors.New("not supported by the exec secret store")
+
+// Exec is a secret store that runs a helper command to look up secrets.
+type Exec struct {
+	Command []string          `toml:"command"`
+	Timeout internal.Duration `toml:"timeout"`
+}
+
+func (e *Exec) SampleConfig() string {
+	return sampleConfig
+}
+
+func (e *Exec) Description() string {
+	return "Secrets looked up by running a helper command"
+}
+
+func (e *Exec) Init() error {
+	if len(e.Command) == 0 {
+		return errors.New("command is required")
+	}
+	return nil
+}
+
+func (e *Exec) Get(key string) (string, error) {
+	args := append(append([]string{}, e.Command[1:]...), key)
+	cmd := exec.Command(e.Command[0], args...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Start()
+	if err == nil {
+		err = internal.WaitTimeout(cmd, e.Timeout.Duration)
+	}
+	if err != nil {
+		// The output may contain the secret, only stderr is reported.
+		return "", fmt.Errorf("getting secret %q: %v: %s", key, err,
+			bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	// Helpers usually end the secret with a newline.
+	out := bytes.TrimSuffix(stdout.Bytes(), []byte("\n"))
+	out = bytes.TrimSuffix(out, []byte("\r"))
+	return string(out), nil
+}
+
+func (e *Exec) Set(key, value string) error {
+	return ErrNotSupported
+}
+
+func (e *Exec) List() ([]string, error) {
+	return nil, ErrNotSupported
+}
+
+func init() {
+	secretstores.Add("exec", func() telegraf.SecretStore {
+		return &Exec{
+			Timeout: internal.Duration{Duration: 5 * time.Second},
+		}
+	})
+}
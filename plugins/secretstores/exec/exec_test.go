@@ -0,0 +1,44 @@
+// +build !windows
+
+package exec
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExec_Get(t *testing.T) {
+	store := &Exec{
+		Command: []string{"echo", "prefix"},
+		Timeout: internal.Duration{Duration: 5 * time.Second},
+	}
+	require.NoError(t, store.Init())
+
+	value, err := store.Get("token")
+	require.NoError(t, err)
+	require.Equal(t, "prefix token", value)
+
+	_, err = store.List()
+	require.Equal(t, ErrNotSupported, err)
+	require.Equal(t, ErrNotSupported, store.Set("token", "s3cr3t"))
+}
+
+func TestExec_GetFails(t *testing.T) {
+	store := &Exec{
+		Command: []string{"sh", "-c", "echo s3cr3t; echo not found >&2; exit 1", "sh"},
+		Timeout: internal.Duration{Duration: 5 * time.Second},
+	}
+	require.NoError(t, store.Init())
+
+	_, err := store.Get("token")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not found")
+	require.NotContains(t, err.Error(), "s3cr3t")
+}
+
+func TestExec_InitErrors(t *testing.T) {
+	require.Error(t, (&Exec{}).Init())
+}
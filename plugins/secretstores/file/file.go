@@ -0,0 +1,246 @@
+package file
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/secretstores"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	fileVersion = 1
+	saltSize    = 16
+	keySize     = 32
+
+	// scrypt parameters recommended for interactive logins.
+	scryptN = 32768
+	scryptR = 8
+	scryptP = 1
+)
+
+var sampleConfig = `
+  ## Unique identifier of the store, referenced as "@{<id>:<key>}".
+  id = "secrets"
+
+  ## Path of the encrypted file, created when the first secret is set.
+  path = "/etc/telegraf/secrets.enc"
+
+  ## Password used to encrypt the file.  Use an environment variable to keep
+  ## the password out of the config file.
+  password = "${TELEGRAF_SECRETS_PASSWORD}"
+`
+
+// encryptedFile is the content of the secret file on disk.
+type encryptedFile struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// File is a secret store keeping the secrets in a file encrypted with a
+// password.
+type File struct {
+	Path     string `toml:"path"`
+	Password string `toml:"password"`
+
+	mu      sync.Mutex
+	secrets map[string]string
+}
+
+func (f *File) SampleConfig() string {
+	return sampleConfig
+}
+
+func (f *File) Description() string {
+	return "Secrets stored in a password encrypted file"
+}
+
+func (f *File) Init() error {
+	if f.Path == "" {
+		return errors.New("path is required")
+	}
+	if f.Password == "" {
+		return errors.New("password is required")
+	}
+
+	// Decrypt the file now to report a wrong password at startup.
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, err := f.load()
+	return err
+}
+
+func (f *File) Get(key string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	secrets, err := f.load()
+	if err != nil {
+		return "", err
+	}
+	value, ok := secrets[key]
+	if !ok {
+		return "", fmt.Errorf("secret %q not found", key)
+	}
+	return value, nil
+}
+
+func (f *File) Set(key, value string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	secrets, err := f.load()
+	if err != nil {
+		return err
+	}
+
+	updated := make(map[string]string, len(secrets)+1)
+	for k, v := range secrets {
+		updated[k] = v
+	}
+	updated[key] = value
+
+	if err := f.save(updated); err != nil {
+		return err
+	}
+	f.secrets = updated
+	return nil
+}
+
+func (f *File) List() ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	secrets, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(secrets))
+	for key := range secrets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// load returns the decrypted secrets, a missing file is an empty store.  The
+// secrets are cached as deriving the key is slow by design.
+func (f *File) load() (map[string]string, error) {
+	if f.secrets != nil {
+		return f.secrets, nil
+	}
+
+	data, err := ioutil.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		f.secrets = make(map[string]string)
+		return f.secrets, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ef encryptedFile
+	if err := json.Unmarshal(data, &ef); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", f.Path, err)
+	}
+	if ef.Version != fileVersion {
+		return nil, fmt.Errorf("unsupported version %d of %s", ef.Version, f.Path)
+	}
+
+	aead, err := f.cipher(ef.Salt)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, ef.Nonce, ef.Data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting %s: wrong password or corrupted file", f.Path)
+	}
+
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("parsing decrypted %s: %v", f.Path, err)
+	}
+	f.secrets = secrets
+	return f.secrets, nil
+}
+
+// save encrypts the secrets with a new salt and replaces the file.
+func (f *File) save(secrets map[string]string) error {
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	aead, err := f.cipher(salt)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(&encryptedFile{
+		Version: fileVersion,
+		Salt:    salt,
+		Nonce:   nonce,
+		Data:    aead.Seal(nil, nonce, plaintext, nil),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so that the store is never left
+	// partially written.
+	tmp, err := ioutil.TempFile(filepath.Dir(f.Path), filepath.Base(f.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.Path)
+}
+
+func (f *File) cipher(salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(f.Password), salt, scryptN, scryptR, scryptP, keySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func init() {
+	secretstores.Add("file", func() telegraf.SecretStore {
+		return &File{}
+	})
+}
@@ -0,0 +1,71 @@
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFile_SetGet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telegraf-secrets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "secrets.enc")
+
+	store := &File{Path: path, Password: "password"}
+	require.NoError(t, store.Init())
+
+	keys, err := store.List()
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	require.NoError(t, store.Set("token", "s3cr3t"))
+	require.NoError(t, store.Set("password", "hunter2"))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "s3cr3t")
+
+	// A new instance reads the secrets from the file.
+	store = &File{Path: path, Password: "password"}
+	require.NoError(t, store.Init())
+
+	value, err := store.Get("token")
+	require.NoError(t, err)
+	require.Equal(t, "s3cr3t", value)
+
+	keys, err = store.List()
+	require.NoError(t, err)
+	require.Equal(t, []string{"password", "token"}, keys)
+
+	_, err = store.Get("missing")
+	require.Error(t, err)
+}
+
+func TestFile_WrongPassword(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telegraf-secrets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "secrets.enc")
+
+	store := &File{Path: path, Password: "password"}
+	require.NoError(t, store.Init())
+	require.NoError(t, store.Set("token", "s3cr3t"))
+
+	store = &File{Path: path, Password: "wrong"}
+	err = store.Init()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "wrong password")
+}
+
+func TestFile_InitErrors(t *testing.T) {
+	require.Error(t, (&File{Password: "password"}).Init())
+	require.Error(t, (&File{Path: "secrets.enc"}).Init())
+}
@@ -0,0 +1,147 @@
+package keyring
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/secretstores"
+)
+
+var sampleConfig = `
+  ## Unique identifier of the store, referenced as "@{<id>:<key>}".
+  id = "keyring"
+
+  ## Service name the secrets are stored under in the keyring.
+  # service = "telegraf"
+
+  ## Directory of the keyring, defaults to "telegraf/keyring" in the user
+  ## config directory, e.g. ~/.config/telegraf/keyring.
+  # directory = ""
+`
+
+var keyRe = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9_.-]*$`)
+
+// keyring is the interface to the keyring of the operating system.
+type keyring interface {
+	Get(service, key string) (string, error)
+	Set(service, key, value string) error
+	Keys(service string) ([]string, error)
+}
+
+// openKeyring opens the keyring used by the store.  No keyring library of the
+// operating system is available, so a directory readable only by the user
+// stands in for it.
+var openKeyring = func(k *Keyring) (keyring, error) {
+	dir := k.Directory
+	if dir == "" {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(configDir, "telegraf", "keyring")
+	}
+	return &dirKeyring{dir: dir}, nil
+}
+
+// Keyring is a secret store keeping the secrets in the keyring of the user.
+type Keyring struct {
+	Service   string `toml:"service"`
+	Directory string `toml:"directory"`
+
+	keyring keyring
+}
+
+func (k *Keyring) SampleConfig() string {
+	return sampleConfig
+}
+
+func (k *Keyring) Description() string {
+	return "Secrets stored in the keyring of the user"
+}
+
+func (k *Keyring) Init() error {
+	if !keyRe.MatchString(k.Service) {
+		return fmt.Errorf("invalid service name %q", k.Service)
+	}
+	kr, err := openKeyring(k)
+	if err != nil {
+		return fmt.Errorf("opening keyring: %v", err)
+	}
+	k.keyring = kr
+	return nil
+}
+
+func (k *Keyring) Get(key string) (string, error) {
+	if !keyRe.MatchString(key) {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	return k.keyring.Get(k.Service, key)
+}
+
+func (k *Keyring) Set(key, value string) error {
+	if !keyRe.MatchString(key) {
+		return fmt.Errorf("invalid key %q", key)
+	}
+	return k.keyring.Set(k.Service, key, value)
+}
+
+func (k *Keyring) List() ([]string, error) {
+	return k.keyring.Keys(k.Service)
+}
+
+// dirKeyring stores each secret in a file of the directory of the service.
+type dirKeyring struct {
+	dir string
+}
+
+func (d *dirKeyring) Get(service, key string) (string, error) {
+	value, err := ioutil.ReadFile(filepath.Join(d.dir, service, key))
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("secret %q not found", key)
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
+
+func (d *dirKeyring) Set(service, key, value string) error {
+	dir := filepath.Join(d.dir, service)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, key), []byte(value), 0600)
+}
+
+func (d *dirKeyring) Keys(service string) ([]string, error) {
+	files, err := ioutil.ReadDir(filepath.Join(d.dir, service))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(files))
+	for _, file := range files {
+		if file.Mode().IsRegular() && keyRe.MatchString(file.Name()) {
+			keys = append(keys, file.Name())
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func init() {
+	secretstores.Add("keyring", func() telegraf.SecretStore {
+		return &Keyring{
+			Service: "telegraf",
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package keyring
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyring_SetGet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telegraf-keyring")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store := &Keyring{Service: "telegraf", Directory: dir}
+	require.NoError(t, store.Init())
+
+	keys, err := store.List()
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	require.NoError(t, store.Set("token", "s3cr3t"))
+	require.NoError(t, store.Set("password", "hunter2"))
+
+	value, err := store.Get("token")
+	require.NoError(t, err)
+	require.Equal(t, "s3cr3t", value)
+
+	keys, err = store.List()
+	require.NoError(t, err)
+	require.Equal(t, []string{"password", "token"}, keys)
+
+	info, err := os.Stat(filepath.Join(dir, "telegraf", "token"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	_, err = store.Get("missing")
+	require.Error(t, err)
+
+	// Services do not share secrets.
+	other := &Keyring{Service: "other", Directory: dir}
+	require.NoError(t, other.Init())
+	_, err = other.Get("token")
+	require.Error(t, err)
+}
+
+func TestKeyring_InvalidNames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telegraf-keyring")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.Error(t, (&Keyring{Service: "../telegraf", Directory: dir}).Init())
+
+	store := &Keyring{Service: "telegraf", Directory: dir}
+	require.NoError(t, store.Init())
+	require.Error(t, store.Set("../token", "s3cr3t"))
+	_, err = store.Get("..")
+	require.Error(t, err)
+}
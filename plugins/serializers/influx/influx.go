@@ -216,15 +216,16 @@ func (s *Serializer) writeMetric(w io.Writer, m telegraf.Metric) error {
 
 	s.buildFooter(m)
 
+	fields := expandDistributions(m.FieldList())
 	if s.fieldSortOrder == SortFields {
-		sort.Slice(m.FieldList(), func(i, j int) bool {
-			return m.FieldList()[i].Key < m.FieldList()[j].Key
+		sort.Slice(fields, func(i, j int) bool {
+			return fields[i].Key < fields[j].Key
 		})
 	}
 
 	pairsLen := 0
 	firstField := true
-	for _, field := range m.FieldList() {
+	for _, field := range fields {
 		err = s.buildFieldPair(field.Key, field.Value)
 		if err != nil {
 			log.Printf(
@@ -289,6 +290,46 @@ func (s *Serializer) writeMetric(w io.Writer, m telegraf.Metric) error {
 	return s.write(w, s.footer)
 }
 
+// expandDistributions replaces the distribution fields with the fields
+// <field>_count, <field>_sum and either <field>_bucket_<upper bound> or
+// <field>_quantile_<quantile>.  The +Inf bucket is left out as it equals the
+// count.
+func expandDistributions(fields []*telegraf.Field) []*telegraf.Field {
+	var expanded []*telegraf.Field
+	for i, field := range fields {
+		d, ok := field.Value.(*telegraf.Distribution)
+		if !ok {
+			if expanded != nil {
+				expanded = append(expanded, field)
+			}
+			continue
+		}
+		if expanded == nil {
+			expanded = append(make([]*telegraf.Field, 0, len(fields)+2), fields[:i]...)
+		}
+
+		expanded = append(expanded,
+			&telegraf.Field{Key: field.Key + "_count", Value: d.Count},
+			&telegraf.Field{Key: field.Key + "_sum", Value: d.Sum},
+		)
+		for _, b := range d.Buckets {
+			if math.IsInf(b.UpperBound, 1) {
+				continue
+			}
+			key := field.Key + "_bucket_" + strconv.FormatFloat(b.UpperBound, 'g', -1, 64)
+			expanded = append(expanded, &telegraf.Field{Key: key, Value: b.Count})
+		}
+		for _, q := range d.Quantiles {
+			key := field.Key + "_quantile_" + strconv.FormatFloat(q.Quantile, 'g', -1, 64)
+			expanded = append(expanded, &telegraf.Field{Key: key, Value: q.Value})
+		}
+	}
+	if expanded == nil {
+		return fields
+	}
+	return expanded
+}
+
 func (s *Serializer) newMetricError(reason string) *MetricError {
 	if len(s.header) != 0 {
 		series := bytes.TrimRight(s.header, " ")
@@ -462,6 +462,51 @@ var tests = []struct {
 		),
 		errReason: NoFields,
 	},
+	{
+		name: "histogram distribution",
+		input: MustMetric(
+			metric.New(
+				"http",
+				map[string]string{},
+				map[string]interface{}{
+					"duration": &telegraf.Distribution{
+						Count: 10,
+						Sum:   4.5,
+						Buckets: []telegraf.Bucket{
+							{UpperBound: 0.1, Count: 2},
+							{UpperBound: 0.5, Count: 7},
+							{UpperBound: math.Inf(1), Count: 10},
+						},
+					},
+				},
+				time.Unix(0, 0),
+			),
+		),
+		typeSupport: UintSupport,
+		output:      []byte("http duration_bucket_0.1=2u,duration_bucket_0.5=7u,duration_count=10u,duration_sum=4.5 0\n"),
+	},
+	{
+		name: "summary distribution",
+		input: MustMetric(
+			metric.New(
+				"http",
+				map[string]string{},
+				map[string]interface{}{
+					"duration": &telegraf.Distribution{
+						Count: 10,
+						Sum:   4.5,
+						Quantiles: []telegraf.Quantile{
+							{Quantile: 0.5, Value: 0.3},
+							{Quantile: 0.99, Value: 0.9},
+						},
+					},
+					"requests": 3.0,
+				},
+				time.Unix(0, 0),
+			),
+		),
+		output: []byte("http duration_count=10i,duration_quantile_0.5=0.3,duration_quantile_0.99=0.9,duration_sum=4.5,requests=3 0\n"),
+	},
 	{
 		name: "procstat",
 		input: MustMetric(
@@ -0,0 +1,221 @@
+package msgpack
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/tinylib/msgp/msgp"
+)
+
+// timestampExtension is the extension type of MessagePack timestamps.
+const timestampExtension = -1
+
+// Timestamp is a time encoded as a MessagePack timestamp.
+type Timestamp time.Time
+
+func (t *Timestamp) ExtensionType() int8 { return timestampExtension }
+
+// Len returns the length of the 96 bit format, which holds any time.
+func (t *Timestamp) Len() int { return 12 }
+
+func (t *Timestamp) MarshalBinaryTo(b []byte) error {
+	tm := time.Time(*t)
+	binary.BigEndian.PutUint32(b, uint32(tm.Nanosecond()))
+	binary.BigEndian.PutUint64(b[4:], uint64(tm.Unix()))
+	return nil
+}
+
+// UnmarshalBinary decodes the 32, 64 and 96 bit timestamp formats.
+func (t *Timestamp) UnmarshalBinary(b []byte) error {
+	switch len(b) {
+	case 4:
+		*t = Timestamp(time.Unix(int64(binary.BigEndian.Uint32(b)), 0))
+	case 8:
+		v := binary.BigEndian.Uint64(b)
+		*t = Timestamp(time.Unix(int64(v&(1<<34-1)), int64(v>>34)))
+	case 12:
+		nsec := binary.BigEndian.Uint32(b)
+		sec := binary.BigEndian.Uint64(b[4:])
+		*t = Timestamp(time.Unix(int64(sec), int64(nsec)))
+	default:
+		return fmt.Errorf("invalid timestamp length %d", len(b))
+	}
+	return nil
+}
+
+// AppendMetric appends the metric as a map with the "name", "time", "tags"
+// and "fields" keys.  Unsigned integers are always encoded as uint types to
+// be told apart from integers.
+func AppendMetric(b []byte, m telegraf.Metric) ([]byte, error) {
+	b = msgp.AppendMapHeader(b, 4)
+	b = msgp.AppendString(b, "name")
+	b = msgp.AppendString(b, m.Name())
+
+	b = msgp.AppendString(b, "time")
+	t := Timestamp(m.Time())
+	b, err := msgp.AppendExtension(b, &t)
+	if err != nil {
+		return nil, err
+	}
+
+	b = msgp.AppendString(b, "tags")
+	b = msgp.AppendMapHeader(b, uint32(len(m.TagList())))
+	for _, tag := range m.TagList() {
+		b = msgp.AppendString(b, tag.Key)
+		b = msgp.AppendString(b, tag.Value)
+	}
+
+	b = msgp.AppendString(b, "fields")
+	b = msgp.AppendMapHeader(b, uint32(len(m.FieldList())))
+	for _, field := range m.FieldList() {
+		b = msgp.AppendString(b, field.Key)
+		switch v := field.Value.(type) {
+		case int64:
+			b = msgp.AppendInt64(b, v)
+		case uint64:
+			b = appendUint64(b, v)
+		case float64:
+			b = msgp.AppendFloat64(b, v)
+		case bool:
+			b = msgp.AppendBool(b, v)
+		case string:
+			b = msgp.AppendString(b, v)
+		default:
+			return nil, fmt.Errorf("field %q has unsupported type %T", field.Key, field.Value)
+		}
+	}
+	return b, nil
+}
+
+// appendUint64 appends the value as the smallest uint type, unlike
+// msgp.AppendUint64 which uses a positive fixint for small values.
+func appendUint64(b []byte, v uint64) []byte {
+	switch {
+	case v <= 1<<8-1:
+		return append(b, 0xcc, byte(v))
+	case v <= 1<<16-1:
+		return append(b, 0xcd, byte(v>>8), byte(v))
+	case v <= 1<<32-1:
+		b = append(b, 0xce, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(b[len(b)-4:], uint32(v))
+		return b
+	default:
+		b = append(b, 0xcf, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(b[len(b)-8:], v)
+		return b
+	}
+}
+
+// ReadMetric reads a metric appended by AppendMetric and returns the
+// remaining bytes.  Unknown keys are skipped.
+func ReadMetric(b []byte) (telegraf.Metric, []byte, error) {
+	size, b, err := msgp.ReadMapHeaderBytes(b)
+	if err != nil {
+		return nil, b, err
+	}
+
+	var name string
+	var tm time.Time
+	tags := make(map[string]string)
+	fields := make(map[string]interface{})
+	for i := uint32(0); i < size; i++ {
+		var key string
+		key, b, err = msgp.ReadStringBytes(b)
+		if err != nil {
+			return nil, b, err
+		}
+
+		switch key {
+		case "name":
+			name, b, err = msgp.ReadStringBytes(b)
+		case "time":
+			var t Timestamp
+			b, err = msgp.ReadExtensionBytes(b, &t)
+			tm = time.Time(t)
+		case "tags":
+			b, err = readTags(b, tags)
+		case "fields":
+			b, err = readFields(b, fields)
+		default:
+			b, err = msgp.Skip(b)
+		}
+		if err != nil {
+			return nil, b, fmt.Errorf("reading %q: %v", key, err)
+		}
+	}
+
+	if name == "" {
+		return nil, b, errors.New("metric without name")
+	}
+	m, err := metric.New(name, tags, fields, tm)
+	if err != nil {
+		return nil, b, err
+	}
+	return m, b, nil
+}
+
+func readTags(b []byte, tags map[string]string) ([]byte, error) {
+	size, b, err := msgp.ReadMapHeaderBytes(b)
+	if err != nil {
+		return b, err
+	}
+	for i := uint32(0); i < size; i++ {
+		var key, value string
+		if key, b, err = msgp.ReadStringBytes(b); err != nil {
+			return b, err
+		}
+		if value, b, err = msgp.ReadStringBytes(b); err != nil {
+			return b, err
+		}
+		tags[key] = value
+	}
+	return b, nil
+}
+
+func readFields(b []byte, fields map[string]interface{}) ([]byte, error) {
+	size, b, err := msgp.ReadMapHeaderBytes(b)
+	if err != nil {
+		return b, err
+	}
+	for i := uint32(0); i < size; i++ {
+		var key string
+		if key, b, err = msgp.ReadStringBytes(b); err != nil {
+			return b, err
+		}
+
+		var value interface{}
+		switch t := msgp.NextType(b); t {
+		case msgp.IntType:
+			value, b, err = msgp.ReadInt64Bytes(b)
+		case msgp.UintType:
+			value, b, err = msgp.ReadUint64Bytes(b)
+		case msgp.Float64Type:
+			value, b, err = msgp.ReadFloat64Bytes(b)
+		case msgp.Float32Type:
+			var f float32
+			f, b, err = msgp.ReadFloat32Bytes(b)
+			value = float64(f)
+		case msgp.BoolType:
+			value, b, err = msgp.ReadBoolBytes(b)
+		case msgp.StrType:
+			value, b, err = msgp.ReadStringBytes(b)
+		case msgp.NilType:
+			b, err = msgp.ReadNilBytes(b)
+			if err != nil {
+				return b, err
+			}
+			continue
+		default:
+			return b, fmt.Errorf("field %q has unsupported type %s", key, t)
+		}
+		if err != nil {
+			return b, err
+		}
+		fields[key] = value
+	}
+	return b, nil
+}
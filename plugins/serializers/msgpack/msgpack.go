@@ -0,0 +1,28 @@
+package msgpack
+
+import (
+	"github.com/influxdata/telegraf"
+)
+
+// Serializer encodes metrics as MessagePack maps, a batch is the
+// concatenation of its metrics.
+type Serializer struct{}
+
+func NewSerializer() *Serializer {
+	return &Serializer{}
+}
+
+func (s *Serializer) Serialize(metric telegraf.Metric) ([]byte, error) {
+	return AppendMetric(nil, metric)
+}
+
+func (s *Serializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
+	var buf []byte
+	for _, metric := range metrics {
+		var err error
+		if buf, err = AppendMetric(buf, metric); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
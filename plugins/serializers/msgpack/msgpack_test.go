@@ -0,0 +1,104 @@
+package msgpack
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/tinylib/msgp/msgp"
+)
+
+func TestSerialize(t *testing.T) {
+	m := testutil.MustMetric("cpu",
+		map[string]string{"cpu": "cpu0"},
+		map[string]interface{}{"usage": uint64(5)},
+		time.Unix(1600000000, 5))
+
+	s := NewSerializer()
+	buf, err := s.Serialize(m)
+	require.NoError(t, err)
+
+	var expected []byte
+	expected = msgp.AppendMapHeader(expected, 4)
+	expected = msgp.AppendString(expected, "name")
+	expected = msgp.AppendString(expected, "cpu")
+	expected = msgp.AppendString(expected, "time")
+	// 96 bit timestamp extension: nanoseconds and seconds.
+	expected = append(expected, 0xc7, 12, 0xff, 0, 0, 0, 5, 0, 0, 0, 0, 0x5f, 0x5e, 0x10, 0x00)
+	expected = msgp.AppendString(expected, "tags")
+	expected = msgp.AppendMapStrStr(expected, map[string]string{"cpu": "cpu0"})
+	expected = msgp.AppendString(expected, "fields")
+	expected = msgp.AppendMapHeader(expected, 1)
+	expected = msgp.AppendString(expected, "usage")
+	// Small unsigned integers are uint 8, not positive fixint.
+	expected = append(expected, 0xcc, 5)
+	require.Equal(t, expected, buf)
+}
+
+func TestRoundTrip(t *testing.T) {
+	metrics := []telegraf.Metric{
+		testutil.MustMetric("cpu",
+			map[string]string{"cpu": "cpu0", "host": "localhost"},
+			map[string]interface{}{
+				"int":       int64(-42),
+				"small_int": int64(5),
+				"uint":      uint64(math.MaxUint64),
+				"small":     uint64(1),
+				"float":     42.5,
+				"bool":      true,
+				"string":    "idle",
+			},
+			time.Unix(1600000000, 123456789)),
+		testutil.MustMetric("mem",
+			map[string]string{},
+			map[string]interface{}{"used": uint64(1 << 20)},
+			time.Unix(-1, 5)),
+	}
+
+	s := NewSerializer()
+	buf, err := s.SerializeBatch(metrics)
+	require.NoError(t, err)
+
+	var actual []telegraf.Metric
+	for len(buf) > 0 {
+		var m telegraf.Metric
+		m, buf, err = ReadMetric(buf)
+		require.NoError(t, err)
+		actual = append(actual, m)
+	}
+	testutil.RequireMetricsEqual(t, metrics, actual)
+}
+
+func TestTimestampFormats(t *testing.T) {
+	var ts Timestamp
+	require.NoError(t, ts.UnmarshalBinary([]byte{0x5f, 0x5e, 0x10, 0x00}))
+	require.True(t, time.Unix(1600000000, 0).Equal(time.Time(ts)))
+
+	// 30 bit nanoseconds and 34 bit seconds.
+	require.NoError(t, ts.UnmarshalBinary([]byte{0, 0, 0, 0x14, 0x5f, 0x5e, 0x10, 0x00}))
+	require.True(t, time.Unix(1600000000, 5).Equal(time.Time(ts)))
+
+	require.Error(t, ts.UnmarshalBinary([]byte{0}))
+}
+
+func TestReadMetricErrors(t *testing.T) {
+	_, _, err := ReadMetric([]byte{0xc0})
+	require.Error(t, err)
+
+	buf := msgp.AppendMapHeader(nil, 1)
+	buf = msgp.AppendString(buf, "fields")
+	buf = msgp.AppendMapHeader(buf, 1)
+	buf = msgp.AppendString(buf, "value")
+	buf = msgp.AppendBytes(buf, []byte("value"))
+	_, _, err = ReadMetric(buf)
+	require.EqualError(t, err, `reading "fields": field "value" has unsupported type bin`)
+
+	buf = msgp.AppendMapHeader(nil, 1)
+	buf = msgp.AppendString(buf, "fields")
+	buf = msgp.AppendMapHeader(buf, 0)
+	_, _, err = ReadMetric(buf)
+	require.EqualError(t, err, "metric without name")
+}
@@ -183,6 +183,11 @@ func (c *Collection) createLabels(metric telegraf.Metric) []LabelPair {
 func (c *Collection) Add(metric telegraf.Metric, now time.Time) {
 	labels := c.createLabels(metric)
 	for _, field := range metric.FieldList() {
+		if d, ok := field.Value.(*telegraf.Distribution); ok {
+			c.addDistribution(metric, field.Key, d, labels, now)
+			continue
+		}
+
 		metricName := MetricName(metric.Name(), field.Key, metric.Type())
 		metricName, ok := SanitizeMetricName(metricName)
 		if !ok {
@@ -333,6 +338,56 @@ func (c *Collection) Add(metric telegraf.Metric, now time.Time) {
 	}
 }
 
+// addDistribution adds a distribution field as a histogram or summary named
+// after the field.
+func (c *Collection) addDistribution(metric telegraf.Metric, key string, d *telegraf.Distribution, labels []LabelPair, now time.Time) {
+	metricName := MetricName(metric.Name(), key, telegraf.Untyped)
+	metricName, ok := SanitizeMetricName(metricName)
+	if !ok {
+		return
+	}
+
+	family := MetricFamily{
+		Name: metricName,
+		Type: telegraf.Histogram,
+	}
+	if d.IsSummary() {
+		family.Type = telegraf.Summary
+	}
+
+	entry, ok := c.Entries[family]
+	if !ok {
+		entry = Entry{
+			Family:  family,
+			Metrics: make(map[MetricKey]*Metric),
+		}
+		c.Entries[family] = entry
+	}
+
+	metricKey := MakeMetricKey(labels)
+	if m, ok := entry.Metrics[metricKey]; ok && metric.Time().Before(m.Time) {
+		return
+	}
+
+	m := &Metric{
+		Labels:  labels,
+		Time:    metric.Time(),
+		AddTime: now,
+	}
+	if family.Type == telegraf.Summary {
+		m.Summary = &Summary{Count: d.Count, Sum: d.Sum}
+		for _, q := range d.Quantiles {
+			m.Summary.Quantiles = append(m.Summary.Quantiles, Quantile{Quantile: q.Quantile, Value: q.Value})
+		}
+	} else {
+		m.Histogram = &Histogram{Count: d.Count, Sum: d.Sum}
+		for _, b := range d.Buckets {
+			m.Histogram.Buckets = append(m.Histogram.Buckets, Bucket{Bound: b.UpperBound, Count: b.Count})
+		}
+	}
+	entry.Metrics[metricKey] = m
+}
+
 func (c *Collection) Expire(now time.Time, age time.Duration) {
 	expireTime := now.Add(-age)
 	for _, entry := range c.Entries {
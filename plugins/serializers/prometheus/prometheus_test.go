@@ -135,6 +135,61 @@ http_request_duration_seconds_bucket{le="0.5"} 129389
 http_request_duration_seconds_bucket{le="+Inf"} 0
 http_request_duration_seconds_sum 0
 http_request_duration_seconds_count 0
+`),
+		},
+		{
+			name: "histogram distribution",
+			metric: testutil.MustMetric(
+				"http",
+				map[string]string{
+					"host": "example.org",
+				},
+				map[string]interface{}{
+					"request_duration_seconds": &telegraf.Distribution{
+						Count: 10,
+						Sum:   4.5,
+						Buckets: []telegraf.Bucket{
+							{UpperBound: 0.1, Count: 2},
+							{UpperBound: 0.5, Count: 7},
+						},
+					},
+				},
+				time.Unix(0, 0),
+			),
+			expected: []byte(`
+# HELP http_request_duration_seconds Telegraf collected metric
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{host="example.org",le="0.1"} 2
+http_request_duration_seconds_bucket{host="example.org",le="0.5"} 7
+http_request_duration_seconds_bucket{host="example.org",le="+Inf"} 10
+http_request_duration_seconds_sum{host="example.org"} 4.5
+http_request_duration_seconds_count{host="example.org"} 10
+`),
+		},
+		{
+			name: "summary distribution",
+			metric: testutil.MustMetric(
+				"http",
+				map[string]string{},
+				map[string]interface{}{
+					"request_duration_seconds": &telegraf.Distribution{
+						Count: 10,
+						Sum:   4.5,
+						Quantiles: []telegraf.Quantile{
+							{Quantile: 0.5, Value: 0.3},
+							{Quantile: 0.99, Value: 0.9},
+						},
+					},
+				},
+				time.Unix(0, 0),
+			),
+			expected: []byte(`
+# HELP http_request_duration_seconds Telegraf collected metric
+# TYPE http_request_duration_seconds summary
+http_request_duration_seconds{quantile="0.5"} 0.3
+http_request_duration_seconds{quantile="0.99"} 0.9
+http_request_duration_seconds_sum 4.5
+http_request_duration_seconds_count 10
 `),
 		},
 		{
@@ -7,6 +7,7 @@ import (
 	"github.com/golang/snappy"
 	"github.com/influxdata/telegraf/plugins/serializers/prometheus"
 	"hash/fnv"
+	"math"
 	"sort"
 	"strconv"
 	"strings"
@@ -60,6 +61,11 @@ func (s *Serializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
 		var metrickey MetricKey
 		var promts *prompb.TimeSeries
 		for _, field := range metric.FieldList() {
+			if d, ok := field.Value.(*telegraf.Distribution); ok {
+				addDistribution(entries, metric, field.Key, d, commonLabels)
+				continue
+			}
+
 			metricName := prometheus.MetricName(metric.Name(), field.Key, metric.Type())
 			metricName, ok := prometheus.SanitizeMetricName(metricName)
 			if !ok {
@@ -246,6 +252,51 @@ func (s *Serializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// addDistribution adds the series of a distribution field, the buckets with
+// the +Inf bucket or the quantiles, the sum and the count.
+func addDistribution(entries map[MetricKey]*prompb.TimeSeries, metric telegraf.Metric, key string, d *telegraf.Distribution, commonLabels []*prompb.Label) {
+	metricName := prometheus.MetricName(metric.Name(), key, telegraf.Untyped)
+	metricName, ok := prometheus.SanitizeMetricName(metricName)
+	if !ok {
+		return
+	}
+
+	var series []*prompb.TimeSeries
+	add := func(name string, labels []*prompb.Label, value float64) {
+		_, promts := getPromTS(name, labels, value, metric.Time())
+		series = append(series, promts)
+	}
+	withLabel := func(name, value string) []*prompb.Label {
+		labels := make([]*prompb.Label, len(commonLabels), len(commonLabels)+1)
+		copy(labels, commonLabels)
+		return append(labels, &prompb.Label{Name: name, Value: value})
+	}
+
+	if d.IsSummary() {
+		for _, q := range d.Quantiles {
+			add(metricName, withLabel("quantile", fmt.Sprint(q.Quantile)), q.Value)
+		}
+	} else {
+		for _, b := range d.Buckets {
+			if math.IsInf(b.UpperBound, 1) {
+				continue
+			}
+			add(fmt.Sprintf("%s_bucket", metricName), withLabel("le", fmt.Sprint(b.UpperBound)), float64(b.Count))
+		}
+		add(fmt.Sprintf("%s_bucket", metricName), withLabel("le", "+Inf"), float64(d.Count))
+	}
+	add(fmt.Sprintf("%s_sum", metricName), commonLabels, d.Sum)
+	add(fmt.Sprintf("%s_count", metricName), commonLabels, float64(d.Count))
+
+	for _, promts := range series {
+		metrickey := MakeMetricKey(promts.Labels)
+		if m, ok := entries[metrickey]; ok && promts.Samples[0].Timestamp < m.Samples[0].Timestamp {
+			continue
+		}
+		entries[metrickey] = promts
+	}
+}
+
 func hasLabel(name string, labels []*prompb.Label) bool {
 	for _, label := range labels {
 		if name == label.Name {
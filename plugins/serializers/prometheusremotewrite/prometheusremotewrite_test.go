@@ -129,6 +129,51 @@ http_request_duration_seconds_count 0
 http_request_duration_seconds_sum 0
 http_request_duration_seconds_bucket{le="+Inf"} 0
 http_request_duration_seconds_bucket{le="0.5"} 129389
+`),
+		},
+		{
+			name: "histogram distribution",
+			metric: testutil.MustMetric(
+				"http",
+				map[string]string{},
+				map[string]interface{}{
+					"request_duration_seconds": &telegraf.Distribution{
+						Count: 10,
+						Sum:   4.5,
+						Buckets: []telegraf.Bucket{
+							{UpperBound: 0.5, Count: 7},
+						},
+					},
+				},
+				time.Unix(0, 0),
+			),
+			expected: []byte(`
+http_request_duration_seconds_count 10
+http_request_duration_seconds_sum 4.5
+http_request_duration_seconds_bucket{le="+Inf"} 10
+http_request_duration_seconds_bucket{le="0.5"} 7
+`),
+		},
+		{
+			name: "summary distribution",
+			metric: testutil.MustMetric(
+				"http",
+				map[string]string{},
+				map[string]interface{}{
+					"request_duration_seconds": &telegraf.Distribution{
+						Count: 10,
+						Sum:   4.5,
+						Quantiles: []telegraf.Quantile{
+							{Quantile: 0.5, Value: 0.3},
+						},
+					},
+				},
+				time.Unix(0, 0),
+			),
+			expected: []byte(`
+http_request_duration_seconds_count 10
+http_request_duration_seconds_sum 4.5
+http_request_duration_seconds{quantile="0.5"} 0.3
 `),
 		},
 	}
@@ -6,10 +6,12 @@ import (
 	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
 	"github.com/influxdata/telegraf/plugins/serializers/carbon2"
 	"github.com/influxdata/telegraf/plugins/serializers/graphite"
 	"github.com/influxdata/telegraf/plugins/serializers/influx"
 	"github.com/influxdata/telegraf/plugins/serializers/json"
+	"github.com/influxdata/telegraf/plugins/serializers/msgpack"
 	"github.com/influxdata/telegraf/plugins/serializers/nowmetric"
 	"github.com/influxdata/telegraf/plugins/serializers/prometheus"
 	"github.com/influxdata/telegraf/plugins/serializers/splunkmetric"
@@ -129,10 +131,48 @@ func NewSerializer(config *Config) (Serializer, error) {
 		serializer, err = NewPrometheusSerializer(config)
 	case "prometheusremotewrite":
 		serializer, err = NewPrometheusRemoteWriteSerializer(config)
+	case "msgpack":
+		serializer, err = NewMsgpackSerializer()
 	default:
 		err = fmt.Errorf("Invalid data format: %s", config.DataFormat)
 	}
-	return serializer, err
+	if err != nil {
+		return nil, err
+	}
+
+	switch config.DataFormat {
+	case "influx", "prometheus", "prometheusremotewrite":
+		// Distributions are encoded natively.
+	default:
+		serializer = &flatteningSerializer{serializer: serializer}
+	}
+	return serializer, nil
+}
+
+// flatteningSerializer flattens the distribution fields for serializers
+// without native support.
+type flatteningSerializer struct {
+	serializer Serializer
+}
+
+// Unwrap returns the serializer of the data format, for outputs that depend
+// on the format.
+func Unwrap(serializer Serializer) Serializer {
+	if s, ok := serializer.(*flatteningSerializer); ok {
+		return s.serializer
+	}
+	return serializer
+}
+
+func (s *flatteningSerializer) Serialize(m telegraf.Metric) ([]byte, error) {
+	if !metric.HasDistribution(m) {
+		return s.serializer.Serialize(m)
+	}
+	return s.serializer.SerializeBatch(metric.FlattenDistributions([]telegraf.Metric{m}))
+}
+
+func (s *flatteningSerializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
+	return s.serializer.SerializeBatch(metric.FlattenDistributions(metrics))
 }
 
 func NewPrometheusRemoteWriteSerializer(config *Config) (Serializer, error) {
@@ -179,6 +219,10 @@ func NewWavefrontSerializer(prefix string, useStrict bool, sourceOverride []stri
 	return wavefront.NewSerializer(prefix, useStrict, sourceOverride)
 }
 
+func NewMsgpackSerializer() (Serializer, error) {
+	return msgpack.NewSerializer(), nil
+}
+
 func NewJsonSerializer(timestampUnits time.Duration) (Serializer, error) {
 	return json.NewSerializer(timestampUnits)
 }
@@ -0,0 +1,16 @@
+package telegraf
+
+// SecretStore is a secret-store plugin interface for looking up secrets such
+// as passwords and tokens.  Plugin settings reference a secret with
+// "@{<store id>:<key>}", the reference is replaced by the secret when the
+// plugin is built.
+type SecretStore interface {
+	PluginDescriber
+
+	// Get returns the secret stored under the key.
+	Get(key string) (string, error)
+	// Set stores the secret under the key, replacing any existing secret.
+	Set(key, value string) error
+	// List returns the keys of all stored secrets.
+	List() ([]string, error)
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -197,20 +198,36 @@ func (a *Accumulator) AddMetric(m telegraf.Metric) {
 	a.addFields(m.Name(), m.Tags(), m.Fields(), m.Type(), m.Time())
 }
 
+// WithTracking returns the accumulator, the tracked metrics are reported
+// delivered as soon as they are added.
 func (a *Accumulator) WithTracking(maxTracked int) telegraf.TrackingAccumulator {
+	a.Lock()
+	a.delivered = make(chan telegraf.DeliveryInfo, maxTracked)
+	a.Unlock()
 	return a
 }
 
 func (a *Accumulator) AddTrackingMetric(m telegraf.Metric) telegraf.TrackingID {
 	a.AddMetric(m)
-	return newTrackingID()
+	return a.deliver()
 }
 
 func (a *Accumulator) AddTrackingMetricGroup(group []telegraf.Metric) telegraf.TrackingID {
 	for _, m := range group {
 		a.AddMetric(m)
 	}
-	return newTrackingID()
+	return a.deliver()
+}
+
+func (a *Accumulator) deliver() telegraf.TrackingID {
+	id := newTrackingID()
+	a.Lock()
+	defer a.Unlock()
+	select {
+	case a.delivered <- &deliveryInfo{id: id}:
+	default:
+	}
+	return id
 }
 
 func (a *Accumulator) Delivered() <-chan telegraf.DeliveryInfo {
@@ -222,6 +239,85 @@ func (a *Accumulator) Delivered() <-chan telegraf.DeliveryInfo {
 	return a.delivered
 }
 
+type deliveryInfo struct {
+	id telegraf.TrackingID
+}
+
+func (d *deliveryInfo) ID() telegraf.TrackingID {
+	return d.id
+}
+
+func (d *deliveryInfo) Delivered() bool {
+	return true
+}
+
+// TrackingAccumulator tracks the delivery of metrics like the agent does, the
+// test accepts or rejects the tracked metrics returned by NextTracked.
+type TrackingAccumulator struct {
+	Accumulator
+
+	trackedLock sync.Mutex
+	tracked     []telegraf.Metric
+	deliveries  chan telegraf.DeliveryInfo
+}
+
+func (a *TrackingAccumulator) WithTracking(maxTracked int) telegraf.TrackingAccumulator {
+	a.deliveryChan(maxTracked)
+	return a
+}
+
+func (a *TrackingAccumulator) AddTrackingMetric(m telegraf.Metric) telegraf.TrackingID {
+	return a.AddTrackingMetricGroup([]telegraf.Metric{m})
+}
+
+func (a *TrackingAccumulator) AddTrackingMetricGroup(group []telegraf.Metric) telegraf.TrackingID {
+	deliveries := a.deliveryChan(1)
+	group, id := metric.WithGroupTracking(group, func(info telegraf.DeliveryInfo) {
+		deliveries <- info
+	})
+	for _, m := range group {
+		a.AddMetric(m)
+	}
+
+	a.trackedLock.Lock()
+	a.tracked = append(a.tracked, group...)
+	a.trackedLock.Unlock()
+	return id
+}
+
+func (a *TrackingAccumulator) Delivered() <-chan telegraf.DeliveryInfo {
+	return a.deliveryChan(1)
+}
+
+func (a *TrackingAccumulator) deliveryChan(size int) chan telegraf.DeliveryInfo {
+	a.trackedLock.Lock()
+	defer a.trackedLock.Unlock()
+	if a.deliveries == nil {
+		a.deliveries = make(chan telegraf.DeliveryInfo, size)
+	}
+	return a.deliveries
+}
+
+// NextTracked waits for the next tracked metric, the test has to accept or
+// reject it.
+func (a *TrackingAccumulator) NextTracked(t *testing.T) telegraf.Metric {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		a.trackedLock.Lock()
+		if len(a.tracked) > 0 {
+			m := a.tracked[0]
+			a.tracked = a.tracked[1:]
+			a.trackedLock.Unlock()
+			return m
+		}
+		a.trackedLock.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("no tracked metric was added")
+	return nil
+}
+
 // AddError appends the given error to Accumulator.Errors.
 func (a *Accumulator) AddError(err error) {
 	if err == nil {
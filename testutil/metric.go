@@ -55,7 +55,7 @@ func lessFunc(lhs, rhs *metricDiff) bool {
 			return lhs.Fields[i].Key < rhs.Fields[i].Key
 		}
 
-		if lhs.Fields[i].Value != rhs.Fields[i].Value {
+		if !reflect.DeepEqual(lhs.Fields[i].Value, rhs.Fields[i].Value) {
 			ltype := reflect.TypeOf(lhs.Fields[i].Value)
 			rtype := reflect.TypeOf(lhs.Fields[i].Value)
 
@@ -74,6 +74,12 @@ func lessFunc(lhs, rhs *metricDiff) bool {
 				return v < lhs.Fields[i].Value.(string)
 			case bool:
 				return !v
+			case telegraf.Distribution:
+				rhs := rhs.Fields[i].Value.(telegraf.Distribution)
+				if v.Count != rhs.Count {
+					return v.Count < rhs.Count
+				}
+				return v.Sum < rhs.Sum
 			default:
 				panic("unknown type")
 			}
@@ -107,6 +113,10 @@ func newMetricDiff(metric telegraf.Metric) *metricDiff {
 	})
 
 	for _, field := range metric.FieldList() {
+		// Distributions are compared by value.
+		if d, ok := field.Value.(*telegraf.Distribution); ok {
+			field = &telegraf.Field{Key: field.Key, Value: *d}
+		}
 		m.Fields = append(m.Fields, field)
 	}
 	sort.Slice(m.Fields, func(i, j int) bool {
@@ -166,6 +176,14 @@ func RequireMetricEqual(t *testing.T, expected, actual telegraf.Metric, opts ...
 func RequireMetricsEqual(t *testing.T, expected, actual []telegraf.Metric, opts ...cmp.Option) {
 	t.Helper()
 
+	if diff := MetricsDiff(expected, actual, opts...); diff != "" {
+		t.Fatalf("[]telegraf.Metric\n--- expected\n+++ actual\n%s", diff)
+	}
+}
+
+// MetricsDiff returns the differences between the arrays of metrics, or an
+// empty string if they are equal.
+func MetricsDiff(expected, actual []telegraf.Metric, opts ...cmp.Option) string {
 	lhs := make([]*metricDiff, 0, len(expected))
 	for _, m := range expected {
 		lhs = append(lhs, newMetricDiff(m))
@@ -176,9 +194,7 @@ func RequireMetricsEqual(t *testing.T, expected, actual []telegraf.Metric, opts
 	}
 
 	opts = append(opts, cmpopts.EquateNaNs())
-	if diff := cmp.Diff(lhs, rhs, opts...); diff != "" {
-		t.Fatalf("[]telegraf.Metric\n--- expected\n+++ actual\n%s", diff)
-	}
+	return cmp.Diff(lhs, rhs, opts...)
 }
 
 // Metric creates a new metric or panics on error.
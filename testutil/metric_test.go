@@ -97,6 +97,46 @@ func TestRequireMetricsEqual(t *testing.T) {
 			},
 			opts: []cmp.Option{SortMetrics()},
 		},
+		{
+			name: "distributions are compared by value",
+			got: []telegraf.Metric{
+				MustMetric(
+					"http",
+					map[string]string{},
+					map[string]interface{}{
+						"latency": &telegraf.Distribution{Count: 3, Sum: 1.5},
+					},
+					time.Unix(0, 0),
+				),
+				MustMetric(
+					"http",
+					map[string]string{},
+					map[string]interface{}{
+						"latency": &telegraf.Distribution{Count: 1, Sum: 0.5},
+					},
+					time.Unix(0, 0),
+				),
+			},
+			want: []telegraf.Metric{
+				MustMetric(
+					"http",
+					map[string]string{},
+					map[string]interface{}{
+						"latency": &telegraf.Distribution{Count: 1, Sum: 0.5},
+					},
+					time.Unix(0, 0),
+				),
+				MustMetric(
+					"http",
+					map[string]string{},
+					map[string]interface{}{
+						"latency": &telegraf.Distribution{Count: 3, Sum: 1.5},
+					},
+					time.Unix(0, 0),
+				),
+			},
+			opts: []cmp.Option{SortMetrics()},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {